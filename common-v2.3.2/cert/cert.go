@@ -8,6 +8,7 @@ SPDX-License-Identifier: Apache-2.0
 package cert
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -600,6 +601,8 @@ func getSignatureAlgorithm(privKey crypto.PrivateKey) (x509.SignatureAlgorithm,
 		signatureAlgorithm = x509.SHA256WithRSA
 	case *sm2.PublicKey:
 		signatureAlgorithm = x509.SignatureAlgorithm(bcx509.SM3WithSM2)
+	case ed25519.PublicKey:
+		signatureAlgorithm = x509.SignatureAlgorithm(bcx509.PureEd25519)
 	}
 
 	return signatureAlgorithm, nil
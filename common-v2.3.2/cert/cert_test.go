@@ -16,6 +16,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"zhanghefan123/security/common/crypto"
+	bcx509 "zhanghefan123/security/common/crypto/x509"
 )
 
 const (
@@ -51,7 +52,7 @@ func TestCreatePrivKeyWithDiffKeyTypes(t *testing.T) {
 		{8, nil},
 		{9, nil},
 		{10, nil},
-		{11, errors.New("generate key pair [ECC_Ed25519] failed, unsupport signature algorithm")},
+		{11, nil},
 	}
 
 	for i, tt := range tests {
@@ -132,6 +133,59 @@ func TestCreateCACertificate(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestIssueEd25519Certificate(t *testing.T) {
+	caKey, err := CreatePrivKey(crypto.ECC_Ed25519, testFilePath, "ed25519_ca.key", true)
+	require.NoError(t, err)
+	err = CreateCACertificate(&CACertificateConfig{
+		PrivKey:      caKey,
+		HashType:     crypto.HASH_TYPE_SHA256,
+		CertPath:     testFilePath,
+		CertFileName: "ed25519_ca.crt",
+	})
+	require.NoError(t, err)
+
+	caCert, err := ParseCertificate(filepath.Join(testFilePath, "ed25519_ca.crt"))
+	require.NoError(t, err)
+	require.True(t, caCert.IsCA)
+
+	subjectKey, err := CreatePrivKey(crypto.ECC_Ed25519, "", "", false)
+	require.NoError(t, err)
+	err = CreateCSR(&CSRConfig{
+		PrivKey:     subjectKey,
+		CsrPath:     testFilePath,
+		CsrFileName: "ed25519.csr",
+		CommonName:  cn,
+	})
+	require.NoError(t, err)
+
+	err = IssueCertificate(&IssueCertificateConfig{
+		HashType:              crypto.HASH_TYPE_SHA256,
+		IssuerPrivKeyFilePath: filepath.Join(testFilePath, "ed25519_ca.key"),
+		IssuerCertFilePath:    filepath.Join(testFilePath, "ed25519_ca.crt"),
+		CsrFilePath:           filepath.Join(testFilePath, "ed25519.csr"),
+		CertPath:              testFilePath,
+		CertFileName:          "ed25519.crt",
+		Sans:                  sans,
+	})
+	require.NoError(t, err)
+
+	cert, err := ParseCertificate(filepath.Join(testFilePath, "ed25519.crt"))
+	require.NoError(t, err)
+	require.Equal(t, cn, cert.Subject.CommonName)
+	require.Equal(t, subjectKey.PublicKey().ToStandardKey(), cert.PublicKey)
+	require.Equal(t, caCert.SubjectKeyId, cert.AuthorityKeyId)
+
+	bcCACert, err := bcx509.ParseCertificate(caCert.Raw)
+	require.NoError(t, err)
+	bcCert, err := bcx509.ParseCertificate(cert.Raw)
+	require.NoError(t, err)
+	require.NoError(t, bcCert.CheckSignatureFrom(bcCACert))
+
+	for _, name := range []string{"ed25519_ca.key", "ed25519_ca.crt", "ed25519.csr", "ed25519.crt"} {
+		require.NoError(t, os.Remove(filepath.Join(testFilePath, name)))
+	}
+}
+
 func TestIssueCertificate(t *testing.T) {
 	//issueCertificate(t, crypto.SM2)
 	//issueCertificate(t, crypto.RSA512)
@@ -14,6 +14,7 @@ import "C"
 import (
 	crypto2 "crypto"
 	ecdsa2 "crypto/ecdsa"
+	ed255192 "crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	rsa2 "crypto/rsa"
@@ -37,6 +38,7 @@ import (
 
 	"zhanghefan123/security/common/crypto"
 	"zhanghefan123/security/common/crypto/asym/ecdsa"
+	"zhanghefan123/security/common/crypto/asym/ed25519"
 	"zhanghefan123/security/common/crypto/asym/rsa"
 	"zhanghefan123/security/common/crypto/asym/sm2"
 )
@@ -53,7 +55,7 @@ func GenerateKeyPair(keyType crypto.KeyType) (crypto.PrivateKey, error) {
 	case crypto.RSA512, crypto.RSA1024, crypto.RSA2048, crypto.RSA3072:
 		return rsa.New(keyType)
 	case crypto.ECC_Ed25519:
-		return nil, fmt.Errorf("unsupport signature algorithm")
+		return ed25519.New(keyType)
 	default:
 		return nil, fmt.Errorf("wrong signature algorithm type")
 	}
@@ -125,6 +127,10 @@ func ParsePrivateKey(der []byte) (crypto2.PrivateKey, error) {
 		return key, nil
 	}
 
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+
 	// Serialization for bitcoin signature key: encode ECC numbers with hex
 	Secp256k1Key, _ := btcec.PrivKeyFromBytes(btcec.S256(), der)
 	key := Secp256k1Key.ToECDSA()
@@ -181,6 +187,8 @@ func PrivateKeyFromDER(der []byte) (crypto.PrivateKey, error) {
 			return &sm2.PrivateKey{K: k}, nil
 		case *rsa2.PrivateKey:
 			return &rsa.PrivateKey{K: k}, nil
+		case ed255192.PrivateKey:
+			return &ed25519.PrivateKey{K: k}, nil
 		case crypto.PrivateKey:
 			return k, nil
 		default:
@@ -210,6 +218,8 @@ func PublicKeyFromDER(der []byte) (crypto.PublicKey, error) {
 			return &ecdsa.PublicKey{K: key}, nil
 		case *tjsm2.PublicKey:
 			return &sm2.PublicKey{K: key}, nil
+		case ed255192.PublicKey:
+			return &ed25519.PublicKey{K: key}, nil
 		case crypto.PublicKey:
 			return key, nil
 		default:
@@ -325,6 +335,9 @@ func Sign(sk interface{}, data []byte) ([]byte, error) {
 			return nil, err
 		}
 		return signedData, nil
+
+	case ed255192.PrivateKey:
+		return ed255192.Sign(key, data), nil
 	default:
 		return nil, fmt.Errorf("fail to sign: unsupported algorithm")
 	}
@@ -462,6 +475,10 @@ func verifyDataSignWithPubKey(key crypto2.PublicKey, data, sig []byte) error {
 		if err != nil {
 			return err
 		}
+	case ed255192.PublicKey:
+		if !ed255192.Verify(key, data, sig) {
+			return fmt.Errorf("invalid ed25519 signature")
+		}
 	default:
 		return fmt.Errorf("fail to verify: unsupported algorithm")
 	}
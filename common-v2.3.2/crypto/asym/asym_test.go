@@ -54,6 +54,7 @@ func TestSignAndVerifyPass(t *testing.T) {
 		testSignAndVerify(t, crypto.RSA1024)
 		testSignAndVerify(t, crypto.RSA512)
 		testSignAndVerify(t, crypto.ECC_Secp256k1)
+		testSignAndVerify(t, crypto.ECC_Ed25519)
 	}
 }
 
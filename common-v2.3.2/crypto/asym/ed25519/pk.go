@@ -0,0 +1,79 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ed25519
+
+import (
+	"bytes"
+	crypto2 "crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"zhanghefan123/security/common/crypto"
+)
+
+type PublicKey struct {
+	K ed25519.PublicKey
+}
+
+func (pk *PublicKey) Bytes() ([]byte, error) {
+	if len(pk.K) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key is nil")
+	}
+
+	return x509.MarshalPKIXPublicKey(pk.K)
+}
+
+func (pk *PublicKey) Verify(msg []byte, sig []byte) (bool, error) {
+	if sig == nil {
+		return false, fmt.Errorf("nil signature")
+	}
+
+	if len(pk.K) != ed25519.PublicKeySize {
+		return false, fmt.Errorf("public key is nil")
+	}
+
+	if !ed25519.Verify(pk.K, msg, sig) {
+		return false, fmt.Errorf("invalid ed25519 signature")
+	}
+
+	return true, nil
+}
+
+// VerifyWithOpts ignores opts.Hash, see PrivateKey.SignWithOpts
+func (pk *PublicKey) VerifyWithOpts(msg []byte, sig []byte, opts *crypto.SignOpts) (bool, error) {
+	return pk.Verify(msg, sig)
+}
+
+func (pk *PublicKey) Type() crypto.KeyType {
+	return crypto.ECC_Ed25519
+}
+
+func (pk *PublicKey) String() (string, error) {
+	pkDER, err := pk.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	block := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: pkDER,
+	}
+
+	buf := new(bytes.Buffer)
+	if err = pem.Encode(buf, block); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func (pk *PublicKey) ToStandardKey() crypto2.PublicKey {
+	return pk.K
+}
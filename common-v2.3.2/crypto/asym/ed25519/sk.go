@@ -0,0 +1,90 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ed25519
+
+import (
+	"bytes"
+	crypto2 "crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"zhanghefan123/security/common/crypto"
+)
+
+type PrivateKey struct {
+	K ed25519.PrivateKey
+}
+
+func (sk *PrivateKey) Bytes() ([]byte, error) {
+	if len(sk.K) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("private key is nil")
+	}
+
+	return x509.MarshalPKCS8PrivateKey(sk.K)
+}
+
+func (sk *PrivateKey) PublicKey() crypto.PublicKey {
+	return &PublicKey{K: sk.K.Public().(ed25519.PublicKey)}
+}
+
+// Sign signs the whole message, Ed25519 hashes it as part of the algorithm
+func (sk *PrivateKey) Sign(msg []byte) ([]byte, error) {
+	if len(sk.K) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("private key is nil")
+	}
+
+	return ed25519.Sign(sk.K, msg), nil
+}
+
+// SignWithOpts ignores opts.Hash, a pre-hashed message is not a valid Ed25519 input
+func (sk *PrivateKey) SignWithOpts(msg []byte, opts *crypto.SignOpts) ([]byte, error) {
+	return sk.Sign(msg)
+}
+
+func (sk *PrivateKey) Type() crypto.KeyType {
+	return crypto.ECC_Ed25519
+}
+
+func (sk *PrivateKey) String() (string, error) {
+	skDER, err := sk.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	block := &pem.Block{
+		Type:  "PRIVATE KEY",
+		Bytes: skDER,
+	}
+
+	buf := new(bytes.Buffer)
+	if err = pem.Encode(buf, block); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func (sk *PrivateKey) ToStandardKey() crypto2.PrivateKey {
+	return sk.K
+}
+
+func New(keyType crypto.KeyType) (crypto.PrivateKey, error) {
+	if keyType != crypto.ECC_Ed25519 {
+		return nil, fmt.Errorf("wrong curve option")
+	}
+
+	_, pri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PrivateKey{K: pri}, nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ed25519
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"zhanghefan123/security/common/crypto"
+)
+
+var msg = "js"
+
+func TestEd25519(t *testing.T) {
+	priv, err := New(crypto.ECC_Ed25519)
+	require.Nil(t, err)
+	require.Equal(t, crypto.ECC_Ed25519, priv.Type())
+
+	buf, err := priv.String()
+	require.Nil(t, err)
+	fmt.Println(buf)
+
+	sign, err := priv.SignWithOpts([]byte(msg), &crypto.SignOpts{Hash: crypto.HASH_TYPE_SHA256})
+	require.Nil(t, err)
+	require.NotEqual(t, nil, sign)
+
+	pub := priv.PublicKey()
+	buf, err = pub.String()
+	require.Nil(t, err)
+	fmt.Println(buf)
+
+	b, err := pub.Verify([]byte(msg), sign)
+	require.Nil(t, err)
+	require.True(t, b)
+
+	b, err = pub.Verify([]byte("other"), sign)
+	require.NotNil(t, err)
+	require.False(t, b)
+}
+
+func TestWrongKeyType(t *testing.T) {
+	_, err := New(crypto.ECC_NISTP256)
+	require.NotNil(t, err)
+}
@@ -14,6 +14,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	_ "crypto/sha1"
@@ -40,6 +41,7 @@ import (
 	bccrypto "zhanghefan123/security/common/crypto"
 	"zhanghefan123/security/common/crypto/asym"
 	bcecdsa "zhanghefan123/security/common/crypto/asym/ecdsa"
+	bced25519 "zhanghefan123/security/common/crypto/asym/ed25519"
 	bcrsa "zhanghefan123/security/common/crypto/asym/rsa"
 	bcsm2 "zhanghefan123/security/common/crypto/asym/sm2"
 )
@@ -116,6 +118,7 @@ const (
 	SHA512WithRSAPSS
 	SHA256WithSM2
 	SM3WithSM2
+	PureEd25519
 )
 
 func (algo SignatureAlgorithm) String() string {
@@ -135,13 +138,15 @@ const (
 	DSA
 	ECDSA
 	SM2
+	Ed25519
 )
 
 var publicKeyAlgoName = [...]string{
-	RSA:   "RSA",
-	DSA:   "DSA",
-	ECDSA: "ECDSA",
-	SM2:   "SM2",
+	RSA:     "RSA",
+	DSA:     "DSA",
+	ECDSA:   "ECDSA",
+	SM2:     "SM2",
+	Ed25519: "Ed25519",
 }
 
 func (algo PublicKeyAlgorithm) String() string {
@@ -200,6 +205,11 @@ func (algo PublicKeyAlgorithm) String() string {
 //
 // ecdsa-with-SHA512 OBJECT IDENTIFIER ::= { iso(1) member-body(2)
 //    us(840) ansi-X9-62(10045) signatures(4) ecdsa-with-SHA2(3) 4 }
+//
+//
+// RFC 8410 3 Curve25519 and Curve448 Algorithm Identifiers
+//
+// id-Ed25519   OBJECT IDENTIFIER ::= { 1 3 101 112 }
 
 var (
 	oidSignatureMD2WithRSA      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 2}
@@ -215,6 +225,7 @@ var (
 	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
 	oidSignatureECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
 	oidSignatureECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidSignatureEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
 
 	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
 	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
@@ -258,6 +269,7 @@ var signatureAlgorithmDetails = []struct {
 	{ECDSAWithSHA512, "ECDSA-SHA512", oidSignatureECDSAWithSHA512, ECDSA, crypto.SHA512},
 	{SHA256WithSM2, "SM2-SHA256", oidSignatureSHA256WithSM2, ECDSA, crypto.SHA256},
 	{SM3WithSM2, "SM2-SM3", oidSignatureSM3WithSM2, ECDSA, crypto.Hash(bccrypto.HASH_TYPE_SM3)},
+	{PureEd25519, "Ed25519", oidSignatureEd25519, Ed25519, crypto.Hash(0) /* no pre-hashing */},
 }
 
 // pssParameters reflects the parameters in an AlgorithmIdentifier that
@@ -379,12 +391,17 @@ func getSignatureAlgorithmFromAI(ai pkix.AlgorithmIdentifier) SignatureAlgorithm
 //
 // id-ecPublicKey OBJECT IDENTIFIER ::= {
 //       iso(1) member-body(2) us(840) ansi-X9-62(10045) keyType(2) 1 }
+//
+// RFC 8410, Section 3
+//
+// id-Ed25519   OBJECT IDENTIFIER ::= { 1 3 101 112 }
 var (
 	oidPublicKeyRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
 	oidPublicKeyDSA   = asn1.ObjectIdentifier{1, 2, 840, 10040, 4, 1}
 	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
 
-	oidPublicKeySM2 = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301, 1}
+	oidPublicKeySM2     = asn1.ObjectIdentifier{1, 2, 156, 10197, 1, 301, 1}
+	oidPublicKeyEd25519 = oidSignatureEd25519
 )
 
 func getPublicKeyAlgorithmFromOID(oid asn1.ObjectIdentifier) PublicKeyAlgorithm {
@@ -397,6 +414,8 @@ func getPublicKeyAlgorithmFromOID(oid asn1.ObjectIdentifier) PublicKeyAlgorithm
 		return ECDSA
 	case oid.Equal(oidPublicKeySM2):
 		return SM2
+	case oid.Equal(oidPublicKeyEd25519):
+		return Ed25519
 	}
 	return UnknownPublicKeyAlgorithm
 }
@@ -1085,6 +1104,18 @@ func parsePublicKey(algo PublicKeyAlgorithm, keyData *publicKeyInfo) (bccrypto.P
 			}
 		}
 		return &bcsm2.PublicKey{K: pk}, nil
+	case Ed25519:
+		// RFC 8410, Section 3
+		// > For all of the OIDs, the parameters MUST be absent.
+		if len(keyData.Algorithm.Parameters.FullBytes) != 0 {
+			return nil, errors.New("x509: Ed25519 key encoded with illegal parameters")
+		}
+		if len(asn1Data) != ed25519.PublicKeySize {
+			return nil, errors.New("x509: wrong Ed25519 public key size")
+		}
+		pub := make([]byte, ed25519.PublicKeySize)
+		copy(pub, asn1Data)
+		return &bced25519.PublicKey{K: pub}, nil
 
 	default:
 		return nil, nil
@@ -1956,8 +1987,12 @@ func signingParamsForPublicKey(pub interface{},
 		hashFunc = crypto.Hash(bccrypto.HASH_TYPE_SM3)
 		sigAlgo.Algorithm = oidSignatureSM3WithSM2
 
+	case ed25519.PublicKey:
+		pubType = Ed25519
+		sigAlgo.Algorithm = oidSignatureEd25519
+
 	default:
-		err = errors.New("x509: only RSA and ECDSA keys supported")
+		err = errors.New("x509: only RSA, ECDSA and Ed25519 keys supported")
 	}
 
 	if err != nil {
@@ -1976,7 +2011,7 @@ func signingParamsForPublicKey(pub interface{},
 				return
 			}
 			sigAlgo.Algorithm, hashFunc = details.oid, details.hash
-			if hashFunc == 0 {
+			if hashFunc == 0 && pubType != Ed25519 {
 				err = errors.New("x509: cannot sign with hash function requested")
 				return
 			}
@@ -2038,8 +2073,11 @@ func marshalPublicKey(pub interface{}) (publicKeyBytes []byte, publicKeyAlgorith
 			return nil, pkix.AlgorithmIdentifier{}, errors.New("asn1 marshal sm2 oid failed")
 		}
 		publicKeyAlgorithm.Parameters.FullBytes = paramBytes
+	case ed25519.PublicKey:
+		publicKeyBytes = pub
+		publicKeyAlgorithm.Algorithm = oidPublicKeyEd25519
 	default:
-		return nil, pkix.AlgorithmIdentifier{}, errors.New("x509: only RSA and ECDSA public keys supported")
+		return nil, pkix.AlgorithmIdentifier{}, errors.New("x509: only RSA, ECDSA and Ed25519 public keys supported")
 
 	}
 
@@ -2123,6 +2161,9 @@ func CreateCertificate(rand io.Reader, template,
 	if hashFunc == crypto.Hash(bccrypto.HASH_TYPE_SM3) {
 		//当SM3时取ZA方式签名,所以不在这里做hash
 		digest = tbsCertContents
+	} else if hashFunc == 0 {
+		// Ed25519 signs the whole message
+		digest = tbsCertContents
 	} else {
 		if !hashFunc.Available() {
 			return nil, x509.ErrUnsupportedAlgorithm
@@ -2376,6 +2417,9 @@ func CreateCertificateRequest(rand io.Reader,
 	if hashFunc == crypto.Hash(bccrypto.HASH_TYPE_SM3) {
 		//当SM3时取ZA方式签名,所以不在这里做hash
 		digest = tbsCSRContents
+	} else if hashFunc == 0 {
+		// Ed25519 signs the whole message
+		digest = tbsCSRContents
 	} else {
 		if !hashFunc.Available() {
 			return nil, x509.ErrUnsupportedAlgorithm
@@ -2742,6 +2786,9 @@ func CreateCRL(rand io.Reader, cert *x509.Certificate, priv interface{},
 	if hashFunc == crypto.Hash(bccrypto.HASH_TYPE_SM3) {
 		//当SM3时取ZA方式签名,所以不在这里做hash
 		digest = tbsCertListContents
+	} else if hashFunc == 0 {
+		// Ed25519 signs the whole message
+		digest = tbsCertListContents
 	} else {
 		if !hashFunc.Available() {
 			return nil, x509.ErrUnsupportedAlgorithm
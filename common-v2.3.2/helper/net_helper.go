@@ -10,6 +10,7 @@ package helper
 import (
 	goCrypto "crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"encoding/pem"
 	"errors"
@@ -112,6 +113,8 @@ func ParseGoPublicKeyToPubKey(publicKey goCrypto.PublicKey) (libp2pcrypto.PubKey
 		return libp2pcrypto.NewSM2PublicKey(p), nil
 	case *rsa.PublicKey:
 		return libp2pcrypto.NewRsaPublicKey(*p), nil
+	case ed25519.PublicKey:
+		return libp2pcrypto.UnmarshalEd25519PublicKey(p)
 	default:
 		return nil, errors.New("unsupported public key type")
 	}
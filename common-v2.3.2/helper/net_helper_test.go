@@ -8,10 +8,13 @@ SPDX-License-Identifier: Apache-2.0
 package helper
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/stretchr/testify/require"
+	"zhanghefan123/security/common/crypto"
+	"zhanghefan123/security/common/crypto/asym"
 )
 
 func TestGetLibp2pPeerIdFromCert(t *testing.T) {
@@ -65,3 +68,12 @@ func TestSecp256k1Curve(t *testing.T) {
 	require.NotNil(t, libp2pPk)
 
 }
+
+func TestEd25519PeerId(t *testing.T) {
+	privk, err := asym.GenerateKeyPair(crypto.ECC_Ed25519)
+	require.Nil(t, err)
+	pid, err := CreateLibp2pPeerIdWithPrivateKey(privk)
+	require.Nil(t, err)
+	// ed25519 public keys are small enough to be inlined into the peer id
+	require.True(t, strings.HasPrefix(pid, "12D3KooW"))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/kr/pretty"
 	"github.com/spf13/cobra"
+	"os"
 	"zhanghefan123/security/config/main/generator"
 	"zhanghefan123/security/config/main/vars"
 )
@@ -16,11 +17,17 @@ func CreateGenerateCmd() *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			fmt.Println("generate configuration")
 			pretty.Println(*(vars.ConfigParamsInstance))
-			generator.Generate(vars.ConfigParamsInstance)
+			if err := generator.Generate(vars.ConfigParamsInstance); err != nil {
+				fmt.Println("generate configuration failed:", err)
+				os.Exit(1)
+			}
 		},
 	}
 	AttachFlags(generateCmd, []string{vars.FlagNameOfNodeNumber, vars.FlagNameOfStartP2PPort,
 		vars.FlagNameOfStartRPCPort, vars.FlagNameOfChooseConsensusType,
-		vars.FlagNameOfGeneratedDestination})
+		vars.FlagNameOfGeneratedDestination, vars.FlagNameOfHost})
+	AttachOptionalFlags(generateCmd, []string{vars.FlagNameOfKeyType, vars.FlagNameOfEnableCA,
+		vars.FlagNameOfTopologyFile, vars.FlagNameOfEmit, vars.FlagNameOfDockerImage, vars.FlagNameOfNetemDelay,
+		vars.FlagNameOfBinaryPath})
 	return generateCmd
 }
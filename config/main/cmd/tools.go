@@ -19,10 +19,10 @@ func InitFlagSet() *pflag.FlagSet {
 		vars.FlagNameShortHandOfStartP2PPort,
 		vars.ConfigParamsInstance.StartP2PPort,
 		"specify the start p2p port")
-	flags.IntVarP(&vars.ConfigParamsInstance.StartRpcPort,
+	flags.IntVarP(&vars.ConfigParamsInstance.StartRPCPort,
 		vars.FlagNameOfStartRPCPort,
 		vars.FlagNameShortHandOfStartRPCPort,
-		vars.ConfigParamsInstance.StartRpcPort,
+		vars.ConfigParamsInstance.StartRPCPort,
 		"Specify the start rpc port")
 	flags.IntVarP(&vars.ConfigParamsInstance.ConsensusType,
 		vars.FlagNameOfChooseConsensusType,
@@ -34,6 +34,41 @@ func InitFlagSet() *pflag.FlagSet {
 		vars.FlagNameShortHandOfGeneratedDestination,
 		vars.ConfigParamsInstance.GeneratedDestination,
 		"specify the generated destination")
+	flags.StringVarP(&vars.ConfigParamsInstance.Host,
+		vars.FlagNameOfHost,
+		vars.FlagNameShortHandOfHost,
+		vars.ConfigParamsInstance.Host,
+		"specify the ip address of the nodes")
+	flags.StringVarP(&vars.ConfigParamsInstance.KeyType,
+		vars.FlagNameOfKeyType,
+		vars.FlagNameShortHandOfKeyType,
+		vars.ConfigParamsInstance.KeyType,
+		"specify the key type of the nodes, ECC_NISTP256, SM2 or ECC_Ed25519")
+	flags.BoolVar(&vars.ConfigParamsInstance.EnableCA,
+		vars.FlagNameOfEnableCA,
+		vars.ConfigParamsInstance.EnableCA,
+		"create a ca and issue sign and tls certificates for the nodes")
+	flags.StringVarP(&vars.ConfigParamsInstance.TopologyFile,
+		vars.FlagNameOfTopologyFile,
+		vars.FlagNameShortHandOfTopologyFile,
+		vars.ConfigParamsInstance.TopologyFile,
+		"specify the topology file, the node number is ignored when it is set")
+	flags.StringSliceVar(&vars.ConfigParamsInstance.Emit,
+		vars.FlagNameOfEmit,
+		vars.ConfigParamsInstance.Emit,
+		"emit deployment files, docker-compose or systemd")
+	flags.StringVar(&vars.ConfigParamsInstance.DockerImage,
+		vars.FlagNameOfDockerImage,
+		vars.ConfigParamsInstance.DockerImage,
+		"specify the image of the nodes in docker-compose")
+	flags.StringVar(&vars.ConfigParamsInstance.NetemDelay,
+		vars.FlagNameOfNetemDelay,
+		vars.ConfigParamsInstance.NetemDelay,
+		"add a tc netem sidecar to every node in docker-compose with the given delay, e.g. 50ms")
+	flags.StringVar(&vars.ConfigParamsInstance.BinaryPath,
+		vars.FlagNameOfBinaryPath,
+		vars.ConfigParamsInstance.BinaryPath,
+		"specify the directory of starter and relaynode used by the systemd units")
 	return flags
 }
 
@@ -47,3 +82,14 @@ func AttachFlags(cmd *cobra.Command, FlagNameS []string) {
 		}
 	}
 }
+
+// AttachOptionalFlags 添加可以不指定的参数, 不指定的时候使用默认值
+func AttachOptionalFlags(cmd *cobra.Command, FlagNameS []string) {
+	initializedFlags := InitFlagSet()
+	cmdFlags := cmd.Flags()
+	for _, flagName := range FlagNameS {
+		if flag := initializedFlags.Lookup(flagName); flag != nil {
+			cmdFlags.AddFlag(flag)
+		}
+	}
+}
@@ -1 +1 @@
-./main generate -n 4 -c 11 -p 11301 -r 12301 -d ../../simulation/config
\ No newline at end of file
+./main generate -n 4 -c 11 -p 11301 -r 12301 -i 127.0.0.1 -d ../../simulation/config
\ No newline at end of file
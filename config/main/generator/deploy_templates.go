@@ -0,0 +1,94 @@
+package generator
+
+// composeTemplate docker-compose.yml 的模板, 节点目录挂载到容器之中相同的路径
+const composeTemplate = `# generated by config generate
+version: "3"
+
+networks:
+  security:
+    driver: bridge
+    ipam:
+      config:
+        - subnet: {{.Subnet}}
+
+services:
+{{- range .Services}}
+
+  {{.Name}}:
+    # {{.Label}}
+    image: {{$.Image}}
+    container_name: {{.Name}}
+{{- if .IsRelay}}
+    entrypoint: ["/usr/local/bin/relaynode", "-cfg", "{{.ConfigFile}}"]
+{{- else}}
+    entrypoint: ["/usr/local/bin/starter", "start", "-c", "{{.ConfigFile}}"]
+{{- end}}
+    working_dir: {{.Path}}
+    restart: on-failure
+    stop_signal: SIGTERM
+    stop_grace_period: {{$.StopTimeout}}s
+    volumes:
+      - {{.Path}}:{{.Path}}
+    ports:
+{{- range .Ports}}
+      - "{{.}}:{{.}}"
+{{- end}}
+    networks:
+      security:
+        ipv4_address: {{.Host}}
+{{- if $.NetemDelay}}
+
+  {{.Name}}-netem:
+    image: {{$.NetemImage}}
+    network_mode: "service:{{.Name}}"
+    cap_add:
+      - NET_ADMIN
+    entrypoint: ["sh", "-c", "tc qdisc replace dev eth0 root netem delay {{$.NetemDelay}} && sleep infinity"]
+    restart: on-failure
+    depends_on:
+      - {{.Name}}
+{{- end}}
+{{- end}}
+`
+
+// nodeUnitTemplate 节点的 systemd unit, SIGHUP 重新加载配置, SIGTERM 优雅退出
+const nodeUnitTemplate = `# generated by config generate
+[Unit]
+Description=security {{.Service.Name}} ({{.Service.Label}})
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory={{.Service.Path}}
+ExecStart={{.BinaryPath}}/starter start -c {{.Service.ConfigFile}}
+ExecReload=/bin/kill -HUP $MAINPID
+KillSignal=SIGTERM
+TimeoutStopSec={{.StopTimeout}}
+Restart=on-failure
+RestartSec=5
+LimitNOFILE=65535
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// relayUnitTemplate 中继节点的 systemd unit
+const relayUnitTemplate = `# generated by config generate
+[Unit]
+Description=security {{.Service.Name}} ({{.Service.Label}})
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory={{.Service.Path}}
+ExecStart={{.BinaryPath}}/relaynode -cfg {{.Service.ConfigFile}}
+KillSignal=SIGTERM
+Restart=on-failure
+RestartSec=5
+LimitNOFILE=65535
+
+[Install]
+WantedBy=multi-user.target
+`
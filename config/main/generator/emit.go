@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+	"zhanghefan123/security/config/main/vars"
+)
+
+var ErrInvalidEmit = errors.New("invalid emit mode")
+
+const (
+	dockerSubnet      = "172.28.0.0/16"       // docker-compose 之中节点所在的网络
+	dockerFirstHostIp = 10                    // 前面的地址留给网关
+	netemImage        = "gaiadocker/iproute2" // 带有 tc 命令的镜像
+	shutdownGrace     = 30                    // 和 chainmaker.yml 之中的 shutdown_grace_period 相同
+	composeFileName   = "docker-compose.yml"
+	systemdDirName    = "systemd"
+)
+
+var (
+	composeTmpl   = template.Must(template.New(composeFileName).Parse(composeTemplate))
+	nodeUnitTmpl  = template.Must(template.New("node.service").Parse(nodeUnitTemplate))
+	relayUnitTmpl = template.Must(template.New("relay.service").Parse(relayUnitTemplate))
+)
+
+// deployService 部署文件之中的一个节点或者中继
+type deployService struct {
+	Name       string // nodeN 或者 relayN
+	Label      string
+	Host       string
+	Ports      []int  // 需要暴露的端口
+	Path       string // 节点目录, 挂载到容器之中相同的路径, 配置文件之中的绝对路径保持有效
+	ConfigFile string
+	IsRelay    bool
+}
+
+// deployTemplateData 渲染部署文件模板需要的数据
+type deployTemplateData struct {
+	Services    []*deployService
+	Subnet      string
+	Image       string
+	NetemImage  string
+	NetemDelay  string
+	BinaryPath  string
+	StopTimeout int
+}
+
+// CheckEmit 检查需要生成的部署文件, docker-compose 之中的节点使用容器的地址, 所以不能和 systemd 同时生成
+func CheckEmit(configParams *vars.ConfigParams) error {
+	modes := make(map[string]bool)
+	for _, mode := range configParams.Emit {
+		switch mode {
+		case vars.EmitDockerCompose, vars.EmitSystemd:
+			modes[mode] = true
+		default:
+			return fmt.Errorf("%w: %s, available: %s, %s", ErrInvalidEmit, mode, vars.EmitDockerCompose, vars.EmitSystemd)
+		}
+	}
+	if modes[vars.EmitDockerCompose] && modes[vars.EmitSystemd] {
+		return fmt.Errorf("%w: %s and %s can not be emitted together", ErrInvalidEmit, vars.EmitDockerCompose, vars.EmitSystemd)
+	}
+	if configParams.NetemDelay != "" {
+		if !modes[vars.EmitDockerCompose] {
+			return fmt.Errorf("%w: netem delay needs %s", ErrInvalidEmit, vars.EmitDockerCompose)
+		}
+		if delay, err := time.ParseDuration(configParams.NetemDelay); err != nil || delay <= 0 {
+			return fmt.Errorf("%w: invalid netem delay %s", ErrInvalidEmit, configParams.NetemDelay)
+		}
+	}
+	return nil
+}
+
+// emitEnabled 是否需要生成某种部署文件
+func emitEnabled(configParams *vars.ConfigParams, mode string) bool {
+	for _, emit := range configParams.Emit {
+		if emit == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// AssignDockerAddresses 为每个节点以及中继分配 docker 网络之中的地址, 需要在生成配置文件之前调用
+func AssignDockerAddresses(nodes []*Node, relays []*Relay) error {
+	_, subnet, err := net.ParseCIDR(dockerSubnet)
+	if err != nil {
+		return err
+	}
+	ones, bits := subnet.Mask.Size()
+	if dockerFirstHostIp+len(nodes)+len(relays) >= 1<<(bits-ones) {
+		return fmt.Errorf("%w: too many nodes for subnet %s", ErrInvalidEmit, dockerSubnet)
+	}
+	hostIp := func(offset int) string {
+		ip := make(net.IP, len(subnet.IP.To4()))
+		copy(ip, subnet.IP.To4())
+		ip[2] += byte(offset >> 8)
+		ip[3] += byte(offset)
+		return ip.String()
+	}
+	for index, node := range nodes {
+		node.Host = hostIp(dockerFirstHostIp + index)
+	}
+	for index, relay := range relays {
+		relay.Host = hostIp(dockerFirstHostIp + len(nodes) + index)
+	}
+	return nil
+}
+
+// EmitDeployment 按照参数生成 docker-compose.yml 或者 systemd 的 unit 文件
+func EmitDeployment(configParams *vars.ConfigParams, nodes []*Node, relays []*Relay, generatedDestination string) error {
+	data := &deployTemplateData{
+		Services:    make([]*deployService, 0, len(nodes)+len(relays)),
+		Subnet:      dockerSubnet,
+		Image:       configParams.DockerImage,
+		NetemImage:  netemImage,
+		NetemDelay:  configParams.NetemDelay,
+		BinaryPath:  configParams.BinaryPath,
+		StopTimeout: shutdownGrace + 10,
+	}
+	for _, node := range nodes {
+		chainMakerPath, _, _ := GetConfigFilePaths(node.Id, generatedDestination)
+		data.Services = append(data.Services, &deployService{
+			Name:       node.Name(),
+			Label:      node.Label,
+			Host:       node.Host,
+			Ports:      []int{node.P2PPort, node.RPCPort},
+			Path:       GetNodePath(node.Id, generatedDestination),
+			ConfigFile: chainMakerPath,
+		})
+	}
+	for _, relay := range relays {
+		data.Services = append(data.Services, &deployService{
+			Name:       relay.Name(),
+			Label:      relay.Label,
+			Host:       relay.Host,
+			Ports:      []int{relay.P2PPort},
+			Path:       GetRelayPath(relay.Id, generatedDestination),
+			ConfigFile: GetRelayConfigPath(relay.Id, generatedDestination),
+			IsRelay:    true,
+		})
+	}
+
+	if emitEnabled(configParams, vars.EmitDockerCompose) {
+		if err := renderFile(composeTmpl, filepath.Join(generatedDestination, composeFileName), data); err != nil {
+			return err
+		}
+	}
+	if emitEnabled(configParams, vars.EmitSystemd) {
+		unitPath := filepath.Join(generatedDestination, systemdDirName)
+		if err := os.MkdirAll(unitPath, os.ModePerm); err != nil {
+			return err
+		}
+		for _, service := range data.Services {
+			tmpl := nodeUnitTmpl
+			if service.IsRelay {
+				tmpl = relayUnitTmpl
+			}
+			unitData := struct {
+				*deployTemplateData
+				Service *deployService
+			}{data, service}
+			if err := renderFile(tmpl, filepath.Join(unitPath, "security-"+service.Name+".service"), unitData); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
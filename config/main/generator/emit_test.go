@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"zhanghefan123/security/config/main/vars"
+)
+
+// update 在 config 目录下运行 go test ./main/generator -run Golden -update 重新生成 testdata 之中的 golden 文件
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// requireGolden 比较生成的文件和 testdata 之中的 golden 文件, 文件之中的生成目录替换为 $DEST
+func requireGolden(t *testing.T, path, destination, golden string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data = bytes.ReplaceAll(data, []byte(destination), []byte("$DEST"))
+	goldenPath := filepath.Join("testdata", golden)
+	if *update {
+		require.NoError(t, os.WriteFile(goldenPath, data, 0644))
+	}
+	expected, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(data), golden)
+}
+
+// testDeployment 三颗卫星, 一个地面站以及一个中继, peerId 使用固定的值
+func testDeployment(t *testing.T, configParams *vars.ConfigParams) ([]*Node, []*Relay) {
+	topology := &Topology{
+		Constellation:  ConstellationConfig{Planes: 1, SatellitesPerPlane: 3},
+		GroundStations: []GroundStationConfig{{Name: "gs-beijing", Links: []string{"sat-0-0"}}},
+		Relays:         []RelayConfig{{Name: "ground-relay", Members: []string{"sat-0-2", "gs-beijing"}}},
+	}
+	nodes, relays, err := topology.Build(configParams)
+	require.NoError(t, err)
+	for _, node := range nodes {
+		node.PeerId = fmt.Sprintf("QmPeer%d", node.Id)
+	}
+	for _, relay := range relays {
+		relay.PeerId = fmt.Sprintf("QmRelay%d", relay.Id)
+	}
+	return nodes, relays
+}
+
+func TestCheckEmit(t *testing.T) {
+	tests := []struct {
+		name       string
+		emit       []string
+		netemDelay string
+		expected   error
+	}{
+		{"nothing", nil, "", nil},
+		{"docker-compose", []string{vars.EmitDockerCompose}, "", nil},
+		{"systemd", []string{vars.EmitSystemd}, "", nil},
+		{"docker-compose with netem", []string{vars.EmitDockerCompose}, "50ms", nil},
+		{"unknown mode", []string{"kubernetes"}, "", ErrInvalidEmit},
+		{"docker-compose and systemd", []string{vars.EmitDockerCompose, vars.EmitSystemd}, "", ErrInvalidEmit},
+		{"netem without docker-compose", []string{vars.EmitSystemd}, "50ms", ErrInvalidEmit},
+		{"invalid netem delay", []string{vars.EmitDockerCompose}, "fast", ErrInvalidEmit},
+		{"non-positive netem delay", []string{vars.EmitDockerCompose}, "0s", ErrInvalidEmit},
+	}
+	for _, tt := range tests {
+		configParams := &vars.ConfigParams{Emit: tt.emit, NetemDelay: tt.netemDelay}
+		require.ErrorIs(t, CheckEmit(configParams), tt.expected, tt.name)
+	}
+}
+
+func TestAssignDockerAddresses(t *testing.T) {
+	nodes, relays := testDeployment(t, testConfigParams())
+	require.NoError(t, AssignDockerAddresses(nodes, relays))
+	require.Equal(t, "172.28.0.10", nodes[0].Host)
+	require.Equal(t, "172.28.0.13", nodes[3].Host)
+	require.Equal(t, "172.28.0.14", relays[0].Host)
+
+	// 地址超过一个字节的时候进位到第三个字节, 子网放不下的时候返回错误
+	many := make([]*Node, 300)
+	for index := range many {
+		many[index] = &Node{}
+	}
+	require.NoError(t, AssignDockerAddresses(many, nil))
+	require.Equal(t, "172.28.1.53", many[299].Host)
+	require.ErrorIs(t, AssignDockerAddresses(make([]*Node, 1<<16), nil), ErrInvalidEmit)
+}
+
+func TestEmitDeploymentGolden(t *testing.T) {
+	tests := []struct {
+		name       string
+		emit       string
+		netemDelay string
+		files      map[string]string // 生成的文件 -> golden 文件
+	}{
+		{
+			name:  "docker-compose",
+			emit:  vars.EmitDockerCompose,
+			files: map[string]string{composeFileName: "docker-compose.yml.golden"},
+		},
+		{
+			name:       "docker-compose with netem",
+			emit:       vars.EmitDockerCompose,
+			netemDelay: "50ms",
+			files:      map[string]string{composeFileName: "docker-compose-netem.yml.golden"},
+		},
+		{
+			name: "systemd",
+			emit: vars.EmitSystemd,
+			files: map[string]string{
+				filepath.Join(systemdDirName, "security-node1.service"):  "security-node1.service.golden",
+				filepath.Join(systemdDirName, "security-relay1.service"): "security-relay1.service.golden",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination := t.TempDir()
+			configParams := testConfigParams()
+			configParams.Emit = []string{tt.emit}
+			configParams.NetemDelay = tt.netemDelay
+			configParams.DockerImage = "security-node:latest"
+			configParams.BinaryPath = "/usr/local/bin"
+			require.NoError(t, CheckEmit(configParams))
+
+			nodes, relays := testDeployment(t, configParams)
+			if tt.emit == vars.EmitDockerCompose {
+				require.NoError(t, AssignDockerAddresses(nodes, relays))
+			}
+			require.NoError(t, EmitDeployment(configParams, nodes, relays, destination))
+			for file, golden := range tt.files {
+				requireGolden(t, filepath.Join(destination, file), destination, golden)
+			}
+			if tt.emit == vars.EmitSystemd {
+				units, err := os.ReadDir(filepath.Join(destination, systemdDirName))
+				require.NoError(t, err)
+				require.Len(t, units, len(nodes)+len(relays))
+				require.NoFileExists(t, filepath.Join(destination, composeFileName))
+			}
+		})
+	}
+}
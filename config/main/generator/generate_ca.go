@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"zhanghefan123/security/common/cert"
+	"zhanghefan123/security/common/crypto"
+	"zhanghefan123/security/common/crypto/asym"
+)
+
+const (
+	certCountry            = "CN"
+	certLocality           = "Beijing"
+	certProvince           = "Beijing"
+	certOrganizationalUnit = "security"
+	certExpireYear         = 10
+)
+
+// GenerateCA 创建这一次部署的 CA, 用于为所有节点签发签名证书以及 TLS 证书
+func GenerateCA(generatedDestination string, keyType crypto.KeyType, sans []string) error {
+	caKeyPath, caCertPath := GetCAKeyAndCertPath(generatedDestination)
+	privateKey, err := cert.CreatePrivKey(keyType, filepath.Dir(caKeyPath), filepath.Base(caKeyPath), true)
+	if err != nil {
+		return fmt.Errorf("create ca key failed, %w", err)
+	}
+	return cert.CreateCACertificate(&cert.CACertificateConfig{
+		PrivKey:            privateKey,
+		HashType:           HashTypeOf(keyType),
+		CertPath:           filepath.Dir(caCertPath),
+		CertFileName:       filepath.Base(caCertPath),
+		Country:            certCountry,
+		Locality:           certLocality,
+		Province:           certProvince,
+		OrganizationalUnit: certOrganizationalUnit,
+		Organization:       ChainId,
+		CommonName:         "ca." + ChainId,
+		ExpireYear:         certExpireYear,
+		Sans:               sans,
+	})
+}
+
+// IssueNodeCertificates 使用 CA 为节点签发证书, TLS 证书对应 private.key, 签名证书对应新生成的 sign.key
+func IssueNodeCertificates(node *Node, generatedDestination string, keyType crypto.KeyType) error {
+	_, privateKeyPath := GetPeerIdAndPrivateKeyPath(node.Id, generatedDestination)
+	signKeyPath, signCertPath := GetSignKeyAndCertPath(node.Id, generatedDestination)
+
+	// 1. TLS 证书
+	tlsKeyPem, err := ioutil.ReadFile(privateKeyPath)
+	if err != nil {
+		return err
+	}
+	tlsKey, err := asym.PrivateKeyFromPEM(tlsKeyPem, nil)
+	if err != nil {
+		return err
+	}
+	if err = issueCertificate(node, generatedDestination, keyType, tlsKey, GetTLSCertPath(node.Id, generatedDestination),
+		[]x509.KeyUsage{x509.KeyUsageDigitalSignature, x509.KeyUsageKeyEncipherment},
+		[]x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}); err != nil {
+		return fmt.Errorf("issue tls certificate failed, %w", err)
+	}
+
+	// 2. 签名证书
+	signKey, err := cert.CreatePrivKey(keyType, filepath.Dir(signKeyPath), filepath.Base(signKeyPath), false)
+	if err != nil {
+		return err
+	}
+	if err = issueCertificate(node, generatedDestination, keyType, signKey, signCertPath,
+		[]x509.KeyUsage{x509.KeyUsageDigitalSignature, x509.KeyUsageContentCommitment},
+		[]x509.ExtKeyUsage{}); err != nil {
+		return fmt.Errorf("issue sign certificate failed, %w", err)
+	}
+	return nil
+}
+
+// issueCertificate 为私钥创建证书请求并使用 CA 进行签发, 证书请求在签发之后删除
+func issueCertificate(node *Node, generatedDestination string, keyType crypto.KeyType, privateKey crypto.PrivateKey,
+	certPath string, keyUsages []x509.KeyUsage, extKeyUsages []x509.ExtKeyUsage) error {
+	caKeyPath, caCertPath := GetCAKeyAndCertPath(generatedDestination)
+	csrPath := strings.TrimSuffix(certPath, filepath.Ext(certPath)) + ".csr"
+	defer os.Remove(csrPath)
+
+	if err := cert.CreateCSR(&cert.CSRConfig{
+		PrivKey:            privateKey,
+		CsrPath:            filepath.Dir(csrPath),
+		CsrFileName:        filepath.Base(csrPath),
+		Country:            certCountry,
+		Locality:           certLocality,
+		Province:           certProvince,
+		OrganizationalUnit: certOrganizationalUnit,
+		Organization:       node.Name(),
+		CommonName:         node.Name() + "." + ChainId,
+	}); err != nil {
+		return err
+	}
+	return cert.IssueCertificate(&cert.IssueCertificateConfig{
+		HashType:              HashTypeOf(keyType),
+		IssuerPrivKeyFilePath: caKeyPath,
+		IssuerCertFilePath:    caCertPath,
+		CsrFilePath:           csrPath,
+		CertPath:              filepath.Dir(certPath),
+		CertFileName:          filepath.Base(certPath),
+		ExpireYear:            certExpireYear,
+		Sans:                  []string{node.Host, "localhost"},
+		KeyUsages:             keyUsages,
+		ExtKeyUsages:          extKeyUsages,
+	})
+}
@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+	"zhanghefan123/security/common/crypto"
+)
+
+// configTemplateData 渲染配置文件模板需要的数据
+type configTemplateData struct {
+	Node           *Node
+	Nodes          []*Node
+	ValidatorNodes []*Node
+	ChainId        string
+	ConsensusType  int
+	Seeds          []string // 直接相连的节点以及通过中继连接的节点的地址
+	Validators     []string // 所有验证者的 peerId
+	HashName       string
+	PrivateKeyFile string // 网络模块使用的私钥, peerId 由这个私钥计算
+	SignKeyFile    string // 节点的签名私钥, 没有 CA 的时候和 PrivateKeyFile 相同
+	SignCertFile   string // 以下的证书只有在创建了 CA 的时候才会生成
+	TLSCertFile    string
+	CACertFile     string
+	LogConfigFile  string
+	GenesisFile    string
+	DataPath       string
+	LogPath        string
+}
+
+var (
+	chainMakerTmpl = template.Must(template.New("chainmaker.yml").Parse(chainMakerTemplate))
+	logTmpl        = template.Must(template.New("log.yml").Parse(logTemplate))
+	genesisTmpl    = template.Must(template.New("bc1.yml").Parse(genesisTemplate))
+	relayTmpl      = template.Must(template.New("relay.yaml").Parse(relayTemplate))
+)
+
+// relayTemplateData 渲染中继配置文件模板需要的数据
+type relayTemplateData struct {
+	Relay          *Relay
+	PrivateKeyFile string
+	MaxPeerCount   int
+}
+
+// defaultMaxPeerCount libp2p 默认允许的最大连接数量
+const defaultMaxPeerCount = 20
+
+// GenerateConfig 生成节点的 chainmaker.yml, log.yml 以及 bc1.yml, 所有节点的 peerId 需要已经生成
+func GenerateConfig(node *Node, nodes []*Node, consensusType int, keyType crypto.KeyType, enableCA bool,
+	generatedDestination string) error {
+	chainMakerPath, logPath, genesisPath := GetConfigFilePaths(node.Id, generatedDestination)
+	_, privateKeyPath := GetPeerIdAndPrivateKeyPath(node.Id, generatedDestination)
+	data := &configTemplateData{
+		Node:           node,
+		Nodes:          nodes,
+		ChainId:        ChainId,
+		ConsensusType:  consensusType,
+		Seeds:          node.Seeds(),
+		Validators:     make([]string, 0, len(nodes)),
+		HashName:       HashNameOf(keyType),
+		PrivateKeyFile: privateKeyPath,
+		SignKeyFile:    privateKeyPath,
+		LogConfigFile:  logPath,
+		GenesisFile:    genesisPath,
+		DataPath:       GetDataPath(node.Id, generatedDestination),
+		LogPath:        GetLogPath(node.Id, generatedDestination),
+	}
+	if enableCA {
+		data.SignKeyFile, data.SignCertFile = GetSignKeyAndCertPath(node.Id, generatedDestination)
+		data.TLSCertFile = GetTLSCertPath(node.Id, generatedDestination)
+		_, data.CACertFile = GetCAKeyAndCertPath(generatedDestination)
+	}
+	for _, other := range nodes {
+		if other.IsValidator() {
+			data.ValidatorNodes = append(data.ValidatorNodes, other)
+			data.Validators = append(data.Validators, other.PeerId)
+		}
+	}
+
+	if err := renderFile(chainMakerTmpl, chainMakerPath, data); err != nil {
+		return err
+	}
+	if err := renderFile(logTmpl, logPath, data); err != nil {
+		return err
+	}
+	return renderFile(genesisTmpl, genesisPath, data)
+}
+
+// GenerateRelayConfig 生成中继节点的 relay.yaml, 成员的 peerId 需要已经生成
+func GenerateRelayConfig(relay *Relay, generatedDestination string) error {
+	_, privateKeyPath := GetRelayPeerIdAndPrivateKeyPath(relay.Id, generatedDestination)
+	maxPeerCount := defaultMaxPeerCount
+	if 2*len(relay.Members) > maxPeerCount {
+		maxPeerCount = 2 * len(relay.Members)
+	}
+	return renderFile(relayTmpl, GetRelayConfigPath(relay.Id, generatedDestination), &relayTemplateData{
+		Relay:          relay,
+		PrivateKeyFile: privateKeyPath,
+		MaxPeerCount:   maxPeerCount,
+	})
+}
+
+// renderFile 渲染模板并写入文件
+func renderFile(tmpl *template.Template, path string, data interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create %s failed, %w", path, err)
+	}
+	if err = tmpl.Execute(file, data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("render %s failed, %w", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"zhanghefan123/security/common/crypto"
+	"zhanghefan123/security/modules/consensus_algorithms"
+)
+
+func TestGenerateConfigGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyType  crypto.KeyType
+		enableCA bool
+	}{
+		{"ecc", crypto.ECC_NISTP256, false},
+		{"sm2-ca", crypto.SM2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination := t.TempDir()
+			nodes, _ := testDeployment(t, testConfigParams())
+			// gs-beijing 只负责接入, 不在验证者集合之中, sat-0-2 通过中继连接 gs-beijing
+			for _, node := range nodes {
+				require.NoError(t, GenerateDir(node.Id, destination))
+				require.NoError(t, GenerateConfig(node, nodes, int(consensus_algorithms.ConsensusType_PBFT), tt.keyType, tt.enableCA, destination))
+			}
+			for _, node := range []*Node{nodes[2], nodes[3]} {
+				chainMakerPath, _, _ := GetConfigFilePaths(node.Id, destination)
+				requireGolden(t, chainMakerPath, destination, tt.name+"-"+node.Name()+"-chainmaker.yml.golden")
+			}
+			_, logPath, genesisPath := GetConfigFilePaths(nodes[0].Id, destination)
+			requireGolden(t, logPath, destination, "log.yml.golden")
+			requireGolden(t, genesisPath, destination, tt.name+"-bc1.yml.golden")
+
+			// 所有节点使用相同的创世配置
+			genesis, err := os.ReadFile(genesisPath)
+			require.NoError(t, err)
+			for _, node := range nodes[1:] {
+				_, _, otherGenesisPath := GetConfigFilePaths(node.Id, destination)
+				other, err := os.ReadFile(otherGenesisPath)
+				require.NoError(t, err)
+				require.Equal(t, string(genesis), string(other), node.Name())
+			}
+		})
+	}
+}
+
+func TestNewNodes(t *testing.T) {
+	configParams := testConfigParams()
+	configParams.NodeNumber = 4
+	nodes := NewNodes(configParams)
+	require.Len(t, nodes, 4)
+	for index, node := range nodes {
+		require.Equal(t, index+1, node.Id)
+		require.Equal(t, node.Name(), node.Label)
+		require.True(t, node.IsValidator())
+		require.Len(t, node.Neighbours, 3)
+		require.NotContains(t, node.Neighbours, node)
+	}
+	require.Equal(t, "/ip4/127.0.0.1/tcp/11302", nodes[1].ListenAddr())
+}
+
+func TestGenerateRelayConfigGolden(t *testing.T) {
+	destination := t.TempDir()
+	_, relays := testDeployment(t, testConfigParams())
+	// 中继的目录在生成密钥的时候创建
+	require.NoError(t, os.MkdirAll(GetRelayPath(relays[0].Id, destination), os.ModePerm))
+	require.NoError(t, GenerateRelayConfig(relays[0], destination))
+	requireGolden(t, GetRelayConfigPath(relays[0].Id, destination), destination, "relay.yaml.golden")
+	require.Equal(t, "/ip4/127.0.0.1/tcp/11305/p2p/QmRelay1/p2p-circuit/p2p/QmPeer3", relays[0].CircuitAddr(relays[0].Members[0]))
+}
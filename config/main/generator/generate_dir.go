@@ -3,24 +3,20 @@ package generator
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 )
 
-func GenerateDir(nodeIndex int, generateDestination string) {
-	if !filepath.IsAbs(generateDestination) {
-		generateDestination, _ = filepath.Abs(generateDestination)
+// GenerateDir 创建节点的证书, 创世配置, 数据以及日志目录
+func GenerateDir(nodeId int, generatedDestination string) error {
+	dirs := []string{
+		GetCertPath(nodeId, generatedDestination),
+		GetChainConfigPath(nodeId, generatedDestination),
+		GetDataPath(nodeId, generatedDestination),
+		GetLogPath(nodeId, generatedDestination),
 	}
-	if _, err := os.Stat(generateDestination); os.IsNotExist(err) {
-		err := os.MkdirAll(generateDestination, os.ModePerm)
-		if err != nil {
-			_ = fmt.Errorf("mkdir for %v failed ", generateDestination)
-		}
-	}
-	certConfigPath := GetCertPath(nodeIndex, generateDestination)
-	if _, err := os.Stat(certConfigPath); os.IsNotExist(err) {
-		err := os.MkdirAll(certConfigPath, os.ModePerm)
-		if err != nil {
-			_ = fmt.Errorf("mkdir for %v failed ", generateDestination)
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return fmt.Errorf("mkdir for %v failed, %w", dir, err)
 		}
 	}
+	return nil
 }
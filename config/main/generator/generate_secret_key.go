@@ -2,63 +2,43 @@ package generator
 
 import (
 	"fmt"
-	"github.com/libp2p/go-libp2p-core/crypto"
-	"github.com/libp2p/go-libp2p-core/peer"
-	"os"
+	"io/ioutil"
+	"path/filepath"
+	"zhanghefan123/security/common/cert"
+	"zhanghefan123/security/common/crypto"
+	"zhanghefan123/security/common/helper"
 )
 
-// GenerateSecretKey 进行私钥的生成
-// nodeIndex 节点id
+// GenerateSecretKey 进行私钥的生成, 私钥以 PEM 格式保存, peerId 的计算方式和节点启动的时候一致
+// nodeId 节点id
 // generatedDestination 生成的地址
-func GenerateSecretKey(nodeIndex int, generatedDestination string) {
-	peerIdPath, privateKeyPath := GetPeerIdAndPrivateKeyPath(nodeIndex, generatedDestination)
-	peerIdFile, _ := os.OpenFile(peerIdPath, os.O_CREATE|os.O_WRONLY, 0666)
-	privateKeyFile, _ := os.OpenFile(privateKeyPath, os.O_CREATE|os.O_WRONLY, 0666)
-	defer func(privateKeyFile *os.File) {
-		err := privateKeyFile.Close()
-		if err != nil {
-			_ = fmt.Errorf("error closing private key file: %s\n", err)
-		}
-	}(privateKeyFile)
-	defer func(peerIdFile *os.File) {
-		err := peerIdFile.Close()
-		if err != nil {
-			_ = fmt.Errorf("error closing private key file: %s\n", err)
-		}
-	}(peerIdFile)
-
-	// 产生私钥
-	privateKey, _, err := crypto.GenerateKeyPair(crypto.RSA, 2048)
-	if err != nil {
-		_ = fmt.Errorf("error generating private key: %v", err)
-		panic(err)
-	}
+// keyType 密钥算法
+func GenerateSecretKey(nodeId int, generatedDestination string, keyType crypto.KeyType) (string, error) {
+	peerIdPath, privateKeyPath := GetPeerIdAndPrivateKeyPath(nodeId, generatedDestination)
+	return generateSecretKey(peerIdPath, privateKeyPath, keyType)
+}
 
-	// 将私钥编码为 PEM 格式
-	privateBytes, err := crypto.MarshalPrivateKey(privateKey)
-	if err != nil {
-		_ = fmt.Errorf("error marshalling private key: %v", err)
-		panic(err)
-	}
+// GenerateRelaySecretKey 生成中继节点的私钥以及 peerId
+func GenerateRelaySecretKey(relayId int, generatedDestination string, keyType crypto.KeyType) (string, error) {
+	peerIdPath, privateKeyPath := GetRelayPeerIdAndPrivateKeyPath(relayId, generatedDestination)
+	return generateSecretKey(peerIdPath, privateKeyPath, keyType)
+}
 
-	// 将私钥写入文件
-	_, err = privateKeyFile.Write(privateBytes)
+// generateSecretKey 生成私钥并写入 privateKeyPath, 计算出的 peerId 写入 peerIdPath
+func generateSecretKey(peerIdPath, privateKeyPath string, keyType crypto.KeyType) (string, error) {
+	// 产生私钥并以 PEM 格式写入文件
+	privateKey, err := cert.CreatePrivKey(keyType, filepath.Dir(privateKeyPath), filepath.Base(privateKeyPath), true)
 	if err != nil {
-		_ = fmt.Errorf("error writing private key: %v", err)
-		panic(err)
+		return "", fmt.Errorf("error generating private key: %w", err)
 	}
 
-	// 创建 peerId
-	peerID, err := peer.IDFromPrivateKey(privateKey)
+	// 创建 peerId 并写入文件
+	peerId, err := helper.CreateLibp2pPeerIdWithPrivateKey(privateKey)
 	if err != nil {
-		_ = fmt.Errorf("error parsing peer ID: %v", err)
-		panic(err)
+		return "", fmt.Errorf("error creating peer ID: %w", err)
 	}
-
-	// 写入 peerId
-	_, err = peerIdFile.Write([]byte(peerID.String()))
-	if err != nil {
-		_ = fmt.Errorf("error writing peer ID: %v", err)
-		panic(err)
+	if err = ioutil.WriteFile(peerIdPath, []byte(peerId), 0644); err != nil {
+		return "", fmt.Errorf("error writing peer ID: %w", err)
 	}
+	return peerId, nil
 }
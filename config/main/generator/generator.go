@@ -1,19 +1,124 @@
 package generator
 
 import (
+	"errors"
+	"fmt"
+	"path/filepath"
 	"sync"
 	"zhanghefan123/security/config/main/vars"
+	"zhanghefan123/security/modules/consensus_algorithms"
 )
 
-func Generate(configParams *vars.ConfigParams) {
+// ErrUnsupportedConsensusType 配置生成暂时只支持 pbft 共识
+var ErrUnsupportedConsensusType = errors.New("unsupported consensus type")
+
+// Generate 生成所有节点的密钥以及配置文件, 生成配置文件之前需要知道所有节点的 peerId
+func Generate(configParams *vars.ConfigParams) error {
+	if configParams.ConsensusType != int(consensus_algorithms.ConsensusType_PBFT) {
+		return fmt.Errorf("%w: %d", ErrUnsupportedConsensusType, configParams.ConsensusType)
+	}
+	keyType, err := ParseKeyType(configParams.KeyType)
+	if err != nil {
+		return err
+	}
+	if err = CheckEmit(configParams); err != nil {
+		return err
+	}
+	// 节点的配置之中使用绝对路径, 节点可以从任意的目录启动
+	destination, err := filepath.Abs(configParams.GeneratedDestination)
+	if err != nil {
+		return err
+	}
+
+	nodes, relays, err := buildNodes(configParams)
+	if err != nil {
+		return err
+	}
+	if emitEnabled(configParams, vars.EmitDockerCompose) {
+		if err = AssignDockerAddresses(nodes, relays); err != nil {
+			return err
+		}
+	}
+
+	// 1. 创建 CA
+	if configParams.EnableCA {
+		if err = GenerateCA(destination, keyType, []string{configParams.Host, "localhost"}); err != nil {
+			return err
+		}
+	}
+
+	// 2. 创建目录, 生成密钥并签发证书
+	for _, relay := range relays {
+		if relay.PeerId, err = GenerateRelaySecretKey(relay.Id, destination, keyType); err != nil {
+			return fmt.Errorf("%s: %w", relay.Name(), err)
+		}
+	}
+	if err = forEachNode(nodes, func(node *Node) error {
+		if err := GenerateDir(node.Id, destination); err != nil {
+			return err
+		}
+		peerId, err := GenerateSecretKey(node.Id, destination, keyType)
+		if err != nil {
+			return err
+		}
+		node.PeerId = peerId
+		if !configParams.EnableCA {
+			return nil
+		}
+		return IssueNodeCertificates(node, destination, keyType)
+	}); err != nil {
+		return err
+	}
+
+	// 3. 生成配置文件
+	for _, relay := range relays {
+		if err = GenerateRelayConfig(relay, destination); err != nil {
+			return fmt.Errorf("%s: %w", relay.Name(), err)
+		}
+	}
+	if err = forEachNode(nodes, func(node *Node) error {
+		return GenerateConfig(node, nodes, configParams.ConsensusType, keyType, configParams.EnableCA, destination)
+	}); err != nil {
+		return err
+	}
+
+	// 4. 生成部署文件
+	return EmitDeployment(configParams, nodes, relays, destination)
+}
+
+// buildNodes 指定了拓扑文件的时候按照拓扑创建节点, 否则创建 NodeNumber 个两两相连的验证者
+func buildNodes(configParams *vars.ConfigParams) ([]*Node, []*Relay, error) {
+	if configParams.TopologyFile == "" {
+		if configParams.NodeNumber <= 0 {
+			return nil, nil, fmt.Errorf("invalid node number %d", configParams.NodeNumber)
+		}
+		return NewNodes(configParams), nil, nil
+	}
+	topology, err := LoadTopology(configParams.TopologyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	return topology.Build(configParams)
+}
+
+// forEachNode 并行地对每一个节点进行处理, 返回第一个错误
+func forEachNode(nodes []*Node, handle func(node *Node) error) error {
 	wg := sync.WaitGroup{}
-	wg.Add(configParams.NodeNumber)
-	for index := 0; index < configParams.NodeNumber; index++ {
-		go func(nodeIndex int) {
+	errs := make([]error, len(nodes))
+	wg.Add(len(nodes))
+	for index, node := range nodes {
+		go func(index int, node *Node) {
 			defer wg.Done()
-			GenerateDir(nodeIndex, configParams.GeneratedDestination)
-			GenerateSecretKey(nodeIndex, configParams.GeneratedDestination)
-		}(index)
+			if err := handle(node); err != nil {
+				errs[index] = fmt.Errorf("%s: %w", node.Name(), err)
+			}
+		}(index, node)
 	}
 	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
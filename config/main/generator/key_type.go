@@ -0,0 +1,41 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"zhanghefan123/security/common/crypto"
+)
+
+// ErrUnsupportedKeyType 节点无法加载的密钥算法
+var ErrUnsupportedKeyType = errors.New("unsupported key type")
+
+// supportedKeyTypes 节点能够通过 asym.PrivateKeyFromPEM 加载并且能够计算出 peerId 的密钥算法
+var supportedKeyTypes = map[string]crypto.KeyType{
+	"ECC_NISTP256":                 crypto.ECC_NISTP256,
+	crypto.CRYPTO_ALGO_SM2:         crypto.SM2,
+	crypto.CRYPTO_ALGO_ECC_Ed25519: crypto.ECC_Ed25519,
+}
+
+// ParseKeyType 将参数之中的算法名称转换为密钥算法
+func ParseKeyType(name string) (crypto.KeyType, error) {
+	if keyType, ok := supportedKeyTypes[name]; ok {
+		return keyType, nil
+	}
+	return 0, fmt.Errorf("%w: %s, available: ECC_NISTP256, SM2, ECC_Ed25519", ErrUnsupportedKeyType, name)
+}
+
+// HashTypeOf 证书以及创世配置之中使用的哈希算法, 国密算法使用 SM3
+func HashTypeOf(keyType crypto.KeyType) crypto.HashType {
+	if keyType == crypto.SM2 {
+		return crypto.HASH_TYPE_SM3
+	}
+	return crypto.HASH_TYPE_SHA256
+}
+
+// HashNameOf 创世配置之中 crypto.hash 的取值
+func HashNameOf(keyType crypto.KeyType) string {
+	if keyType == crypto.SM2 {
+		return crypto.CRYPTO_ALGO_SM3
+	}
+	return crypto.CRYPTO_ALGO_SHA256
+}
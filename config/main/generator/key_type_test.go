@@ -0,0 +1,37 @@
+package generator
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"zhanghefan123/security/common/crypto"
+)
+
+func TestParseKeyType(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected crypto.KeyType
+		err      error
+	}{
+		{"ECC_NISTP256", crypto.ECC_NISTP256, nil},
+		{crypto.CRYPTO_ALGO_SM2, crypto.SM2, nil},
+		{crypto.CRYPTO_ALGO_ECC_Ed25519, crypto.ECC_Ed25519, nil},
+		{"RSA2048", 0, ErrUnsupportedKeyType},
+		{"ecc_nistp256", 0, ErrUnsupportedKeyType},
+		{"", 0, ErrUnsupportedKeyType},
+	}
+	for _, tt := range tests {
+		keyType, err := ParseKeyType(tt.name)
+		require.ErrorIs(t, err, tt.err, tt.name)
+		require.Equal(t, tt.expected, keyType, tt.name)
+	}
+}
+
+func TestHashOfKeyType(t *testing.T) {
+	// 国密算法使用 SM3, 其他算法使用 SHA256
+	require.Equal(t, crypto.HASH_TYPE_SM3, HashTypeOf(crypto.SM2))
+	require.Equal(t, crypto.CRYPTO_ALGO_SM3, HashNameOf(crypto.SM2))
+	require.Equal(t, crypto.HASH_TYPE_SHA256, HashTypeOf(crypto.ECC_NISTP256))
+	require.Equal(t, crypto.CRYPTO_ALGO_SHA256, HashNameOf(crypto.ECC_NISTP256))
+	require.Equal(t, crypto.HASH_TYPE_SHA256, HashTypeOf(crypto.ECC_Ed25519))
+	require.Equal(t, crypto.CRYPTO_ALGO_SHA256, HashNameOf(crypto.ECC_Ed25519))
+}
@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"fmt"
+	"zhanghefan123/security/config/main/vars"
+)
+
+// ChainId 生成的配置之中链的 id
+const ChainId = "chain1"
+
+const (
+	RoleValidator = "validator" // 参与 pbft 共识的节点
+	RoleAccess    = "access"    // 只负责接入的节点, 不在验证者集合之中
+)
+
+// Node 生成配置的时候需要的节点信息
+type Node struct {
+	Id         int     // 节点编号, 从 1 开始, 和目录 nodeN 对应
+	Label      string  // 拓扑之中的名称, 例如 sat-0-1, 没有拓扑的时候和 Name 相同
+	Role       string  // 节点的角色, validator 或者 access
+	Host       string  // 节点的 ip 地址
+	P2PPort    int     // 节点的 p2p 端口
+	RPCPort    int     // 节点的 rpc 端口
+	PeerId     string  // 通过私钥计算出来的 peerId
+	Neighbours []*Node // 直接连接的节点, 作为 seeds
+	Relays     []*Relay
+}
+
+// NewNodes 从起始端口开始为每个节点分配端口, 所有节点都是验证者并且两两直接相连
+func NewNodes(configParams *vars.ConfigParams) []*Node {
+	nodes := make([]*Node, 0, configParams.NodeNumber)
+	for index := 0; index < configParams.NodeNumber; index++ {
+		node := newNode(configParams, index, RoleValidator)
+		node.Label = node.Name()
+		nodes = append(nodes, node)
+	}
+	for _, node := range nodes {
+		for _, other := range nodes {
+			if other != node {
+				node.Neighbours = append(node.Neighbours, other)
+			}
+		}
+	}
+	return nodes
+}
+
+// newNode 为第 index 个节点分配编号以及端口
+func newNode(configParams *vars.ConfigParams, index int, role string) *Node {
+	return &Node{
+		Id:      index + 1,
+		Role:    role,
+		Host:    configParams.Host,
+		P2PPort: configParams.StartP2PPort + index,
+		RPCPort: configParams.StartRPCPort + index,
+	}
+}
+
+// Name 节点的名称, 同时作为 org_id
+func (node *Node) Name() string {
+	return fmt.Sprintf("node%d", node.Id)
+}
+
+// ListenAddr 节点 p2p 的监听地址
+func (node *Node) ListenAddr() string {
+	return fmt.Sprintf("/ip4/%s/tcp/%d", node.Host, node.P2PPort)
+}
+
+// MultiAddr 其他节点连接这个节点的时候使用的地址
+func (node *Node) MultiAddr() string {
+	return fmt.Sprintf("%s/p2p/%s", node.ListenAddr(), node.PeerId)
+}
+
+// IsValidator 节点是否在 pbft 的验证者集合之中
+func (node *Node) IsValidator() bool {
+	return node.Role == RoleValidator
+}
+
+// Seeds 节点启动的时候连接的地址, 包括直接相连的节点以及通过中继连接的节点
+func (node *Node) Seeds() []string {
+	seeds := make([]string, 0, len(node.Neighbours))
+	added := make(map[string]struct{})
+	add := func(seed string) {
+		if _, ok := added[seed]; !ok {
+			added[seed] = struct{}{}
+			seeds = append(seeds, seed)
+		}
+	}
+	for _, neighbour := range node.Neighbours {
+		add(neighbour.MultiAddr())
+	}
+	for _, relay := range node.Relays {
+		for _, member := range relay.Members {
+			if member != node {
+				add(relay.CircuitAddr(member))
+			}
+		}
+	}
+	return seeds
+}
+
+// link 在两个节点之间建立双向的连接
+func link(a, b *Node) {
+	if a == b {
+		return
+	}
+	for _, neighbour := range a.Neighbours {
+		if neighbour == b {
+			return
+		}
+	}
+	a.Neighbours = append(a.Neighbours, b)
+	b.Neighbours = append(b.Neighbours, a)
+}
+
+// Relay 中继节点, 成员之间通过中继建立连接, 配置文件由 network/libp2p/relaynode 使用
+type Relay struct {
+	Id      int
+	Label   string
+	Host    string
+	P2PPort int
+	PeerId  string
+	Members []*Node
+}
+
+// Name 中继节点的名称, 同时作为目录名称
+func (relay *Relay) Name() string {
+	return fmt.Sprintf("relay%d", relay.Id)
+}
+
+// MultiAddr 中继节点的地址
+func (relay *Relay) MultiAddr() string {
+	return fmt.Sprintf("/ip4/%s/tcp/%d/p2p/%s", relay.Host, relay.P2PPort, relay.PeerId)
+}
+
+// CircuitAddr 通过中继连接目标节点的地址
+func (relay *Relay) CircuitAddr(target *Node) string {
+	return fmt.Sprintf("%s/p2p-circuit/p2p/%s", relay.MultiAddr(), target.PeerId)
+}
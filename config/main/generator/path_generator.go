@@ -22,3 +22,65 @@ func GetPeerIdAndPrivateKeyPath(nodeId int, generatedDestination string) (string
 	privateKeyPath := filepath.Join(certPath, "/private.key")
 	return peerIdPath, privateKeyPath
 }
+
+// GetChainConfigPath 获取创世配置 bcx.yml 的存放路径
+func GetChainConfigPath(nodeId int, generatedDestination string) string {
+	return filepath.Join(GetNodePath(nodeId, generatedDestination), "chainconfig")
+}
+
+// GetConfigFilePaths 获取 chainmaker.yml, log.yml 以及 bc1.yml 的存放路径
+func GetConfigFilePaths(nodeId int, generatedDestination string) (string, string, string) {
+	nodePath := GetNodePath(nodeId, generatedDestination)
+	chainMakerPath := filepath.Join(nodePath, "chainmaker.yml")
+	logPath := filepath.Join(nodePath, "log.yml")
+	genesisPath := filepath.Join(GetChainConfigPath(nodeId, generatedDestination), "bc1.yml")
+	return chainMakerPath, logPath, genesisPath
+}
+
+// GetDataPath 获取节点运行时数据 (请求池日志等) 的存放路径
+func GetDataPath(nodeId int, generatedDestination string) string {
+	return filepath.Join(GetNodePath(nodeId, generatedDestination), "data")
+}
+
+// GetLogPath 获取节点日志的存放路径
+func GetLogPath(nodeId int, generatedDestination string) string {
+	return filepath.Join(GetNodePath(nodeId, generatedDestination), "log")
+}
+
+// GetCAPath 获取 CA 私钥以及证书的存放路径, 同一次生成的所有节点共用一个 CA
+func GetCAPath(generatedDestination string) string {
+	return filepath.Join(generatedDestination, "ca")
+}
+
+// GetCAKeyAndCertPath 获取 CA 私钥以及证书的路径
+func GetCAKeyAndCertPath(generatedDestination string) (string, string) {
+	caPath := GetCAPath(generatedDestination)
+	return filepath.Join(caPath, "ca.key"), filepath.Join(caPath, "ca.crt")
+}
+
+// GetTLSCertPath 获取节点 TLS 证书的路径, TLS 证书使用的是 private.key
+func GetTLSCertPath(nodeId int, generatedDestination string) string {
+	return filepath.Join(GetCertPath(nodeId, generatedDestination), "tls.crt")
+}
+
+// GetSignKeyAndCertPath 获取节点签名私钥以及签名证书的路径
+func GetSignKeyAndCertPath(nodeId int, generatedDestination string) (string, string) {
+	certPath := GetCertPath(nodeId, generatedDestination)
+	return filepath.Join(certPath, "sign.key"), filepath.Join(certPath, "sign.crt")
+}
+
+// GetRelayPath 获取中继节点的路径
+func GetRelayPath(relayId int, generatedDestination string) string {
+	return filepath.Join(generatedDestination, fmt.Sprintf("relay%d", relayId))
+}
+
+// GetRelayPeerIdAndPrivateKeyPath 获取中继节点 peerId 以及私钥的存放路径
+func GetRelayPeerIdAndPrivateKeyPath(relayId int, generatedDestination string) (string, string) {
+	certPath := filepath.Join(GetRelayPath(relayId, generatedDestination), "cert")
+	return filepath.Join(certPath, "peerId"), filepath.Join(certPath, "private.key")
+}
+
+// GetRelayConfigPath 获取中继节点配置文件的路径
+func GetRelayConfigPath(relayId int, generatedDestination string) string {
+	return filepath.Join(GetRelayPath(relayId, generatedDestination), "relay.yaml")
+}
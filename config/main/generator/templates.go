@@ -0,0 +1,175 @@
+package generator
+
+// chainMakerTemplate 节点配置文件 chainmaker.yml 的模板
+const chainMakerTemplate = `# generated by config generate, node{{.Node.Id}} ({{.Node.Label}}, {{.Node.Role}})
+auth_type: "permissionedWithCert"
+
+log:
+  config_file: {{.LogConfigFile}}
+
+crypto_engine: tjfoc
+
+blockchain:
+  - chainId: {{.ChainId}}
+    genesis: {{.GenesisFile}}
+    # seeds 之中不包含自己并且可能包含只负责接入的节点, 所以需要单独进行配置
+    validators:
+{{- range .Validators}}
+      - "{{.}}"
+{{- end}}
+    legal_users: []
+
+node:
+  org_id: {{.Node.Name}}
+  priv_key_file: {{.SignKeyFile}}
+{{- if .SignCertFile}}
+  cert_file: {{.SignCertFile}}
+{{- end}}
+  cert_cache_size: 1000
+  shutdown_grace_period: 30
+  fast_sync:
+    enabled: true
+  pkcs11:
+    enabled: false
+
+net:
+  provider: LibP2P
+  listen_addr: {{.Node.ListenAddr}}
+  seeds:
+{{- range .Seeds}}
+    - "{{.}}"
+{{- end}}
+  tls:
+    enabled: true
+    priv_key_file: {{.PrivateKeyFile}}
+{{- if .TLSCertFile}}
+    cert_file: {{.TLSCertFile}}
+{{- end}}
+  # fault injection for experiments, can be changed by reload or "client admin faults"
+  faults:
+    enabled: false
+    seed: 0
+    rules: []
+    #  - direction: send
+    #    peers: []
+    #    msg_types: [CONSENSUS_MSG]
+    #    drop_rate: 0.1
+    #    delay: 50ms
+    #    jitter: 20ms
+    partitions: []
+    #  - nodes: [node2]
+    #    start: 10s
+    #    end: 40s
+
+rpc:
+  provider: grpc
+  host: 0.0.0.0
+  port: {{.Node.RPCPort}}
+  request_channel_size: 10
+  # disable, oneway or twoway, twoway verifies client certificates with ca_file
+  tls:
+    mode: disable
+  # set a secret token before enabling, the node refuses to start with an empty token
+  admin:
+    enabled: false
+    token: ""
+  request_pool:
+    queue_size: 100
+    emergency_users: []
+    operator_users: []
+    wal_path: {{.DataPath}}/request_wal
+    result_grace: 300
+  session:
+    ttl: 3600
+  ratelimit:
+    enabled: false
+    type: 0
+    token_per_second: -1
+    token_bucket_size: -1
+  max_send_msg_size: 100
+  max_recv_msg_size: 100
+
+consensus:
+  consensus_type: {{.ConsensusType}}
+  pbft:
+    # one json lines file per chain, checked offline by "decisions check", empty disables it
+    decision_log_path: {{.DataPath}}/decisions
+`
+
+// logTemplate 日志配置文件 log.yml 的模板
+const logTemplate = `log:
+  system:
+    log_level_default: INFO
+    log_levels:
+      core: INFO
+      net: INFO
+      consensus: INFO
+    file_path: {{.LogPath}}/system.log
+    max_age: 365
+    rotation_time: 1
+    log_in_console: false
+    show_color: true
+  brief:
+    log_level_default: INFO
+    file_path: {{.LogPath}}/brief.log
+    max_age: 365
+    rotation_time: 1
+    log_in_console: false
+    show_color: true
+  event:
+    log_level_default: INFO
+    file_path: {{.LogPath}}/event.log
+    max_age: 365
+    rotation_time: 1
+    log_in_console: false
+    show_color: true
+`
+
+// genesisTemplate 创世配置文件 bc1.yml 的模板
+const genesisTemplate = `chain_id: {{.ChainId}}
+version: "2030200"
+sequence: 0
+auth_type: "permissionedWithCert"
+
+crypto:
+  hash: {{.HashName}}
+
+block:
+  tx_timestamp_verify: true
+  tx_timeout: 600
+  block_tx_capacity: 100
+  block_size: 10
+  block_interval: 10
+
+consensus:
+  type: {{.ConsensusType}}
+  nodes:
+{{- range .ValidatorNodes}}
+    - org_id: "{{.Name}}"
+      node_id:
+        - "{{.PeerId}}"
+{{- end}}
+  ext_config:
+{{- if .CACertFile}}
+
+trust_roots:
+{{- range .Nodes}}
+  - org_id: "{{.Name}}"
+    root:
+      - "{{$.CACertFile}}"
+{{- end}}
+{{- end}}
+`
+
+// relayTemplate 中继节点配置文件 relay.yaml 的模板, 由 network/libp2p/relaynode 加载
+const relayTemplate = `# generated by config generate, {{.Relay.Name}} ({{.Relay.Label}})
+net:
+  auth_type: "permissionedWithKey"
+  provider: LibP2P
+  listen_addr: /ip4/{{.Relay.Host}}/tcp/{{.Relay.P2PPort}}
+  # 所有成员都会和中继建立连接
+  max_peer_count_allow: {{.MaxPeerCount}}
+  tls:
+    enabled: true
+    priv_key_file: {{.PrivateKeyFile}}
+`
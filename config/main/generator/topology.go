@@ -0,0 +1,183 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"zhanghefan123/security/config/main/vars"
+)
+
+var (
+	ErrUnknownTopologyNode = errors.New("unknown node in topology")
+	ErrInvalidTopology     = errors.New("invalid topology")
+)
+
+// Topology 拓扑文件, 描述星座的轨道面, 每个轨道面的卫星, 地面站以及中继节点
+type Topology struct {
+	Constellation  ConstellationConfig   `mapstructure:"constellation"`
+	Satellites     []SatelliteConfig     `mapstructure:"satellites"`
+	GroundStations []GroundStationConfig `mapstructure:"ground_stations"`
+	Relays         []RelayConfig         `mapstructure:"relays"`
+}
+
+// ConstellationConfig 星座的配置, 卫星的名称为 sat-<轨道面>-<编号>, 从 0 开始
+type ConstellationConfig struct {
+	Planes             int    `mapstructure:"planes"`               // 轨道面的数量
+	SatellitesPerPlane int    `mapstructure:"satellites_per_plane"` // 每个轨道面的卫星数量
+	InterPlaneLinks    bool   `mapstructure:"inter_plane_links"`    // 相邻轨道面之中相同编号的卫星之间是否有星间链路
+	Role               string `mapstructure:"role"`                 // 卫星的默认角色, 默认为 validator
+}
+
+// SatelliteConfig 单独修改某颗卫星的角色
+type SatelliteConfig struct {
+	Name string `mapstructure:"name"`
+	Role string `mapstructure:"role"`
+}
+
+// GroundStationConfig 地面站, 和 links 之中的卫星建立星地链路
+type GroundStationConfig struct {
+	Name  string   `mapstructure:"name"`
+	Role  string   `mapstructure:"role"` // 默认为 access
+	Links []string `mapstructure:"links"`
+}
+
+// RelayConfig 中继节点, members 之中的节点通过中继互相连接
+type RelayConfig struct {
+	Name    string   `mapstructure:"name"`
+	Members []string `mapstructure:"members"`
+}
+
+// LoadTopology 读取拓扑文件
+func LoadTopology(path string) (*Topology, error) {
+	topologyViper := viper.New()
+	topologyViper.SetConfigFile(path)
+	if err := topologyViper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read topology file %s failed, %w", path, err)
+	}
+	topology := &Topology{}
+	if err := topologyViper.Unmarshal(topology); err != nil {
+		return nil, fmt.Errorf("parse topology file %s failed, %w", path, err)
+	}
+	return topology, nil
+}
+
+// SatelliteName 卫星在拓扑之中的名称
+func SatelliteName(plane, index int) string {
+	return fmt.Sprintf("sat-%d-%d", plane, index)
+}
+
+// Build 按照拓扑创建节点以及中继, 卫星在前地面站在后依次分配编号以及端口, 中继的端口排在所有节点之后
+func (topology *Topology) Build(configParams *vars.ConfigParams) ([]*Node, []*Relay, error) {
+	constellation := topology.Constellation
+	if constellation.Planes < 0 || constellation.SatellitesPerPlane < 0 {
+		return nil, nil, fmt.Errorf("%w: negative planes or satellites per plane", ErrInvalidTopology)
+	}
+	satelliteRole, err := roleOrDefault(constellation.Role, RoleValidator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes := make([]*Node, 0)
+	byLabel := make(map[string]*Node)
+	addNode := func(label, role string) error {
+		if _, ok := byLabel[label]; ok {
+			return fmt.Errorf("%w: duplicated name %s", ErrInvalidTopology, label)
+		}
+		node := newNode(configParams, len(nodes), role)
+		node.Label = label
+		nodes = append(nodes, node)
+		byLabel[label] = node
+		return nil
+	}
+
+	// 1. 卫星以及星间链路, 同一个轨道面之中的卫星组成环, 相邻轨道面相同编号的卫星之间可以有链路
+	grid := make([][]*Node, constellation.Planes)
+	for plane := 0; plane < constellation.Planes; plane++ {
+		for index := 0; index < constellation.SatellitesPerPlane; index++ {
+			if err = addNode(SatelliteName(plane, index), satelliteRole); err != nil {
+				return nil, nil, err
+			}
+			grid[plane] = append(grid[plane], nodes[len(nodes)-1])
+		}
+	}
+	for plane := 0; plane < constellation.Planes; plane++ {
+		for index := 0; index < constellation.SatellitesPerPlane; index++ {
+			link(grid[plane][index], grid[plane][(index+1)%constellation.SatellitesPerPlane])
+			if constellation.InterPlaneLinks && constellation.Planes > 1 {
+				link(grid[plane][index], grid[(plane+1)%constellation.Planes][index])
+			}
+		}
+	}
+	for _, satellite := range topology.Satellites {
+		node, ok := byLabel[satellite.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: satellite %s", ErrUnknownTopologyNode, satellite.Name)
+		}
+		if node.Role, err = roleOrDefault(satellite.Role, satelliteRole); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 2. 地面站以及星地链路
+	for _, station := range topology.GroundStations {
+		role, err := roleOrDefault(station.Role, RoleAccess)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = addNode(station.Name, role); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, station := range topology.GroundStations {
+		for _, name := range station.Links {
+			target, ok := byLabel[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: %s linked by ground station %s", ErrUnknownTopologyNode, name, station.Name)
+			}
+			link(byLabel[station.Name], target)
+		}
+	}
+
+	// 3. 中继节点
+	relays := make([]*Relay, 0, len(topology.Relays))
+	for index, relayConfig := range topology.Relays {
+		relay := &Relay{
+			Id:      index + 1,
+			Label:   relayConfig.Name,
+			Host:    configParams.Host,
+			P2PPort: configParams.StartP2PPort + len(nodes) + index,
+		}
+		for _, name := range relayConfig.Members {
+			member, ok := byLabel[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: %s in relay %s", ErrUnknownTopologyNode, name, relayConfig.Name)
+			}
+			relay.Members = append(relay.Members, member)
+			member.Relays = append(member.Relays, relay)
+		}
+		relays = append(relays, relay)
+	}
+
+	validators := 0
+	for _, node := range nodes {
+		if node.IsValidator() {
+			validators++
+		}
+	}
+	if validators == 0 {
+		return nil, nil, fmt.Errorf("%w: no validator", ErrInvalidTopology)
+	}
+	return nodes, relays, nil
+}
+
+// roleOrDefault 检查角色, 没有配置的时候使用默认角色
+func roleOrDefault(role, defaultRole string) (string, error) {
+	switch role {
+	case "":
+		return defaultRole, nil
+	case RoleValidator, RoleAccess:
+		return role, nil
+	default:
+		return "", fmt.Errorf("%w: unknown role %s", ErrInvalidTopology, role)
+	}
+}
@@ -0,0 +1,191 @@
+package generator
+
+import (
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sort"
+	"testing"
+	"zhanghefan123/security/config/main/vars"
+)
+
+// testConfigParams 节点从 11301 以及 12301 开始分配端口
+func testConfigParams() *vars.ConfigParams {
+	return &vars.ConfigParams{
+		StartP2PPort: 11301,
+		StartRPCPort: 12301,
+		Host:         "127.0.0.1",
+	}
+}
+
+// neighbourLabels 返回节点直接相连的节点的名称
+func neighbourLabels(node *Node) []string {
+	labels := make([]string, 0, len(node.Neighbours))
+	for _, neighbour := range node.Neighbours {
+		labels = append(labels, neighbour.Label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// nodeByLabel 按照拓扑之中的名称查找节点
+func nodeByLabel(t *testing.T, nodes []*Node, label string) *Node {
+	for _, node := range nodes {
+		if node.Label == label {
+			return node
+		}
+	}
+	t.Fatalf("node %s not found", label)
+	return nil
+}
+
+func TestTopologyBuild(t *testing.T) {
+	tests := []struct {
+		name       string
+		topology   *Topology
+		labels     []string            // 按照编号排列的节点名称
+		validators int                 // 验证者的数量
+		neighbours map[string][]string // 节点 -> 直接相连的节点
+		relays     map[string][]string // 中继 -> 成员
+	}{
+		{
+			name:       "single plane ring",
+			topology:   &Topology{Constellation: ConstellationConfig{Planes: 1, SatellitesPerPlane: 3}},
+			labels:     []string{"sat-0-0", "sat-0-1", "sat-0-2"},
+			validators: 3,
+			neighbours: map[string][]string{
+				"sat-0-0": {"sat-0-1", "sat-0-2"},
+				"sat-0-1": {"sat-0-0", "sat-0-2"},
+			},
+		},
+		{
+			name: "inter plane links",
+			topology: &Topology{Constellation: ConstellationConfig{
+				Planes: 3, SatellitesPerPlane: 4, InterPlaneLinks: true,
+			}},
+			labels: []string{
+				"sat-0-0", "sat-0-1", "sat-0-2", "sat-0-3",
+				"sat-1-0", "sat-1-1", "sat-1-2", "sat-1-3",
+				"sat-2-0", "sat-2-1", "sat-2-2", "sat-2-3",
+			},
+			validators: 12,
+			neighbours: map[string][]string{
+				"sat-0-0": {"sat-0-1", "sat-0-3", "sat-1-0", "sat-2-0"},
+				"sat-1-2": {"sat-0-2", "sat-1-1", "sat-1-3", "sat-2-2"},
+			},
+		},
+		{
+			name: "two planes without inter plane links",
+			topology: &Topology{Constellation: ConstellationConfig{
+				Planes: 2, SatellitesPerPlane: 2,
+			}},
+			labels:     []string{"sat-0-0", "sat-0-1", "sat-1-0", "sat-1-1"},
+			validators: 4,
+			neighbours: map[string][]string{
+				"sat-0-0": {"sat-0-1"},
+				"sat-1-1": {"sat-1-0"},
+			},
+		},
+		{
+			name: "roles, ground stations and relays",
+			topology: &Topology{
+				Constellation:  ConstellationConfig{Planes: 1, SatellitesPerPlane: 3, Role: RoleAccess},
+				Satellites:     []SatelliteConfig{{Name: "sat-0-0", Role: RoleValidator}, {Name: "sat-0-1", Role: RoleValidator}},
+				GroundStations: []GroundStationConfig{{Name: "beijing", Links: []string{"sat-0-0"}}, {Name: "kashi", Role: RoleValidator}},
+				Relays:         []RelayConfig{{Name: "leo-relay", Members: []string{"sat-0-2", "beijing", "kashi"}}},
+			},
+			labels:     []string{"sat-0-0", "sat-0-1", "sat-0-2", "beijing", "kashi"},
+			validators: 3,
+			neighbours: map[string][]string{
+				"sat-0-0": {"beijing", "sat-0-1", "sat-0-2"},
+				"beijing": {"sat-0-0"},
+				"kashi":   {},
+			},
+			relays: map[string][]string{
+				"leo-relay": {"sat-0-2", "beijing", "kashi"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configParams := testConfigParams()
+			nodes, relays, err := tt.topology.Build(configParams)
+			require.NoError(t, err)
+
+			labels := make([]string, 0, len(nodes))
+			validators := 0
+			for index, node := range nodes {
+				labels = append(labels, node.Label)
+				// 编号以及端口按照节点的顺序分配
+				require.Equal(t, index+1, node.Id)
+				require.Equal(t, configParams.StartP2PPort+index, node.P2PPort)
+				require.Equal(t, configParams.StartRPCPort+index, node.RPCPort)
+				if node.IsValidator() {
+					validators++
+				}
+			}
+			require.Equal(t, tt.labels, labels)
+			require.Equal(t, tt.validators, validators)
+			for label, expected := range tt.neighbours {
+				sort.Strings(expected)
+				require.Equal(t, expected, neighbourLabels(nodeByLabel(t, nodes, label)), label)
+			}
+
+			require.Len(t, relays, len(tt.relays))
+			for index, relay := range relays {
+				// 中继的端口排在所有节点之后
+				require.Equal(t, index+1, relay.Id)
+				require.Equal(t, configParams.StartP2PPort+len(nodes)+index, relay.P2PPort)
+				members := make([]string, 0, len(relay.Members))
+				for _, member := range relay.Members {
+					members = append(members, member.Label)
+					require.Contains(t, member.Relays, relay)
+				}
+				require.Equal(t, tt.relays[relay.Label], members, relay.Label)
+			}
+		})
+	}
+}
+
+func TestTopologyBuildErrors(t *testing.T) {
+	constellation := ConstellationConfig{Planes: 1, SatellitesPerPlane: 2}
+	tests := []struct {
+		name     string
+		topology *Topology
+		expected error
+	}{
+		{"negative planes", &Topology{Constellation: ConstellationConfig{Planes: -1, SatellitesPerPlane: 2}}, ErrInvalidTopology},
+		{"empty constellation", &Topology{}, ErrInvalidTopology},
+		{"unknown constellation role", &Topology{Constellation: ConstellationConfig{Planes: 1, SatellitesPerPlane: 2, Role: "leader"}}, ErrInvalidTopology},
+		{"no validator", &Topology{Constellation: ConstellationConfig{Planes: 1, SatellitesPerPlane: 2, Role: RoleAccess}}, ErrInvalidTopology},
+		{"unknown satellite", &Topology{Constellation: constellation, Satellites: []SatelliteConfig{{Name: "sat-0-2"}}}, ErrUnknownTopologyNode},
+		{"unknown satellite role", &Topology{Constellation: constellation, Satellites: []SatelliteConfig{{Name: "sat-0-1", Role: "leader"}}}, ErrInvalidTopology},
+		{"duplicated ground station", &Topology{Constellation: constellation, GroundStations: []GroundStationConfig{{Name: "sat-0-0"}}}, ErrInvalidTopology},
+		{"unknown ground station role", &Topology{Constellation: constellation, GroundStations: []GroundStationConfig{{Name: "beijing", Role: "leader"}}}, ErrInvalidTopology},
+		{"unknown link", &Topology{Constellation: constellation, GroundStations: []GroundStationConfig{{Name: "beijing", Links: []string{"sat-1-0"}}}}, ErrUnknownTopologyNode},
+		{"unknown relay member", &Topology{Constellation: constellation, Relays: []RelayConfig{{Name: "relay", Members: []string{"kashi"}}}}, ErrUnknownTopologyNode},
+	}
+	for _, tt := range tests {
+		_, _, err := tt.topology.Build(testConfigParams())
+		require.ErrorIs(t, err, tt.expected, tt.name)
+	}
+}
+
+func TestLoadTopologyExample(t *testing.T) {
+	topology, err := LoadTopology(filepath.Join("..", "topology.example.yml"))
+	require.NoError(t, err)
+	nodes, relays, err := topology.Build(testConfigParams())
+	require.NoError(t, err)
+	// 卫星为 node1 ~ node12, 地面站为 node13 ~ node14, 其中 sat-2-3 以及 gs-beijing 只负责接入
+	require.Len(t, nodes, 14)
+	require.Equal(t, "sat-2-3", nodes[11].Label)
+	require.False(t, nodes[11].IsValidator())
+	require.Equal(t, "gs-beijing", nodes[12].Label)
+	require.False(t, nodes[12].IsValidator())
+	require.True(t, nodes[13].IsValidator())
+	require.Equal(t, []string{"sat-0-0", "sat-1-0"}, neighbourLabels(nodes[12]))
+	require.Len(t, relays, 1)
+	require.Equal(t, 11301+14, relays[0].P2PPort)
+
+	_, err = LoadTopology(filepath.Join("testdata", "missing.yml"))
+	require.Error(t, err)
+}
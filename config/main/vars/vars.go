@@ -17,21 +17,57 @@ const (
 
 	FlagNameOfGeneratedDestination          = "generated-destination"
 	FlagNameShortHandOfGeneratedDestination = "d"
+
+	FlagNameOfHost          = "host"
+	FlagNameShortHandOfHost = "i"
+
+	FlagNameOfKeyType          = "key-type"
+	FlagNameShortHandOfKeyType = "k"
+
+	FlagNameOfEnableCA = "ca"
+
+	FlagNameOfTopologyFile          = "topology"
+	FlagNameShortHandOfTopologyFile = "t"
+
+	FlagNameOfEmit        = "emit"
+	FlagNameOfDockerImage = "docker-image"
+	FlagNameOfNetemDelay  = "netem-delay"
+	FlagNameOfBinaryPath  = "binary-path"
+)
+
+const (
+	EmitDockerCompose = "docker-compose"
+	EmitSystemd       = "systemd"
 )
 
 type ConfigParams struct {
 	NodeNumber           int
 	StartP2PPort         int
 	StartRPCPort         int
-	StartRpcPort         int
 	ConsensusType        int
 	GeneratedDestination string
+	Host                 string   // 节点监听以及互相连接所使用的 ip 地址
+	KeyType              string   // 节点密钥的算法, ECC_NISTP256, SM2 或者 ECC_Ed25519
+	EnableCA             bool     // 是否创建 CA 并为节点签发证书
+	TopologyFile         string   // 拓扑文件, 指定的时候节点的数量以及 seeds 由拓扑决定
+	Emit                 []string // 额外生成的部署文件, docker-compose 或者 systemd
+	DockerImage          string   // docker-compose 之中节点使用的镜像, 镜像的 /usr/local/bin 之中需要有 starter 以及 relaynode
+	NetemDelay           string   // 不为空的时候为每个节点添加 tc netem 容器注入链路时延, 例如 50ms
+	BinaryPath           string   // systemd 之中 starter 以及 relaynode 所在的目录
 }
 
 var ConfigParamsInstance = &ConfigParams{
 	NodeNumber:           4,
 	StartP2PPort:         11301,
 	StartRPCPort:         12301,
-	ConsensusType:        consensus_algorithms.ConsensusType_PBFT,
-	GeneratedDestination: "../../simulation",
+	ConsensusType:        int(consensus_algorithms.ConsensusType_PBFT),
+	GeneratedDestination: "../../simulation/config",
+	Host:                 "127.0.0.1",
+	KeyType:              "ECC_NISTP256",
+	EnableCA:             false,
+	TopologyFile:         "",
+	Emit:                 []string{},
+	DockerImage:          "security-node:latest",
+	NetemDelay:           "",
+	BinaryPath:           "/usr/local/bin",
 }
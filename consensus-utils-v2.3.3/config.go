@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"path"
 	"strconv"
+	"zhanghefan123/security/modules/clock"
+	"zhanghefan123/security/modules/decision_log"
 	"zhanghefan123/security/modules/request_pool"
 
 	"zhanghefan123/security/common/msgbus"
@@ -52,6 +54,10 @@ type ConsensusImplConfig struct {
 	SigAlgoInVote     string
 	CheckVoteInSingle bool
 	RequestPool       *request_pool.RequestPool // zhf add code
+	Clock             clock.Clock               // zhf add code, nil means the system clock
+	DecisionLog       decision_log.Sink         // zhf add code, nil disables the decision log
+	Validators        []string                  // zhf add code, nil means the validators of the chain in localconf
+	LegalUsers        []string                  // zhf add code, nil means the legal users of the chain in localconf
 }
 
 // ValidatorListFunc load validator list by chain config and blockchain store
@@ -263,6 +263,36 @@ func RefreshLogLevelsConfig() error {
 	return nil
 }
 
+// ErrInvalidLogLevel is returned when the log level given is not one of DEBUG, INFO, WARN, ERROR. // zhf add code
+var ErrInvalidLogLevel = errors.New("invalid log level, should be one of DEBUG, INFO, WARN, ERROR")
+
+// SetLogLevel change the level of the loggers of a module at runtime, the default level will be changed
+// if module is empty. // zhf add code
+func SetLogLevel(module string, level string) error {
+	level = strings.ToUpper(level)
+	switch level {
+	case "DEBUG", "INFO", "WARN", "ERROR":
+	default:
+		return ErrInvalidLogLevel
+	}
+	liveMutex.Lock()
+	defer liveMutex.Unlock()
+	logConfig := &ChainMakerConfig.LogConfig
+	if module == "" {
+		logConfig.SystemLog.LogLevelDefault = level
+	} else {
+		// copy on write, loggers created concurrently may still read the old map
+		logLevels := make(map[string]string, len(logConfig.SystemLog.LogLevels)+1)
+		for name, moduleLevel := range logConfig.SystemLog.LogLevels {
+			logLevels[name] = moduleLevel
+		}
+		logLevels[strings.ToLower(module)] = level
+		logConfig.SystemLog.LogLevels = logLevels
+	}
+	logger.RefreshLogConfig(logConfig)
+	return nil
+}
+
 // UpdateDebugConfig refresh the switches of the debug mode.
 func UpdateDebugConfig(pairs []*config.ConfigKeyValue) error {
 	value := reflect.ValueOf(&ChainMakerConfig.DebugConfig)
@@ -0,0 +1,173 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package localconf
+
+import (
+	"github.com/spf13/viper"
+	"reflect"
+	"strings"
+	"sync"
+	"zhanghefan123/security/logger"
+)
+
+// zhf add code
+
+// liveOptions are the options that can be changed without restarting the node. An option matches
+// itself and every option below it, e.g. "net.faults" matches "net.faults.enabled". Only options
+// which the running node reads again belong here, the others are reported as restart required.
+var liveOptions = []string{
+	"net.seeds",
+	"net.blacklist.addresses",
+	"net.blacklist.node_ids",
+	"net.faults",
+	"log.system.log_level_default",
+	"log.system.log_levels",
+	"log.brief.log_level_default",
+	"log.brief.log_levels",
+	"log.event.log_level_default",
+	"log.event.log_levels",
+	"rpc.admin.token",
+	"node.shutdown_grace_period",
+}
+
+// liveMutex guards the live options of ChainMakerConfig, which are written by a reload or SetLogLevel
+// while the node is running. Code reading a live option after startup goes through the accessors below.
+var liveMutex sync.RWMutex
+
+// AdminToken returns the admin credential of the running config.
+func AdminToken() string {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	return ChainMakerConfig.RpcConfig.AdminConfig.Token
+}
+
+// ShutdownGracePeriod returns node.shutdown_grace_period of the running config in seconds.
+func ShutdownGracePeriod() int {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	return ChainMakerConfig.NodeConfig.ShutdownGracePeriod
+}
+
+// ConfigChange is an option whose value in the config file differs from the running config.
+type ConfigChange struct {
+	Key  string // the path of the option in chainmaker.yml, e.g. net.seeds
+	Live bool   // whether the change can be applied without restarting the node
+}
+
+// IsLiveOption returns whether the option with the given key can be changed without restart.
+func IsLiveOption(key string) bool {
+	for _, option := range liveOptions {
+		if key == option || strings.HasPrefix(key, option+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadLocalConfig reads the config file again without touching the running config. The node id,
+// which is derived from the private key at startup, is copied from the running config.
+func LoadLocalConfig() (*CMConfig, error) {
+	cmViper := viper.New()
+	if err := loadConfigFile(cmViper); err != nil {
+		return nil, err
+	}
+	cmConfig := newChainConfigWithDefault()
+	if err := cmViper.Unmarshal(cmConfig); err != nil {
+		return nil, err
+	}
+	cmConfig.Deal()
+	cmConfig.SetNodeId(ChainMakerConfig.NodeConfig.NodeId)
+	return cmConfig, nil
+}
+
+// DiffLocalConfig compares the loaded config with the running config option by option.
+func DiffLocalConfig(running, loaded *CMConfig) []ConfigChange {
+	liveMutex.RLock()
+	defer liveMutex.RUnlock()
+	keys := make([]string, 0)
+	diffValues("", reflect.ValueOf(*running), reflect.ValueOf(*loaded), &keys)
+	changes := make([]ConfigChange, 0, len(keys))
+	for _, key := range keys {
+		changes = append(changes, ConfigChange{Key: key, Live: IsLiveOption(key)})
+	}
+	return changes
+}
+
+// ApplyLiveChanges copies the values of the live changes from the loaded config into the running
+// config and refreshes the log levels. Changes which are not live are ignored. The values are
+// replaced as a whole, so readers holding a slice or map of the old config are not affected.
+func ApplyLiveChanges(loaded *CMConfig, changes []ConfigChange) {
+	liveMutex.Lock()
+	defer liveMutex.Unlock()
+	logChanged := false
+	for _, change := range changes {
+		if !change.Live {
+			continue
+		}
+		copyOption(change.Key, reflect.ValueOf(ChainMakerConfig).Elem(), reflect.ValueOf(loaded).Elem())
+		if strings.HasPrefix(change.Key, "log.") {
+			logChanged = true
+		}
+	}
+	if logChanged {
+		logger.RefreshLogConfig(&ChainMakerConfig.LogConfig)
+	}
+}
+
+// optionName returns the name of a struct field in chainmaker.yml.
+func optionName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+	if tag == "" {
+		return strings.ToLower(field.Name)
+	}
+	return tag
+}
+
+// diffValues records the keys of the options that differ, structs are compared field by field,
+// everything else is compared as a whole.
+func diffValues(prefix string, running, loaded reflect.Value, keys *[]string) {
+	if running.Kind() != reflect.Struct {
+		if !reflect.DeepEqual(running.Interface(), loaded.Interface()) {
+			*keys = append(*keys, prefix)
+		}
+		return
+	}
+	for i := 0; i < running.NumField(); i++ {
+		field := running.Type().Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		key := optionName(field)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		diffValues(key, running.Field(i), loaded.Field(i), keys)
+	}
+}
+
+// copyOption copies the option with the given key from the loaded config into the running config.
+func copyOption(key string, running, loaded reflect.Value) {
+	for _, name := range strings.Split(key, ".") {
+		if running.Kind() != reflect.Struct {
+			return
+		}
+		found := false
+		for i := 0; i < running.NumField(); i++ {
+			field := running.Type().Field(i)
+			if field.PkgPath == "" && optionName(field) == name {
+				running, loaded = running.Field(i), loaded.Field(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return
+		}
+	}
+	running.Set(loaded)
+}
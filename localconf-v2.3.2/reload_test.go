@@ -0,0 +1,81 @@
+/*
+Copyright (C) BABEC. All rights reserved.
+Copyright (C) THL A29 Limited, a Tencent company. All rights reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package localconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLocalConfig(t *testing.T) {
+	running := newChainConfigWithDefault()
+	running.NetConfig.ListenAddr = "/ip4/0.0.0.0/tcp/11301"
+	running.NetConfig.Seeds = []string{"/ip4/127.0.0.1/tcp/11301/p2p/QmNode1"}
+	running.RpcConfig.AdminConfig.Token = "old"
+
+	loaded := newChainConfigWithDefault()
+	loaded.NetConfig.ListenAddr = "/ip4/0.0.0.0/tcp/11302"
+	loaded.NetConfig.Seeds = []string{"/ip4/127.0.0.1/tcp/11301/p2p/QmNode1", "/ip4/127.0.0.1/tcp/11302/p2p/QmNode2"}
+	loaded.NetConfig.BlackList.NodeIds = []string{"QmBad"}
+	loaded.RpcConfig.AdminConfig.Token = "new"
+	loaded.RpcConfig.RateLimitConfig.TokenPerSecond = 100
+
+	changes := DiffLocalConfig(running, loaded)
+	assert.ElementsMatch(t, []ConfigChange{
+		{Key: "net.listen_addr", Live: false},
+		{Key: "net.seeds", Live: true},
+		{Key: "net.blacklist.node_ids", Live: true},
+		{Key: "rpc.ratelimit.token_per_second", Live: false},
+		{Key: "rpc.admin.token", Live: true},
+	}, changes)
+}
+
+func TestApplyLiveChanges(t *testing.T) {
+	origin := ChainMakerConfig
+	defer func() { ChainMakerConfig = origin }()
+
+	ChainMakerConfig = newChainConfigWithDefault()
+	ChainMakerConfig.NetConfig.ListenAddr = "/ip4/0.0.0.0/tcp/11301"
+	loaded := newChainConfigWithDefault()
+	loaded.NetConfig.ListenAddr = "/ip4/0.0.0.0/tcp/11302"
+	loaded.NetConfig.BlackList.Addresses = []string{"127.0.0.1:11305"}
+	loaded.NodeConfig.ShutdownGracePeriod = 10
+
+	ApplyLiveChanges(loaded, DiffLocalConfig(ChainMakerConfig, loaded))
+	// options which need a restart keep their running values
+	assert.Equal(t, "/ip4/0.0.0.0/tcp/11301", ChainMakerConfig.NetConfig.ListenAddr)
+	assert.Equal(t, []string{"127.0.0.1:11305"}, ChainMakerConfig.NetConfig.BlackList.Addresses)
+	assert.Equal(t, 10, ChainMakerConfig.NodeConfig.ShutdownGracePeriod)
+	assert.Equal(t, []ConfigChange{{Key: "net.listen_addr", Live: false}}, DiffLocalConfig(ChainMakerConfig, loaded))
+}
+
+func TestApplyLiveChangesWhileReading(t *testing.T) {
+	origin := ChainMakerConfig
+	defer func() { ChainMakerConfig = origin }()
+
+	ChainMakerConfig = newChainConfigWithDefault()
+	ChainMakerConfig.RpcConfig.AdminConfig.Token = "old"
+	loaded := newChainConfigWithDefault()
+	loaded.RpcConfig.AdminConfig.Token = "new"
+	loaded.NodeConfig.ShutdownGracePeriod = 10
+
+	// the accessors are read on every admin call and on shutdown, run with -race to check them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			_ = AdminToken()
+			_ = ShutdownGracePeriod()
+		}
+	}()
+	ApplyLiveChanges(loaded, DiffLocalConfig(ChainMakerConfig, loaded))
+	<-done
+	assert.Equal(t, "new", AdminToken())
+	assert.Equal(t, 10, ShutdownGracePeriod())
+}
@@ -8,6 +8,7 @@ SPDX-License-Identifier: Apache-2.0
 package localconf
 
 import (
+	"errors"
 	"fmt"
 	"time"
 	"zhanghefan123/security/common/crypto/pkcs11"
@@ -16,20 +17,21 @@ import (
 )
 
 type nodeConfig struct {
-	Type              string         `mapstructure:"type"`
-	CertFile          string         `mapstructure:"cert_file"`
-	PrivKeyFile       string         `mapstructure:"priv_key_file"`
-	CertEncFile       string         `mapstructure:"cert_enc_file"`
-	PrivEncKeyFile    string         `mapstructure:"priv_enc_key_file"`
-	PrivKeyPassword   string         `mapstructure:"priv_key_password"`
-	AuthType          string         `mapstructure:"auth_type"`
-	P11Config         pkcs11Config   `mapstructure:"pkcs11"`
-	NodeId            string         `mapstructure:"node_id"`
-	OrgId             string         `mapstructure:"org_id"`
-	SignerCacheSize   int            `mapstructure:"signer_cache_size"`
-	CertCacheSize     int            `mapstructure:"cert_cache_size"`
-	CertKeyUsageCheck bool           `mapstructure:"cert_key_usage_check"`
-	FastSyncConfig    fastSyncConfig `mapstructure:"fast_sync"`
+	Type                string         `mapstructure:"type"`
+	CertFile            string         `mapstructure:"cert_file"`
+	PrivKeyFile         string         `mapstructure:"priv_key_file"`
+	CertEncFile         string         `mapstructure:"cert_enc_file"`
+	PrivEncKeyFile      string         `mapstructure:"priv_enc_key_file"`
+	PrivKeyPassword     string         `mapstructure:"priv_key_password"`
+	AuthType            string         `mapstructure:"auth_type"`
+	P11Config           pkcs11Config   `mapstructure:"pkcs11"`
+	NodeId              string         `mapstructure:"node_id"`
+	OrgId               string         `mapstructure:"org_id"`
+	SignerCacheSize     int            `mapstructure:"signer_cache_size"`
+	CertCacheSize       int            `mapstructure:"cert_cache_size"`
+	CertKeyUsageCheck   bool           `mapstructure:"cert_key_usage_check"`
+	FastSyncConfig      fastSyncConfig `mapstructure:"fast_sync"`
+	ShutdownGracePeriod int            `mapstructure:"shutdown_grace_period"` // zhf add code, seconds to drain in-flight requests on shutdown
 }
 
 type netConfig struct {
@@ -45,6 +47,7 @@ type netConfig struct {
 	StunClient              stunClient        `mapstructure:"stun_client"`
 	StunServer              stunServer        `mapstructure:"stun_server"`
 	EnablePunch             bool              `mapstructure:"enable_punch"`
+	Faults                  FaultsConfig      `mapstructure:"faults"` // zhf add code
 }
 
 type netTlsConfig struct {
@@ -70,6 +73,36 @@ type blackList struct {
 	NodeIds   []string `mapstructure:"node_ids"`
 }
 
+// FaultsConfig configures the fault injection filter of the net service, for experiments only. zhf add code
+type FaultsConfig struct {
+	Enabled    bool              `mapstructure:"enabled"`
+	Seed       int64             `mapstructure:"seed"` // seed of the random decisions, 0 means seeded by time
+	Rules      []FaultRuleConfig `mapstructure:"rules"`
+	Partitions []PartitionConfig `mapstructure:"partitions"`
+}
+
+// FaultRuleConfig applies faults to the messages matching the direction, peers and message types,
+// empty peers or message types match everything. zhf add code
+type FaultRuleConfig struct {
+	Direction     string        `mapstructure:"direction"` // send, recv or both (default)
+	Peers         []string      `mapstructure:"peers"`
+	MsgTypes      []string      `mapstructure:"msg_types"` // names of NetMsg_MsgType, e.g. CONSENSUS_MSG
+	DropRate      float64       `mapstructure:"drop_rate"`
+	Delay         time.Duration `mapstructure:"delay"`
+	Jitter        time.Duration `mapstructure:"jitter"`
+	DuplicateRate float64       `mapstructure:"duplicate_rate"`
+	ReorderRate   float64       `mapstructure:"reorder_rate"`
+	ReorderWindow time.Duration `mapstructure:"reorder_window"`
+}
+
+// PartitionConfig isolates the nodes from the rest of the network between start and end, which are
+// measured from the start of the net service, an end of 0 means until the node stops. zhf add code
+type PartitionConfig struct {
+	Nodes []string      `mapstructure:"nodes"`
+	Start time.Duration `mapstructure:"start"`
+	End   time.Duration `mapstructure:"end"`
+}
+
 type chainTrustRoots struct {
 	ChainId    string       `mapstructure:"chain_id"`
 	TrustRoots []trustRoots `mapstructure:"trust_roots"`
@@ -101,18 +134,70 @@ type stunServer struct {
 }
 
 type rpcConfig struct {
-	Provider                               string           `mapstructure:"provider"`
-	Host                                   string           `mapstructure:"host"`
-	Port                                   int              `mapstructure:"port"`
-	TLSConfig                              tlsConfig        `mapstructure:"tls"`
-	BlackList                              blackList        `mapstructure:"blacklist"`
-	RateLimitConfig                        rateLimitConfig  `mapstructure:"ratelimit"`
-	SubscriberConfig                       subscriberConfig `mapstructure:"subscriber"`
-	GatewayConfig                          gatewayConfig    `mapstructure:"gateway"`
-	CheckChainConfTrustRootsChangeInterval int              `mapstructure:"check_chain_conf_trust_roots_change_interval"`
-	MaxSendMsgSize                         int              `mapstructure:"max_send_msg_size"`
-	MaxRecvMsgSize                         int              `mapstructure:"max_recv_msg_size"`
-	RequestChannelSize                     int              `mapstructure:"request_channel_size"` // zhf add code
+	Provider                               string            `mapstructure:"provider"`
+	Host                                   string            `mapstructure:"host"`
+	Port                                   int               `mapstructure:"port"`
+	TLSConfig                              tlsConfig         `mapstructure:"tls"`
+	BlackList                              blackList         `mapstructure:"blacklist"`
+	RateLimitConfig                        rateLimitConfig   `mapstructure:"ratelimit"`
+	SubscriberConfig                       subscriberConfig  `mapstructure:"subscriber"`
+	GatewayConfig                          gatewayConfig     `mapstructure:"gateway"`
+	CheckChainConfTrustRootsChangeInterval int               `mapstructure:"check_chain_conf_trust_roots_change_interval"`
+	MaxSendMsgSize                         int               `mapstructure:"max_send_msg_size"`
+	MaxRecvMsgSize                         int               `mapstructure:"max_recv_msg_size"`
+	RequestChannelSize                     int               `mapstructure:"request_channel_size"` // zhf add code
+	AdminConfig                            adminConfig       `mapstructure:"admin"`                // zhf add code
+	RequestPoolConfig                      requestPoolConfig `mapstructure:"request_pool"`         // zhf add code
+	SessionConfig                          sessionConfig     `mapstructure:"session"`              // zhf add code
+}
+
+// zhf add code
+type requestPoolConfig struct {
+	QueueSize      int      `mapstructure:"queue_size"`      // max length of each priority queue, falls back to request_channel_size
+	EmergencyUsers []string `mapstructure:"emergency_users"` // users whose requests are served first
+	OperatorUsers  []string `mapstructure:"operator_users"`  // users served before normal users
+	WalPath        string   `mapstructure:"wal_path"`        // directory of the request journal, empty disables persistence
+	ResultGrace    int      `mapstructure:"result_grace"`    // seconds a result is kept for reconnecting clients, default 300
+}
+
+// zhf add code
+type sessionConfig struct {
+	TTL int `mapstructure:"ttl"` // seconds a session issued to an authenticated user stays valid, default 3600
+}
+
+// zhf add code
+type adminConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // whether to register the admin service
+	Token   string `mapstructure:"token"`   // the admin credential, carried in the "admin-token" metadata
+}
+
+// zhf add code
+// PlaceholderAdminToken is the token of older example configs, it is never accepted as a credential.
+const PlaceholderAdminToken = "change-me-admin-token"
+
+// zhf add code
+var (
+	ErrEmptyAdminToken       = errors.New("admin service enabled but rpc.admin.token is empty")
+	ErrPlaceholderAdminToken = errors.New("rpc.admin.token is the example placeholder, set a secret token")
+)
+
+// ValidateAdminToken returns an error if the token can not be used as the admin credential. zhf add code
+func ValidateAdminToken(token string) error {
+	switch token {
+	case "":
+		return ErrEmptyAdminToken
+	case PlaceholderAdminToken:
+		return ErrPlaceholderAdminToken
+	}
+	return nil
+}
+
+// Validate returns an error if the admin service is enabled without a usable token. zhf add code
+func (c adminConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	return ValidateAdminToken(c.Token)
 }
 
 type tlsConfig struct {
@@ -123,6 +208,7 @@ type tlsConfig struct {
 	CertEncFile           string `mapstructure:"cert_enc_file"`
 	TestClientPrivKeyFile string `mapstructure:"test_client_priv_key_file"`
 	TestClientCertFile    string `mapstructure:"test_client_cert_file"`
+	CAFile                string `mapstructure:"ca_file"` // zhf add code, ca used to verify client certificates in twoway mode
 }
 
 type rateLimitConfig struct {
@@ -192,6 +278,11 @@ type debugConfig struct {
 type BlockchainConfig struct {
 	ChainId string
 	Genesis string
+	// zhf add code for multiple authentication domains
+	// Validators are the node ids of the validators of this chain, derived from net.seeds when empty.
+	Validators []string `mapstructure:"validators"`
+	// LegalUsers are the users registered in the authentication domain of this chain.
+	LegalUsers []string `mapstructure:"legal_users"`
 }
 
 //type txPoolConfig struct {
@@ -250,6 +341,8 @@ type tbftConfig struct {
 
 // zhf add code
 type pbftConfig struct {
+	// DecisionLogPath is the directory of the decision logs, one json lines file per chain, empty disables them.
+	DecisionLogPath string `mapstructure:"decision_log_path"`
 }
 
 type ConsensusConfig struct {
@@ -398,6 +491,16 @@ func (c *CMConfig) GetBlockChains() []BlockchainConfig {
 	return c.BlockChainConfig
 }
 
+// GetBlockChain - get the config of the blockchain with the given chain id. zhf add code
+func (c *CMConfig) GetBlockChain(chainId string) (BlockchainConfig, bool) {
+	for _, chainConfig := range c.BlockChainConfig {
+		if chainConfig.ChainId == chainId {
+			return chainConfig, true
+		}
+	}
+	return BlockchainConfig{}, false
+}
+
 func (c *CMConfig) GetStorePath() string {
 	if path, ok := c.StorageConfig["store_path"]; ok {
 		return path.(string)
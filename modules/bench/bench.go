@@ -0,0 +1,210 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"sync"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// Sample 一次认证请求的结果
+type Sample struct {
+	Start   time.Duration // 请求相对于压测开始的时间, 开环模式下为计划到达的时间, 排队等待的时间计入延迟
+	Latency time.Duration // 从 Start 到收到结果的时间
+	Target  string        // 处理请求的节点
+	UserId  string
+	Result  pb.AuthenticationResult
+	Err     error
+}
+
+// TimedOut 判断请求是否超时: 共识超时或者调用超过了 Config.Timeout
+func (s Sample) TimedOut() bool {
+	if s.Err == nil {
+		return s.Result == pb.AuthenticationResult_ConsensusTimeout
+	}
+	return errors.Is(s.Err, context.DeadlineExceeded) || status.Code(s.Err) == codes.DeadlineExceeded
+}
+
+// Succeeded 判断请求是否得到了共识的判断, 合法用户以及非法用户都算成功
+func (s Sample) Succeeded() bool {
+	return s.Err == nil && s.Result != pb.AuthenticationResult_ConsensusTimeout
+}
+
+// job 分配给某个节点的一次请求
+type job struct {
+	target    int
+	userId    string
+	scheduled time.Time // 开环模式下计划到达的时间, 闭环模式下为空, 使用真正发起请求的时间
+}
+
+// runner 一次压测的状态
+type runner struct {
+	config  Config
+	targets []Target
+	start   time.Time
+	mutex   sync.Mutex
+	samples []Sample
+}
+
+// Run 按照配置对 targets 进行压测, 直到 Duration 到期, 发起了 Requests 个请求或者 ctx 被取消,
+// 之后等待所有已经发起的请求结束并生成报告
+func Run(ctx context.Context, config Config, targets []Target) (*Report, error) {
+	if len(targets) == 0 {
+		return nil, ErrNoTarget
+	}
+	config = config.withDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	before := countMessages(config.Timeout, targets)
+
+	r := &runner{config: config, targets: targets, start: time.Now()}
+	// stop 只控制是否继续发起新的请求, 已经发起的请求使用 ctx, 压测时长到期之后仍然等待它们结束
+	stop, cancel := ctx, context.CancelFunc(func() {})
+	if config.Duration > 0 {
+		stop, cancel = context.WithTimeout(ctx, config.Duration)
+	}
+	defer cancel()
+	var skipped int
+	if config.Rate > 0 {
+		skipped = r.openLoop(ctx, stop)
+	} else {
+		r.closedLoop(ctx, stop)
+	}
+	elapsed := time.Since(r.start)
+
+	after := countMessages(config.Timeout, targets)
+	return newReport(config, targets, r.samples, elapsed, skipped, before, after), nil
+}
+
+// dispatch 依次生成请求, 直到 stop 结束或者发起了 Requests 个请求, 请求按照轮询的方式分配给各个节点,
+// next 返回 false 表示停止
+func (r *runner) dispatch(stop context.Context, next func(j job) bool) {
+	rng := rand.New(rand.NewSource(r.config.Seed))
+	// 开环模式下计划的下一次到达时间
+	arrival := r.start
+	for i := 0; r.config.Requests <= 0 || i < r.config.Requests; i++ {
+		j := job{target: i % len(r.targets), userId: r.config.Users.Next(rng)}
+		if r.config.Rate > 0 {
+			j.scheduled = arrival
+			arrival = arrival.Add(r.interval(rng))
+			wait := time.NewTimer(time.Until(j.scheduled))
+			select {
+			case <-wait.C:
+			case <-stop.Done():
+				wait.Stop()
+				return
+			}
+		}
+		if stop.Err() != nil || !next(j) {
+			return
+		}
+	}
+}
+
+// interval 开环模式下两次到达之间的间隔
+func (r *runner) interval(rng *rand.Rand) time.Duration {
+	mean := float64(time.Second) / r.config.Rate
+	if r.config.Arrival == ArrivalPoisson {
+		return time.Duration(rng.ExpFloat64() * mean)
+	}
+	return time.Duration(mean)
+}
+
+// closedLoop Concurrency 个协程各自在上一个请求结束之后立即发起下一个请求
+func (r *runner) closedLoop(ctx, stop context.Context) {
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for i := 0; i < r.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				r.execute(ctx, j)
+			}
+		}()
+	}
+	r.dispatch(stop, func(j job) bool {
+		select {
+		case jobs <- j:
+			return true
+		case <-stop.Done():
+			return false
+		}
+	})
+	close(jobs)
+	wg.Wait()
+}
+
+// openLoop 请求按照 Rate 到达, 和之前的请求是否结束无关, 同时进行的请求达到 Concurrency 的时候新到达的请求被跳过,
+// 返回被跳过的请求的数量
+func (r *runner) openLoop(ctx, stop context.Context) int {
+	inflight := make(chan struct{}, r.config.Concurrency)
+	skipped := 0
+	var wg sync.WaitGroup
+	r.dispatch(stop, func(j job) bool {
+		select {
+		case inflight <- struct{}{}:
+		default:
+			skipped++
+			return true
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-inflight
+				wg.Done()
+			}()
+			r.execute(ctx, j)
+		}()
+		return true
+	})
+	wg.Wait()
+	return skipped
+}
+
+// execute 发起一次请求并记录结果
+func (r *runner) execute(ctx context.Context, j job) {
+	if j.scheduled.IsZero() {
+		j.scheduled = time.Now()
+	}
+	target := r.targets[j.target]
+	callCtx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	result, err := target.Authenticate(callCtx, j.userId)
+	cancel()
+	sample := Sample{
+		Start:   j.scheduled.Sub(r.start),
+		Latency: time.Since(j.scheduled),
+		Target:  target.Name,
+		UserId:  j.userId,
+		Result:  result,
+		Err:     err,
+	}
+	r.mutex.Lock()
+	r.samples = append(r.samples, sample)
+	r.mutex.Unlock()
+}
+
+// messageCounts 某个节点的消息数量快照
+type messageCounts struct {
+	counts map[string]uint64
+	err    error
+}
+
+// countMessages 获取每个节点的消息数量, 不支持统计的节点为空, 压测被取消之后仍然获取
+func countMessages(timeout time.Duration, targets []Target) []messageCounts {
+	snapshots := make([]messageCounts, len(targets))
+	for i, target := range targets {
+		if target.CountMessages == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		snapshots[i].counts, snapshots[i].err = target.CountMessages(ctx)
+		cancel()
+	}
+	return snapshots
+}
@@ -0,0 +1,223 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// gauge 记录同时进行的请求数量的峰值
+type gauge struct {
+	inflight int32
+	peak     int32
+}
+
+// enter 开始一个请求, 返回结束请求的函数
+func (g *gauge) enter() func() {
+	current := atomic.AddInt32(&g.inflight, 1)
+	for {
+		peak := atomic.LoadInt32(&g.peak)
+		if current <= peak || atomic.CompareAndSwapInt32(&g.peak, peak, current) {
+			break
+		}
+	}
+	return func() { atomic.AddInt32(&g.inflight, -1) }
+}
+
+// fakeNode 模拟一个节点, 用户 id 以 bad 开头的为非法用户, 以 slow 开头的请求一直等待到超时
+type fakeNode struct {
+	latency  time.Duration
+	gauge    *gauge
+	mutex    sync.Mutex
+	messages uint64
+}
+
+func newFakeNode(latency time.Duration, g *gauge) *fakeNode {
+	return &fakeNode{latency: latency, gauge: g}
+}
+
+func (n *fakeNode) target(name string) Target {
+	return Target{
+		Name: name,
+		Authenticate: func(ctx context.Context, userId string) (pb.AuthenticationResult, error) {
+			defer n.gauge.enter()()
+			n.mutex.Lock()
+			n.messages += 3
+			n.mutex.Unlock()
+			if strings.HasPrefix(userId, "slow") {
+				<-ctx.Done()
+				return pb.AuthenticationResult_LegalUser, ctx.Err()
+			}
+			time.Sleep(n.latency)
+			if strings.HasPrefix(userId, "bad") {
+				return pb.AuthenticationResult_IllegalUser, nil
+			}
+			return pb.AuthenticationResult_LegalUser, nil
+		},
+		CountMessages: func(ctx context.Context) (map[string]uint64, error) {
+			n.mutex.Lock()
+			defer n.mutex.Unlock()
+			return map[string]uint64{"send/CONSENSUS_MSG": n.messages, "recv/CONSENSUS_MSG": 2 * n.messages}, nil
+		},
+	}
+}
+
+func TestRunClosedLoop(t *testing.T) {
+	g := &gauge{}
+	node1, node2 := newFakeNode(time.Millisecond, g), newFakeNode(time.Millisecond, g)
+	users, err := NewUserPicker(DistributionSequential, []string{"alice", "bad-bob"}, 0)
+	require.NoError(t, err)
+	report, err := Run(context.Background(), Config{
+		Concurrency: 4,
+		Requests:    40,
+		Users:       users,
+		Seed:        1,
+	}, []Target{node1.target("node1"), node2.target("node2")})
+	require.NoError(t, err)
+
+	require.Equal(t, 40, report.Total.Requests)
+	require.Equal(t, 40, report.Total.Succeeded)
+	require.Equal(t, map[string]int{"LegalUser": 20, "IllegalUser": 20}, report.Total.Results)
+	require.LessOrEqual(t, g.peak, int32(4))
+	require.Len(t, report.Nodes, 2)
+	for _, node := range report.Nodes {
+		require.Equal(t, 20, node.Requests)
+		require.Equal(t, uint64(60), node.MsgSent)
+		require.Equal(t, uint64(120), node.MsgReceived)
+	}
+	require.Equal(t, uint64(120), report.Total.MsgSent)
+	require.GreaterOrEqual(t, report.Total.Latency.P50, 1.0)
+	require.LessOrEqual(t, report.Total.Latency.P50, report.Total.Latency.P99)
+	require.Len(t, report.Samples, 40)
+}
+
+func TestRunTimeouts(t *testing.T) {
+	node := newFakeNode(0, &gauge{})
+	users, err := NewUserPicker(DistributionSequential, []string{"alice", "slow-carol"}, 0)
+	require.NoError(t, err)
+	report, err := Run(context.Background(), Config{
+		Concurrency: 2,
+		Requests:    4,
+		Timeout:     20 * time.Millisecond,
+		Users:       users,
+	}, []Target{node.target("node1")})
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Total.Timeouts)
+	require.Equal(t, 0, report.Total.Errors)
+	require.Equal(t, 0.5, report.Total.TimeoutRate)
+}
+
+func TestRunOpenLoop(t *testing.T) {
+	g := &gauge{}
+	node := newFakeNode(50*time.Millisecond, g)
+	users, err := NewUserPicker(DistributionUnique, nil, 0)
+	require.NoError(t, err)
+	report, err := Run(context.Background(), Config{
+		Concurrency: 2,
+		Rate:        200,
+		Duration:    100 * time.Millisecond,
+		Users:       users,
+	}, []Target{node.target("node1")})
+	require.NoError(t, err)
+	// 请求的到达和结束无关, 同时进行的请求达到上限之后新到达的请求被跳过
+	require.Greater(t, report.Skipped, 0)
+	require.LessOrEqual(t, g.peak, int32(2))
+	require.Equal(t, report.Total.Requests, report.Total.Succeeded)
+	require.Equal(t, ArrivalConstant, report.Arrival)
+}
+
+func TestRunValidate(t *testing.T) {
+	users, err := NewUserPicker(DistributionUniform, []string{"alice"}, 0)
+	require.NoError(t, err)
+	_, err = Run(context.Background(), Config{Requests: 1, Users: users}, nil)
+	require.True(t, errors.Is(err, ErrNoTarget))
+	node := newFakeNode(0, &gauge{})
+	_, err = Run(context.Background(), Config{Users: users}, []Target{node.target("node1")})
+	require.True(t, errors.Is(err, ErrUnboundedBench))
+	_, err = Run(context.Background(), Config{Requests: 1, Rate: 1, Arrival: "burst", Users: users},
+		[]Target{node.target("node1")})
+	require.Error(t, err)
+}
+
+func TestUserPickers(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	users := GenerateUsers("user", 10)
+	require.Equal(t, "user1", users[0])
+	require.Equal(t, "user10", users[9])
+
+	zipf, err := NewUserPicker(DistributionZipf, users, 1.5)
+	require.NoError(t, err)
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[zipf.Next(rng)]++
+	}
+	require.Greater(t, counts["user1"], counts["user2"])
+	require.Greater(t, counts["user1"], 300)
+
+	unique, err := NewUserPicker(DistributionUnique, []string{"u-"}, 0)
+	require.NoError(t, err)
+	require.Equal(t, "u-1", unique.Next(rng))
+	require.Equal(t, "u-2", unique.Next(rng))
+
+	_, err = NewUserPicker(DistributionZipf, users, 1)
+	require.Error(t, err)
+	_, err = NewUserPicker(DistributionUniform, nil, 0)
+	require.True(t, errors.Is(err, ErrNoUsers))
+	_, err = NewUserPicker("normal", users, 0)
+	require.Error(t, err)
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	summary := summarizeLatency(latencies)
+	require.Equal(t, LatencySummary{Min: 1, Mean: 50.5, P50: 50, P95: 95, P99: 99, Max: 100}, summary)
+}
+
+func TestReportWriters(t *testing.T) {
+	node := newFakeNode(0, &gauge{})
+	users, err := NewUserPicker(DistributionSequential, []string{"alice"}, 0)
+	require.NoError(t, err)
+	report, err := Run(context.Background(), Config{Concurrency: 1, Requests: 3, Users: users},
+		[]Target{node.target("node1")})
+	require.NoError(t, err)
+
+	var buffer bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buffer))
+	records, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, csvHeader, records[0])
+	require.Equal(t, "node1", records[1][0])
+	require.Equal(t, AllNodes, records[2][0])
+
+	buffer.Reset()
+	require.NoError(t, report.WriteSamplesCSV(&buffer))
+	records, err = csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.Equal(t, "LegalUser", records[1][4])
+
+	buffer.Reset()
+	require.NoError(t, report.WriteJSON(&buffer))
+	decoded := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+	require.Contains(t, decoded, "total")
+	require.Contains(t, decoded, "nodes")
+
+	buffer.Reset()
+	require.NoError(t, report.WriteText(&buffer))
+	require.Contains(t, buffer.String(), "closed loop")
+}
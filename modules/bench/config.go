@@ -0,0 +1,178 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+const (
+	DefaultConcurrency = 8                // 默认的并发数量
+	DefaultDuration    = 30 * time.Second // 默认的压测时长
+	DefaultTimeout     = 60 * time.Second // 默认的单个请求的超时时间
+
+	ArrivalConstant = "constant" // 开环模式下固定间隔到达
+	ArrivalPoisson  = "poisson"  // 开环模式下按照泊松过程到达
+
+	DistributionUniform    = "uniform"    // 从用户列表之中均匀选择
+	DistributionZipf       = "zipf"       // 从用户列表之中按照 zipf 分布选择, 少数用户占大多数请求
+	DistributionSequential = "sequential" // 按照顺序轮流选择用户列表之中的用户
+	DistributionUnique     = "unique"     // 每个请求使用一个新的用户
+)
+
+var (
+	ErrNoTarget       = errors.New("no target to benchmark")
+	ErrNoUsers        = errors.New("user distribution needs at least one user")
+	ErrUnboundedBench = errors.New("either duration or requests must be set")
+)
+
+// AuthenticateFunc 提交一次认证请求并等待结果
+type AuthenticateFunc func(ctx context.Context, userId string) (pb.AuthenticationResult, error)
+
+// CountMessagesFunc 获取节点从启动到现在收发的消息数量, 键为 方向/消息类型, 例如 send/CONSENSUS_MSG
+type CountMessagesFunc func(ctx context.Context) (map[string]uint64, error)
+
+// Target 被压测的一个节点, 请求按照轮询的方式分配给各个节点
+type Target struct {
+	Name          string
+	Authenticate  AuthenticateFunc
+	CountMessages CountMessagesFunc // 为空的时候不统计节点的消息数量
+}
+
+// Config 压测的配置
+type Config struct {
+	Concurrency int           // 最多同时进行的请求数量
+	Rate        float64       // 开环模式下每秒到达的请求数量, 为 0 表示闭环模式: 每个并发在上一个请求结束之后立即发起下一个
+	Arrival     string        // 开环模式下的到达过程, constant 或者 poisson
+	Duration    time.Duration // 发起请求的时长, 为 0 的时候只受 Requests 限制
+	Requests    int           // 最多发起的请求数量, 为 0 的时候只受 Duration 限制
+	Timeout     time.Duration // 单个请求的超时时间
+	Seed        int64         // 随机数种子, 决定用户的选择以及泊松到达的间隔, 为 0 的时候使用当前时间
+	Users       UserPicker    // 用户的分布
+}
+
+// withDefaults 填充没有设置的配置项
+func (config Config) withDefaults() Config {
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	if config.Arrival == "" {
+		config.Arrival = ArrivalConstant
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	return config
+}
+
+// validate 检查配置是否合法
+func (config Config) validate() error {
+	if config.Duration <= 0 && config.Requests <= 0 {
+		return ErrUnboundedBench
+	}
+	if config.Rate < 0 {
+		return fmt.Errorf("invalid arrival rate %v", config.Rate)
+	}
+	if config.Arrival != ArrivalConstant && config.Arrival != ArrivalPoisson {
+		return fmt.Errorf("unknown arrival process %q, expect %s or %s", config.Arrival, ArrivalConstant, ArrivalPoisson)
+	}
+	if config.Users == nil {
+		return ErrNoUsers
+	}
+	return nil
+}
+
+// UserPicker 为每个请求选择用户, 只在一个协程之中调用
+type UserPicker interface {
+	Next(rng *rand.Rand) string
+}
+
+// NewUserPicker 根据分布的名称创建 UserPicker, users 为用户列表, zipfS 为 zipf 分布的参数 (大于 1)
+func NewUserPicker(distribution string, users []string, zipfS float64) (UserPicker, error) {
+	if distribution == DistributionUnique {
+		prefix := "bench-user-"
+		if len(users) > 0 {
+			prefix = users[0]
+		}
+		return &uniqueUsers{prefix: prefix}, nil
+	}
+	if len(users) == 0 {
+		return nil, ErrNoUsers
+	}
+	switch distribution {
+	case DistributionUniform, "":
+		return uniformUsers(users), nil
+	case DistributionSequential:
+		return &sequentialUsers{users: users}, nil
+	case DistributionZipf:
+		if zipfS <= 1 {
+			return nil, fmt.Errorf("zipf parameter must be greater than 1, got %v", zipfS)
+		}
+		return &zipfUsers{users: users, s: zipfS}, nil
+	default:
+		return nil, fmt.Errorf("unknown user distribution %q", distribution)
+	}
+}
+
+// GenerateUsers 生成 n 个用户 id: prefix1 ... prefixN
+func GenerateUsers(prefix string, n int) []string {
+	users := make([]string, n)
+	for i := range users {
+		users[i] = fmt.Sprintf("%s%d", prefix, i+1)
+	}
+	return users
+}
+
+// uniformUsers 均匀选择
+type uniformUsers []string
+
+// Next 均匀选择一个用户
+func (users uniformUsers) Next(rng *rand.Rand) string {
+	return users[rng.Intn(len(users))]
+}
+
+// sequentialUsers 轮流选择
+type sequentialUsers struct {
+	users []string
+	next  int
+}
+
+// Next 选择下一个用户
+func (s *sequentialUsers) Next(*rand.Rand) string {
+	user := s.users[s.next%len(s.users)]
+	s.next++
+	return user
+}
+
+// zipfUsers 按照 zipf 分布选择, 列表之中靠前的用户被选中的概率更大
+type zipfUsers struct {
+	users []string
+	s     float64
+	zipf  *rand.Zipf
+}
+
+// Next 按照 zipf 分布选择一个用户, 第一次调用的时候使用传入的随机数生成器创建分布
+func (z *zipfUsers) Next(rng *rand.Rand) string {
+	if z.zipf == nil {
+		z.zipf = rand.NewZipf(rng, z.s, 1, uint64(len(z.users)-1))
+	}
+	return z.users[z.zipf.Uint64()]
+}
+
+// uniqueUsers 每次生成一个新的用户
+type uniqueUsers struct {
+	prefix string
+	next   int
+}
+
+// Next 生成一个新的用户
+func (u *uniqueUsers) Next(*rand.Rand) string {
+	u.next++
+	return fmt.Sprintf("%s%d", u.prefix, u.next)
+}
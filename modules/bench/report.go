@@ -0,0 +1,290 @@
+package bench
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AllNodes 汇总所有节点的报告的名称
+	AllNodes = "all"
+
+	sendPrefix = "send/" // 发送消息的计数的前缀
+	recvPrefix = "recv/" // 接收消息的计数的前缀
+)
+
+// LatencySummary 延迟的统计, 单位毫秒, 只统计成功得到判断的请求
+type LatencySummary struct {
+	Min  float64 `json:"min_ms"`
+	Mean float64 `json:"mean_ms"`
+	P50  float64 `json:"p50_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+}
+
+// NodeReport 一个节点或者所有节点的统计
+type NodeReport struct {
+	Name        string            `json:"node"`
+	Requests    int               `json:"requests"`
+	Succeeded   int               `json:"succeeded"`
+	Timeouts    int               `json:"timeouts"`
+	Errors      int               `json:"errors"`
+	Results     map[string]int    `json:"results"`      // 每种认证结果的数量
+	Throughput  float64           `json:"throughput"`   // 每秒成功的请求数量
+	TimeoutRate float64           `json:"timeout_rate"` // 超时的请求占所有请求的比例
+	Latency     LatencySummary    `json:"latency"`
+	Messages    map[string]uint64 `json:"messages,omitempty"` // 压测期间节点收发的消息数量, 键为 方向/消息类型
+	MsgSent     uint64            `json:"msg_sent"`
+	MsgReceived uint64            `json:"msg_received"`
+	MsgError    string            `json:"msg_error,omitempty"` // 获取消息数量失败的原因
+}
+
+// Report 一次压测的报告
+type Report struct {
+	Concurrency int          `json:"concurrency"`
+	Rate        float64      `json:"rate"` // 为 0 表示闭环模式
+	Arrival     string       `json:"arrival,omitempty"`
+	Seed        int64        `json:"seed"`
+	Elapsed     float64      `json:"elapsed_seconds"`
+	Skipped     int          `json:"skipped"` // 开环模式下因为同时进行的请求达到上限而没有发起的请求数量
+	Total       NodeReport   `json:"total"`
+	Nodes       []NodeReport `json:"nodes"`
+	Samples     []Sample     `json:"-"` // 按照开始时间排序的每个请求的结果
+}
+
+// newReport 根据请求的结果以及压测前后的消息数量生成报告
+func newReport(config Config, targets []Target, samples []Sample, elapsed time.Duration, skipped int,
+	before, after []messageCounts) *Report {
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Start < samples[j].Start })
+	report := &Report{
+		Concurrency: config.Concurrency,
+		Rate:        config.Rate,
+		Seed:        config.Seed,
+		Elapsed:     elapsed.Seconds(),
+		Skipped:     skipped,
+		Total:       summarize(AllNodes, samples, elapsed),
+		Nodes:       make([]NodeReport, 0, len(targets)),
+		Samples:     samples,
+	}
+	if config.Rate > 0 {
+		report.Arrival = config.Arrival
+	}
+	for i, target := range targets {
+		nodeSamples := make([]Sample, 0, len(samples)/len(targets)+1)
+		for _, sample := range samples {
+			if sample.Target == target.Name {
+				nodeSamples = append(nodeSamples, sample)
+			}
+		}
+		node := summarize(target.Name, nodeSamples, elapsed)
+		node.addMessages(before[i], after[i])
+		report.Total.MsgSent += node.MsgSent
+		report.Total.MsgReceived += node.MsgReceived
+		report.Nodes = append(report.Nodes, node)
+	}
+	return report
+}
+
+// summarize 统计一组请求的结果
+func summarize(name string, samples []Sample, elapsed time.Duration) NodeReport {
+	node := NodeReport{Name: name, Requests: len(samples), Results: make(map[string]int)}
+	latencies := make([]time.Duration, 0, len(samples))
+	for _, sample := range samples {
+		switch {
+		case sample.TimedOut():
+			node.Timeouts++
+		case sample.Err != nil:
+			node.Errors++
+		}
+		if sample.Err == nil {
+			node.Results[sample.Result.String()]++
+		}
+		if sample.Succeeded() {
+			node.Succeeded++
+			latencies = append(latencies, sample.Latency)
+		}
+	}
+	if elapsed > 0 {
+		node.Throughput = float64(node.Succeeded) / elapsed.Seconds()
+	}
+	if node.Requests > 0 {
+		node.TimeoutRate = float64(node.Timeouts) / float64(node.Requests)
+	}
+	node.Latency = summarizeLatency(latencies)
+	return node
+}
+
+// summarizeLatency 计算延迟的最小值, 平均值, 百分位数以及最大值
+func summarizeLatency(latencies []time.Duration) LatencySummary {
+	if len(latencies) == 0 {
+		return LatencySummary{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum time.Duration
+	for _, latency := range latencies {
+		sum += latency
+	}
+	return LatencySummary{
+		Min:  millis(latencies[0]),
+		Mean: millis(sum / time.Duration(len(latencies))),
+		P50:  millis(percentile(latencies, 0.50)),
+		P95:  millis(percentile(latencies, 0.95)),
+		P99:  millis(percentile(latencies, 0.99)),
+		Max:  millis(latencies[len(latencies)-1]),
+	}
+}
+
+// percentile 按照 nearest-rank 方法计算排序之后的延迟的百分位数
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// millis 将时间转换为毫秒
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// addMessages 根据压测前后的消息数量计算压测期间节点收发的消息数量
+func (node *NodeReport) addMessages(before, after messageCounts) {
+	if err := after.err; err != nil || before.err != nil {
+		if err == nil {
+			err = before.err
+		}
+		node.MsgError = err.Error()
+		return
+	}
+	if after.counts == nil {
+		return
+	}
+	node.Messages = make(map[string]uint64, len(after.counts))
+	for key, count := range after.counts {
+		delta := count - before.counts[key]
+		if count < before.counts[key] {
+			// 节点在压测期间重启过, 计数从 0 重新开始
+			delta = count
+		}
+		node.Messages[key] = delta
+		switch {
+		case strings.HasPrefix(key, sendPrefix):
+			node.MsgSent += delta
+		case strings.HasPrefix(key, recvPrefix):
+			node.MsgReceived += delta
+		}
+	}
+}
+
+// WriteJSON 将报告输出为 json
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// csvHeader 报告的 csv 格式的表头
+var csvHeader = []string{"node", "requests", "succeeded", "timeouts", "errors", "throughput", "timeout_rate",
+	"min_ms", "mean_ms", "p50_ms", "p95_ms", "p99_ms", "max_ms", "msg_sent", "msg_received"}
+
+// WriteCSV 将报告输出为 csv, 每个节点一行, 最后一行为所有节点的汇总
+func (report *Report) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, node := range append(append([]NodeReport{}, report.Nodes...), report.Total) {
+		record := []string{
+			node.Name,
+			strconv.Itoa(node.Requests),
+			strconv.Itoa(node.Succeeded),
+			strconv.Itoa(node.Timeouts),
+			strconv.Itoa(node.Errors),
+			formatFloat(node.Throughput),
+			formatFloat(node.TimeoutRate),
+			formatFloat(node.Latency.Min),
+			formatFloat(node.Latency.Mean),
+			formatFloat(node.Latency.P50),
+			formatFloat(node.Latency.P95),
+			formatFloat(node.Latency.P99),
+			formatFloat(node.Latency.Max),
+			strconv.FormatUint(node.MsgSent, 10),
+			strconv.FormatUint(node.MsgReceived, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteSamplesCSV 将每个请求的结果输出为 csv, 用于绘制延迟随时间变化的曲线
+func (report *Report) WriteSamplesCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"start_ms", "latency_ms", "node", "user", "result", "error"}); err != nil {
+		return err
+	}
+	for _, sample := range report.Samples {
+		result, errMsg := sample.Result.String(), ""
+		if sample.Err != nil {
+			result, errMsg = "", sample.Err.Error()
+		}
+		record := []string{
+			formatFloat(millis(sample.Start)),
+			formatFloat(millis(sample.Latency)),
+			sample.Target,
+			sample.UserId,
+			result,
+			errMsg,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteText 将报告输出为便于阅读的文本
+func (report *Report) WriteText(w io.Writer) error {
+	mode := "closed loop"
+	if report.Rate > 0 {
+		mode = fmt.Sprintf("open loop, %s arrivals at %.1f/s", report.Arrival, report.Rate)
+	}
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "mode: %s, concurrency: %d, seed: %d, elapsed: %.2fs\n",
+		mode, report.Concurrency, report.Seed, report.Elapsed)
+	if report.Skipped > 0 {
+		fmt.Fprintf(&builder, "skipped: %d arrivals while %d requests were in flight\n",
+			report.Skipped, report.Concurrency)
+	}
+	for _, node := range append(append([]NodeReport{}, report.Nodes...), report.Total) {
+		fmt.Fprintf(&builder, "%s\trequests: %d\tsucceeded: %d\ttimeouts: %d (%.2f%%)\terrors: %d\tthroughput: %.2f/s\n",
+			node.Name, node.Requests, node.Succeeded, node.Timeouts, node.TimeoutRate*100, node.Errors, node.Throughput)
+		fmt.Fprintf(&builder, "\tlatency ms\tmin: %.2f\tmean: %.2f\tp50: %.2f\tp95: %.2f\tp99: %.2f\tmax: %.2f\n",
+			node.Latency.Min, node.Latency.Mean, node.Latency.P50, node.Latency.P95, node.Latency.P99, node.Latency.Max)
+		switch {
+		case node.MsgError != "":
+			fmt.Fprintf(&builder, "\tmessages\tunavailable: %s\n", node.MsgError)
+		case node.Messages != nil || node.Name == AllNodes && (node.MsgSent > 0 || node.MsgReceived > 0):
+			fmt.Fprintf(&builder, "\tmessages\tsent: %d\treceived: %d\n", node.MsgSent, node.MsgReceived)
+		}
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// formatFloat 输出保留三位小数的浮点数
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
@@ -3,7 +3,10 @@ package blockchain
 import (
 	"zhanghefan123/security/common/msgbus"
 	"zhanghefan123/security/logger"
+	"zhanghefan123/security/modules/decision_log"
+	"zhanghefan123/security/modules/lifecycle"
 	"zhanghefan123/security/modules/request_pool"
+	"zhanghefan123/security/modules/session"
 	"zhanghefan123/security/protocol"
 )
 
@@ -21,25 +24,47 @@ type Blockchain struct {
 	net protocol.Net
 	// requestPool 用于接受请求的池子
 	RequestPool *request_pool.RequestPool
+	// Sessions 认证通过之后签发的会话
+	Sessions *session.Store
 	// netService 链提供的网络服务
 	netService protocol.NetService
 	// consensus 共识模块
 	consensus protocol.ConsensusEngine
-	// initModules is the modules that have been initialized.
-	initModules map[string]struct{}
-	// startModules is the modules that have been started.
-	startModules map[string]struct{}
+	// decisionLog 共识模块的决策日志, 没有配置的时候为空
+	decisionLog *decision_log.FileSink
+	// lifecycle 按照依赖关系管理模块的初始化, 启动以及停止
+	lifecycle *lifecycle.Manager
 }
 
 // NewBlockChain 新的区块链
 func NewBlockChain(chainId string, genesis string, msgBus msgbus.MessageBus, net protocol.Net) *Blockchain {
-	return &Blockchain{ // 返回一个区块链的结构体实例
-		log:          logger.GetLoggerByChain(logger.MODULE_BLOCKCHAIN, chainId), // 日志记录器的获取
-		genesis:      genesis,                                                    // 创世区块bcx.xml的全路径
-		chainId:      chainId,                                                    // 区块链的id
-		msgBus:       msgBus,                                                     // 消息总线，每创建一个区块链，都会创建一个消息总线
-		net:          net,                                                        // server 之中保存的 net
-		initModules:  make(map[string]struct{}),                                  // 已经初始化的模块
-		startModules: make(map[string]struct{}),                                  // 已经启动的模块
+	log := logger.GetLoggerByChain(logger.MODULE_BLOCKCHAIN, chainId) // 日志记录器的获取
+	return &Blockchain{                                               // 返回一个区块链的结构体实例
+		log:       log,
+		genesis:   genesis,                   // 创世区块bcx.xml的全路径
+		chainId:   chainId,                   // 区块链的id
+		msgBus:    msgBus,                    // 消息总线，每创建一个区块链，都会创建一个消息总线
+		net:       net,                       // server 之中保存的 net
+		lifecycle: lifecycle.NewManager(log), // 模块的生命周期管理器
 	}
 }
+
+// ChainId 返回区块链的 id
+func (bc *Blockchain) ChainId() string {
+	return bc.chainId
+}
+
+// Consensus 返回区块链的共识模块
+func (bc *Blockchain) Consensus() protocol.ConsensusEngine {
+	return bc.consensus
+}
+
+// NetService 返回区块链的网络服务
+func (bc *Blockchain) NetService() protocol.NetService {
+	return bc.netService
+}
+
+// Health 返回区块链之中每个模块的健康状态
+func (bc *Blockchain) Health() []lifecycle.ModuleHealth {
+	return bc.lifecycle.Health()
+}
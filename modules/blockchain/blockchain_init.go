@@ -1,77 +1,109 @@
 package blockchain
 
 import (
+	"path/filepath"
+	"time"
 	consensus_utils "zhanghefan123/security/consensus-utils"
 	"zhanghefan123/security/localconf"
 	"zhanghefan123/security/logger"
+	"zhanghefan123/security/modules/clock"
 	"zhanghefan123/security/modules/consensus_provider"
+	"zhanghefan123/security/modules/decision_log"
+	"zhanghefan123/security/modules/lifecycle"
 	"zhanghefan123/security/modules/net"
 	"zhanghefan123/security/modules/request_pool"
+	"zhanghefan123/security/modules/session"
 )
 
-type InitFunction func() error
-
-// 初始化模块的名称
+// 模块的名称
 const (
 	ModuleNameNetService  = "NetService"
 	ModuleNameConsensus   = "ConsensusService"
 	ModuleNameRequestPool = "RequestPool"
 )
 
-// Init 初始化区块链
+// Init 注册区块链之中的所有模块, 然后按照依赖关系进行初始化
 func (bc *Blockchain) Init() (err error) {
-	// 一个元素类型为 map[string]InitFunction 的数组, 使用数组的原因是可以保证顺序
-	baseModules := []map[string]InitFunction{
-		// 初始化订阅器
-		{ModuleNameRequestPool: bc.InitRequestPool},    // 初始化请求池
-		{ModuleNameNetService: bc.InitNetService},      // 网络模块
-		{ModuleNameConsensus: bc.InitConsensusService}, // 共识模块服务
-	}
-	err = bc.InitBaseModules(baseModules)
-	return err
-}
+	// 会话只保存在内存之中, 不需要启动以及停止
+	sessionTTL := time.Duration(localconf.ChainMakerConfig.RpcConfig.SessionConfig.TTL) * time.Second
+	bc.Sessions = session.NewStore(clock.Real, sessionTTL)
 
-// InitBaseModules 初始化基础模块
-func (bc *Blockchain) InitBaseModules(baseModules []map[string]InitFunction) (err error) {
-	moduleNum := len(baseModules)
-	for idx, baseModule := range baseModules {
-		for name, initFunc := range baseModule {
-			if err := initFunc(); err != nil {
-				bc.log.Errorf("init base module[%s] failed, %s", name, err)
-				return err
-			}
-			bc.log.Infof("BASE INIT STEP (%d/%d) => init base[%s] success :)", idx+1, moduleNum, name)
+	modules := []lifecycle.Module{
+		// 请求池, 在共识模块停止之后才停止, 保证共识模块交回的请求能够写入请求日志
+		{
+			Name:   ModuleNameRequestPool,
+			Init:   bc.InitRequestPool,
+			Start:  bc.startRequestPool,
+			Stop:   bc.StopRequestPool,
+			Health: bc.requestPoolHealth,
+		},
+		// 网络模块
+		{
+			Name:  ModuleNameNetService,
+			Init:  bc.InitNetService,
+			Start: bc.startNetService,
+			Stop:  bc.StopNetService,
+		},
+		// 共识模块服务, 依赖网络模块以及请求池
+		{
+			Name:      ModuleNameConsensus,
+			DependsOn: []string{ModuleNameNetService, ModuleNameRequestPool},
+			Init:      bc.InitConsensusService,
+			Start:     bc.startConsensusService,
+			Stop:      bc.StopConsensus,
+		},
+	}
+	for _, module := range modules {
+		if err = bc.lifecycle.Register(module); err != nil {
+			return err
 		}
 	}
-	return
+	return bc.lifecycle.Init()
 }
 
 // InitRequestPool 初始化请求池
 func (bc *Blockchain) InitRequestPool() (err error) {
-	_, ok := bc.initModules[ModuleNameRequestPool]
-	if ok {
-		bc.log.Errorf("init request pool already exists")
-		return
+	// 每个优先级队列的长度, 没有配置的时候使用 request_channel_size
+	poolConfig := localconf.ChainMakerConfig.RpcConfig.RequestPoolConfig
+	queueSize := poolConfig.QueueSize
+	if queueSize <= 0 {
+		queueSize = localconf.ChainMakerConfig.RpcConfig.RequestChannelSize
+	}
+	classifier := request_pool.NewUserListClassifier(poolConfig.EmergencyUsers, poolConfig.OperatorUsers)
+	gracePeriod := time.Duration(poolConfig.ResultGrace) * time.Second
+	opts := []request_pool.PoolOption{request_pool.WithResultGracePeriod(gracePeriod)}
+
+	// 配置了 wal_path 的时候使用请求日志, 每条链有自己的目录
+	if poolConfig.WalPath != "" {
+		journal, err := request_pool.OpenJournal(filepath.Join(poolConfig.WalPath, bc.chainId), gracePeriod)
+		if err != nil {
+			bc.log.Errorf("open request journal failed, %s", err)
+			return err
+		}
+		opts = append(opts, request_pool.WithJournal(journal))
+	}
+	bc.RequestPool = request_pool.NewRequestPool(queueSize, classifier, opts...)
+
+	// 重新提交重启之前没有结束的请求, 它们会在请求池启动之后交给共识模块
+	recovered, err := bc.RequestPool.Recover()
+	if err != nil {
+		bc.log.Errorf("recover request pool failed, %s", err)
+		_ = bc.RequestPool.CloseJournal()
+		return err
+	}
+	if recovered > 0 {
+		bc.log.Infof("recovered %d unfinished requests from journal", recovered)
 	}
-	requestChannelSize := localconf.ChainMakerConfig.RpcConfig.RequestChannelSize
-	bc.RequestPool = request_pool.NewRequestPool(requestChannelSize)
-	bc.initModules[ModuleNameRequestPool] = struct{}{}
 	return nil
 }
 
 // InitNetService 初始化网络服务
 func (bc *Blockchain) InitNetService() (err error) {
-	_, ok := bc.initModules[ModuleNameNetService]
-	if ok {
-		bc.log.Infof("net service module existed, ignore.")
-		return
-	}
 	var netServiceFactory net.NetServiceFactory
 	if bc.netService, err = netServiceFactory.NewNetService(bc.net, bc.chainId, net.WithMsgBus(bc.msgBus)); err != nil {
 		bc.log.Errorf("new net service failed, %s", err)
 		return
 	}
-	bc.initModules[ModuleNameNetService] = struct{}{}
 	return
 }
 
@@ -80,13 +112,6 @@ func (bc *Blockchain) InitConsensusService() (err error) {
 	// 获取本地节点 id
 	localPeerId := localconf.ChainMakerConfig.NodeConfig.NodeId
 
-	// 判断是否初始化了共识模块
-	_, ok := bc.initModules[ModuleNameConsensus]
-	if ok {
-		bc.log.Infof("consensus module existed, ignore.")
-		return
-	}
-
 	config := &consensus_utils.ConsensusImplConfig{
 		ChainId:     bc.chainId,                                                   // (区块链的id)
 		NodeId:      localPeerId,                                                  // (本地节点的 id)
@@ -95,6 +120,16 @@ func (bc *Blockchain) InitConsensusService() (err error) {
 		Logger:      logger.GetLoggerByChain(logger.MODULE_CONSENSUS, bc.chainId), // 日志
 		RequestPool: bc.RequestPool,                                               // (请求池)
 	}
+
+	// 配置了 decision_log_path 的时候记录共识的决策, 每条链一个文件
+	if logPath := localconf.ChainMakerConfig.ConsensusConfig.PbftConfig.DecisionLogPath; logPath != "" {
+		if bc.decisionLog, err = decision_log.OpenFile(filepath.Join(logPath, bc.chainId+".jsonl")); err != nil {
+			bc.log.Errorf("open decision log failed, %s", err)
+			return err
+		}
+		config.DecisionLog = bc.decisionLog
+	}
+
 	// 获取相应的创建者
 	provider := consensus_provider.GetConsensusProvider(localconf.ChainMakerConfig.ConsensusConfig.ConsensusType)
 
@@ -102,20 +137,8 @@ func (bc *Blockchain) InitConsensusService() (err error) {
 	bc.consensus, err = provider(config)
 	if err != nil {
 		bc.log.Errorf("new consensus engine failed, %s", err)
+		bc.closeDecisionLog()
 		return err
 	}
-
-	// 创建初始化模块
-	bc.initModules[ModuleNameConsensus] = struct{}{}
 	return
 }
-
-// isModuleInit 判断 module 是否启动了
-func (bc *Blockchain) isModuleInit(moduleName string) bool {
-	_, ok := bc.initModules[moduleName]
-	if ok {
-		return true
-	} else {
-		return false
-	}
-}
@@ -4,42 +4,14 @@ import (
 	"go.uber.org/zap"
 )
 
-type StartFunction func() error
-
-// Start 启动区块链之中所有模块
+// Start 按照依赖关系启动区块链之中所有模块, 某个模块启动失败的时候已经启动的模块会被停止
 func (bc *Blockchain) Start() error {
-	var startModules = make([]map[string]StartFunction, 0)
-
-	// 添加启动网络模块的函数
-	if bc.isModuleInit(ModuleNameNetService) && !bc.isModuleStartUp(ModuleNameNetService) {
-		startModules = append(startModules, map[string]StartFunction{ModuleNameNetService: bc.startNetService})
-	}
-
-	// 添加启动共识模块的函数
-	if bc.isModuleInit(ModuleNameConsensus) && !bc.isModuleStartUp(ModuleNameConsensus) {
-		startModules = append(startModules, map[string]StartFunction{ModuleNameConsensus: bc.startConsensusService})
-	}
-
-	// 调用 bc.StartModules 启动
-	err := bc.StartModules(startModules)
-	if err != nil {
-		return err
-	}
-	return nil
+	return bc.lifecycle.Start()
 }
 
-// StartModules 进行模块的启动
-func (bc *Blockchain) StartModules(startModules []map[string]StartFunction) error {
-	moduleNum := len(startModules)
-	for idx, baseModule := range startModules {
-		for name, initFunc := range baseModule {
-			if err := initFunc(); err != nil {
-				bc.log.Errorf("start base module[%s] failed, %s", name, err)
-				return err
-			}
-			bc.log.Infof("START STEP (%d/%d) => init base[%s] success :)", idx+1, moduleNum, name)
-		}
-	}
+// startRequestPool 启动请求池的分发协程
+func (bc *Blockchain) startRequestPool() error {
+	bc.RequestPool.Start()
 	return nil
 }
 
@@ -50,7 +22,6 @@ func (bc *Blockchain) startNetService() error {
 		bc.log.Error("start net service error", zap.Error(err))
 		return err
 	}
-	bc.startModules[ModuleNameNetService] = struct{}{}
 	return nil
 }
 
@@ -61,16 +32,5 @@ func (bc *Blockchain) startConsensusService() error {
 		bc.log.Error("start consensus service error", zap.Error(err))
 		return err
 	}
-	bc.startModules[ModuleNameConsensus] = struct{}{}
 	return nil
 }
-
-// isModuleStartUp 判断模块是否已经启动
-func (bc *Blockchain) isModuleStartUp(name string) bool {
-	_, ok := bc.startModules[name]
-	if ok {
-		return true
-	} else {
-		return false
-	}
-}
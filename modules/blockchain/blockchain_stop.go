@@ -1,40 +1,20 @@
 package blockchain
 
-import "fmt"
-
-type StopFunction func() error
-
-// Stop 停止区块链
-func (bc *Blockchain) Stop() {
-	// 停止模块
-	var stopModules = make([]map[string]StopFunction, 0)
-
-	// 添加停止网络模块的函数
-	if bc.isModuleStartUp(ModuleNameNetService) {
-		stopModules = append(stopModules, map[string]StopFunction{ModuleNameConsensus: bc.StopNetService})
-	}
-
-	// 添加停止共识模块的函数
-	if bc.isModuleStartUp(ModuleNameConsensus) {
-		stopModules = append(stopModules, map[string]StopFunction{ModuleNameConsensus: bc.StopNetService})
-	}
-
-	if err := bc.StopModules(stopModules); err != nil {
-		fmt.Printf("Error stopping modules: %v\n", err)
+// Drain 请求池不再接受新的请求, 返回的 channel 在已经接受的请求都结束之后关闭
+func (bc *Blockchain) Drain() <-chan struct{} {
+	if bc.RequestPool == nil {
+		drainedC := make(chan struct{})
+		close(drainedC)
+		return drainedC
 	}
+	return bc.RequestPool.Drain()
 }
 
-// StopModules 进行所有模块的停止
-func (bc *Blockchain) StopModules(stopModules []map[string]StopFunction) error {
-	moduleNum := len(stopModules)
-	for idx, baseModule := range stopModules {
-		for name, stopFunc := range baseModule {
-			if err := stopFunc(); err != nil {
-				bc.log.Errorf("stop module[%s] failed, %s", name, err)
-				continue
-			}
-			bc.log.Infof("STOP STEP (%d/%d) => stop module[%s] success :)", idx+1, moduleNum, name)
-		}
+// Stop 按照和启动相反的顺序停止区块链之中的模块: 共识模块, 网络模块, 最后是请求池
+func (bc *Blockchain) Stop() error {
+	if err := bc.lifecycle.Stop(); err != nil {
+		bc.log.Errorf("stop blockchain[%s] failed, %s", bc.chainId, err)
+		return err
 	}
 	return nil
 }
@@ -46,16 +26,42 @@ func (bc *Blockchain) StopNetService() error {
 		bc.log.Errorf("stop net service failed, %v", err)
 		return err
 	}
-	delete(bc.startModules, ModuleNameNetService)
 	return nil
 }
 
-// StopConsensus 停止共识
+// StopConsensus 停止共识, 之后关闭决策日志
 func (bc *Blockchain) StopConsensus() error {
 	// stop the consensus
 	if err := bc.consensus.Stop(); err != nil {
 		bc.log.Errorf("stop consensus failed, %v", err)
+		return err
+	}
+	bc.closeDecisionLog()
+	return nil
+}
+
+// closeDecisionLog 关闭决策日志
+func (bc *Blockchain) closeDecisionLog() {
+	if bc.decisionLog == nil {
+		return
+	}
+	if err := bc.decisionLog.Close(); err != nil {
+		bc.log.Errorf("close decision log failed, %v", err)
+	}
+	bc.decisionLog = nil
+}
+
+// StopRequestPool 停止请求池, 排队的请求会被关闭, 然后关闭请求日志
+func (bc *Blockchain) StopRequestPool() error {
+	bc.RequestPool.Stop()
+	if err := bc.RequestPool.CloseJournal(); err != nil {
+		bc.log.Errorf("close request journal failed, %v", err)
+		return err
 	}
-	delete(bc.startModules, ModuleNameConsensus)
 	return nil
 }
+
+// requestPoolHealth 请求池的健康检查
+func (bc *Blockchain) requestPoolHealth() error {
+	return bc.RequestPool.Health()
+}
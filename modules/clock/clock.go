@@ -0,0 +1,45 @@
+package clock
+
+import "time"
+
+// Clock 时钟, 共识之中的超时以及过期时间通过它获取, 仿真的时候替换为虚拟时钟
+type Clock interface {
+	// Now 返回当前时间
+	Now() time.Time
+	// NewTimer 创建一个 d 之后触发的计时器
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer 计时器, 和 time.Timer 相同, 触发的时候向 C() 写入触发的时间
+type Timer interface {
+	// C 返回计时器触发的 channel
+	C() <-chan time.Time
+	// Stop 停止计时器, 计时器已经触发或者已经停止的时候返回 false
+	Stop() bool
+}
+
+// Real 使用系统时间的时钟
+var Real Clock = realClock{}
+
+// realClock 使用系统时间的时钟
+type realClock struct{}
+
+// Now 返回系统时间
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer 创建系统计时器
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// realTimer 包装 time.Timer
+type realTimer struct {
+	*time.Timer
+}
+
+// C 返回计时器触发的 channel
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
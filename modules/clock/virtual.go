@@ -0,0 +1,145 @@
+package clock
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Virtual 虚拟时钟, 时间只有在调用 AdvanceTo 的时候才会前进, 到期的计时器按照到期时间以及创建的顺序触发
+type Virtual struct {
+	mutex      sync.Mutex
+	now        time.Time
+	timers     timerHeap // 还没有触发的计时器
+	seq        uint64    // 计时器的创建序号
+	generation uint64    // 每次创建或者停止计时器的时候增加, 用于判断是否有新的计时器
+}
+
+// NewVirtual 创建从 start 开始的虚拟时钟
+func NewVirtual(start time.Time) *Virtual {
+	return &Virtual{now: start}
+}
+
+// Now 返回虚拟时间
+func (v *Virtual) Now() time.Time {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.now
+}
+
+// NewTimer 创建虚拟计时器, d 小于等于 0 的时候在下一次 AdvanceTo 的时候触发
+func (v *Virtual) NewTimer(d time.Duration) Timer {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.seq++
+	v.generation++
+	t := &virtualTimer{
+		clock:    v,
+		deadline: v.now.Add(d),
+		seq:      v.seq,
+		c:        make(chan time.Time, 1),
+	}
+	heap.Push(&v.timers, t)
+	return t
+}
+
+// NextDeadline 返回最早到期的计时器的到期时间, 没有计时器的时候返回 false
+func (v *Virtual) NextDeadline() (time.Time, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if len(v.timers) == 0 {
+		return time.Time{}, false
+	}
+	return v.timers[0].deadline, true
+}
+
+// AdvanceTo 将时间前进到 t 并且触发所有到期的计时器, 返回触发的计时器数量, 时间不会倒退
+func (v *Virtual) AdvanceTo(t time.Time) int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if t.After(v.now) {
+		v.now = t
+	}
+	fired := 0
+	for len(v.timers) > 0 && !v.timers[0].deadline.After(v.now) {
+		timer := heap.Pop(&v.timers).(*virtualTimer)
+		timer.c <- v.now
+		fired++
+	}
+	return fired
+}
+
+// Pending 返回还没有触发的计时器数量
+func (v *Virtual) Pending() int {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return len(v.timers)
+}
+
+// Generation 返回计时器的变化次数
+func (v *Virtual) Generation() uint64 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.generation
+}
+
+// virtualTimer 虚拟计时器
+type virtualTimer struct {
+	clock    *Virtual
+	deadline time.Time
+	seq      uint64
+	index    int // 在堆之中的位置, 已经触发或者停止的时候为 -1
+	c        chan time.Time
+}
+
+// C 返回计时器触发的 channel
+func (t *virtualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop 停止计时器
+func (t *virtualTimer) Stop() bool {
+	v := t.clock
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&v.timers, t.index)
+	v.generation++
+	return true
+}
+
+// timerHeap 按照到期时间以及创建顺序排列的计时器
+type timerHeap []*virtualTimer
+
+func (h timerHeap) Len() int { return len(h) }
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].deadline.Equal(h[j].deadline) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	t := x.(*virtualTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = nil
+	t.index = -1
+	*h = old[:n-1]
+	return t
+}
@@ -0,0 +1,44 @@
+package clock
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestVirtualClockFiresInOrder(t *testing.T) {
+	start := time.Unix(0, 0)
+	v := NewVirtual(start)
+	late := v.NewTimer(2 * time.Second)
+	early := v.NewTimer(time.Second)
+	stopped := v.NewTimer(time.Second)
+	require.True(t, stopped.Stop())
+	require.False(t, stopped.Stop())
+	require.Equal(t, 2, v.Pending())
+
+	deadline, ok := v.NextDeadline()
+	require.True(t, ok)
+	require.Equal(t, start.Add(time.Second), deadline)
+
+	require.Equal(t, 0, v.AdvanceTo(start.Add(500*time.Millisecond)))
+	require.Equal(t, 1, v.AdvanceTo(start.Add(time.Second)))
+	require.Equal(t, start.Add(time.Second), <-early.C())
+	require.False(t, early.Stop())
+
+	require.Equal(t, 1, v.AdvanceTo(start.Add(3*time.Second)))
+	require.Equal(t, start.Add(3*time.Second), <-late.C())
+	require.Equal(t, start.Add(3*time.Second), v.Now())
+	_, ok = v.NextDeadline()
+	require.False(t, ok)
+}
+
+func TestVirtualClockDoesNotGoBack(t *testing.T) {
+	start := time.Unix(100, 0)
+	v := NewVirtual(start)
+	v.AdvanceTo(start.Add(-time.Second))
+	require.Equal(t, start, v.Now())
+	generation := v.Generation()
+	v.NewTimer(0)
+	require.Greater(t, v.Generation(), generation)
+	require.Equal(t, 1, v.AdvanceTo(start))
+}
@@ -0,0 +1,373 @@
+package config_checker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"zhanghefan123/security/common/crypto/asym"
+	"zhanghefan123/security/common/helper"
+	"zhanghefan123/security/common/helper/libp2ppeer"
+	"zhanghefan123/security/localconf"
+	"zhanghefan123/security/modules/consensus_provider"
+	"zhanghefan123/security/modules/net"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Level 问题的严重程度
+type Level int
+
+const (
+	LevelError   Level = iota // 节点无法启动或者无法达成共识
+	LevelWarning              // 节点可以运行, 但是很可能不符合预期
+)
+
+// String 问题的严重程度的名称
+func (level Level) String() string {
+	if level == LevelError {
+		return "ERROR"
+	}
+	return "WARNING"
+}
+
+// Problem 配置之中的一个问题
+type Problem struct {
+	Level   Level
+	Key     string // 出问题的配置项, 例如 net.seeds[1]
+	Message string // 出问题的原因以及修改的建议
+}
+
+// String 打印问题
+func (problem Problem) String() string {
+	return fmt.Sprintf("[%s] %s: %s", problem.Level, problem.Key, problem.Message)
+}
+
+// HasError 是否存在会导致节点无法正常运行的问题
+func HasError(problems []Problem) bool {
+	for _, problem := range problems {
+		if problem.Level == LevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// checker 保存检查的过程之中发现的问题
+type checker struct {
+	config   *localconf.CMConfig
+	problems []Problem
+	peerId   string // 通过 net.tls.priv_key_file 计算出来的 peerId, 私钥无法加载的时候为空
+}
+
+// Check 检查配置文件, 返回所有发现的问题, 没有问题的时候返回空
+func Check(config *localconf.CMConfig) []Problem {
+	c := &checker{config: config, problems: make([]Problem, 0)}
+	c.checkFiles()
+	c.checkNetKey()
+	seeds := c.checkSeeds()
+	c.checkChains(seeds)
+	c.checkPorts()
+	c.checkConsensus()
+	c.checkFaults()
+	c.checkAdmin()
+	return c.problems
+}
+
+func (c *checker) errorf(key, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Level: LevelError, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) warnf(key, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Level: LevelWarning, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkFile 检查文件是否存在, 配置之中的相对路径相对于节点启动时的工作目录
+func (c *checker) checkFile(key, path string, required bool) bool {
+	if path == "" {
+		if required {
+			c.errorf(key, "is not set")
+		}
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		absPath, _ := filepath.Abs(path)
+		c.errorf(key, "%s does not exist (resolved to %s, relative paths are resolved against the working directory)",
+			path, absPath)
+		return false
+	}
+	if info.IsDir() {
+		c.errorf(key, "%s is a directory, a file is expected", path)
+		return false
+	}
+	return true
+}
+
+// checkFiles 检查配置之中引用的文件
+func (c *checker) checkFiles() {
+	c.checkFile("log.config_file", c.config.LogConfig.ConfigFile, false)
+	c.checkFile("node.priv_key_file", c.config.NodeConfig.PrivKeyFile, false)
+	c.checkFile("node.cert_file", c.config.NodeConfig.CertFile, false)
+	c.checkFile("net.tls.cert_file", c.config.NetConfig.TLSConfig.CertFile, false)
+	for index, chain := range c.config.GetBlockChains() {
+		c.checkFile(fmt.Sprintf("blockchain[%d].genesis", index), chain.Genesis, true)
+	}
+}
+
+// checkNetKey 加载网络模块的私钥并计算 peerId, 和私钥旁边的 peerId 文件进行比较
+func (c *checker) checkNetKey() {
+	const key = "net.tls.priv_key_file"
+	keyPath := c.config.NetConfig.TLSConfig.PrivKeyFile
+	if !c.checkFile(key, keyPath, true) {
+		return
+	}
+	keyPem, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		c.errorf(key, "read %s failed, %s", keyPath, err)
+		return
+	}
+	privateKey, err := asym.PrivateKeyFromPEM(keyPem, nil)
+	if err != nil {
+		c.errorf(key, "%s is not a PEM private key the node can load, %s", keyPath, err)
+		return
+	}
+	c.peerId, err = helper.CreateLibp2pPeerIdWithPrivateKey(privateKey)
+	if err != nil {
+		c.errorf(key, "can not derive a peer id from %s, %s", keyPath, err)
+		return
+	}
+	// config generate 会把 peerId 写在私钥旁边
+	peerIdPath := filepath.Join(filepath.Dir(keyPath), "peerId")
+	if recorded, err := ioutil.ReadFile(peerIdPath); err == nil {
+		if strings.TrimSpace(string(recorded)) != c.peerId {
+			c.errorf(key, "the peer id of the key is %s, but %s records %s, the key and the peer id file do not match",
+				c.peerId, peerIdPath, strings.TrimSpace(string(recorded)))
+		}
+	}
+}
+
+// seed 解析之后的种子节点
+type seed struct {
+	peerId string
+	host   string
+	port   int
+}
+
+// parseSeed 解析种子节点的地址, 地址需要以 /p2p/<peerId> 结尾
+func parseSeed(address string) (*seed, error) {
+	multiAddr, err := ma.NewMultiaddr(address)
+	if err != nil {
+		return nil, err
+	}
+	info, err := libp2ppeer.AddrInfoFromP2pAddr(multiAddr)
+	if err != nil {
+		return nil, fmt.Errorf("missing /p2p/<peer id> at the end")
+	}
+	s := &seed{peerId: info.ID.Pretty()}
+	if len(info.Addrs) > 0 {
+		s.host, s.port = hostAndPort(info.Addrs[0])
+	}
+	return s, nil
+}
+
+// hostAndPort 获取地址之中的 ip 以及 tcp 端口, 不存在的时候为零值
+func hostAndPort(multiAddr ma.Multiaddr) (string, int) {
+	host, err := multiAddr.ValueForProtocol(ma.P_IP4)
+	if err != nil {
+		host, _ = multiAddr.ValueForProtocol(ma.P_IP6)
+	}
+	portValue, err := multiAddr.ValueForProtocol(ma.P_TCP)
+	if err != nil {
+		return host, 0
+	}
+	port, _ := strconv.Atoi(portValue)
+	return host, port
+}
+
+// checkSeeds 检查种子节点的语法, 重复的节点以及和自己的地址相同但是 peerId 不同的节点, 返回能够解析的种子节点
+func (c *checker) checkSeeds() []*seed {
+	listenHost, listenPort := "", 0
+	if listenAddr, err := ma.NewMultiaddr(c.config.NetConfig.ListenAddr); err != nil {
+		c.errorf("net.listen_addr", "%q is not a valid multiaddr, e.g. /ip4/0.0.0.0/tcp/11301, %s",
+			c.config.NetConfig.ListenAddr, err)
+	} else {
+		listenHost, listenPort = hostAndPort(listenAddr)
+	}
+
+	seeds := make([]*seed, 0, len(c.config.NetConfig.Seeds))
+	seen := make(map[string]int)
+	for index, address := range c.config.NetConfig.Seeds {
+		key := fmt.Sprintf("net.seeds[%d]", index)
+		s, err := parseSeed(address)
+		if err != nil {
+			c.errorf(key, "%q is invalid, %s, e.g. /ip4/127.0.0.1/tcp/11301/p2p/<peer id>", address, err)
+			continue
+		}
+		if first, ok := seen[s.peerId]; ok {
+			c.warnf(key, "peer %s is already listed in net.seeds[%d]", s.peerId, first)
+			continue
+		}
+		seen[s.peerId] = index
+		if c.peerId != "" && s.peerId != c.peerId && s.port == listenPort && s.host == listenHost {
+			c.errorf(key, "has the same address as net.listen_addr but peer id %s, the peer id of this node is %s",
+				s.peerId, c.peerId)
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds
+}
+
+// checkChains 检查每条链的验证者, 验证者不能重复, 并且能够连接到的验证者需要达到 pbft 的法定人数
+func (c *checker) checkChains(seeds []*seed) {
+	chains := c.config.GetBlockChains()
+	if len(chains) == 0 {
+		c.errorf("blockchain", "no blockchain is configured")
+		return
+	}
+	reachable := make(map[string]struct{}, len(seeds)+1)
+	for _, s := range seeds {
+		reachable[s.peerId] = struct{}{}
+	}
+	if c.peerId != "" {
+		reachable[c.peerId] = struct{}{}
+	}
+
+	chainIds := make(map[string]int)
+	for index, chain := range chains {
+		chainKey := fmt.Sprintf("blockchain[%d]", index)
+		if chain.ChainId == "" {
+			c.errorf(chainKey+".chainId", "is not set")
+		} else if first, ok := chainIds[chain.ChainId]; ok {
+			c.errorf(chainKey+".chainId", "%s is already used by blockchain[%d]", chain.ChainId, first)
+		}
+		chainIds[chain.ChainId] = index
+
+		validatorsKey := chainKey + ".validators"
+		validators := chain.Validators
+		if len(validators) == 0 {
+			// 没有单独配置验证者的时候使用 seeds 之中的节点
+			validatorsKey = "net.seeds"
+			validators = make([]string, 0, len(seeds))
+			for _, s := range seeds {
+				validators = append(validators, s.peerId)
+			}
+		}
+		c.checkValidators(chain.ChainId, validatorsKey, validators, reachable)
+	}
+}
+
+// checkValidators 检查一条链的验证者
+func (c *checker) checkValidators(chainId, key string, validators []string, reachable map[string]struct{}) {
+	unique := make(map[string]struct{}, len(validators))
+	for index, validator := range validators {
+		if validator == "" {
+			c.errorf(fmt.Sprintf("%s[%d]", key, index), "empty validator of chain %s", chainId)
+			continue
+		}
+		if _, ok := unique[validator]; ok {
+			c.errorf(fmt.Sprintf("%s[%d]", key, index), "validator %s of chain %s is duplicated", validator, chainId)
+			continue
+		}
+		unique[validator] = struct{}{}
+	}
+	if len(unique) == 0 {
+		c.errorf(key, "chain %s has no validator", chainId)
+		return
+	}
+
+	// n = 3f + 1, 至少需要 2f + 1 个验证者才能达成共识
+	n := len(unique)
+	f := (n - 1) / 3
+	quorum := 2*f + 1
+	connected := 0
+	for validator := range unique {
+		if _, ok := reachable[validator]; ok {
+			connected++
+		}
+	}
+	if connected < quorum {
+		c.errorf(key, "only %d of the %d validators of chain %s are this node or in net.seeds, "+
+			"pbft needs %d to reach a quorum", connected, n, chainId, quorum)
+	}
+	if f == 0 && n > 1 {
+		c.warnf(key, "chain %s has %d validators and can not tolerate any faulty validator, use at least 4",
+			chainId, n)
+	}
+	if c.peerId != "" {
+		if _, ok := unique[c.peerId]; !ok {
+			c.warnf(key, "this node (%s) is not a validator of chain %s and only forwards requests", c.peerId, chainId)
+		}
+	}
+}
+
+// checkPorts 检查 p2p, rpc, monitor 以及 pprof 的端口是否冲突
+func (c *checker) checkPorts() {
+	used := make(map[int]string)
+	usePort := func(key string, port int) {
+		if port <= 0 || port > 65535 {
+			c.errorf(key, "port %d is out of range", port)
+			return
+		}
+		if other, ok := used[port]; ok {
+			c.errorf(key, "port %d is already used by %s", port, other)
+			return
+		}
+		used[port] = key
+	}
+	if listenAddr, err := ma.NewMultiaddr(c.config.NetConfig.ListenAddr); err == nil {
+		if _, port := hostAndPort(listenAddr); port != 0 {
+			usePort("net.listen_addr", port)
+		}
+	}
+	usePort("rpc.port", c.config.RpcConfig.Port)
+	if c.config.MonitorConfig.Enabled {
+		usePort("monitor.port", c.config.MonitorConfig.Port)
+	}
+	if c.config.PProfConfig.Enabled {
+		usePort("pprof.port", c.config.PProfConfig.Port)
+	}
+}
+
+// checkConsensus 检查共识类型是否存在并且已经注册了对应的实现
+func (c *checker) checkConsensus() {
+	consensusType := c.config.ConsensusConfig.ConsensusType
+	if consensusType == 0 {
+		c.errorf("consensus.consensus_type", "is not set, e.g. 11 for pbft")
+		return
+	}
+	if consensus_provider.GetConsensusProvider(consensusType) == nil {
+		c.errorf("consensus.consensus_type", "no consensus provider is registered for type %d", consensusType)
+	}
+}
+
+// checkFaults 检查故障注入的配置, 启用故障注入的节点会丢弃或者延迟消息, 只应该在实验之中使用
+func (c *checker) checkFaults() {
+	faults := c.config.NetConfig.Faults
+	if _, err := net.FaultConfigFromLocalConf(faults); err != nil {
+		c.errorf("net.faults", "%v", err)
+		return
+	}
+	if faults.Enabled {
+		c.warnf("net.faults.enabled", "fault injection is enabled, messages will be dropped or delayed by %d rule(s) and %d partition(s)",
+			len(faults.Rules), len(faults.Partitions))
+	}
+}
+
+// checkAdmin 检查管理服务的凭证, 凭证为空或者是示例配置之中的占位符的时候节点拒绝启动
+func (c *checker) checkAdmin() {
+	adminConfig := c.config.RpcConfig.AdminConfig
+	if !adminConfig.Enabled {
+		return
+	}
+	if err := adminConfig.Validate(); err != nil {
+		c.errorf("rpc.admin.token", "%v", err)
+		return
+	}
+	mode := strings.ToLower(c.config.RpcConfig.TLSConfig.Mode)
+	if mode == "" || mode == "disable" {
+		c.warnf("rpc.admin.enabled", "admin service is enabled while rpc.tls.mode is disable, the admin token is sent in plaintext")
+	}
+}
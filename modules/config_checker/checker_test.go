@@ -0,0 +1,146 @@
+package config_checker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"zhanghefan123/security/localconf"
+)
+
+const (
+	peer1 = "QmPgjmUc7FfJtjn4FRCJN6QPV4k1ZfhVhkU9KxjZWaV6Xa"
+	peer2 = "QmRs3yoSXn8ghhEjBcH4YNxdJGmC3hMeZCVCeRQWQYYjWt"
+	peer3 = "QmTUz2DtUoTS2ZYyCeukjGQ9x8W2H4a4BT9wvNMWDScGXp"
+	peer4 = "QmUwtginfn8VZ3rPBpd2wKKTh7mKkJBmFZxvHmwt7aadjx"
+)
+
+// newConfig 创建一个只有一条链的配置
+func newConfig(t *testing.T) *localconf.CMConfig {
+	genesis := filepath.Join(t.TempDir(), "bc1.yml")
+	require.NoError(t, ioutil.WriteFile(genesis, []byte("chain_id: chain1\n"), 0644))
+	config := &localconf.CMConfig{}
+	config.NetConfig.ListenAddr = "/ip4/0.0.0.0/tcp/11301"
+	config.RpcConfig.Port = 12301
+	config.BlockChainConfig = []localconf.BlockchainConfig{{ChainId: "chain1", Genesis: genesis}}
+	return config
+}
+
+// problemsOf 返回和配置项相关的问题
+func problemsOf(problems []Problem, key string) []Problem {
+	result := make([]Problem, 0)
+	for _, problem := range problems {
+		if strings.HasPrefix(problem.Key, key) {
+			result = append(result, problem)
+		}
+	}
+	return result
+}
+
+func TestCheckSeeds(t *testing.T) {
+	config := newConfig(t)
+	config.NetConfig.Seeds = []string{
+		"/ip4/127.0.0.1/tcp/11302/p2p/" + peer2,
+		"/ip4/127.0.0.1/tcp/11303",
+		"ip4/127.0.0.1/tcp/11304/p2p/" + peer4,
+		"/ip4/127.0.0.1/tcp/11305/p2p/" + peer2,
+	}
+	problems := Check(config)
+
+	require.Empty(t, problemsOf(problems, "net.seeds[0]"))
+	// 没有 /p2p/ 的种子节点无法作为验证者
+	require.Len(t, problemsOf(problems, "net.seeds[1]"), 1)
+	require.Contains(t, problemsOf(problems, "net.seeds[1]")[0].Message, "/p2p/")
+	require.Len(t, problemsOf(problems, "net.seeds[2]"), 1)
+	require.Equal(t, LevelWarning, problemsOf(problems, "net.seeds[3]")[0].Level)
+	require.True(t, HasError(problems))
+}
+
+func TestCheckValidators(t *testing.T) {
+	config := newConfig(t)
+	config.NetConfig.Seeds = []string{
+		"/ip4/127.0.0.1/tcp/11302/p2p/" + peer2,
+		"/ip4/127.0.0.1/tcp/11303/p2p/" + peer3,
+		"/ip4/127.0.0.1/tcp/11304/p2p/" + peer4,
+	}
+	config.BlockChainConfig[0].Validators = []string{peer1, peer2, peer3, peer4, peer2}
+	problems := problemsOf(Check(config), "blockchain[0].validators")
+
+	// 重复的验证者, 自己的私钥没有加载, 能够连接到的 3 个验证者刚好满足 2f + 1
+	require.Len(t, problems, 1)
+	require.Contains(t, problems[0].Message, "duplicated")
+
+	config.NetConfig.Seeds = config.NetConfig.Seeds[:1]
+	problems = problemsOf(Check(config), "blockchain[0].validators")
+	require.True(t, HasError(problems))
+	require.Contains(t, problems[1].Message, "needs 3")
+}
+
+func TestCheckPortsAndConsensus(t *testing.T) {
+	config := newConfig(t)
+	config.MonitorConfig.Enabled = true
+	config.MonitorConfig.Port = 12301
+	config.PProfConfig.Port = 11301
+	problems := Check(config)
+
+	require.Len(t, problemsOf(problems, "monitor.port"), 1)
+	require.Contains(t, problemsOf(problems, "monitor.port")[0].Message, "rpc.port")
+	// 没有启用的 pprof 不占用端口
+	require.Empty(t, problemsOf(problems, "pprof.port"))
+	require.Len(t, problemsOf(problems, "consensus.consensus_type"), 1)
+
+	config.ConsensusConfig.ConsensusType = 255
+	problems = problemsOf(Check(config), "consensus.consensus_type")
+	require.Len(t, problems, 1)
+	require.Contains(t, problems[0].Message, "no consensus provider")
+}
+
+func TestCheckFiles(t *testing.T) {
+	config := newConfig(t)
+	config.NetConfig.TLSConfig.PrivKeyFile = filepath.Join(t.TempDir(), "private.key")
+	config.BlockChainConfig[0].Genesis = ""
+	problems := Check(config)
+
+	require.Len(t, problemsOf(problems, "net.tls.priv_key_file"), 1)
+	require.Contains(t, problemsOf(problems, "net.tls.priv_key_file")[0].Message, "does not exist")
+	require.Len(t, problemsOf(problems, "blockchain[0].genesis"), 1)
+}
+
+func TestCheckFaults(t *testing.T) {
+	config := newConfig(t)
+	require.Empty(t, problemsOf(Check(config), "net.faults"))
+
+	config.NetConfig.Faults.Enabled = true
+	config.NetConfig.Faults.Partitions = []localconf.PartitionConfig{{Nodes: []string{"node2"}}}
+	problems := problemsOf(Check(config), "net.faults.enabled")
+	require.Len(t, problems, 1)
+	require.Equal(t, LevelWarning, problems[0].Level)
+
+	config.NetConfig.Faults.Rules = []localconf.FaultRuleConfig{{MsgTypes: []string{"NO_SUCH_MSG"}}}
+	problems = problemsOf(Check(config), "net.faults")
+	require.Len(t, problems, 1)
+	require.Equal(t, LevelError, problems[0].Level)
+}
+
+func TestCheckAdmin(t *testing.T) {
+	config := newConfig(t)
+	require.Empty(t, problemsOf(Check(config), "rpc.admin"))
+
+	config.RpcConfig.AdminConfig.Enabled = true
+	for _, token := range []string{"", localconf.PlaceholderAdminToken} {
+		config.RpcConfig.AdminConfig.Token = token
+		problems := problemsOf(Check(config), "rpc.admin.token")
+		require.Len(t, problems, 1, token)
+		require.Equal(t, LevelError, problems[0].Level)
+	}
+
+	// 没有开启 tls 的时候凭证以明文传输
+	config.RpcConfig.AdminConfig.Token = "secret"
+	problems := problemsOf(Check(config), "rpc.admin")
+	require.Len(t, problems, 1)
+	require.Equal(t, LevelWarning, problems[0].Level)
+	config.RpcConfig.TLSConfig.Mode = "oneway"
+	require.Empty(t, problemsOf(Check(config), "rpc.admin"))
+}
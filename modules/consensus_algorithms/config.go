@@ -8,4 +8,5 @@ const (
 	ConsensusType_PBFT ConsensusProtocolType = 11
 )
 
-var PbftMsgBusTopics = []msgbus.Topic{msgbus.RecvConsensusMsg}
+// PbftMsgBusTopics pbft 需要订阅的主题, RecvTxPoolMsg 用于接收其他节点广播的待处理请求
+var PbftMsgBusTopics = []msgbus.Topic{msgbus.RecvConsensusMsg, msgbus.RecvTxPoolMsg}
@@ -241,8 +241,9 @@ type PrePrepare struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	UserId   string `protobuf:"bytes,1,opt,name=UserId,proto3" json:"UserId,omitempty"`
-	AccessId string `protobuf:"bytes,2,opt,name=AccessId,proto3" json:"AccessId,omitempty"`
+	UserId    string `protobuf:"bytes,1,opt,name=UserId,proto3" json:"UserId,omitempty"`
+	AccessId  string `protobuf:"bytes,2,opt,name=AccessId,proto3" json:"AccessId,omitempty"`
+	RequestId string `protobuf:"bytes,3,opt,name=RequestId,proto3" json:"RequestId,omitempty"`
 }
 
 func (x *PrePrepare) Reset() {
@@ -291,23 +292,95 @@ func (x *PrePrepare) GetAccessId() string {
 	return ""
 }
 
+func (x *PrePrepare) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+// 接入节点通过 pubsub 广播的待处理请求, 由主节点进行排序
+type PendingRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId string `protobuf:"bytes,1,opt,name=RequestId,proto3" json:"RequestId,omitempty"`
+	UserId    string `protobuf:"bytes,2,opt,name=UserId,proto3" json:"UserId,omitempty"`
+	AccessId  string `protobuf:"bytes,3,opt,name=AccessId,proto3" json:"AccessId,omitempty"`
+}
+
+func (x *PendingRequest) Reset() {
+	*x = PendingRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pbft_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PendingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PendingRequest) ProtoMessage() {}
+
+func (x *PendingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pbft_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PendingRequest.ProtoReflect.Descriptor instead.
+func (*PendingRequest) Descriptor() ([]byte, []int) {
+	return file_pbft_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PendingRequest) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *PendingRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *PendingRequest) GetAccessId() string {
+	if x != nil {
+		return x.AccessId
+	}
+	return ""
+}
+
 // 应该对应于 PBFTMsg 的 Msg 部分
 type Vote struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Type     VoteType `protobuf:"varint,1,opt,name=Type,proto3,enum=VoteType" json:"Type,omitempty"`
-	Voter    string   `protobuf:"bytes,2,opt,name=Voter,proto3" json:"Voter,omitempty"`
-	UserId   string   `protobuf:"bytes,3,opt,name=UserId,proto3" json:"UserId,omitempty"`
-	AccessId string   `protobuf:"bytes,4,opt,name=AccessId,proto3" json:"AccessId,omitempty"`
-	Judge    bool     `protobuf:"varint,5,opt,name=Judge,proto3" json:"Judge,omitempty"`
+	Type      VoteType `protobuf:"varint,1,opt,name=Type,proto3,enum=VoteType" json:"Type,omitempty"`
+	Voter     string   `protobuf:"bytes,2,opt,name=Voter,proto3" json:"Voter,omitempty"`
+	UserId    string   `protobuf:"bytes,3,opt,name=UserId,proto3" json:"UserId,omitempty"`
+	AccessId  string   `protobuf:"bytes,4,opt,name=AccessId,proto3" json:"AccessId,omitempty"`
+	Judge     bool     `protobuf:"varint,5,opt,name=Judge,proto3" json:"Judge,omitempty"`
+	RequestId string   `protobuf:"bytes,6,opt,name=RequestId,proto3" json:"RequestId,omitempty"` // 投票所属的请求, 用于区分同一个用户的不同轮次
 }
 
 func (x *Vote) Reset() {
 	*x = Vote{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_pbft_proto_msgTypes[2]
+		mi := &file_pbft_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -320,7 +393,7 @@ func (x *Vote) String() string {
 func (*Vote) ProtoMessage() {}
 
 func (x *Vote) ProtoReflect() protoreflect.Message {
-	mi := &file_pbft_proto_msgTypes[2]
+	mi := &file_pbft_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -333,7 +406,7 @@ func (x *Vote) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Vote.ProtoReflect.Descriptor instead.
 func (*Vote) Descriptor() ([]byte, []int) {
-	return file_pbft_proto_rawDescGZIP(), []int{2}
+	return file_pbft_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Vote) GetType() VoteType {
@@ -371,6 +444,13 @@ func (x *Vote) GetJudge() bool {
 	return false
 }
 
+func (x *Vote) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
 var File_pbft_proto protoreflect.FileDescriptor
 
 var file_pbft_proto_rawDesc = []byte{
@@ -378,20 +458,30 @@ var file_pbft_proto_rawDesc = []byte{
 	0x50, 0x42, 0x46, 0x54, 0x4d, 0x73, 0x67, 0x12, 0x20, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0c, 0x2e, 0x50, 0x42, 0x46, 0x54, 0x4d, 0x73, 0x67, 0x54,
 	0x79, 0x70, 0x65, 0x52, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x4d, 0x73, 0x67,
-	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x4d, 0x73, 0x67, 0x22, 0x40, 0x0a, 0x0a, 0x50,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x4d, 0x73, 0x67, 0x22, 0x5e, 0x0a, 0x0a, 0x50,
 	0x72, 0x65, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x55, 0x73, 0x65,
 	0x72, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x55, 0x73, 0x65, 0x72, 0x49,
 	0x64, 0x12, 0x1a, 0x0a, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49, 0x64, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49, 0x64, 0x22, 0x85, 0x01,
-	0x0a, 0x04, 0x56, 0x6f, 0x74, 0x65, 0x12, 0x1d, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0e, 0x32, 0x09, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52,
-	0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x55,
-	0x73, 0x65, 0x72, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x55, 0x73, 0x65,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49, 0x64, 0x12, 0x1c, 0x0a,
+	0x09, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x09, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x22, 0x62, 0x0a, 0x0e, 0x50,
+	0x65, 0x6e, 0x64, 0x69, 0x6e, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a,
+	0x09, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x09, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x55,
+	0x73, 0x65, 0x72, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x55, 0x73, 0x65,
 	0x72, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49, 0x64, 0x18,
-	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49, 0x64, 0x12,
-	0x14, 0x0a, 0x05, 0x4a, 0x75, 0x64, 0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05,
-	0x4a, 0x75, 0x64, 0x67, 0x65, 0x2a, 0x53, 0x0a, 0x04, 0x53, 0x74, 0x65, 0x70, 0x12, 0x08, 0x0a,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49, 0x64, 0x22,
+	0xa3, 0x01, 0x0a, 0x04, 0x56, 0x6f, 0x74, 0x65, 0x12, 0x1d, 0x0a, 0x04, 0x54, 0x79, 0x70, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x09, 0x2e, 0x56, 0x6f, 0x74, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x04, 0x54, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x56, 0x6f, 0x74, 0x65, 0x72,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x56, 0x6f, 0x74, 0x65, 0x72, 0x12, 0x16, 0x0a,
+	0x06, 0x55, 0x73, 0x65, 0x72, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x55,
+	0x73, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x49,
+	0x64, 0x12, 0x14, 0x0a, 0x05, 0x4a, 0x75, 0x64, 0x67, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08,
+	0x52, 0x05, 0x4a, 0x75, 0x64, 0x67, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x49, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x49, 0x64, 0x2a, 0x53, 0x0a, 0x04, 0x53, 0x74, 0x65, 0x70, 0x12, 0x08, 0x0a,
 	0x04, 0x49, 0x4e, 0x49, 0x54, 0x10, 0x00, 0x12, 0x0f, 0x0a, 0x0b, 0x50, 0x52, 0x45, 0x5f, 0x50,
 	0x52, 0x45, 0x50, 0x41, 0x52, 0x45, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x50, 0x52, 0x45, 0x50,
 	0x41, 0x52, 0x45, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x4f, 0x4d, 0x4d, 0x49, 0x54, 0x10,
@@ -422,14 +512,15 @@ func file_pbft_proto_rawDescGZIP() []byte {
 }
 
 var file_pbft_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_pbft_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_pbft_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_pbft_proto_goTypes = []interface{}{
-	(Step)(0),          // 0: Step
-	(PBFTMsgType)(0),   // 1: PBFTMsgType
-	(VoteType)(0),      // 2: VoteType
-	(*PBFTMsg)(nil),    // 3: PBFTMsg
-	(*PrePrepare)(nil), // 4: PrePrepare
-	(*Vote)(nil),       // 5: Vote
+	(Step)(0),              // 0: Step
+	(PBFTMsgType)(0),       // 1: PBFTMsgType
+	(VoteType)(0),          // 2: VoteType
+	(*PBFTMsg)(nil),        // 3: PBFTMsg
+	(*PrePrepare)(nil),     // 4: PrePrepare
+	(*PendingRequest)(nil), // 5: PendingRequest
+	(*Vote)(nil),           // 6: Vote
 }
 var file_pbft_proto_depIdxs = []int32{
 	1, // 0: PBFTMsg.Type:type_name -> PBFTMsgType
@@ -472,6 +563,18 @@ func file_pbft_proto_init() {
 			}
 		}
 		file_pbft_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PendingRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pbft_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Vote); i {
 			case 0:
 				return &v.state
@@ -490,7 +593,7 @@ func file_pbft_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_pbft_proto_rawDesc,
 			NumEnums:      3,
-			NumMessages:   3,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
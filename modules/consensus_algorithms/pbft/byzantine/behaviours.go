@@ -0,0 +1,199 @@
+package byzantine
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
+	"zhanghefan123/security/modules/utils"
+)
+
+// replayHistorySize Replay 记录的已经发送的投票的最大数量
+const replayHistorySize = 64
+
+// Func 将函数转换为 pbft.Behaviour
+type Func func(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg
+
+// Tamper 调用函数本身
+func (f Func) Tamper(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+	return f(from, to, msg)
+}
+
+// Chain 依次应用多个拜占庭行为, 前一个行为输出的每条消息都会交给后一个行为
+func Chain(behaviours ...pbft.Behaviour) pbft.Behaviour {
+	return Func(func(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+		msgs := []*pbftPb.PBFTMsg{msg}
+		for _, behaviour := range behaviours {
+			next := make([]*pbftPb.PBFTMsg, 0, len(msgs))
+			for _, m := range msgs {
+				next = append(next, behaviour.Tamper(from, to, m)...)
+			}
+			msgs = next
+		}
+		return msgs
+	})
+}
+
+// decodeVote 解析消息之中的投票, prePrepare 消息以及无法解析的消息返回 false
+func decodeVote(msg *pbftPb.PBFTMsg) (*pbftPb.Vote, bool) {
+	if msg.Type == pbftPb.PBFTMsgType_MSG_PRE_PREPARE {
+		return nil, false
+	}
+	vote := new(pbftPb.Vote)
+	if err := utils.Unmarshal(msg.Msg, vote); err != nil {
+		return nil, false
+	}
+	return vote, true
+}
+
+// encodeVote 将投票重新封装为消息
+func encodeVote(msgType pbftPb.PBFTMsgType, vote *pbftPb.Vote) *pbftPb.PBFTMsg {
+	return &pbftPb.PBFTMsg{
+		Type: msgType,
+		Msg:  utils.MustMarshal(vote),
+	}
+}
+
+// copyVote 拷贝投票, 避免修改发送给其他节点的同一个投票
+func copyVote(vote *pbftPb.Vote) *pbftPb.Vote {
+	return message.NewVote(vote.Type, vote.Voter, vote.UserId, vote.AccessId, vote.RequestId, vote.Judge)
+}
+
+// hasType 判断消息类型是否在列表之中, 列表为空的时候匹配所有的投票
+func hasType(types []pbftPb.PBFTMsgType, msgType pbftPb.PBFTMsgType) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// FlipJudge 将发送给所有节点的指定类型的投票的判断取反, 类型为空的时候取反所有的投票
+func FlipJudge(types ...pbftPb.PBFTMsgType) pbft.Behaviour {
+	return Func(func(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+		vote, ok := decodeVote(msg)
+		if !ok || !hasType(types, msg.Type) {
+			return []*pbftPb.PBFTMsg{msg}
+		}
+		vote.Judge = !vote.Judge
+		return []*pbftPb.PBFTMsg{encodeVote(msg.Type, vote)}
+	})
+}
+
+// Equivocate 对 prepare 以及 commit 投票进行模棱两可的投票:
+// 发送给 validators 之中下标为奇数的节点的投票判断取反, 其他节点收到的是真实的判断
+func Equivocate(validators []string) pbft.Behaviour {
+	flipped := make(map[string]struct{})
+	for i, validator := range validators {
+		if i%2 == 1 {
+			flipped[validator] = struct{}{}
+		}
+	}
+	flip := FlipJudge(pbftPb.PBFTMsgType_MSG_PREPARE, pbftPb.PBFTMsgType_MSG_COMMIT)
+	return Func(func(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+		if _, ok := flipped[to]; !ok {
+			return []*pbftPb.PBFTMsg{msg}
+		}
+		return flip.Tamper(from, to, msg)
+	})
+}
+
+// SilentCommit 在提交阶段保持沉默, 不发送 commit 投票
+func SilentCommit() pbft.Behaviour {
+	return Func(func(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+		if msg.Type == pbftPb.PBFTMsgType_MSG_COMMIT {
+			return nil
+		}
+		return []*pbftPb.PBFTMsg{msg}
+	})
+}
+
+// WrongReply 向接入节点返回相反的认证结果
+func WrongReply() pbft.Behaviour {
+	return FlipJudge(pbftPb.PBFTMsgType_MSG_REPLY)
+}
+
+// ForgeVoter 除了自己的投票之外, 再以 impersonated 之中每个验证者的名义发送一份相同的投票
+func ForgeVoter(impersonated ...string) pbft.Behaviour {
+	return Func(func(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+		msgs := []*pbftPb.PBFTMsg{msg}
+		vote, ok := decodeVote(msg)
+		if !ok {
+			return msgs
+		}
+		for _, voter := range impersonated {
+			forged := copyVote(vote)
+			forged.Voter = voter
+			msgs = append(msgs, encodeVote(msg.Type, forged))
+		}
+		return msgs
+	})
+}
+
+// PhantomVotes 每次发送消息的时候, 额外为 users 之中的每个用户发送 prepare 以及 commit 投票,
+// 这些用户从来没有经过主节点排序, 诚实的节点不会收到对应的 prePrepare
+func PhantomVotes(users ...string) pbft.Behaviour {
+	var mutex sync.Mutex
+	round := 0
+	return Func(func(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+		mutex.Lock()
+		round++
+		requestId := fmt.Sprintf("phantom-%s-%d", from, round)
+		mutex.Unlock()
+		msgs := []*pbftPb.PBFTMsg{msg}
+		for _, user := range users {
+			prepare := message.NewVote(pbftPb.VoteType_VOTE_PREPARE, from, user, from, requestId, true)
+			commit := message.NewVote(pbftPb.VoteType_VOTE_COMMIT, from, user, from, requestId, true)
+			msgs = append(msgs, encodeVote(pbftPb.PBFTMsgType_MSG_PREPARE, prepare),
+				encodeVote(pbftPb.PBFTMsgType_MSG_COMMIT, commit))
+		}
+		return msgs
+	})
+}
+
+// replay 记录已经发送的投票, 之后发送投票的时候一起重放
+type replay struct {
+	mutex   sync.Mutex
+	history []*pbftPb.PBFTMsg
+	votes   []*pbftPb.Vote
+}
+
+// Replay 每次发送投票的时候, 额外重放之前发送过的属于其他请求的投票
+func Replay() pbft.Behaviour {
+	return &replay{}
+}
+
+// Tamper 记录这次的投票并且附加之前的投票
+func (r *replay) Tamper(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg {
+	msgs := []*pbftPb.PBFTMsg{msg}
+	vote, ok := decodeVote(msg)
+	if !ok {
+		return msgs
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, old := range r.votes {
+		if old.RequestId != vote.RequestId {
+			msgs = append(msgs, r.history[i])
+		}
+	}
+	// 同一个投票会发送给每个节点, 只记录一次
+	for _, old := range r.history {
+		if old.Type == msg.Type && bytes.Equal(old.Msg, msg.Msg) {
+			return msgs
+		}
+	}
+	r.history = append(r.history, msg)
+	r.votes = append(r.votes, vote)
+	if len(r.history) > replayHistorySize {
+		r.history = r.history[1:]
+		r.votes = r.votes[1:]
+	}
+	return msgs
+}
@@ -0,0 +1,133 @@
+package byzantine
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
+)
+
+// voteMsg 创建 node4 发出的投票消息
+func voteMsg(voteType pbftPb.VoteType, requestId string, judge bool) *pbftPb.PBFTMsg {
+	vote := message.NewVote(voteType, "node4", "alice", "node2", requestId, judge)
+	switch voteType {
+	case pbftPb.VoteType_VOTE_PREPARE:
+		return message.SerializePrepareConsensusMessage(vote)
+	case pbftPb.VoteType_VOTE_COMMIT:
+		return message.SerializeCommitConsensusMessage(vote)
+	default:
+		return message.SerializeReplyConsensusMessage(vote)
+	}
+}
+
+// votesOf 解析所有消息之中的投票
+func votesOf(t *testing.T, msgs []*pbftPb.PBFTMsg) []*pbftPb.Vote {
+	votes := make([]*pbftPb.Vote, 0, len(msgs))
+	for _, msg := range msgs {
+		vote, ok := decodeVote(msg)
+		require.True(t, ok)
+		votes = append(votes, vote)
+	}
+	return votes
+}
+
+func TestEquivocate(t *testing.T) {
+	behaviour := Equivocate([]string{"node1", "node2", "node3", "node4"})
+	prepare := voteMsg(pbftPb.VoteType_VOTE_PREPARE, "r1", true)
+	require.True(t, votesOf(t, behaviour.Tamper("node4", "node1", prepare))[0].Judge)
+	require.False(t, votesOf(t, behaviour.Tamper("node4", "node2", prepare))[0].Judge)
+	require.True(t, votesOf(t, behaviour.Tamper("node4", "node3", prepare))[0].Judge)
+	// 响应不受影响
+	reply := voteMsg(pbftPb.VoteType_VOTE_REPLY, "r1", true)
+	require.True(t, votesOf(t, behaviour.Tamper("node4", "node2", reply))[0].Judge)
+	// 原来的消息没有被修改
+	require.True(t, votesOf(t, []*pbftPb.PBFTMsg{prepare})[0].Judge)
+}
+
+func TestSilentCommitAndWrongReply(t *testing.T) {
+	require.Empty(t, SilentCommit().Tamper("node4", "node1", voteMsg(pbftPb.VoteType_VOTE_COMMIT, "r1", true)))
+	require.Len(t, SilentCommit().Tamper("node4", "node1", voteMsg(pbftPb.VoteType_VOTE_PREPARE, "r1", true)), 1)
+
+	replies := WrongReply().Tamper("node4", "node2", voteMsg(pbftPb.VoteType_VOTE_REPLY, "r1", true))
+	require.False(t, votesOf(t, replies)[0].Judge)
+	commits := WrongReply().Tamper("node4", "node2", voteMsg(pbftPb.VoteType_VOTE_COMMIT, "r1", true))
+	require.True(t, votesOf(t, commits)[0].Judge)
+}
+
+func TestForgeVoter(t *testing.T) {
+	msgs := ForgeVoter("node2", "node3").Tamper("node4", "node1", voteMsg(pbftPb.VoteType_VOTE_PREPARE, "r1", false))
+	votes := votesOf(t, msgs)
+	require.Len(t, votes, 3)
+	require.Equal(t, []string{"node4", "node2", "node3"}, []string{votes[0].Voter, votes[1].Voter, votes[2].Voter})
+
+	preprepare := message.SerializePrePrepareConsensusMessage(message.NewPrePrepare("alice", "node2", "r1"))
+	require.Len(t, ForgeVoter("node2").Tamper("node4", "node1", preprepare), 1)
+}
+
+func TestPhantomVotes(t *testing.T) {
+	preprepare := message.SerializePrePrepareConsensusMessage(message.NewPrePrepare("alice", "node2", "r1"))
+	msgs := PhantomVotes("ghost").Tamper("node4", "node1", preprepare)
+	require.Len(t, msgs, 3)
+	votes := votesOf(t, msgs[1:])
+	for _, vote := range votes {
+		require.Equal(t, "ghost", vote.UserId)
+		require.Equal(t, "node4", vote.Voter)
+		require.True(t, vote.Judge)
+	}
+	require.Equal(t, votes[0].RequestId, votes[1].RequestId)
+	next := votesOf(t, PhantomVotes("ghost").Tamper("node4", "node1", preprepare)[1:])
+	require.Equal(t, votes[0].RequestId, next[0].RequestId, "each behaviour counts its own rounds")
+}
+
+func TestReplay(t *testing.T) {
+	behaviour := Replay()
+	first := voteMsg(pbftPb.VoteType_VOTE_PREPARE, "r1", true)
+	require.Len(t, behaviour.Tamper("node4", "node1", first), 1)
+	require.Len(t, behaviour.Tamper("node4", "node2", first), 1)
+	// 同一个请求的投票不会被重放
+	require.Len(t, behaviour.Tamper("node4", "node1", voteMsg(pbftPb.VoteType_VOTE_COMMIT, "r1", true)), 1)
+
+	msgs := behaviour.Tamper("node4", "node1", voteMsg(pbftPb.VoteType_VOTE_PREPARE, "r2", true))
+	votes := votesOf(t, msgs)
+	require.Len(t, votes, 3)
+	require.Equal(t, "r2", votes[0].RequestId)
+	require.Equal(t, "r1", votes[1].RequestId)
+	require.Equal(t, "r1", votes[2].RequestId)
+}
+
+func TestChain(t *testing.T) {
+	behaviour := Chain(FlipJudge(), ForgeVoter("node2"))
+	votes := votesOf(t, behaviour.Tamper("node4", "node1", voteMsg(pbftPb.VoteType_VOTE_COMMIT, "r1", true)))
+	require.Len(t, votes, 2)
+	require.False(t, votes[0].Judge)
+	require.False(t, votes[1].Judge)
+	require.Equal(t, "node2", votes[1].Voter)
+	require.Empty(t, Chain(SilentCommit(), ForgeVoter("node2")).Tamper("node4", "node1",
+		voteMsg(pbftPb.VoteType_VOTE_COMMIT, "r1", true)))
+}
+
+func TestParse(t *testing.T) {
+	validators := []string{"node1", "node2", "node3", "node4"}
+	behaviour, err := Parse("equivocate", validators)
+	require.NoError(t, err)
+	prepare := voteMsg(pbftPb.VoteType_VOTE_PREPARE, "r1", true)
+	require.False(t, votesOf(t, behaviour.Tamper("node4", "node2", prepare))[0].Judge)
+
+	behaviour, err = Parse("forge_voter: node2, node3", validators)
+	require.NoError(t, err)
+	require.Len(t, behaviour.Tamper("node4", "node1", prepare), 3)
+
+	_, err = Parse("forge_voter", validators)
+	require.Error(t, err)
+	_, err = Parse("silent_commit:node1", validators)
+	require.Error(t, err)
+	_, err = Parse("lazy", validators)
+	require.Error(t, err)
+
+	behaviour, err = ParseAll(nil, validators)
+	require.NoError(t, err)
+	require.Nil(t, behaviour)
+	behaviour, err = ParseAll([]string{"wrong_reply", "silent_commit"}, validators)
+	require.NoError(t, err)
+	require.Empty(t, behaviour.Tamper("node4", "node1", voteMsg(pbftPb.VoteType_VOTE_COMMIT, "r1", true)))
+}
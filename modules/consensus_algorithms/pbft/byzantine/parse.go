@@ -0,0 +1,102 @@
+package byzantine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft"
+)
+
+// 拜占庭行为的名称, 用于场景文件等只能使用字符串描述行为的地方
+const (
+	NameFlipJudge    = "flip_judge"    // FlipJudge, 取反所有的投票
+	NameEquivocate   = "equivocate"    // Equivocate
+	NameSilentCommit = "silent_commit" // SilentCommit
+	NameWrongReply   = "wrong_reply"   // WrongReply
+	NameForgeVoter   = "forge_voter"   // ForgeVoter, 参数为冒充的验证者, 例如 forge_voter:node2,node3
+	NamePhantomVotes = "phantom_votes" // PhantomVotes, 参数为虚构的用户, 例如 phantom_votes:ghost1,ghost2
+	NameReplay       = "replay"        // Replay
+)
+
+// factories 名称 -> 根据参数以及验证者列表创建行为的函数
+var factories = map[string]func(args, validators []string) (pbft.Behaviour, error){
+	NameFlipJudge:    noArgs(func([]string) pbft.Behaviour { return FlipJudge() }),
+	NameEquivocate:   noArgs(Equivocate),
+	NameSilentCommit: noArgs(func([]string) pbft.Behaviour { return SilentCommit() }),
+	NameWrongReply:   noArgs(func([]string) pbft.Behaviour { return WrongReply() }),
+	NameReplay:       noArgs(func([]string) pbft.Behaviour { return Replay() }),
+	NameForgeVoter: func(args, validators []string) (pbft.Behaviour, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s needs the impersonated validators", NameForgeVoter)
+		}
+		return ForgeVoter(args...), nil
+	},
+	NamePhantomVotes: func(args, validators []string) (pbft.Behaviour, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s needs the phantom users", NamePhantomVotes)
+		}
+		return PhantomVotes(args...), nil
+	},
+}
+
+// noArgs 包装不需要参数的行为
+func noArgs(create func(validators []string) pbft.Behaviour) func(args, validators []string) (pbft.Behaviour, error) {
+	return func(args, validators []string) (pbft.Behaviour, error) {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("behaviour takes no arguments, got %v", args)
+		}
+		return create(validators), nil
+	}
+}
+
+// Names 返回所有可以解析的行为名称
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse 根据名称创建拜占庭行为, 参数跟在冒号之后并用逗号分隔, validators 为链的验证者列表, Equivocate 需要
+func Parse(spec string, validators []string) (pbft.Behaviour, error) {
+	name, rawArgs := spec, ""
+	if index := strings.Index(spec, ":"); index >= 0 {
+		name, rawArgs = spec[:index], spec[index+1:]
+	}
+	create, ok := factories[strings.TrimSpace(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown byzantine behaviour %q, expect one of %s", name, strings.Join(Names(), ", "))
+	}
+	args := make([]string, 0)
+	for _, arg := range strings.Split(rawArgs, ",") {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			args = append(args, arg)
+		}
+	}
+	behaviour, err := create(args, validators)
+	if err != nil {
+		return nil, fmt.Errorf("parse byzantine behaviour %q failed, %v", spec, err)
+	}
+	return behaviour, nil
+}
+
+// ParseAll 依次解析多个行为并按照顺序组合, 没有行为的时候返回 nil, 表示诚实节点
+func ParseAll(specs []string, validators []string) (pbft.Behaviour, error) {
+	behaviours := make([]pbft.Behaviour, 0, len(specs))
+	for _, spec := range specs {
+		behaviour, err := Parse(spec, validators)
+		if err != nil {
+			return nil, err
+		}
+		behaviours = append(behaviours, behaviour)
+	}
+	switch len(behaviours) {
+	case 0:
+		return nil, nil
+	case 1:
+		return behaviours[0], nil
+	}
+	return Chain(behaviours...), nil
+}
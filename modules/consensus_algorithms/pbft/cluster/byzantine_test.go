@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"zhanghefan123/security/common/msgbus"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/byzantine"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
+	"zhanghefan123/security/modules/decision_log"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/modules/utils"
+	"zhanghefan123/security/protobuf/pb-go/net"
+)
+
+func TestByzantineEquivocation(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"},
+		WithByzantine("node4", byzantine.Equivocate(NodeIds(4))))
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	c.RequireAgreement(t, "mallory", pb.AuthenticationResult_IllegalUser)
+}
+
+func TestByzantineEquivocatingPrimary(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"},
+		WithByzantine("node1", byzantine.Equivocate(NodeIds(4))))
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+}
+
+func TestByzantineSilentCommit(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"}, WithByzantine("node3", byzantine.SilentCommit()))
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	c.RequireAgreement(t, "mallory", pb.AuthenticationResult_IllegalUser)
+}
+
+func TestByzantineWrongReply(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"}, WithByzantine("node4", byzantine.WrongReply()))
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	c.RequireAgreement(t, "mallory", pb.AuthenticationResult_IllegalUser)
+}
+
+func TestByzantineForgedVoter(t *testing.T) {
+	// 以两个诚实节点的名义投出相反的票, 如果不检查发送者, 伪造的投票可以单独达到法定人数
+	c := newStartedCluster(t, 4, []string{"alice"}, WithByzantine("node4",
+		byzantine.FlipJudge(), byzantine.ForgeVoter("node2", "node3")))
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	c.RequireAgreement(t, "mallory", pb.AuthenticationResult_IllegalUser)
+}
+
+func TestByzantinePhantomVotes(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice", "ghost"}, WithByzantine("node4", byzantine.PhantomVotes("ghost")))
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	// 没有经过排序的用户不会在诚实节点上开始一轮共识
+	for _, node := range c.Honest() {
+		_, err := node.Consensus.DumpUserState("ghost")
+		require.ErrorIs(t, err, variables.ErrUserRoundNotFound, node.Id)
+	}
+	c.RequireAgreement(t, "ghost", pb.AuthenticationResult_LegalUser)
+}
+
+// publishPrePrepare 让 node 收到 from 发送的对 userId 的请求 requestId 的排序
+func publishPrePrepare(node *Node, from, userId, requestId string) {
+	payload := utils.MustMarshal(message.SerializePrePrepareConsensusMessage(message.NewPrePrepare(userId, from, requestId)))
+	node.MsgBus.PublishSync(msgbus.RecvConsensusMsg, &net.NetMsg{Payload: payload, Type: net.NetMsg_CONSENSUS_MSG, To: from})
+}
+
+func TestByzantineForgedPrePrepare(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	isolate(c)
+	// 不是主节点的 node4 自己对请求进行排序, 诚实节点不能因此开始一轮共识
+	for _, node := range c.Honest() {
+		publishPrePrepare(node, "node4", "mallory", "forged")
+	}
+	require.Never(t, func() bool {
+		for _, node := range c.Honest() {
+			if roundOf(t, node, "mallory") != "" {
+				return true
+			}
+		}
+		return false
+	}, 200*time.Millisecond, 10*time.Millisecond)
+	c.Router.SetFilter(nil)
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+}
+
+func TestByzantinePrePrepareReplacesRound(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	isolate(c)
+	node3 := c.Nodes[2]
+	publishPrePrepare(node3, "node1", "alice", "request1")
+	require.Eventually(t, func() bool { return roundOf(t, node3, "alice") == "request1" }, 5*time.Second, 10*time.Millisecond)
+
+	// 其他节点发送的排序不能替换正在进行的一轮, 主节点对新的请求进行排序之后才会替换
+	publishPrePrepare(node3, "node4", "alice", "request2")
+	require.Never(t, func() bool { return roundOf(t, node3, "alice") != "request1" }, 200*time.Millisecond, 10*time.Millisecond)
+	publishPrePrepare(node3, "node1", "alice", "request2")
+	require.Eventually(t, func() bool { return roundOf(t, node3, "alice") == "request2" }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestByzantineReplay(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"}, WithByzantine("node4",
+		byzantine.Replay(), byzantine.FlipJudge(pbftPb.PBFTMsgType_MSG_REPLY)))
+	for i := 0; i < 3; i++ {
+		c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+		c.RequireAgreement(t, "mallory", pb.AuthenticationResult_IllegalUser)
+	}
+}
+
+func TestByzantineSwitchedOnAtRuntime(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	require.NoError(t, c.SetByzantine("node2", byzantine.FlipJudge(), byzantine.SilentCommit()))
+	require.Len(t, c.Honest(), 3)
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	require.NoError(t, c.SetByzantine("node2"))
+	require.Len(t, c.Honest(), 4)
+	require.ErrorIs(t, c.SetByzantine("node9"), ErrNodeNotFound)
+}
+
+func TestByzantineDecisionLog(t *testing.T) {
+	sink := decision_log.NewMemorySink()
+	c := newStartedCluster(t, 4, []string{"alice"}, WithDecisionLog(sink),
+		WithByzantine("node4", byzantine.Equivocate(NodeIds(4)), byzantine.WrongReply()))
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	c.RequireAgreement(t, "mallory", pb.AuthenticationResult_IllegalUser)
+
+	// 拜占庭节点只篡改发送出去的消息, 所有节点记录的决定仍然需要一致并且带有法定人数证书
+	report := decision_log.Check(sink.Records(), decision_log.Options{LivenessGap: DefaultTimeout})
+	require.True(t, report.Passed(), report.Violations)
+	require.Equal(t, []string{"node1", "node2", "node3", "node4"}, report.Nodes)
+	// 两个用户分别通过三个诚实节点接入
+	require.Equal(t, 6, report.Requests)
+	require.Equal(t, report.Requests, report.Replies)
+}
@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/state"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
+	"zhanghefan123/security/modules/request_pool"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/modules/utils"
+	"zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// roundOf 返回节点上用户正在进行的这一轮所对应的请求 id, 没有进行中的一轮的时候返回空
+func roundOf(t *testing.T, node *Node, userId string) string {
+	data, err := node.Consensus.DumpUserState(userId)
+	if err != nil {
+		return ""
+	}
+	dump := &state.UserStateDump{}
+	require.NoError(t, json.Unmarshal(data, dump))
+	return dump.RequestId
+}
+
+// isolate 丢弃所有节点之间的消息, 认证请求会一直停留在接入节点上
+func isolate(c *Cluster) {
+	c.Router.SetFilter(func(from, to string, msg *net.NetMsg) bool { return false })
+}
+
+// startRound 在后台通过 nodeId 提交认证请求, 等待接入节点开始这一轮, 返回这一轮的请求 id 以及取消函数
+func startRound(t *testing.T, c *Cluster, nodeId, userId string) (string, context.CancelFunc, <-chan error) {
+	node, err := c.Node(nodeId)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	errC := make(chan error, 1)
+	go func() {
+		_, err := c.AuthenticateContext(ctx, nodeId, userId)
+		errC <- err
+	}()
+	var requestId string
+	require.Eventually(t, func() bool {
+		requestId = roundOf(t, node, userId)
+		return requestId != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	return requestId, cancel, errC
+}
+
+func TestClusterCallerCancellation(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	isolate(c)
+	node2 := c.Nodes[1]
+
+	_, cancel, errC := startRound(t, c, "node2", "alice")
+	cancel()
+	require.ErrorIs(t, <-errC, context.Canceled)
+
+	// 调用者离开之后接入节点放弃这一轮, 同一个用户可以重新发起认证
+	require.Eventually(t, func() bool { return roundOf(t, node2, "alice") == "" }, 5*time.Second, 10*time.Millisecond)
+	c.Router.SetFilter(nil)
+	c.RequireAuthentication(t, "node2", "alice", pb.AuthenticationResult_LegalUser)
+}
+
+func TestClusterConflictingRequestKeepsRound(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	isolate(c)
+	node2 := c.Nodes[1]
+	requestId, _, _ := startRound(t, c, "node2", "alice")
+
+	// 同一个用户的另一个请求直接到达共识模块, 它被放弃, 不能影响正在进行的那一轮
+	requireAbandoned(t, node2, "alice")
+	require.Never(t, func() bool { return roundOf(t, node2, "alice") != requestId }, 200*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestClusterLocalRequestKeepsForeignRound(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	isolate(c)
+	node3 := c.Nodes[2]
+
+	// node3 正在参与其他节点接入的 alice 的一轮, 它不等待结果
+	node3.Consensus.Lock()
+	require.NoError(t, node3.Consensus.ConsensusState.AddUserForAuthentication("alice", "foreign", nil))
+	node3.Consensus.Unlock()
+
+	// 本地接入的请求被放弃, 其他接入节点的那一轮不能少掉 node3 的投票
+	requireAbandoned(t, node3, "alice")
+	require.Equal(t, "foreign", roundOf(t, node3, "alice"))
+}
+
+// requireAbandoned 将用户的一个新请求直接交给 node 的共识模块, 要求这个请求被放弃
+func requireAbandoned(t *testing.T, node *Node, userId string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	finishChannel := make(chan *pb.RpcMessage, 1)
+	conflicting := request_pool.NewRequest(ctx, userId, &pb.RpcMessage{
+		Type:    pb.RpcMessageType_AuthRequest,
+		Content: utils.MustMarshal(&pb.AuthenticationRequest{UserId: userId}),
+	}, finishChannel)
+	conflicting.RequestId = "conflicting"
+	pbft.HandleAuthenticationRequest(node.Consensus, conflicting)
+	select {
+	case _, ok := <-finishChannel:
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("conflicting request was not abandoned")
+	}
+}
+
+func TestClusterExpireUserRound(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	isolate(c)
+	node2 := c.Nodes[1]
+	require.ErrorIs(t, node2.Consensus.ExpireUserRound("alice"), variables.ErrUserRoundNotFound)
+
+	replyC := make(chan *pb.AuthenticationReply, 1)
+	go func() {
+		reply, _ := c.Authenticate("node2", "alice")
+		replyC <- reply
+	}()
+	require.Eventually(t, func() bool { return roundOf(t, node2, "alice") != "" }, 5*time.Second, 10*time.Millisecond)
+
+	// 管理服务强制结束这一轮, 等待的调用者收到 ConsensusTimeout
+	require.NoError(t, node2.Consensus.ExpireUserRound("alice"))
+	reply := <-replyC
+	require.NotNil(t, reply)
+	require.Equal(t, pb.AuthenticationResult_ConsensusTimeout, reply.Result)
+	require.Equal(t, "", roundOf(t, node2, "alice"))
+}
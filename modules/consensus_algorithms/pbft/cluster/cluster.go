@@ -0,0 +1,261 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"zhanghefan123/security/common/msgbus"
+	consensusutils "zhanghefan123/security/consensus-utils"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/byzantine"
+	"zhanghefan123/security/modules/decision_log"
+	"zhanghefan123/security/modules/request_pool"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/modules/utils"
+	"zhanghefan123/security/protocol"
+	"zhanghefan123/security/protocol/test"
+)
+
+const (
+	DefaultChainId   = "chain1"         // 默认的链 id
+	DefaultQueueSize = 100              // 默认的请求池队列大小
+	DefaultTimeout   = 10 * time.Second // 默认的认证超时时间
+)
+
+var (
+	ErrNodeNotFound     = errors.New("node not found in cluster")
+	ErrRequestAbandoned = errors.New("authentication request abandoned by consensus")
+)
+
+// Node 集群之中的一个节点, 拥有独立的消息总线, 请求池以及共识实例
+type Node struct {
+	Id          string
+	MsgBus      msgbus.MessageBus
+	RequestPool *request_pool.RequestPool
+	Consensus   *pbft.ConsensusPbftImpl
+}
+
+// Cluster 在一个进程之中运行的多节点 pbft 集群, 节点之间通过 Router 通信
+type Cluster struct {
+	ChainId    string
+	Nodes      []*Node
+	Router     *Router
+	logger     protocol.Logger
+	queueSize  int
+	timeout    time.Duration
+	byzantines map[string]pbft.Behaviour // 节点 id -> 节点的拜占庭行为
+	decisions  decision_log.Sink         // 所有节点共用的决策日志
+	stopOnce   sync.Once
+}
+
+// Option 集群的配置项
+type Option func(c *Cluster)
+
+// WithChainId 设置链 id
+func WithChainId(chainId string) Option {
+	return func(c *Cluster) {
+		c.ChainId = chainId
+	}
+}
+
+// WithLogger 设置所有节点使用的日志记录器
+func WithLogger(logger protocol.Logger) Option {
+	return func(c *Cluster) {
+		c.logger = logger
+	}
+}
+
+// WithQueueSize 设置每个节点请求池的队列大小
+func WithQueueSize(queueSize int) Option {
+	return func(c *Cluster) {
+		c.queueSize = queueSize
+	}
+}
+
+// WithTimeout 设置 Authenticate 等待结果的超时时间
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Cluster) {
+		c.timeout = timeout
+	}
+}
+
+// WithByzantine 设置节点的拜占庭行为, 多个行为按照顺序组合
+func WithByzantine(nodeId string, behaviours ...pbft.Behaviour) Option {
+	return func(c *Cluster) {
+		c.byzantines[nodeId] = chainBehaviours(behaviours)
+	}
+}
+
+// WithDecisionLog 所有节点将决策记录写入 sink, 之后可以使用 decision_log.Check 进行检查
+func WithDecisionLog(sink decision_log.Sink) Option {
+	return func(c *Cluster) {
+		c.decisions = sink
+	}
+}
+
+// NodeIds 返回 n 个节点的 id: node1 ... nodeN, 第一个节点为主节点
+func NodeIds(n int) []string {
+	nodeIds := make([]string, n)
+	for i := range nodeIds {
+		nodeIds[i] = fmt.Sprintf("node%d", i+1)
+	}
+	return nodeIds
+}
+
+// NewCluster 创建 n 个节点的集群, legalUsers 为认证域之中注册的合法用户, 创建之后需要调用 Start
+func NewCluster(n int, legalUsers []string, opts ...Option) (*Cluster, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid cluster size %d", n)
+	}
+	c := &Cluster{
+		ChainId:    DefaultChainId,
+		Router:     NewRouter(),
+		logger:     test.HoleLogger{},
+		queueSize:  DefaultQueueSize,
+		timeout:    DefaultTimeout,
+		byzantines: make(map[string]pbft.Behaviour),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	nodeIds := NodeIds(n)
+	// 没有合法用户的时候同样使用空的列表, 不能回退到全局的 localconf
+	legalUsers = append([]string{}, legalUsers...)
+	for _, nodeId := range nodeIds {
+		node := &Node{
+			Id:          nodeId,
+			MsgBus:      msgbus.NewMessageBus(),
+			RequestPool: request_pool.NewRequestPool(c.queueSize, nil),
+		}
+		consensus, err := pbft.New(&consensusutils.ConsensusImplConfig{
+			ChainId:     c.ChainId,
+			NodeId:      nodeId,
+			MsgBus:      node.MsgBus,
+			Logger:      c.logger,
+			RequestPool: node.RequestPool,
+			DecisionLog: c.decisions,
+			Validators:  nodeIds,
+			LegalUsers:  legalUsers,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create consensus of %s failed, %v", nodeId, err)
+		}
+		node.Consensus = consensus
+		if behaviour, ok := c.byzantines[nodeId]; ok {
+			consensus.SetBehaviour(behaviour)
+		}
+		c.Router.Attach(nodeId, node.MsgBus)
+		c.Nodes = append(c.Nodes, node)
+	}
+	return c, nil
+}
+
+// Start 启动所有节点的请求池以及共识
+func (c *Cluster) Start() error {
+	for _, node := range c.Nodes {
+		node.RequestPool.Start()
+		if err := node.Consensus.Start(); err != nil {
+			return fmt.Errorf("start consensus of %s failed, %v", node.Id, err)
+		}
+	}
+	return nil
+}
+
+// Stop 停止所有的节点, 重复调用是安全的.
+// 消息总线关闭之后仍在发送的协程会向已经关闭的 channel 写入, 因此只注销主题, 不关闭消息总线
+func (c *Cluster) Stop() {
+	c.stopOnce.Do(func() {
+		for _, node := range c.Nodes {
+			c.Router.Detach(node.Id)
+			_ = node.Consensus.Stop()
+			node.RequestPool.Stop()
+		}
+	})
+}
+
+// Node 根据 id 获取节点
+func (c *Cluster) Node(nodeId string) (*Node, error) {
+	for _, node := range c.Nodes {
+		if node.Id == nodeId {
+			return node, nil
+		}
+	}
+	return nil, ErrNodeNotFound
+}
+
+// SetByzantine 在运行的时候修改节点的拜占庭行为, 不传入行为表示恢复为诚实节点
+func (c *Cluster) SetByzantine(nodeId string, behaviours ...pbft.Behaviour) error {
+	node, err := c.Node(nodeId)
+	if err != nil {
+		return err
+	}
+	node.Consensus.SetBehaviour(chainBehaviours(behaviours))
+	return nil
+}
+
+// Honest 返回所有没有设置拜占庭行为的节点
+func (c *Cluster) Honest() []*Node {
+	honest := make([]*Node, 0, len(c.Nodes))
+	for _, node := range c.Nodes {
+		if node.Consensus.Behaviour() == nil {
+			honest = append(honest, node)
+		}
+	}
+	return honest
+}
+
+// Primary 返回当前视图的主节点 id
+func (c *Cluster) Primary() string {
+	return c.Nodes[0].Consensus.ValidatorSet.Primary()
+}
+
+// Authenticate 以 nodeId 作为接入节点提交用户的认证请求, 和 AuthenticationService 一样等待共识的结果
+func (c *Cluster) Authenticate(nodeId, userId string) (*pb.AuthenticationReply, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	return c.AuthenticateContext(ctx, nodeId, userId)
+}
+
+// AuthenticateContext 和 Authenticate 相同, ctx 为调用者的上下文, 取消之后接入节点会放弃这一轮
+func (c *Cluster) AuthenticateContext(ctx context.Context, nodeId, userId string) (*pb.AuthenticationReply, error) {
+	node, err := c.Node(nodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	finishChannel := make(chan *pb.RpcMessage, 1)
+	message := &pb.RpcMessage{
+		Type:    pb.RpcMessageType_AuthRequest,
+		Content: utils.MustMarshal(&pb.AuthenticationRequest{UserId: userId}),
+	}
+	request := request_pool.NewRequest(ctx, userId, message, finishChannel)
+	if err := node.RequestPool.AddRequest(request); err != nil {
+		return nil, err
+	}
+
+	select {
+	case result, ok := <-finishChannel:
+		if !ok {
+			return nil, ErrRequestAbandoned
+		}
+		reply := &pb.AuthenticationReply{}
+		utils.MustUnmarshal(result.Content, reply)
+		return reply, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// chainBehaviours 按照顺序组合多个拜占庭行为, 没有行为的时候返回 nil
+func chainBehaviours(behaviours []pbft.Behaviour) pbft.Behaviour {
+	switch len(behaviours) {
+	case 0:
+		return nil
+	case 1:
+		return behaviours[0]
+	}
+	return byzantine.Chain(behaviours...)
+}
@@ -0,0 +1,134 @@
+package cluster
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"zhanghefan123/security/common/msgbus"
+	"zhanghefan123/security/localconf"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// newStartedCluster 创建并启动集群, 测试结束的时候停止
+func newStartedCluster(t *testing.T, n int, legalUsers []string, opts ...Option) *Cluster {
+	c, err := NewCluster(n, legalUsers, opts...)
+	require.NoError(t, err)
+	require.NoError(t, c.Start())
+	t.Cleanup(c.Stop)
+	return c
+}
+
+// RequireAuthentication 提交认证请求并断言认证的结果
+func (c *Cluster) RequireAuthentication(t *testing.T, nodeId, userId string, expected pb.AuthenticationResult) *pb.AuthenticationReply {
+	t.Helper()
+	reply, err := c.Authenticate(nodeId, userId)
+	require.NoError(t, err)
+	require.Equal(t, userId, reply.UserId)
+	require.Equal(t, expected, reply.Result, "authentication of %s through %s", userId, nodeId)
+	return reply
+}
+
+// RequireAgreement 依次通过每个诚实节点提交用户的认证请求, 断言所有诚实节点返回相同并且正确的结果
+func (c *Cluster) RequireAgreement(t *testing.T, userId string, expected pb.AuthenticationResult) {
+	t.Helper()
+	honest := c.Honest()
+	require.NotEmpty(t, honest, "no honest node in cluster")
+	for _, node := range honest {
+		c.RequireAuthentication(t, node.Id, userId, expected)
+	}
+}
+
+func TestClusterAuthenticateLegalUser(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	require.Equal(t, "node1", c.Primary())
+	reply := c.RequireAuthentication(t, "node1", "alice", pb.AuthenticationResult_LegalUser)
+	require.NotEmpty(t, reply.RequestId)
+	require.NotZero(t, c.Router.Delivered())
+}
+
+func TestClusterAuthenticateIllegalUser(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	c.RequireAuthentication(t, "node1", "mallory", pb.AuthenticationResult_IllegalUser)
+}
+
+func TestClusterAuthenticateThroughBackup(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice", "bob"})
+	c.RequireAuthentication(t, "node3", "alice", pb.AuthenticationResult_LegalUser)
+	c.RequireAuthentication(t, "node4", "carol", pb.AuthenticationResult_IllegalUser)
+}
+
+func TestClusterRepeatedAuthentication(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	for i := 0; i < 5; i++ {
+		c.RequireAuthentication(t, "node2", "alice", pb.AuthenticationResult_LegalUser)
+	}
+}
+
+func TestClusterConcurrentUsers(t *testing.T) {
+	users := []string{"u1", "u2", "u3", "u4", "u5", "u6"}
+	c := newStartedCluster(t, 4, users[:3])
+	var wg sync.WaitGroup
+	for i, user := range users {
+		wg.Add(1)
+		go func(i int, user string) {
+			defer wg.Done()
+			reply, err := c.Authenticate(c.Nodes[i%len(c.Nodes)].Id, user)
+			require.NoError(t, err)
+			expected := pb.AuthenticationResult_IllegalUser
+			if i < 3 {
+				expected = pb.AuthenticationResult_LegalUser
+			}
+			require.Equal(t, expected, reply.Result, user)
+		}(i, user)
+	}
+	wg.Wait()
+}
+
+func TestClusterToleratesOneSilentNode(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	c.Router.SetFilter(func(from, to string, msg *net.NetMsg) bool {
+		return from != "node4" && to != "node4"
+	})
+	c.RequireAuthentication(t, "node2", "alice", pb.AuthenticationResult_LegalUser)
+	require.NotZero(t, c.Router.Dropped())
+}
+
+func TestClusterDropsMalformedMessages(t *testing.T) {
+	c := newStartedCluster(t, 4, []string{"alice"})
+	// 任意节点都可以发送格式错误的消息, 接收的节点丢弃它们而不是崩溃
+	consensusPayloads := [][]byte{{0x08, 0x07}, {0x12, 0x05, 0xff}, {0x08, 0x01, 0x12, 0x02, 0x12, 0x09}, {0x0a, 0x02, 0xc3, 0x28}}
+	pendingPayloads := [][]byte{{0x12, 0x05, 0xff}, {0x0a, 0x02, 0xc3, 0x28}}
+	for _, node := range c.Nodes {
+		for _, payload := range consensusPayloads {
+			node.MsgBus.PublishSync(msgbus.RecvConsensusMsg, &net.NetMsg{Payload: payload, Type: net.NetMsg_CONSENSUS_MSG, To: "node4"})
+		}
+		for _, payload := range pendingPayloads {
+			node.MsgBus.PublishSync(msgbus.RecvTxPoolMsg, &net.NetMsg{Payload: payload, Type: net.NetMsg_TX, To: "node4"})
+		}
+	}
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+}
+
+func TestClusterIgnoresLocalConf(t *testing.T) {
+	// 验证者以及合法用户通过配置传给每个节点, 全局的 localconf 之中同名的链不会产生影响
+	origin := localconf.ChainMakerConfig
+	t.Cleanup(func() { localconf.ChainMakerConfig = origin })
+	localconf.ChainMakerConfig = &localconf.CMConfig{
+		BlockChainConfig: []localconf.BlockchainConfig{{
+			ChainId:    DefaultChainId,
+			Validators: []string{"other1", "other2"},
+			LegalUsers: []string{"mallory"},
+		}},
+	}
+	c := newStartedCluster(t, 4, []string{"alice"})
+	require.Equal(t, NodeIds(4), c.Nodes[0].Consensus.ValidatorSet.Validators)
+	c.RequireAgreement(t, "alice", pb.AuthenticationResult_LegalUser)
+	c.RequireAgreement(t, "mallory", pb.AuthenticationResult_IllegalUser)
+}
+
+func TestClusterUnknownNode(t *testing.T) {
+	c := newStartedCluster(t, 1, nil)
+	_, err := c.Authenticate("node9", "alice")
+	require.ErrorIs(t, err, ErrNodeNotFound)
+}
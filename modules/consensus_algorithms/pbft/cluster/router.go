@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"zhanghefan123/security/common/msgbus"
+	"zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// Filter 决定一条消息是否投递, from 为发送节点, to 为接收节点, 返回 false 的消息会被丢弃
+type Filter func(from, to string, msg *net.NetMsg) bool
+
+// Router 内存之中的网络, 代替 NetService 在各个节点的消息总线之间转发消息
+type Router struct {
+	mutex     sync.RWMutex
+	buses     map[string]msgbus.MessageBus // 节点 id -> 节点的消息总线
+	ports     map[string]*port             // 节点 id -> 订阅节点发送主题的端口
+	filter    Filter                       // 投递之前的过滤器, 为空表示全部投递
+	delivered uint64                       // 已经投递的消息数量
+	dropped   uint64                       // 被过滤器丢弃的消息数量
+}
+
+// recvTopics 发送主题对应的接收主题, 和 NetService 保持一致
+var recvTopics = map[msgbus.Topic]msgbus.Topic{
+	msgbus.SendConsensusMsg: msgbus.RecvConsensusMsg,
+	msgbus.SendTxPoolMsg:    msgbus.RecvTxPoolMsg,
+}
+
+// NewRouter 创建新的 Router
+func NewRouter() *Router {
+	return &Router{
+		buses: make(map[string]msgbus.MessageBus),
+		ports: make(map[string]*port),
+	}
+}
+
+// Attach 将节点的消息总线接入网络, 订阅节点的发送主题
+func (r *Router) Attach(nodeId string, bus msgbus.MessageBus) {
+	p := &port{router: r, nodeId: nodeId}
+	r.mutex.Lock()
+	r.buses[nodeId] = bus
+	r.ports[nodeId] = p
+	r.mutex.Unlock()
+	for topic := range recvTopics {
+		bus.Register(topic, p)
+	}
+}
+
+// Detach 将节点从网络之中移除, 之后发给这个节点的消息会被丢弃
+func (r *Router) Detach(nodeId string) {
+	r.mutex.Lock()
+	bus, p := r.buses[nodeId], r.ports[nodeId]
+	delete(r.buses, nodeId)
+	delete(r.ports, nodeId)
+	r.mutex.Unlock()
+	if bus == nil {
+		return
+	}
+	for topic := range recvTopics {
+		bus.UnRegister(topic, p)
+	}
+}
+
+// SetFilter 设置投递之前的过滤器, 传入 nil 表示全部投递
+func (r *Router) SetFilter(filter Filter) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.filter = filter
+}
+
+// Delivered 返回已经投递的消息数量
+func (r *Router) Delivered() uint64 {
+	return atomic.LoadUint64(&r.delivered)
+}
+
+// Dropped 返回被过滤器丢弃的消息数量
+func (r *Router) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// route 将 from 发出的消息投递给目的节点, To 为空表示广播给除了自己之外的所有节点
+func (r *Router) route(from string, topic msgbus.Topic, msg *net.NetMsg) {
+	r.mutex.RLock()
+	filter := r.filter
+	destinations := make([]string, 0, len(r.buses))
+	if msg.To == "" {
+		for nodeId := range r.buses {
+			if nodeId != from {
+				destinations = append(destinations, nodeId)
+			}
+		}
+		sort.Strings(destinations)
+	} else if _, ok := r.buses[msg.To]; ok {
+		destinations = append(destinations, msg.To)
+	}
+	buses := make([]msgbus.MessageBus, len(destinations))
+	for i, nodeId := range destinations {
+		buses[i] = r.buses[nodeId]
+	}
+	r.mutex.RUnlock()
+
+	for i, to := range destinations {
+		if filter != nil && !filter(from, to, msg) {
+			atomic.AddUint64(&r.dropped, 1)
+			continue
+		}
+		// 和 NetService 一样, 接收方看到的 To 为发送节点
+		buses[i].Publish(recvTopics[topic], &net.NetMsg{
+			Payload: msg.Payload,
+			Type:    msg.Type,
+			To:      from,
+		})
+		atomic.AddUint64(&r.delivered, 1)
+	}
+}
+
+// port 订阅某个节点的发送主题, 将消息交给 Router 转发
+type port struct {
+	router *Router
+	nodeId string
+}
+
+// OnMessage 收到节点发送的消息
+func (p *port) OnMessage(message *msgbus.Message) {
+	if msg, ok := message.Payload.(*net.NetMsg); ok {
+		p.router.route(p.nodeId, message.Topic, msg)
+	}
+}
+
+// OnQuit 消息总线关闭
+func (p *port) OnQuit() {}
@@ -7,6 +7,7 @@ import (
 type ConsensusMessage struct {
 	Type pbftPb.PBFTMsgType
 	Msg  interface{}
+	From string // 发送消息的节点, 本节点产生的消息为本节点的 id
 }
 
 // CreatePrePrepareConsensusMessage 创建预准备消息
@@ -14,8 +15,9 @@ func CreatePrePrepareConsensusMessage(prePrepare *pbftPb.PrePrepare) *ConsensusM
 	return &ConsensusMessage{
 		Type: pbftPb.PBFTMsgType_MSG_PRE_PREPARE,
 		Msg: &pbftPb.PrePrepare{
-			AccessId: prePrepare.AccessId,
-			UserId:   prePrepare.UserId,
+			AccessId:  prePrepare.AccessId,
+			UserId:    prePrepare.UserId,
+			RequestId: prePrepare.RequestId,
 		}, // 这里不是直接使用, 而进行拷贝, 是避免副作用
 	}
 }
@@ -25,11 +27,12 @@ func CreatePrepareConsensusMessage(prepareVote *pbftPb.Vote) *ConsensusMessage {
 	return &ConsensusMessage{
 		Type: pbftPb.PBFTMsgType_MSG_PREPARE,
 		Msg: &pbftPb.Vote{
-			Type:     prepareVote.Type,
-			Voter:    prepareVote.Voter,
-			UserId:   prepareVote.UserId,
-			AccessId: prepareVote.AccessId,
-			Judge:    prepareVote.Judge,
+			Type:      prepareVote.Type,
+			Voter:     prepareVote.Voter,
+			UserId:    prepareVote.UserId,
+			AccessId:  prepareVote.AccessId,
+			Judge:     prepareVote.Judge,
+			RequestId: prepareVote.RequestId,
 		}, // 这里不是直接使用, 而进行拷贝, 是避免副作用
 	}
 }
@@ -39,11 +42,12 @@ func CreateCommitConsensusMessage(commit *pbftPb.Vote) *ConsensusMessage {
 	return &ConsensusMessage{
 		Type: pbftPb.PBFTMsgType_MSG_COMMIT,
 		Msg: &pbftPb.Vote{
-			Type:     commit.Type,
-			Voter:    commit.Voter,
-			UserId:   commit.UserId,
-			AccessId: commit.AccessId,
-			Judge:    commit.Judge,
+			Type:      commit.Type,
+			Voter:     commit.Voter,
+			UserId:    commit.UserId,
+			AccessId:  commit.AccessId,
+			Judge:     commit.Judge,
+			RequestId: commit.RequestId,
 		},
 	}
 }
@@ -53,11 +57,12 @@ func CreateReplyConsensusMessage(reply *pbftPb.Vote) *ConsensusMessage {
 	return &ConsensusMessage{
 		Type: pbftPb.PBFTMsgType_MSG_REPLY,
 		Msg: &pbftPb.Vote{
-			Type:     reply.Type,
-			Voter:    reply.Voter,
-			UserId:   reply.UserId,
-			AccessId: reply.AccessId,
-			Judge:    reply.Judge,
+			Type:      reply.Type,
+			Voter:     reply.Voter,
+			UserId:    reply.UserId,
+			AccessId:  reply.AccessId,
+			Judge:     reply.Judge,
+			RequestId: reply.RequestId,
 		},
 	}
 }
@@ -1,44 +1,39 @@
 package message
 
 import (
+	"fmt"
 	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
 	"zhanghefan123/security/modules/utils"
 )
 
-// CreateConsensusMsgFromBytes 从 bytes unmarshal 成为 consensus_msg
-func CreateConsensusMsgFromBytes(bytes []byte) *ConsensusMessage {
-	pbftMsg := new(pbftPb.PBFTMsg)      // net message 包含的 payload 就是 pbft.PBFTMsg, 其的产生定义在 consensus_message.go 之中
-	utils.MustUnmarshal(bytes, pbftMsg) // 将 bytes 变为 pbftMsg
+// CreateConsensusMsgFromBytes 从 bytes unmarshal 成为 consensus_msg, bytes 来自网络, 格式错误或者类型未知的时候返回错误
+func CreateConsensusMsgFromBytes(bytes []byte) (*ConsensusMessage, error) {
+	pbftMsg := new(pbftPb.PBFTMsg) // net message 包含的 payload 就是 pbft.PBFTMsg, 其的产生定义在 consensus_message.go 之中
+	// 将 bytes 变为 pbftMsg
+	if err := utils.Unmarshal(bytes, pbftMsg); err != nil {
+		return nil, err
+	}
 	switch pbftMsg.Type {
 	case pbftPb.PBFTMsgType_MSG_PRE_PREPARE:
 		prePrepare := new(pbftPb.PrePrepare)
-		utils.MustUnmarshal(pbftMsg.Msg, prePrepare) // 根据类型将 pbftMsg 之中的 Msg unMarshal 成对应的 proto
+		// 根据类型将 pbftMsg 之中的 Msg unMarshal 成对应的 proto
+		if err := utils.Unmarshal(pbftMsg.Msg, prePrepare); err != nil {
+			return nil, err
+		}
 		return &ConsensusMessage{
 			Type: pbftPb.PBFTMsgType_MSG_PRE_PREPARE,
 			Msg:  prePrepare,
+		}, nil
+	case pbftPb.PBFTMsgType_MSG_PREPARE, pbftPb.PBFTMsgType_MSG_COMMIT, pbftPb.PBFTMsgType_MSG_REPLY:
+		vote := new(pbftPb.Vote)
+		if err := utils.Unmarshal(pbftMsg.Msg, vote); err != nil {
+			return nil, err
 		}
-	case pbftPb.PBFTMsgType_MSG_PREPARE:
-		prepare := new(pbftPb.Vote)
-		utils.MustUnmarshal(pbftMsg.Msg, prepare)
-		return &ConsensusMessage{
-			Type: pbftPb.PBFTMsgType_MSG_PREPARE,
-			Msg:  prepare,
-		}
-	case pbftPb.PBFTMsgType_MSG_COMMIT:
-		commit := new(pbftPb.Vote)
-		utils.MustUnmarshal(pbftMsg.Msg, commit)
 		return &ConsensusMessage{
-			Type: pbftPb.PBFTMsgType_MSG_COMMIT,
-			Msg:  commit,
-		}
-	case pbftPb.PBFTMsgType_MSG_REPLY:
-		reply := new(pbftPb.Vote)
-		utils.MustUnmarshal(pbftMsg.Msg, reply)
-		return &ConsensusMessage{
-			Type: pbftPb.PBFTMsgType_MSG_REPLY,
-			Msg:  reply,
-		}
+			Type: pbftMsg.Type,
+			Msg:  vote,
+		}, nil
 	default:
-		panic("unhandled default case")
+		return nil, fmt.Errorf("unknown pbft message type %d", pbftMsg.Type)
 	}
 }
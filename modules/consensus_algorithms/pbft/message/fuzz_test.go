@@ -0,0 +1,91 @@
+//go:build go1.18
+// +build go1.18
+
+package message
+
+import (
+	"github.com/golang/protobuf/proto"
+	"math/rand"
+	"testing"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/utils"
+)
+
+// 运行方式: go test -run '^$' -fuzz FuzzCreateConsensusMsgFromBytes ./consensus_algorithms/pbft/message
+
+// addSeeds 添加随机生成的合法消息以及一些边界情况作为种子语料
+func addSeeds(f *testing.F, encode func(msg *pbftPb.PBFTMsg) []byte) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 16; i++ {
+		msg, _ := randomPBFTMsg(rng)
+		f.Add(encode(msg))
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0x08, 0x07})
+	f.Add([]byte{0x0a, 0x02, 0xc3, 0x28})
+	f.Add([]byte{0x12, 0xff, 0xff, 0xff, 0xff, 0x0f})
+}
+
+// requireStable 解析成功的消息重新序列化之后再次解析得到相同的消息
+func requireStable(t *testing.T, decoded proto.Message, fresh proto.Message) {
+	if err := utils.Unmarshal(utils.MustMarshal(decoded), fresh); err != nil {
+		t.Fatalf("re-encoded %T can not be decoded: %v", decoded, err)
+	}
+	if !proto.Equal(decoded, fresh) {
+		t.Fatalf("re-encoded %T changed: %v != %v", decoded, decoded, fresh)
+	}
+}
+
+func FuzzCreateConsensusMsgFromBytes(f *testing.F) {
+	addSeeds(f, func(msg *pbftPb.PBFTMsg) []byte { return utils.MustMarshal(msg) })
+	f.Fuzz(func(t *testing.T, data []byte) {
+		consensusMsg, err := CreateConsensusMsgFromBytes(data)
+		if err != nil {
+			return
+		}
+		switch msg := consensusMsg.Msg.(type) {
+		case *pbftPb.PrePrepare:
+			if consensusMsg.Type != pbftPb.PBFTMsgType_MSG_PRE_PREPARE {
+				t.Fatalf("prePrepare decoded as %s", consensusMsg.Type)
+			}
+			requireStable(t, msg, new(pbftPb.PrePrepare))
+		case *pbftPb.Vote:
+			if consensusMsg.Type == pbftPb.PBFTMsgType_MSG_PRE_PREPARE {
+				t.Fatalf("vote decoded as %s", consensusMsg.Type)
+			}
+			requireStable(t, msg, new(pbftPb.Vote))
+		default:
+			t.Fatalf("unexpected message %T", consensusMsg.Msg)
+		}
+	})
+}
+
+func FuzzUnmarshalPBFTMsg(f *testing.F) {
+	addSeeds(f, func(msg *pbftPb.PBFTMsg) []byte { return utils.MustMarshal(msg) })
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg := new(pbftPb.PBFTMsg)
+		if utils.Unmarshal(data, msg) == nil {
+			requireStable(t, msg, new(pbftPb.PBFTMsg))
+		}
+	})
+}
+
+func FuzzUnmarshalVote(f *testing.F) {
+	addSeeds(f, func(msg *pbftPb.PBFTMsg) []byte { return msg.Msg })
+	f.Fuzz(func(t *testing.T, data []byte) {
+		vote := new(pbftPb.Vote)
+		if utils.Unmarshal(data, vote) == nil {
+			requireStable(t, vote, new(pbftPb.Vote))
+		}
+	})
+}
+
+func FuzzUnmarshalPrePrepare(f *testing.F) {
+	addSeeds(f, func(msg *pbftPb.PBFTMsg) []byte { return msg.Msg })
+	f.Fuzz(func(t *testing.T, data []byte) {
+		prePrepare := new(pbftPb.PrePrepare)
+		if utils.Unmarshal(data, prePrepare) == nil {
+			requireStable(t, prePrepare, new(pbftPb.PrePrepare))
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package message
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"testing"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/utils"
+	pbNet "zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// randomString 生成随机长度的合法 utf-8 字符串, 包含多字节的字符
+func randomString(rng *rand.Rand) string {
+	runes := make([]rune, rng.Intn(24))
+	for i := range runes {
+		if rng.Intn(4) == 0 {
+			runes[i] = rune(0x4e00 + rng.Intn(0x5000))
+		} else {
+			runes[i] = rune(0x20 + rng.Intn(0x5f))
+		}
+	}
+	return string(runes)
+}
+
+// randomPBFTMsg 随机生成一条 pbft 消息, 返回序列化之后的消息以及其中的内容
+func randomPBFTMsg(rng *rand.Rand) (*pbftPb.PBFTMsg, proto.Message) {
+	if rng.Intn(4) == 0 {
+		prePrepare := NewPrePrepare(randomString(rng), randomString(rng), randomString(rng))
+		return SerializePrePrepareConsensusMessage(prePrepare), prePrepare
+	}
+	vote := NewVote(pbftPb.VoteType(rng.Intn(3)), randomString(rng), randomString(rng), randomString(rng),
+		randomString(rng), rng.Intn(2) == 0)
+	switch vote.Type {
+	case pbftPb.VoteType_VOTE_PREPARE:
+		return SerializePrepareConsensusMessage(vote), vote
+	case pbftPb.VoteType_VOTE_COMMIT:
+		return SerializeCommitConsensusMessage(vote), vote
+	default:
+		return SerializeReplyConsensusMessage(vote), vote
+	}
+}
+
+// sendThroughNet 模拟网络发送: 封装为 NetMsg 并序列化, 接收方反序列化 NetMsg 之后解析其中的共识消息
+func sendThroughNet(t *testing.T, msg *pbftPb.PBFTMsg, destination string) (*pbNet.NetMsg, *ConsensusMessage, error) {
+	wire := utils.MustMarshal(GenerateNetMsgFromProto(msg, destination))
+	received := new(pbNet.NetMsg)
+	require.NoError(t, utils.Unmarshal(wire, received))
+	consensusMsg, err := CreateConsensusMsgFromBytes(received.Payload)
+	return received, consensusMsg, err
+}
+
+func TestConsensusMsgRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		msg, content := randomPBFTMsg(rng)
+		received, consensusMsg, err := sendThroughNet(t, msg, "node2")
+		require.NoError(t, err)
+		require.Equal(t, "node2", received.To)
+		require.Equal(t, pbNet.NetMsg_CONSENSUS_MSG, received.Type)
+		require.Equal(t, msg.Type, consensusMsg.Type)
+		require.True(t, proto.Equal(content, consensusMsg.Msg.(proto.Message)), "message %d: %v", i, content)
+	}
+}
+
+func TestPendingRequestRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		pendingRequest := NewPendingRequest(randomString(rng), randomString(rng), randomString(rng))
+		decoded, err := CreatePendingRequestFromBytes(utils.MustMarshal(pendingRequest))
+		require.NoError(t, err)
+		require.True(t, proto.Equal(pendingRequest, decoded))
+	}
+}
+
+func TestCreateConsensusMsgFromMalformedBytes(t *testing.T) {
+	vote := NewVote(pbftPb.VoteType_VOTE_COMMIT, "node1", "alice", "node2", "request1", true)
+	valid := utils.MustMarshal(SerializeCommitConsensusMessage(vote))
+
+	// 在投票中间截断的消息, 前两个字节本身是一条 Msg 为空的合法消息
+	for i := 3; i < len(valid); i++ {
+		_, err := CreateConsensusMsgFromBytes(valid[:i])
+		require.Error(t, err, "truncated at %d", i)
+	}
+
+	// 未知的消息类型
+	_, err := CreateConsensusMsgFromBytes(utils.MustMarshal(&pbftPb.PBFTMsg{Type: 7, Msg: utils.MustMarshal(vote)}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown pbft message type")
+
+	// 外层合法, 内层的投票不合法
+	_, err = CreateConsensusMsgFromBytes(utils.MustMarshal(&pbftPb.PBFTMsg{
+		Type: pbftPb.PBFTMsgType_MSG_PREPARE,
+		Msg:  []byte{0x12, 0x05, 0xff},
+	}))
+	require.Error(t, err)
+
+	// 非法的 utf-8 字符串
+	_, err = CreateConsensusMsgFromBytes(utils.MustMarshal(&pbftPb.PBFTMsg{
+		Type: pbftPb.PBFTMsgType_MSG_PRE_PREPARE,
+		Msg:  []byte{0x0a, 0x02, 0xc3, 0x28},
+	}))
+	require.Error(t, err)
+
+	_, err = CreatePendingRequestFromBytes([]byte{0x0a, 0x10})
+	require.Error(t, err)
+}
@@ -0,0 +1,24 @@
+package message
+
+import (
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/utils"
+)
+
+// NewPendingRequest 创建新的待处理请求
+func NewPendingRequest(requestId, userId, accessId string) *pbftPb.PendingRequest {
+	return &pbftPb.PendingRequest{
+		RequestId: requestId,
+		UserId:    userId,
+		AccessId:  accessId,
+	}
+}
+
+// CreatePendingRequestFromBytes 从 bytes unmarshal 成为 PendingRequest, bytes 来自网络, 格式错误的时候返回错误
+func CreatePendingRequestFromBytes(bytes []byte) (*pbftPb.PendingRequest, error) {
+	pendingRequest := new(pbftPb.PendingRequest)
+	if err := utils.Unmarshal(bytes, pendingRequest); err != nil {
+		return nil, err
+	}
+	return pendingRequest, nil
+}
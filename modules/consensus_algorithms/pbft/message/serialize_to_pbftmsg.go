@@ -16,7 +16,7 @@ func SerializePrePrepareConsensusMessage(prePrepare *pbft.PrePrepare) *pbft.PBFT
 // SerializePrepareConsensusMessage 转换 prepare 消息
 func SerializePrepareConsensusMessage(prepare *pbft.Vote) *pbft.PBFTMsg {
 	return &pbft.PBFTMsg{
-		Type: pbft.PBFTMsgType_MSG_PRE_PREPARE,
+		Type: pbft.PBFTMsgType_MSG_PREPARE,
 		Msg:  utils.MustMarshal(prepare),
 	}
 }
@@ -0,0 +1,23 @@
+package pbft
+
+import (
+	"encoding/json"
+)
+
+// DumpUserState 导出用户当前的共识状态 (json 格式), 供管理服务查看
+func (pbftImpl *ConsensusPbftImpl) DumpUserState(userId string) ([]byte, error) {
+	pbftImpl.RLock()
+	defer pbftImpl.RUnlock()
+	dump, err := pbftImpl.ConsensusState.DumpUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(dump, "", "  ")
+}
+
+// ExpireUserRound 强制结束用户正在进行的这一轮共识
+func (pbftImpl *ConsensusPbftImpl) ExpireUserRound(userId string) error {
+	pbftImpl.Lock()
+	defer pbftImpl.Unlock()
+	return pbftImpl.ConsensusState.ExpireUser(userId)
+}
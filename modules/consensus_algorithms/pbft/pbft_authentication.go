@@ -0,0 +1,187 @@
+package pbft
+
+import (
+	"time"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
+	"zhanghefan123/security/modules/decision_log"
+	"zhanghefan123/security/modules/request_pool"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/modules/utils"
+)
+
+// UserLegalityCheck 检测用户的合法性
+func UserLegalityCheck(validUsers *map[string]interface{}, user string) bool {
+	if _, ok := (*validUsers)[user]; !ok {
+		return false
+	}
+	return true
+}
+
+// PendingRequest 添加待处理用户认证请求, 并将请求广播给其他的节点, 由主节点进行排序
+func PendingRequest(pbftImpl *ConsensusPbftImpl, requestId string, userId string, channel chan pb.AuthenticationResult) error {
+	// 1. 首先需要添加用户到 GlobalState 之中, 同时记录请求 id, 之后广播回来的同一个请求会被忽略
+	pbftImpl.Lock()
+	consensusState := pbftImpl.ConsensusState
+	// 本节点可能还在参与其他节点接入的同一个用户的一轮, 放弃那一轮会让其他接入节点少掉本节点的投票,
+	// 因此和本节点接入的一轮一样, 在超时之前新的请求会被拒绝, 只有已经超时的一轮才会被替换
+	if _, ok := consensusState.CurrentUsers[userId]; ok && consensusState.AuthenticationResults[userId] == nil &&
+		consensusState.IsRoundExpired(userId) {
+		consensusState.RemoveUser(userId)
+	}
+	err := consensusState.AddUserForAuthentication(userId, requestId, channel)
+	if err == nil {
+		consensusState.MarkRequestSeen(requestId)
+	}
+	pbftImpl.Unlock()
+	if err != nil {
+		return err
+	}
+	recordRound(pbftImpl, decision_log.PhaseRequest, userId, requestId)
+
+	// 2. 将请求广播给其他的节点, 这样即使本节点无法到达法定数量的节点, 主节点也可以对请求进行排序
+	pendingRequest := message.NewPendingRequest(requestId, userId, pbftImpl.LocalPeerId)
+	SendPendingRequestMessage(pbftImpl, pendingRequest)
+
+	// 3. 如果本节点就是主节点, 直接进行排序
+	if pbftImpl.ValidatorSet.IsPrimary(pbftImpl.LocalPeerId) {
+		OrderRequest(pbftImpl, pendingRequest)
+	}
+	return nil
+}
+
+// OrderRequest 主节点对请求进行排序: 生成 prePrepareMessage 发送给其他的验证者, 并启动本地的共识流程
+func OrderRequest(pbftImpl *ConsensusPbftImpl, pendingRequest *pbftPb.PendingRequest) {
+	pbftImpl.Logger.Infof("[%s] primary order request [%s] of user [%s] from [%s]", pbftImpl.LocalPeerId,
+		pendingRequest.RequestId, pendingRequest.UserId, pendingRequest.AccessId)
+
+	// 接入节点仍然是 AccessId, 因此 reply 消息会回到接入节点, 由接入节点返回结果
+	prePrepareMessage := message.NewPrePrepare(pendingRequest.UserId, pendingRequest.AccessId, pendingRequest.RequestId)
+	SendPrePrepareMessage(pbftImpl, prePrepareMessage)
+
+	// 本地的 prePrepare 放到内部消息 channel 之中, 调用者可能就在处理消息的协程之中, 因此不能阻塞
+	pbftImpl.sendInternal(message.CreatePrePrepareConsensusMessage(prePrepareMessage))
+}
+
+// HandleGossipedRequest 处理其他节点广播来的待处理请求, 按照请求 id 去重, 只有主节点会进行排序
+func HandleGossipedRequest(pbftImpl *ConsensusPbftImpl, pendingRequest *pbftPb.PendingRequest) {
+	pbftImpl.Lock()
+	firstSeen := pbftImpl.ConsensusState.MarkRequestSeen(pendingRequest.RequestId)
+	pbftImpl.Unlock()
+	if !firstSeen {
+		pbftImpl.Logger.Debugf("duplicated pending request [%s], ignore", pendingRequest.RequestId)
+		return
+	}
+	if !pbftImpl.ValidatorSet.IsPrimary(pbftImpl.LocalPeerId) {
+		return
+	}
+	OrderRequest(pbftImpl, pendingRequest)
+}
+
+// HandleAuthenticationRequest 处理认证请求, 注册之后在新的协程之中等待结果, 不阻塞消息的处理
+func HandleAuthenticationRequest(pbftImpl *ConsensusPbftImpl, request *request_pool.Request) {
+	// 调用者在请求排队的时候已经离开了, 不需要再发起共识
+	if request.Cancelled() {
+		pbftImpl.Logger.Warnf("authentication request cancelled before consensus, %v", request.Ctx.Err())
+		request.Close()
+		return
+	}
+
+	// 获取结果, 缓冲为 1 保证共识完成的时候写入结果不会阻塞
+	resultChannel := make(chan pb.AuthenticationResult, 1)
+
+	// 创建 authRequest 空对象
+	authRequest := &pb.AuthenticationRequest{}
+
+	// 进行反序列化, 内容来自客户端, 格式错误的时候放弃这个请求
+	if err := utils.Unmarshal(request.Message.Content, authRequest); err != nil {
+		pbftImpl.Logger.Warnf("malformed authentication request [%s], %v", request.RequestId, err)
+		request.Close()
+		return
+	}
+
+	// 拿到 userId
+	userId := authRequest.UserId
+
+	// 创建新的请求添加到队列之中并进行广播
+	// 用户已经有其他请求的一轮在本节点上等待结果的时候放弃这个请求, 不能影响正在进行的那一轮
+	if err := PendingRequest(pbftImpl, request.RequestId, userId, resultChannel); err != nil {
+		pbftImpl.Logger.Errorf("[%s] user [%s] is already authenticating, abandon request [%s], %v",
+			pbftImpl.LocalPeerId, userId, request.RequestId, err)
+		request.Close()
+		return
+	}
+
+	go waitAuthenticationResult(pbftImpl, request, userId, resultChannel)
+}
+
+// waitAuthenticationResult 等待共识的结果并返回给调用者
+func waitAuthenticationResult(pbftImpl *ConsensusPbftImpl, request *request_pool.Request, userId string, resultChannel chan pb.AuthenticationResult) {
+	// 结果送达或者放弃之后关闭 responseChan, 避免调用者一直等待
+	defer request.Close()
+
+	// 计时器处理
+	t := pbftImpl.Clock.NewTimer(time.Second * 30)
+	defer t.Stop()
+
+	select {
+	// 当从 resultChannel 之中返回结果的时候
+	case result := <-resultChannel:
+		// 日志输出
+		pbftImpl.Logger.Infof("HandleUserRequest received message")
+
+		// 创建 authenticationReply消息
+		authReply := &pb.AuthenticationReply{
+			UserId:    userId,
+			Result:    result,
+			RequestId: request.RequestId,
+		}
+
+		// 创建 rpc 消息 将响应结果返回
+		rpcMessage := &pb.RpcMessage{
+			Type:    pb.RpcMessageType_AuthReply,
+			Content: utils.MustMarshal(authReply),
+		}
+
+		if !request.Reply(rpcMessage) {
+			pbftImpl.Logger.Warnf("caller of user [%s] left before reply", userId)
+		}
+
+	// 当计时器超时的时候
+	case <-t.C():
+		// 日志输出
+		pbftImpl.Logger.Errorf("handle user request time out")
+
+		// 超时的这一轮不会再有结果, 清理用户的状态, 用户已经开始了其他请求的一轮的时候不做处理
+		pbftImpl.Lock()
+		pbftImpl.ConsensusState.RemoveRequest(userId, request.RequestId)
+		pbftImpl.Unlock()
+		recordRound(pbftImpl, decision_log.PhaseTimeout, userId, request.RequestId)
+
+		// 创建 authenticationReply消息
+		authReply := &pb.AuthenticationReply{
+			UserId:    userId,
+			Result:    pb.AuthenticationResult_ConsensusTimeout,
+			RequestId: request.RequestId,
+		}
+
+		// 创建 rpc 消息, 将响应结果返回
+		rpcMessage := &pb.RpcMessage{
+			Type:    pb.RpcMessageType_AuthReply,
+			Content: utils.MustMarshal(authReply),
+		}
+
+		request.Reply(rpcMessage)
+
+	// 当调用者断开连接或者超过了调用者的截止时间的时候
+	case <-request.Ctx.Done():
+		// 日志输出
+		pbftImpl.Logger.Warnf("authentication of user [%s] cancelled by caller, %v", userId, request.Ctx.Err())
+
+		// 放弃这一轮共识, 只清理这个请求的一轮
+		pbftImpl.Lock()
+		pbftImpl.ConsensusState.RemoveRequest(userId, request.RequestId)
+		pbftImpl.Unlock()
+		recordRound(pbftImpl, decision_log.PhaseCancel, userId, request.RequestId)
+	}
+}
@@ -0,0 +1,27 @@
+package pbft
+
+import (
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+)
+
+// Behaviour 拜占庭行为, 在节点将共识消息发送给 to 之前进行篡改, 返回实际发送的消息, 返回空表示不发送.
+// 只用于对抗测试, 诚实的节点没有设置拜占庭行为, 本地的共识流程不受影响
+type Behaviour interface {
+	Tamper(from, to string, msg *pbftPb.PBFTMsg) []*pbftPb.PBFTMsg
+}
+
+// behaviourBox atomic.Value 不能直接保存 nil 接口
+type behaviourBox struct {
+	behaviour Behaviour
+}
+
+// SetBehaviour 设置节点的拜占庭行为, 传入 nil 表示恢复为诚实节点, 可以在共识运行的时候调用
+func (pbftImpl *ConsensusPbftImpl) SetBehaviour(behaviour Behaviour) {
+	pbftImpl.behaviour.Store(behaviourBox{behaviour: behaviour})
+}
+
+// Behaviour 返回节点的拜占庭行为, 诚实的节点返回 nil
+func (pbftImpl *ConsensusPbftImpl) Behaviour() Behaviour {
+	box, _ := pbftImpl.behaviour.Load().(behaviourBox)
+	return box.behaviour
+}
@@ -3,13 +3,16 @@ package pbft
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"zhanghefan123/security/common/msgbus"
 	consensusutils "zhanghefan123/security/consensus-utils"
+	"zhanghefan123/security/modules/clock"
 	"zhanghefan123/security/modules/consensus_algorithms"
-	"zhanghefan123/security/modules/consensus_algorithms/pbft/handler"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/state"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/validator"
+	"zhanghefan123/security/modules/decision_log"
 	"zhanghefan123/security/modules/request_pool"
 	"zhanghefan123/security/modules/utils"
 	"zhanghefan123/security/protobuf/pb-go/net"
@@ -21,7 +24,9 @@ type ConsensusPbftImpl struct {
 	sync.RWMutex                                   // 读写锁
 	Ctx             context.Context                // 上下文
 	Logger          protocol.Logger                // 日志记录器
+	Clock           clock.Clock                    // 时钟, 共识的超时通过它计时, 仿真的时候为虚拟时钟
 	LocalPeerId     string                         // 本地节点 peerId
+	ChainId         string                         // 所属的链, 每条链是一个独立的认证域
 	ChainConfig     *protocol.ChainConf            // 链配置
 	ValidatorSet    *validator.ValidatorSet        // 验证者集合
 	ConsensusState  *state.GlobalState             // 存储了共识状态，包括对于每个请求的投票集合
@@ -29,28 +34,55 @@ type ConsensusPbftImpl struct {
 	MsgBus          msgbus.MessageBus              // 消息总线
 	InternalMsgChan chan *message.ConsensusMessage // 内部消息队列
 	ExternalMsgChan chan *message.ConsensusMessage // 外部消息队列
+	GossipMsgChan   chan *pbftPb.PendingRequest    // 其他节点广播的待处理请求
 	RequestPool     *request_pool.RequestPool      // 请求池
+	DecisionLog     *decision_log.Recorder         // 决策日志, 没有配置的时候为空, 记录不会产生任何效果
+	behaviour       atomic.Value                   // 拜占庭行为, 只用于对抗测试
+	quitC           chan struct{}                  // 停止信号
+	stopOnce        sync.Once                      // 保证只停止一次
 }
 
 // New 通过 ConsensusImplConfig 创建新的 ConsensusPbftImpl 实例
 func New(config *consensusutils.ConsensusImplConfig) (*ConsensusPbftImpl, error) {
-	// 从 localconf 之中获取 validator
-	validators := utils.GetValidatorsFromLocalConfig()
+	// 配置之中没有指定的时候从 localconf 之中获取这条链的 validator 以及注册的合法用户
+	validators := append([]string{}, config.Validators...)
+	if config.Validators == nil {
+		validators = utils.GetValidatorsOfChain(config.ChainId)
+	}
+	legalUsers := make(map[string]interface{}, len(config.LegalUsers))
+	for _, user := range config.LegalUsers {
+		legalUsers[user] = struct{}{}
+	}
+	if config.LegalUsers == nil {
+		legalUsers = utils.GetLegalUsersOfChain(config.ChainId)
+	}
 
 	// 设置 validatorSet
 	validatorSet := validator.NewValidatorSet(config.Logger, validators)
 
+	// 没有指定时钟的时候使用系统时钟
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real
+	}
+
 	// 创建 pbft 实例
 	pbftImpl := &ConsensusPbftImpl{
 		Logger:          config.Logger,
+		Clock:           clk,
 		LocalPeerId:     config.NodeId,
+		ChainId:         config.ChainId,
 		ChainConfig:     &config.ChainConf,
 		ValidatorSet:    validatorSet,
-		ConsensusState:  state.NewConsensusState(config.Logger, config.NodeId, validatorSet),
+		ConsensusState:  state.NewConsensusState(config.Logger, clk, config.NodeId, validatorSet),
+		LegalUsers:      &legalUsers,
 		MsgBus:          config.MsgBus,
 		InternalMsgChan: make(chan *message.ConsensusMessage),
 		ExternalMsgChan: make(chan *message.ConsensusMessage),
+		GossipMsgChan:   make(chan *pbftPb.PendingRequest),
 		RequestPool:     config.RequestPool,
+		DecisionLog:     decision_log.NewRecorder(config.NodeId, config.ChainId, clk, config.DecisionLog),
+		quitC:           make(chan struct{}),
 	}
 
 	// 将创建的结果进行返回
@@ -60,18 +92,48 @@ func New(config *consensusutils.ConsensusImplConfig) (*ConsensusPbftImpl, error)
 // OnMessage 收到消息时候的处理行为
 func (pbftImpl *ConsensusPbftImpl) OnMessage(msg *msgbus.Message) {
 	switch msg.Topic {
-	// 仅仅进行了 RecvConsensusMsg 消息的订阅
+	// 其他节点发送来的共识消息
 	case msgbus.RecvConsensusMsg:
 		// 将 payload 转换为 NetMsg
-		if msg, ok := msg.Payload.(net.NetMsg); ok {
+		if msg, ok := msg.Payload.(*net.NetMsg); ok {
 			// 将 netMsg 之中的内容转换为 consensusMsg
-			consensusMsg := message.CreateConsensusMsgFromBytes(msg.Payload)
+			// 格式错误的消息直接丢弃, 不能让任意节点发送的数据导致本节点崩溃
+			consensusMsg, err := message.CreateConsensusMsgFromBytes(msg.Payload)
+			if err != nil {
+				pbftImpl.Logger.Warnf("[%s] malformed consensus message from %s, %v", pbftImpl.LocalPeerId, msg.To, err)
+				return
+			}
+
+			// 投票者必须是发送投票的节点, 防止拜占庭节点伪造其他验证者的投票
+			if vote, ok := consensusMsg.Msg.(*pbftPb.Vote); ok && vote.Voter != msg.To {
+				pbftImpl.Logger.Warnf("[%s] %s vote of voter %s sent by %s, ignore",
+					pbftImpl.LocalPeerId, vote.Type, vote.Voter, msg.To)
+				return
+			}
+			consensusMsg.From = msg.To
 
 			// 输出收到了消息
 			pbftImpl.Logger.Infof("OnMessage receive message")
 
-			// 向外部 channel 发送消息
-			pbftImpl.ExternalMsgChan <- consensusMsg
+			// 向外部 channel 发送消息, 共识模块停止之后直接丢弃
+			select {
+			case pbftImpl.ExternalMsgChan <- consensusMsg:
+			case <-pbftImpl.quitC:
+			}
+		}
+	// 其他节点广播的待处理请求, 交给主节点进行排序
+	case msgbus.RecvTxPoolMsg:
+		if msg, ok := msg.Payload.(*net.NetMsg); ok {
+			pendingRequest, err := message.CreatePendingRequestFromBytes(msg.Payload)
+			if err != nil {
+				pbftImpl.Logger.Warnf("[%s] malformed pending request from %s, %v", pbftImpl.LocalPeerId, msg.To, err)
+				return
+			}
+			pbftImpl.Logger.Infof("OnMessage receive pending request [%s]", pendingRequest.RequestId)
+			select {
+			case pbftImpl.GossipMsgChan <- pendingRequest:
+			case <-pbftImpl.quitC:
+			}
 		}
 	default:
 		panic("unhandled default case")
@@ -94,11 +156,29 @@ func (pbftImpl *ConsensusPbftImpl) RegisterMsgBusTopics() {
 // Start 启动方法
 func (pbftImpl *ConsensusPbftImpl) Start() error {
 	pbftImpl.RegisterMsgBusTopics()
-	go handler.Handle(pbftImpl)
+	go Handle(pbftImpl)
 	return nil
 }
 
-// Stop 停止方法
+// Stop 停止方法, 注销消息总线的主题并且停止消息的处理
 func (pbftImpl *ConsensusPbftImpl) Stop() error {
+	pbftImpl.stopOnce.Do(func() {
+		for _, topic := range consensus_algorithms.PbftMsgBusTopics {
+			pbftImpl.MsgBus.UnRegister(topic, pbftImpl)
+		}
+		close(pbftImpl.quitC)
+	})
 	return nil
 }
+
+// sendInternal 将本节点产生的消息放到内部消息 channel 之中,
+// 调用者通常就在处理消息的协程之中, 因此在新的协程之中发送, 不能阻塞
+func (pbftImpl *ConsensusPbftImpl) sendInternal(msg *message.ConsensusMessage) {
+	msg.From = pbftImpl.LocalPeerId
+	go func() {
+		select {
+		case pbftImpl.InternalMsgChan <- msg:
+		case <-pbftImpl.quitC:
+		}
+	}()
+}
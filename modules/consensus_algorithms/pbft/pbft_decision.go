@@ -0,0 +1,34 @@
+package pbft
+
+import (
+	"zhanghefan123/security/modules/decision_log"
+)
+
+// recordRound 记录接入节点上一轮认证的开始或者结束
+func recordRound(pbftImpl *ConsensusPbftImpl, phase, userId, requestId string) {
+	view, primary := pbftImpl.ValidatorSet.CurrentView()
+	pbftImpl.DecisionLog.Record(decision_log.Record{
+		View:      view,
+		Primary:   primary,
+		Phase:     phase,
+		UserId:    userId,
+		RequestId: requestId,
+		AccessId:  pbftImpl.LocalPeerId,
+	})
+}
+
+// recordDecision 记录节点做出的决定或者接入节点返回的结果, 以及支持这个判断的投票者
+func recordDecision(pbftImpl *ConsensusPbftImpl, phase, userId, requestId, accessId string, judgement bool, quorum []string) {
+	view, primary := pbftImpl.ValidatorSet.CurrentView()
+	pbftImpl.DecisionLog.Record(decision_log.Record{
+		View:       view,
+		Primary:    primary,
+		Phase:      phase,
+		UserId:     userId,
+		RequestId:  requestId,
+		AccessId:   accessId,
+		Judgement:  judgement,
+		Quorum:     quorum,
+		Validators: pbftImpl.ValidatorSet.Size(),
+	})
+}
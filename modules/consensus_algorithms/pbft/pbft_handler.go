@@ -0,0 +1,142 @@
+package pbft
+
+import (
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/state"
+	"zhanghefan123/security/modules/request_pool"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// Handle 各种类型消息, 直到共识模块停止
+func Handle(pbftImpl *ConsensusPbftImpl) {
+	pruneTimer := pbftImpl.Clock.NewTimer(state.PruneInterval)
+	defer func() { pruneTimer.Stop() }()
+	for {
+		select {
+		// 接受到用户发送来的请求
+		case userRequest := <-pbftImpl.RequestPool.RequestChan:
+			HandleUserRequest(pbftImpl, userRequest)
+		// 接受其他节点广播来的待处理请求
+		case pendingRequest := <-pbftImpl.GossipMsgChan:
+			HandleGossipedRequest(pbftImpl, pendingRequest)
+		// 接受内部网络之中的 ConsensusMsg, 持有锁防止和管理服务同时修改共识状态
+		case internalMsg := <-pbftImpl.InternalMsgChan:
+			pbftImpl.Lock()
+			HandleConsensusMsg(pbftImpl, internalMsg)
+			pbftImpl.Unlock()
+		// 接受外部网络中的 ConsensusMsg
+		case externalMsg := <-pbftImpl.ExternalMsgChan:
+			pbftImpl.Lock()
+			HandleConsensusMsg(pbftImpl, externalMsg)
+			pbftImpl.Unlock()
+		// 定期清理过期的请求 id 以及投票, 而不是在每次收到投票的时候遍历
+		case <-pruneTimer.C():
+			pbftImpl.Lock()
+			pbftImpl.ConsensusState.Prune()
+			pbftImpl.Unlock()
+			pruneTimer = pbftImpl.Clock.NewTimer(state.PruneInterval)
+		// 共识模块停止
+		case <-pbftImpl.quitC:
+			return
+		}
+	}
+}
+
+// HandleUserRequest 处理用户消息
+func HandleUserRequest(pbftImpl *ConsensusPbftImpl, request *request_pool.Request) {
+	// 拿到实际的消息类型
+	switch request.Message.Type {
+	case pb.RpcMessageType_AuthRequest:
+		HandleAuthenticationRequest(pbftImpl, request)
+	}
+}
+
+// HandleConsensusMsg 处理消息
+func HandleConsensusMsg(pbftImpl *ConsensusPbftImpl, msg *message.ConsensusMessage) {
+	switch msg.Type {
+	case pbftPb.PBFTMsgType_MSG_PRE_PREPARE:
+		prePrepareMsg := msg.Msg.(*pbftPb.PrePrepare)
+		HandlePrePrepareMessage(pbftImpl, msg.From, prePrepareMsg)
+	case pbftPb.PBFTMsgType_MSG_PREPARE:
+		prepareMsg := msg.Msg.(*pbftPb.Vote)
+		HandlePrepareMessage(pbftImpl, prepareMsg)
+	case pbftPb.PBFTMsgType_MSG_COMMIT:
+		commitMsg := msg.Msg.(*pbftPb.Vote)
+		HandleCommitMessage(pbftImpl, commitMsg)
+	case pbftPb.PBFTMsgType_MSG_REPLY:
+		replyMsg := msg.Msg.(*pbftPb.Vote)
+		HandleReplyMessage(pbftImpl, replyMsg)
+	}
+}
+
+// HandlePrePrepareMessage 处理预准备消息, 只有当前视图的主节点 from 才能对请求进行排序
+func HandlePrePrepareMessage(pbftImpl *ConsensusPbftImpl, from string, prePrepareMsg *pbftPb.PrePrepare) {
+	pbftImpl.Logger.Infof("handle internal preprepare message")
+	consensusState := pbftImpl.ConsensusState
+	userId, requestId := prePrepareMsg.UserId, prePrepareMsg.RequestId
+	if !pbftImpl.ValidatorSet.IsPrimary(from) {
+		pbftImpl.Logger.Warnf("[%s] preprepare of request [%s] from non-primary %s, ignore", pbftImpl.LocalPeerId, requestId, from)
+		return
+	}
+	if consensusState.IsRequestFinished(requestId) {
+		pbftImpl.Logger.Debugf("[%s] preprepare of finished request [%s], ignore", pbftImpl.LocalPeerId, requestId)
+		return
+	}
+	consensusState.MarkRequestSeen(requestId)
+
+	if userState, ok := consensusState.UserStates[userId]; ok && userState.RequestId != requestId {
+		// 接入节点上同一个用户只会有一个请求在共识, 不同的请求 id 说明是其他节点接入的同一个用户,
+		// 本节点接入的一轮在超时之前不能被替换
+		if consensusState.AuthenticationResults[userId] != nil && !consensusState.IsRoundExpired(userId) {
+			pbftImpl.Logger.Errorf("[%s] user [%s] is authenticating with request [%s], ignore request [%s]",
+				pbftImpl.LocalPeerId, userId, userState.RequestId, requestId)
+			return
+		}
+		// 主节点已经对新的请求进行了排序 (发送者在上面已经检查过), 说明之前的一轮在主节点上已经结束,
+		// 本节点上没有结束 (例如没有收集到足够的投票) 的那一轮被新的请求替换
+		pbftImpl.Logger.Warnf("[%s] round [%s] of user [%s] replaced by request [%s] from primary %s",
+			pbftImpl.LocalPeerId, userState.RequestId, userId, requestId, from)
+		consensusState.RemoveUser(userId)
+	}
+	// 请求可能是其他节点接入的, 本节点只是参与共识, 不需要返回结果
+	if _, ok := consensusState.CurrentUsers[userId]; !ok {
+		if err := consensusState.AddUserForAuthentication(userId, requestId, nil); err != nil {
+			pbftImpl.Logger.Errorf("add user for authentication failed, %v", err)
+			return
+		}
+	}
+	// 重复的 prePrepare 不会再次进入准备阶段
+	if consensusState.UserStates[userId].Step != pbftPb.Step_PRE_PREPARE {
+		pbftImpl.Logger.Debugf("[%s] duplicated preprepare of request [%s], ignore", pbftImpl.LocalPeerId, requestId)
+		return
+	}
+	EnterPrepareStage(pbftImpl, prePrepareMsg)
+
+	// 在 prePrepare 之前到达的投票
+	for _, earlyVote := range consensusState.TakeEarlyVotes(requestId) {
+		addVote(pbftImpl, earlyVote)
+	}
+}
+
+// HandlePrepareMessage 处理准备消息
+func HandlePrepareMessage(pbftImpl *ConsensusPbftImpl, prepareVote *pbftPb.Vote) {
+	pbftImpl.Logger.Infof("handle internal prepare message")
+	addVote(pbftImpl, prepareVote)
+}
+
+// HandleCommitMessage 处理提交消息
+func HandleCommitMessage(pbftImpl *ConsensusPbftImpl, commitVote *pbftPb.Vote) {
+	pbftImpl.Logger.Infof("handle internal commit message")
+	addVote(pbftImpl, commitVote)
+}
+
+// HandleReplyMessage 处理响应消息, 响应消息可以是本地提交的发送给其他节点的, 也可能是其他节点发来的
+func HandleReplyMessage(pbftImpl *ConsensusPbftImpl, replyVote *pbftPb.Vote) {
+	pbftImpl.Logger.Infof("handle internal reply message")
+	if pbftImpl.LocalPeerId == replyVote.AccessId {
+		addVote(pbftImpl, replyVote)
+	} else {
+		SendConsensusVoteMessage(pbftImpl, replyVote) // 将消息发送到指定的 accessId 的位置处
+	}
+}
@@ -0,0 +1,76 @@
+package pbft
+
+import (
+	"zhanghefan123/security/common/msgbus"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
+	"zhanghefan123/security/modules/utils"
+	pbNet "zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// SendPrePrepareMessage 发送预准备消息
+func SendPrePrepareMessage(pbftImpl *ConsensusPbftImpl, prePrepareMessage *pbftPb.PrePrepare) {
+	// 序列化为 pbft.PBFTMsg
+	msg := message.SerializePrePrepareConsensusMessage(prePrepareMessage)
+
+	// 广播这条消息
+	SendMessageCore(pbftImpl, msg, variables.AllConsensusNodes)
+}
+
+// SendConsensusVoteMessage 发送共识投票消息
+func SendConsensusVoteMessage(pbftImpl *ConsensusPbftImpl, vote *pbftPb.Vote) {
+	var msg *pbftPb.PBFTMsg
+	switch vote.Type {
+	case pbftPb.VoteType_VOTE_PREPARE:
+		// 如果是 prepare 消息的话就进行广播的操作
+		msg = message.SerializePrepareConsensusMessage(vote)
+		SendMessageCore(pbftImpl, msg, variables.AllConsensusNodes)
+	case pbftPb.VoteType_VOTE_COMMIT:
+		// 如果是 commit 消息的话就进行广播的操作
+		msg = message.SerializeCommitConsensusMessage(vote)
+		SendMessageCore(pbftImpl, msg, variables.AllConsensusNodes)
+	case pbftPb.VoteType_VOTE_REPLY:
+		// 如果是 reply 消息的话就返回到 accessNode
+		msg = message.SerializeReplyConsensusMessage(vote)
+		SendMessageCore(pbftImpl, msg, vote.AccessId)
+	}
+}
+
+// SendMessageCore 消息发送的核心, 设置了拜占庭行为的节点在发送给每个节点之前会篡改消息
+func SendMessageCore(pbftImpl *ConsensusPbftImpl, msg *pbftPb.PBFTMsg, destination string) {
+	destinations := []string{destination}
+	if destination == variables.AllConsensusNodes {
+		destinations = make([]string, 0, len(pbftImpl.ValidatorSet.Validators))
+		for _, validator := range pbftImpl.ValidatorSet.Validators {
+			if validator != pbftImpl.LocalPeerId {
+				destinations = append(destinations, validator)
+			}
+		}
+	}
+	behaviour := pbftImpl.Behaviour()
+	for _, validator := range destinations {
+		msgs := []*pbftPb.PBFTMsg{msg}
+		if behaviour != nil {
+			msgs = behaviour.Tamper(pbftImpl.LocalPeerId, validator, msg)
+		}
+		for _, m := range msgs {
+			go func(m *pbftPb.PBFTMsg, validator string) {
+				netMsg := message.GenerateNetMsgFromProto(m, validator)
+				pbftImpl.Logger.Infof("%s send consensus message to %s succeed", pbftImpl.LocalPeerId, validator)
+				pbftImpl.MsgBus.Publish(msgbus.SendConsensusMsg, netMsg)
+			}(m, validator)
+		}
+	}
+}
+
+// SendPendingRequestMessage 通过 pubsub 将待处理请求广播给其他的节点, NetMsg 的 To 为空表示广播
+func SendPendingRequestMessage(pbftImpl *ConsensusPbftImpl, pendingRequest *pbftPb.PendingRequest) {
+	netMsg := &pbNet.NetMsg{
+		Payload: utils.MustMarshal(pendingRequest),
+		Type:    pbNet.NetMsg_TX,
+	}
+	pbftImpl.Logger.Infof("%s gossip pending request [%s] of user [%s]",
+		pbftImpl.LocalPeerId, pendingRequest.RequestId, pendingRequest.UserId)
+	pbftImpl.MsgBus.Publish(msgbus.SendTxPoolMsg, netMsg)
+}
@@ -1,24 +1,24 @@
-package state
+package pbft
 
 import (
 	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
-	"zhanghefan123/security/modules/consensus_algorithms/pbft"
-	"zhanghefan123/security/modules/consensus_algorithms/pbft/api"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/message"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
+	"zhanghefan123/security/modules/decision_log"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
 )
 
 // EnterPrepareStage [PrePrepare -> Prepare] 进入准备阶段
-func EnterPrepareStage(pbftImpl *pbft.ConsensusPbftImpl, prePrepare *pbftPb.PrePrepare) {
+func EnterPrepareStage(pbftImpl *ConsensusPbftImpl, prePrepare *pbftPb.PrePrepare) {
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s/%s] consensus enter prepare", pbftImpl.LocalPeerId, prePrepare.UserId)
 
 	// 用户合法性检查 --> 给出了一次自己的判断
-	legal := api.UserLegalityCheck(pbftImpl.LegalUsers, prePrepare.UserId)
+	legal := UserLegalityCheck(pbftImpl.LegalUsers, prePrepare.UserId)
 
 	// 创建相应的 PrepareVote
 	prepareVote := message.NewVote(pbftPb.VoteType_VOTE_PREPARE, pbftImpl.LocalPeerId,
-		prePrepare.UserId, prePrepare.AccessId, legal)
+		prePrepare.UserId, prePrepare.AccessId, prePrepare.RequestId, legal)
 
 	// 将 vote 封装成为 ConsensusMsg
 	prepareVoteConsensusMsg := message.CreatePrepareConsensusMessage(prepareVote)
@@ -33,24 +33,25 @@ func EnterPrepareStage(pbftImpl *pbft.ConsensusPbftImpl, prePrepare *pbftPb.PreP
 		pbftImpl.Logger.Errorf("state error: user state: %v", variables.ErrUserDontExist)
 	}
 
-	// 将 自己产生的 Vote 放到内部消息 channel 之中
-	pbftImpl.InternalMsgChan <- prepareVoteConsensusMsg
+	// 将自己产生的 Vote 广播给其他的验证者, 并放到内部消息 channel 之中
+	SendConsensusVoteMessage(pbftImpl, prepareVote)
+	pbftImpl.sendInternal(prepareVoteConsensusMsg)
 
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s] generated [%s] prepare message", pbftImpl.LocalPeerId, prepareVote.UserId)
 }
 
 // EnterCommitStage 当收到了超过 [2/3] 个 Prepare 消息的时候, 进入 Commit 阶段
-func EnterCommitStage(pbftImpl *pbft.ConsensusPbftImpl, prepare *pbftPb.Vote) {
+func EnterCommitStage(pbftImpl *ConsensusPbftImpl, prepare *pbftPb.Vote) {
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s/%s] consensus enter commit", pbftImpl.LocalPeerId, prepare.UserId)
 
 	// 用户合法性检查
-	legal := api.UserLegalityCheck(pbftImpl.LegalUsers, prepare.UserId)
+	legal := UserLegalityCheck(pbftImpl.LegalUsers, prepare.UserId)
 
 	// 创建相应的 commitVote
 	commitVote := message.NewVote(pbftPb.VoteType_VOTE_COMMIT, pbftImpl.LocalPeerId,
-		prepare.UserId, prepare.AccessId, legal)
+		prepare.UserId, prepare.AccessId, prepare.RequestId, legal)
 
 	// 将 vote 封装成为 ConsensusMsg
 	commitVoteConsensusMsg := message.CreateCommitConsensusMessage(commitVote)
@@ -65,27 +66,43 @@ func EnterCommitStage(pbftImpl *pbft.ConsensusPbftImpl, prepare *pbftPb.Vote) {
 		pbftImpl.Logger.Errorf("state error: user state: %v", variables.ErrUserDontExist)
 	}
 
-	// 将自己产生的 Vote 放到内部消息 channel 之中
-	pbftImpl.InternalMsgChan <- commitVoteConsensusMsg
+	// 将自己产生的 Vote 广播给其他的验证者, 并放到内部消息 channel 之中
+	SendConsensusVoteMessage(pbftImpl, commitVote)
+	pbftImpl.sendInternal(commitVoteConsensusMsg)
 
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s] generated [%s] commit message", pbftImpl.LocalPeerId, prepare.UserId)
+
+	// 在准备阶段的时候 commit 投票可能已经达到了 2/3
+	if userVoteSet, ok := pbftImpl.ConsensusState.UserVoteSets[prepare.UserId]; ok && userVoteSet.CommitVoteSet.Maj23 {
+		EnterReplyStage(pbftImpl, prepare)
+	}
 }
 
 // EnterReplyStage 进入响应阶段, legal 是超过了 2/3 的人给出的判断, 这个时候不应该给出自己的判断
-func EnterReplyStage(pbftImpl *pbft.ConsensusPbftImpl, commit *pbftPb.Vote) {
+func EnterReplyStage(pbftImpl *ConsensusPbftImpl, commit *pbftPb.Vote) {
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s/%s] consensus enter reply", pbftImpl.LocalPeerId, commit.UserId)
 
+	// 超过 2/3 的 commit 投票给出的判断
+	consensusState := pbftImpl.ConsensusState
+	legal := false
+	var quorum []string
+	if userVoteSet, ok := consensusState.UserVoteSets[commit.UserId]; ok {
+		legal = userVoteSet.CommitVoteSet.Judgement
+		quorum = userVoteSet.CommitVoteSet.Quorum()
+	}
+	recordDecision(pbftImpl, decision_log.PhaseCommit, commit.UserId, commit.RequestId, commit.AccessId, legal, quorum)
+
 	// 创建相应的 replyVote
 	replyVote := message.NewVote(pbftPb.VoteType_VOTE_REPLY, pbftImpl.LocalPeerId,
-		commit.UserId, commit.AccessId, legal)
+		commit.UserId, commit.AccessId, commit.RequestId, legal)
 
 	// 将 replyVote 封装成为 ConsensusMsg
 	replyVoteConsensusMsg := message.CreateReplyConsensusMessage(replyVote)
 
 	// 进行状态的转换
-	if userState, ok := pbftImpl.ConsensusState.UserStates[commit.UserId]; ok {
+	if userState, ok := consensusState.UserStates[commit.UserId]; ok {
 		err := userState.EnterReplyStage()
 		if err != nil {
 			pbftImpl.Logger.Errorf("state error: %v", err)
@@ -94,15 +111,20 @@ func EnterReplyStage(pbftImpl *pbft.ConsensusPbftImpl, commit *pbftPb.Vote) {
 		pbftImpl.Logger.Errorf("state error: user state: %v", variables.ErrUserDontExist)
 	}
 
-	// 将自己产生的 Vote 放到内部消息 channel 之中
-	pbftImpl.InternalMsgChan <- replyVoteConsensusMsg
+	// 将自己产生的 Vote 放到内部消息 channel 之中, 不是接入节点的时候会被转发给接入节点
+	pbftImpl.sendInternal(replyVoteConsensusMsg)
+
+	// 只参与共识的节点在发送响应之后这一轮就结束了, 之后同一个用户可以重新发起认证
+	if commit.AccessId != pbftImpl.LocalPeerId {
+		consensusState.RemoveUser(commit.UserId)
+	}
 
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s] generated [%s] reply message", pbftImpl.LocalPeerId, commit.UserId)
 }
 
 // EnterCompleteStage 进入
-func EnterCompleteStage(pbftImpl *pbft.ConsensusPbftImpl, reply *pbftPb.Vote) {
+func EnterCompleteStage(pbftImpl *ConsensusPbftImpl, reply *pbftPb.Vote) {
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s/%s] consensus enter complte", pbftImpl.LocalPeerId, reply.UserId)
 
@@ -116,6 +138,31 @@ func EnterCompleteStage(pbftImpl *pbft.ConsensusPbftImpl, reply *pbftPb.Vote) {
 		pbftImpl.Logger.Errorf("state error: user state: %v", variables.ErrUserDontExist)
 	}
 
+	// 超过 1/3 的 reply 投票给出的判断
+	consensusState := pbftImpl.ConsensusState
+	legal := false
+	var quorum []string
+	if userVoteSet, ok := consensusState.UserVoteSets[reply.UserId]; ok {
+		legal = userVoteSet.ReplyVoteSet.Judgement
+		quorum = userVoteSet.ReplyVoteSet.Quorum()
+	}
+	recordDecision(pbftImpl, decision_log.PhaseReply, reply.UserId, reply.RequestId, reply.AccessId, legal, quorum)
+
+	// 接入节点将结果交给等待的请求, 只参与共识的节点没有结果 channel
+	if resultChan, ok := consensusState.AuthenticationResults[reply.UserId]; ok && resultChan != nil {
+		result := pb.AuthenticationResult_IllegalUser
+		if legal {
+			result = pb.AuthenticationResult_LegalUser
+		}
+		select {
+		case resultChan <- result:
+		default:
+		}
+	}
+
+	// 这一轮已经结束, 之后同一个用户可以重新发起认证
+	consensusState.RemoveUser(reply.UserId)
+
 	// 日志输出
 	pbftImpl.Logger.Infof("[%s] generated [%s] reply message", pbftImpl.LocalPeerId, reply.UserId)
 }
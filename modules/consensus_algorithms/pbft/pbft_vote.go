@@ -0,0 +1,54 @@
+package pbft
+
+import (
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+)
+
+// addVote 将投票加入用户这一轮的投票集合, 达到法定人数的时候进入下一个阶段:
+// 1. 投票所属的请求已经在本节点上结束了, 丢弃
+// 2. 还没有收到这个请求的 prePrepare, 先保存起来, 进入准备阶段之后再加入
+func addVote(pbftImpl *ConsensusPbftImpl, vote *pbftPb.Vote) {
+	consensusState := pbftImpl.ConsensusState
+	userState, ok := consensusState.UserStates[vote.UserId]
+	if !ok || userState.RequestId != vote.RequestId || userState.Step == pbftPb.Step_PRE_PREPARE {
+		if consensusState.IsRequestFinished(vote.RequestId) {
+			pbftImpl.Logger.Debugf("[%s] %s vote of finished request [%s], ignore",
+				pbftImpl.LocalPeerId, vote.Type, vote.RequestId)
+			return
+		}
+		if !consensusState.BufferEarlyVote(vote) {
+			pbftImpl.Logger.Warnf("[%s] too many early votes from %s, drop %s vote of request [%s]",
+				pbftImpl.LocalPeerId, vote.Voter, vote.Type, vote.RequestId)
+		}
+		return
+	}
+	userVoteSet := consensusState.UserVoteSets[vote.UserId]
+
+	switch vote.Type {
+	case pbftPb.VoteType_VOTE_PREPARE:
+		if err := userVoteSet.PrepareVoteSet.AddVote(vote); err != nil {
+			return
+		}
+		// 只有还在准备阶段的时候才进入提交阶段, 之后到达的投票只进行记录
+		if userVoteSet.PrepareVoteSet.Maj23 && userState.Step == pbftPb.Step_PREPARE {
+			pbftImpl.Logger.Infof("[%s] up to 2/3 consistent prepare message", vote.UserId)
+			EnterCommitStage(pbftImpl, vote)
+		}
+	case pbftPb.VoteType_VOTE_COMMIT:
+		if err := userVoteSet.CommitVoteSet.AddVote(vote); err != nil {
+			return
+		}
+		if userVoteSet.CommitVoteSet.Maj23 && userState.Step == pbftPb.Step_COMMIT {
+			pbftImpl.Logger.Infof("[%s] up to 2/3 consistent commit message", vote.UserId)
+			EnterReplyStage(pbftImpl, vote)
+		}
+	case pbftPb.VoteType_VOTE_REPLY:
+		if err := userVoteSet.ReplyVoteSet.AddVote(vote); err != nil {
+			return
+		}
+		if userVoteSet.ReplyVoteSet.Maj13 {
+			pbftImpl.Logger.Infof("[%s] up to 1/3 consistent reply message", vote.UserId)
+			EnterCompleteStage(pbftImpl, vote)
+		}
+	}
+}
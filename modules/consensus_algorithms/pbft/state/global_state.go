@@ -1,6 +1,10 @@
 package state
 
 import (
+	"sort"
+	"time"
+	"zhanghefan123/security/modules/clock"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/validator"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/vote"
@@ -8,32 +12,63 @@ import (
 	"zhanghefan123/security/protocol"
 )
 
+// seenRequestTTL 已经见过以及已经结束的请求 id 的保留时间, 超过之后同一个请求 id 不会再被去重
+const seenRequestTTL = 5 * time.Minute
+
+// earlyVoteTTL 在 prePrepare 之前到达的投票的保留时间, 和接入节点等待共识结果的时间一致
+const earlyVoteTTL = 30 * time.Second
+
+// maxEarlyVotesPerVoter 每个投票者最多缓存的在 prePrepare 之前到达的投票, 防止拜占庭节点使用不存在的请求 id 占满内存
+const maxEarlyVotesPerVoter = 1024
+
+// PruneInterval 清理过期的请求 id 以及投票的间隔
+const PruneInterval = 10 * time.Second
+
+// roundTTL 一轮共识的最长时间, 和接入节点等待共识结果的时间一致, 超过之后这一轮可以被其他请求替换
+const roundTTL = 30 * time.Second
+
+// earlyVote 在 prePrepare 之前到达的投票
+type earlyVote struct {
+	vote     *pbftPb.Vote
+	received time.Time
+}
+
 // GlobalState 共识状态
 type GlobalState struct {
 	Logger                protocol.Logger
+	Clock                 clock.Clock                             // 请求过期时间使用的时钟
 	LocalPeerId           string                                  // 当前卫星的 peerId
 	ValidatorSet          *validator.ValidatorSet                 // 所有的验证者集合
 	CurrentUsers          map[string]interface{}                  // 当前的所有用户
 	UserVoteSets          map[string]*vote.UserVoteSet            // 每个用户在各个阶段的投票集合
 	UserStates            map[string]*UserState                   // 每个用户的状态
 	AuthenticationResults map[string]chan pb.AuthenticationResult // 这个是给用户响应的结果
+	SeenRequests          map[string]time.Time                    // 已经见过的请求 id, 用于对广播的请求进行去重
+	FinishedRequests      map[string]time.Time                    // 在本节点上已经结束的请求 id, 之后到达的投票会被丢弃
+	EarlyVotes            map[string][]*earlyVote                 // 请求 id -> 在 prePrepare 之前到达的投票
+	EarlyVoteCounts       map[string]int                          // 投票者 -> 缓存的在 prePrepare 之前到达的投票数量
 }
 
 // NewConsensusState 新的共识状态
-func NewConsensusState(logger protocol.Logger, localPeerId string, validatorSet *validator.ValidatorSet) *GlobalState {
+func NewConsensusState(logger protocol.Logger, clk clock.Clock, localPeerId string, validatorSet *validator.ValidatorSet) *GlobalState {
 	return &GlobalState{
 		Logger:                logger,
+		Clock:                 clk,
 		LocalPeerId:           localPeerId,
 		ValidatorSet:          validatorSet,
 		CurrentUsers:          make(map[string]interface{}),
 		UserVoteSets:          make(map[string]*vote.UserVoteSet),
 		UserStates:            make(map[string]*UserState),
 		AuthenticationResults: make(map[string]chan pb.AuthenticationResult),
+		SeenRequests:          make(map[string]time.Time),
+		FinishedRequests:      make(map[string]time.Time),
+		EarlyVotes:            make(map[string][]*earlyVote),
+		EarlyVoteCounts:       make(map[string]int),
 	}
 }
 
-// AddUserForAuthentication 添加等待认证的用户
-func (gs *GlobalState) AddUserForAuthentication(userId string, resultChan chan pb.AuthenticationResult) error {
+// AddUserForAuthentication 添加等待认证的用户, requestId 为这一轮所对应的请求
+func (gs *GlobalState) AddUserForAuthentication(userId, requestId string, resultChan chan pb.AuthenticationResult) error {
 	// 判断是否已经存在了等待认证的用户
 	if _, ok := gs.CurrentUsers[userId]; ok {
 		gs.Logger.Errorf("user authentication already exist")
@@ -41,7 +76,190 @@ func (gs *GlobalState) AddUserForAuthentication(userId string, resultChan chan p
 	}
 	gs.CurrentUsers[userId] = struct{}{}
 	gs.UserVoteSets[userId] = vote.NewUserVoteSet(gs.Logger, gs.ValidatorSet) // 设置投票集
-	gs.UserStates[userId] = NewUserState(userId)                              // 新的状态
+	gs.UserStates[userId] = NewUserState(userId, requestId, gs.Clock.Now())   // 新的状态
 	gs.AuthenticationResults[userId] = resultChan                             // 创建投票结果
 	return nil
 }
+
+// MarkRequestSeen 记录见过的请求 id, 第一次见到的时候返回 true, 重复的请求返回 false
+func (gs *GlobalState) MarkRequestSeen(requestId string) bool {
+	if _, ok := gs.SeenRequests[requestId]; ok {
+		return false
+	}
+	gs.SeenRequests[requestId] = gs.Clock.Now()
+	return true
+}
+
+// Prune 删除超过保留时间的请求 id 以及投票, 由共识模块每隔 PruneInterval 调用一次
+func (gs *GlobalState) Prune() {
+	now := gs.Clock.Now()
+	for seenId, seenTime := range gs.SeenRequests {
+		if now.Sub(seenTime) > seenRequestTTL {
+			delete(gs.SeenRequests, seenId)
+		}
+	}
+	for finishedId, finishedTime := range gs.FinishedRequests {
+		if now.Sub(finishedTime) > seenRequestTTL {
+			delete(gs.FinishedRequests, finishedId)
+		}
+	}
+	for requestId, votes := range gs.EarlyVotes {
+		if len(votes) > 0 && now.Sub(votes[0].received) > earlyVoteTTL {
+			gs.dropEarlyVotes(requestId)
+		}
+	}
+}
+
+// IsRequestFinished 判断请求在本节点上是否已经结束
+func (gs *GlobalState) IsRequestFinished(requestId string) bool {
+	_, ok := gs.FinishedRequests[requestId]
+	return ok
+}
+
+// BufferEarlyVote 保存在 prePrepare 之前到达的投票, 消息总线以及网络都不保证消息的顺序,
+// 每个验证者在一个请求上每种投票只保存一票, 并且总共最多保存 maxEarlyVotesPerVoter 票, 投票被丢弃的时候返回 false
+func (gs *GlobalState) BufferEarlyVote(vote *pbftPb.Vote) bool {
+	if !gs.ValidatorSet.Contains(vote.Voter) || gs.EarlyVoteCounts[vote.Voter] >= maxEarlyVotesPerVoter {
+		return false
+	}
+	for _, buffered := range gs.EarlyVotes[vote.RequestId] {
+		if buffered.vote.Voter == vote.Voter && buffered.vote.Type == vote.Type {
+			return false
+		}
+	}
+	gs.EarlyVotes[vote.RequestId] = append(gs.EarlyVotes[vote.RequestId], &earlyVote{vote: vote, received: gs.Clock.Now()})
+	gs.EarlyVoteCounts[vote.Voter]++
+	return true
+}
+
+// dropEarlyVotes 删除请求在 prePrepare 之前到达的投票, 返回被删除的投票
+func (gs *GlobalState) dropEarlyVotes(requestId string) []*earlyVote {
+	buffered := gs.EarlyVotes[requestId]
+	delete(gs.EarlyVotes, requestId)
+	for _, buffer := range buffered {
+		if gs.EarlyVoteCounts[buffer.vote.Voter]--; gs.EarlyVoteCounts[buffer.vote.Voter] <= 0 {
+			delete(gs.EarlyVoteCounts, buffer.vote.Voter)
+		}
+	}
+	return buffered
+}
+
+// TakeEarlyVotes 取出请求在 prePrepare 之前到达的投票
+func (gs *GlobalState) TakeEarlyVotes(requestId string) []*pbftPb.Vote {
+	buffered := gs.dropEarlyVotes(requestId)
+	votes := make([]*pbftPb.Vote, 0, len(buffered))
+	for _, buffer := range buffered {
+		votes = append(votes, buffer.vote)
+	}
+	return votes
+}
+
+// IsRoundExpired 判断用户正在进行的这一轮是否已经超过了最长时间, 没有进行中的一轮的时候返回 false
+func (gs *GlobalState) IsRoundExpired(userId string) bool {
+	userState, ok := gs.UserStates[userId]
+	return ok && gs.Clock.Now().Sub(userState.Started) > roundTTL
+}
+
+// RemoveUser 放弃用户的这一轮认证, 删除用户相关的所有状态, 之后同一个用户可以重新发起认证
+func (gs *GlobalState) RemoveUser(userId string) {
+	if _, ok := gs.CurrentUsers[userId]; !ok {
+		return
+	}
+	// 这一轮的请求已经结束, 之后到达的投票不会再开始新的一轮
+	if userState, ok := gs.UserStates[userId]; ok && userState.RequestId != "" {
+		gs.FinishedRequests[userState.RequestId] = gs.Clock.Now()
+		gs.dropEarlyVotes(userState.RequestId)
+	}
+	delete(gs.CurrentUsers, userId)
+	delete(gs.UserVoteSets, userId)
+	delete(gs.UserStates, userId)
+	delete(gs.AuthenticationResults, userId)
+	gs.Logger.Infof("[%s] round of user [%s] abandoned", gs.LocalPeerId, userId)
+}
+
+// RemoveRequest 放弃用户 requestId 对应的这一轮认证, 用户正在进行的是其他请求的一轮的时候不做任何处理
+func (gs *GlobalState) RemoveRequest(userId, requestId string) bool {
+	userState, ok := gs.UserStates[userId]
+	if !ok || userState.RequestId != requestId {
+		return false
+	}
+	gs.RemoveUser(userId)
+	return true
+}
+
+// VoteSetDump 某个阶段投票集合的快照
+type VoteSetDump struct {
+	LegalVoters   []string `json:"legal_voters"`   // 认为用户合法的投票者
+	IllegalVoters []string `json:"illegal_voters"` // 认为用户非法的投票者
+	Quorum        bool     `json:"quorum"`         // 是否已经达到法定人数
+	Judgement     bool     `json:"judgement"`      // 这个阶段给出的判断
+}
+
+// UserStateDump 用户共识状态的快照, 用于管理服务进行导出
+type UserStateDump struct {
+	UserId     string       `json:"user_id"`
+	RequestId  string       `json:"request_id"`
+	Step       string       `json:"step"`
+	Validators []string     `json:"validators"`
+	Prepare    *VoteSetDump `json:"prepare"`
+	Commit     *VoteSetDump `json:"commit"`
+	Reply      *VoteSetDump `json:"reply"`
+}
+
+// newVoteSetDump 根据投票集合之中的投票创建快照
+func newVoteSetDump(legalVotes, illegalVotes map[string]*pbftPb.Vote, quorum, judgement bool) *VoteSetDump {
+	dump := &VoteSetDump{
+		LegalVoters:   make([]string, 0, len(legalVotes)),
+		IllegalVoters: make([]string, 0, len(illegalVotes)),
+		Quorum:        quorum,
+		Judgement:     judgement,
+	}
+	for voter := range legalVotes {
+		dump.LegalVoters = append(dump.LegalVoters, voter)
+	}
+	for voter := range illegalVotes {
+		dump.IllegalVoters = append(dump.IllegalVoters, voter)
+	}
+	sort.Strings(dump.LegalVoters)
+	sort.Strings(dump.IllegalVoters)
+	return dump
+}
+
+// DumpUser 导出用户当前的共识状态
+func (gs *GlobalState) DumpUser(userId string) (*UserStateDump, error) {
+	if _, ok := gs.CurrentUsers[userId]; !ok {
+		return nil, variables.ErrUserRoundNotFound
+	}
+	dump := &UserStateDump{
+		UserId:     userId,
+		Validators: append([]string{}, gs.ValidatorSet.Validators...),
+	}
+	if userState, ok := gs.UserStates[userId]; ok {
+		dump.Step = userState.Step.String()
+		dump.RequestId = userState.RequestId
+	}
+	if voteSet, ok := gs.UserVoteSets[userId]; ok {
+		prepare, commit, reply := voteSet.PrepareVoteSet, voteSet.CommitVoteSet, voteSet.ReplyVoteSet
+		dump.Prepare = newVoteSetDump(prepare.LegalUserVotes, prepare.IllegalUserVotes, prepare.Maj23, prepare.Judgement)
+		dump.Commit = newVoteSetDump(commit.LegalUserVotes, commit.IllegalUserVotes, commit.Maj23, commit.Judgement)
+		dump.Reply = newVoteSetDump(reply.LegalUserVotes, reply.IllegalUserVotes, reply.Maj13, reply.Judgement)
+	}
+	return dump, nil
+}
+
+// ExpireUser 强制结束用户正在进行的这一轮共识, 正在等待结果的请求会收到 ConsensusTimeout
+func (gs *GlobalState) ExpireUser(userId string) error {
+	if _, ok := gs.CurrentUsers[userId]; !ok {
+		return variables.ErrUserRoundNotFound
+	}
+	// 等待结果的一方如果还在等待, 直接告诉它超时了
+	if resultChan, ok := gs.AuthenticationResults[userId]; ok {
+		select {
+		case resultChan <- pb.AuthenticationResult_ConsensusTimeout:
+		default:
+		}
+	}
+	gs.RemoveUser(userId)
+	gs.Logger.Infof("[%s] round of user [%s] expired by admin", gs.LocalPeerId, userId)
+	return nil
+}
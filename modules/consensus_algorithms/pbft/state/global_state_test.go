@@ -0,0 +1,128 @@
+package state
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"zhanghefan123/security/modules/clock"
+	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/validator"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/protocol/test"
+)
+
+// newTestState 创建 node1 ... node4 作为验证者的共识状态
+func newTestState() *GlobalState {
+	clk := clock.NewVirtual(time.Unix(0, 0))
+	validatorSet := validator.NewValidatorSet(test.HoleLogger{}, []string{"node1", "node2", "node3", "node4"})
+	return NewConsensusState(test.HoleLogger{}, clk, "node1", validatorSet)
+}
+
+func TestRemoveUser(t *testing.T) {
+	gs := newTestState()
+	require.NoError(t, gs.AddUserForAuthentication("alice", "request1", nil))
+	require.ErrorIs(t, gs.AddUserForAuthentication("alice", "request2", nil), variables.ErrAlreadyExistUserRequest)
+	gs.BufferEarlyVote(&pbftPb.Vote{UserId: "alice", RequestId: "request1", Voter: "node2"})
+
+	gs.RemoveUser("alice")
+	_, err := gs.DumpUser("alice")
+	require.ErrorIs(t, err, variables.ErrUserRoundNotFound)
+	require.True(t, gs.IsRequestFinished("request1"))
+	require.Empty(t, gs.TakeEarlyVotes("request1"))
+
+	// 删除之后同一个用户可以开始新的一轮, 删除不存在的用户不做任何处理
+	require.NoError(t, gs.AddUserForAuthentication("alice", "request2", nil))
+	gs.RemoveUser("bob")
+}
+
+func TestRemoveRequest(t *testing.T) {
+	gs := newTestState()
+	require.NoError(t, gs.AddUserForAuthentication("alice", "request1", nil))
+
+	// 其他请求不能删除用户正在进行的一轮
+	require.False(t, gs.RemoveRequest("alice", "request2"))
+	require.False(t, gs.RemoveRequest("bob", "request1"))
+	dump, err := gs.DumpUser("alice")
+	require.NoError(t, err)
+	require.Equal(t, "request1", dump.RequestId)
+	require.False(t, gs.IsRequestFinished("request2"))
+
+	require.True(t, gs.RemoveRequest("alice", "request1"))
+	_, err = gs.DumpUser("alice")
+	require.ErrorIs(t, err, variables.ErrUserRoundNotFound)
+}
+
+func TestExpireUser(t *testing.T) {
+	gs := newTestState()
+	require.ErrorIs(t, gs.ExpireUser("alice"), variables.ErrUserRoundNotFound)
+
+	resultChan := make(chan pb.AuthenticationResult, 1)
+	require.NoError(t, gs.AddUserForAuthentication("alice", "request1", resultChan))
+	require.NoError(t, gs.ExpireUser("alice"))
+	require.Equal(t, pb.AuthenticationResult_ConsensusTimeout, <-resultChan)
+	_, err := gs.DumpUser("alice")
+	require.ErrorIs(t, err, variables.ErrUserRoundNotFound)
+	require.True(t, gs.IsRequestFinished("request1"))
+
+	// 只参与共识的节点没有结果 channel, 结果 channel 已满的时候也不会阻塞
+	require.NoError(t, gs.AddUserForAuthentication("bob", "request2", nil))
+	require.NoError(t, gs.ExpireUser("bob"))
+	resultChan <- pb.AuthenticationResult_LegalUser
+	require.NoError(t, gs.AddUserForAuthentication("carol", "request3", resultChan))
+	require.NoError(t, gs.ExpireUser("carol"))
+}
+
+func TestIsRoundExpired(t *testing.T) {
+	gs := newTestState()
+	clk := gs.Clock.(*clock.Virtual)
+	require.False(t, gs.IsRoundExpired("alice"))
+
+	require.NoError(t, gs.AddUserForAuthentication("alice", "request1", nil))
+	clk.AdvanceTo(clk.Now().Add(roundTTL))
+	require.False(t, gs.IsRoundExpired("alice"))
+	clk.AdvanceTo(clk.Now().Add(time.Second))
+	require.True(t, gs.IsRoundExpired("alice"))
+}
+
+func TestBufferEarlyVoteLimits(t *testing.T) {
+	gs := newTestState()
+	prepare := &pbftPb.Vote{Type: pbftPb.VoteType_VOTE_PREPARE, UserId: "alice", RequestId: "request1", Voter: "node2"}
+	require.True(t, gs.BufferEarlyVote(prepare))
+	// 同一个投票者在一个请求上每种投票只保存一票, 不是验证者的投票直接丢弃
+	require.False(t, gs.BufferEarlyVote(prepare))
+	require.True(t, gs.BufferEarlyVote(&pbftPb.Vote{Type: pbftPb.VoteType_VOTE_COMMIT, UserId: "alice", RequestId: "request1", Voter: "node2"}))
+	require.False(t, gs.BufferEarlyVote(&pbftPb.Vote{Type: pbftPb.VoteType_VOTE_PREPARE, UserId: "alice", RequestId: "request1", Voter: "node9"}))
+	require.Len(t, gs.TakeEarlyVotes("request1"), 2)
+	require.Empty(t, gs.EarlyVoteCounts)
+
+	// 每个投票者总共最多保存 maxEarlyVotesPerVoter 票, 其他投票者不受影响
+	for i := 0; i < maxEarlyVotesPerVoter; i++ {
+		require.True(t, gs.BufferEarlyVote(&pbftPb.Vote{UserId: "alice", RequestId: fmt.Sprintf("request%d", i), Voter: "node4"}))
+	}
+	require.False(t, gs.BufferEarlyVote(&pbftPb.Vote{UserId: "alice", RequestId: "one-more", Voter: "node4"}))
+	require.True(t, gs.BufferEarlyVote(&pbftPb.Vote{UserId: "alice", RequestId: "one-more", Voter: "node3"}))
+	gs.TakeEarlyVotes("request0")
+	require.True(t, gs.BufferEarlyVote(&pbftPb.Vote{UserId: "alice", RequestId: "one-more", Voter: "node4"}))
+}
+
+func TestPrune(t *testing.T) {
+	gs := newTestState()
+	clk := gs.Clock.(*clock.Virtual)
+	require.True(t, gs.MarkRequestSeen("request1"))
+	require.True(t, gs.BufferEarlyVote(&pbftPb.Vote{UserId: "alice", RequestId: "request1", Voter: "node2"}))
+
+	// 只有 Prune 会删除过期的投票以及请求 id
+	clk.AdvanceTo(clk.Now().Add(earlyVoteTTL + time.Second))
+	require.False(t, gs.MarkRequestSeen("request1"))
+	require.Len(t, gs.EarlyVotes, 1)
+	gs.Prune()
+	require.Empty(t, gs.EarlyVotes)
+	require.Empty(t, gs.EarlyVoteCounts)
+	require.False(t, gs.MarkRequestSeen("request1"))
+
+	clk.AdvanceTo(clk.Now().Add(seenRequestTTL))
+	gs.Prune()
+	require.True(t, gs.MarkRequestSeen("request1"))
+}
@@ -1,21 +1,26 @@
 package state
 
 import (
+	"time"
 	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
 )
 
 // UserState 状态
 type UserState struct {
-	UserId string
-	Step   pbftPb.Step
+	UserId    string
+	RequestId string    // 这一轮所对应的请求
+	Started   time.Time // 这一轮开始的时间
+	Step      pbftPb.Step
 }
 
 // NewUserState 创建用户状态
-func NewUserState(userId string) *UserState {
+func NewUserState(userId, requestId string, started time.Time) *UserState {
 	return &UserState{
-		UserId: userId,
-		Step:   pbftPb.Step_PRE_PREPARE,
+		UserId:    userId,
+		RequestId: requestId,
+		Started:   started,
+		Step:      pbftPb.Step_PRE_PREPARE,
 	}
 }
 
@@ -23,6 +28,7 @@ func NewUserState(userId string) *UserState {
 func (us *UserState) EnterPrepareStage() error {
 	if us.Step == pbftPb.Step_PRE_PREPARE {
 		us.Step = pbftPb.Step_PREPARE
+		return nil
 	}
 	return variables.ErrWrongState
 }
@@ -31,6 +37,7 @@ func (us *UserState) EnterPrepareStage() error {
 func (us *UserState) EnterCommitStage() error {
 	if us.Step == pbftPb.Step_PREPARE {
 		us.Step = pbftPb.Step_COMMIT
+		return nil
 	}
 	return variables.ErrWrongState
 }
@@ -39,14 +46,16 @@ func (us *UserState) EnterCommitStage() error {
 func (us *UserState) EnterReplyStage() error {
 	if us.Step == pbftPb.Step_COMMIT {
 		us.Step = pbftPb.Step_REPLY
+		return nil
 	}
 	return variables.ErrWrongState
 }
 
-// EnterCompleteStage 进入结束阶段
+// EnterCompleteStage 进入结束阶段, 接入节点收到足够的响应之后结果已经确定, 可以从任何阶段结束
 func (us *UserState) EnterCompleteStage() error {
-	if us.Step == pbftPb.Step_REPLY {
-		us.Step = pbftPb.Step_COMPLETE
+	if us.Step == pbftPb.Step_INIT || us.Step == pbftPb.Step_COMPLETE {
+		return variables.ErrWrongState
 	}
-	return variables.ErrWrongState
+	us.Step = pbftPb.Step_COMPLETE
+	return nil
 }
@@ -9,6 +9,7 @@ type ValidatorSet struct {
 	sync.Mutex
 	Logger     protocol.Logger
 	Validators []string
+	View       uint64 // 当前视图, 主节点为 Validators[View % n]
 }
 
 // Size 返回 validatorSet 的大小
@@ -17,3 +18,40 @@ func (vs *ValidatorSet) Size() int {
 	defer vs.Unlock()
 	return len(vs.Validators)
 }
+
+// Primary 返回当前视图的主节点, 由主节点对广播来的请求进行排序
+func (vs *ValidatorSet) Primary() string {
+	vs.Lock()
+	defer vs.Unlock()
+	if len(vs.Validators) == 0 {
+		return ""
+	}
+	return vs.Validators[vs.View%uint64(len(vs.Validators))]
+}
+
+// CurrentView 返回当前视图以及主节点
+func (vs *ValidatorSet) CurrentView() (uint64, string) {
+	vs.Lock()
+	defer vs.Unlock()
+	if len(vs.Validators) == 0 {
+		return vs.View, ""
+	}
+	return vs.View, vs.Validators[vs.View%uint64(len(vs.Validators))]
+}
+
+// IsPrimary 判断节点是否为当前视图的主节点
+func (vs *ValidatorSet) IsPrimary(peerId string) bool {
+	return vs.Primary() == peerId
+}
+
+// Contains 判断节点是否为验证者
+func (vs *ValidatorSet) Contains(peerId string) bool {
+	vs.Lock()
+	defer vs.Unlock()
+	for _, validator := range vs.Validators {
+		if validator == peerId {
+			return true
+		}
+	}
+	return false
+}
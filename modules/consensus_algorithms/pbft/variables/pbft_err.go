@@ -12,4 +12,6 @@ var (
 	ErrRequestHandleTimeOut    = errors.New("request handle time out")
 	ErrUserDontExist           = errors.New("user dont exist")
 	ErrWrongState              = errors.New("wrong state")
+	ErrUserRoundNotFound       = errors.New("no pending round of user")
+	ErrVoterNotValidator       = errors.New("voter is not a validator")
 )
@@ -1,9 +1,8 @@
 package vote
 
 import (
+	"sort"
 	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
-	"zhanghefan123/security/modules/consensus_algorithms/pbft"
-	"zhanghefan123/security/modules/consensus_algorithms/pbft/state"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/validator"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
 	"zhanghefan123/security/protocol"
@@ -32,6 +31,10 @@ func (vs *PrepareCommitVoteset) AddVote(vote *pbftPb.Vote) error {
 		vs.Logger.Errorf("AddVote on nil Vote")
 		return variables.ErrAddNilVote
 	}
+	if !vs.ValidatorSet.Contains(vote.Voter) {
+		vs.Logger.Warnf("vote from non-validator %s, ignore", vote.Voter)
+		return variables.ErrVoterNotValidator
+	}
 	if _, ok := vs.LegalUserVotes[vote.Voter]; ok {
 		return nil
 	}
@@ -52,7 +55,7 @@ func (vs *PrepareCommitVoteset) AddVote(vote *pbftPb.Vote) error {
 	if !vs.Maj23 {
 		if int32(quorum) <= (vs.LegalUserVotesSum) {
 			vs.Maj23 = true
-			vs.Judgement = false
+			vs.Judgement = true
 		} else if int32(quorum) <= vs.IllegalUserVotesSum {
 			vs.Maj23 = true
 			vs.Judgement = false
@@ -61,6 +64,27 @@ func (vs *PrepareCommitVoteset) AddVote(vote *pbftPb.Vote) error {
 	return nil
 }
 
+// Quorum 返回和达到 2/3 的判断一致的投票者, 按照 id 排序, 还没有达到 2/3 的时候返回空
+func (vs *PrepareCommitVoteset) Quorum() []string {
+	if !vs.Maj23 {
+		return nil
+	}
+	if vs.Judgement {
+		return voters(vs.LegalUserVotes)
+	}
+	return voters(vs.IllegalUserVotes)
+}
+
+// voters 返回投票集合之中的投票者, 按照 id 排序
+func voters(votes map[string]*pbftPb.Vote) []string {
+	ids := make([]string, 0, len(votes))
+	for voter := range votes {
+		ids = append(ids, voter)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
 // NewVoteSet 创建新的投票集给 prepare 和 commit
 func NewVoteSet(logger protocol.Logger, typ pbftPb.VoteType, validatorSet *validator.ValidatorSet) *PrepareCommitVoteset {
 	return &PrepareCommitVoteset{
@@ -75,47 +99,3 @@ func NewVoteSet(logger protocol.Logger, typ pbftPb.VoteType, validatorSet *valid
 		ValidatorSet:        validatorSet,
 	}
 }
-
-// AddPrepareVote 添加准备投票
-func (vs *PrepareCommitVoteset) AddPrepareVote(pbftImpl *pbft.ConsensusPbftImpl, prepareVote *pbftPb.Vote) {
-	userId := prepareVote.UserId
-	if userState, ok := pbftImpl.ConsensusState.UserStates[userId]; ok {
-		if userState.Step != pbftPb.Step_PREPARE {
-			pbftImpl.Logger.Errorf("[%s] add prepareVote at incorrect step", pbftImpl.LocalPeerId)
-		}
-	} else {
-		pbftImpl.Logger.Errorf("cannot retrieve user state")
-		return
-	}
-
-	err := vs.AddVote(prepareVote)
-	if err != nil {
-		return
-	}
-
-	if vs.Maj23 {
-		pbftImpl.Logger.Infof("[%s] up to 2/3 consistent prepare message", prepareVote.UserId)
-		state.EnterCommitStage(pbftImpl, prepareVote)
-	}
-}
-
-// AddCommitVote 添加提交投票
-func (vs *PrepareCommitVoteset) AddCommitVote(pbftImpl *pbft.ConsensusPbftImpl, commitVote *pbftPb.Vote) {
-	userId := commitVote.UserId
-	if userState, ok := pbftImpl.ConsensusState.UserStates[userId]; ok {
-		if userState.Step != pbftPb.Step_COMMIT {
-			pbftImpl.Logger.Errorf("[%s] add commit vote at incorrect step", pbftImpl.LocalPeerId)
-		}
-	} else {
-		pbftImpl.Logger.Errorf("cannot retrieve user state")
-		return
-	}
-	err := vs.AddVote(commitVote)
-	if err != nil {
-		return
-	}
-	if vs.Maj23 {
-		pbftImpl.Logger.Infof("[%s] up to 2/3 consistent commit message", commitVote.UserId)
-		state.EnterReplyStage(pbftImpl, commitVote)
-	}
-}
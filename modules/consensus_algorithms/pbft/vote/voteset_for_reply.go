@@ -2,8 +2,6 @@ package vote
 
 import (
 	pbftPb "zhanghefan123/security/modules/consensus_algorithms/consensus-pb/pbft"
-	"zhanghefan123/security/modules/consensus_algorithms/pbft"
-	"zhanghefan123/security/modules/consensus_algorithms/pbft/state"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/validator"
 	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
 	"zhanghefan123/security/protocol"
@@ -32,6 +30,10 @@ func (rvs *ReplyVoteSet) AddVote(vote *pbftPb.Vote) error {
 		rvs.Logger.Errorf("AddVote on nil Vote")
 		return variables.ErrAddNilVote
 	}
+	if !rvs.ValidatorSet.Contains(vote.Voter) {
+		rvs.Logger.Warnf("vote from non-validator %s, ignore", vote.Voter)
+		return variables.ErrVoterNotValidator
+	}
 	if _, ok := rvs.LegalUserVotes[vote.Voter]; ok {
 		return nil
 	}
@@ -52,7 +54,7 @@ func (rvs *ReplyVoteSet) AddVote(vote *pbftPb.Vote) error {
 	if !rvs.Maj13 {
 		if int32(quorum) <= (rvs.LegalUserVotesSum) {
 			rvs.Maj13 = true
-			rvs.Judgement = false
+			rvs.Judgement = true
 		} else if int32(quorum) <= rvs.IllegalUserVotesSum {
 			rvs.Maj13 = true
 			rvs.Judgement = false
@@ -61,28 +63,15 @@ func (rvs *ReplyVoteSet) AddVote(vote *pbftPb.Vote) error {
 	return nil
 }
 
-// AddReplyVote 添加响应投票
-func (rvs *ReplyVoteSet) AddReplyVote(pbftImpl *pbft.ConsensusPbftImpl, replyVote *pbftPb.Vote) {
-	userId := replyVote.UserId
-	if userState, ok := pbftImpl.ConsensusState.UserStates[userId]; ok {
-		if userState.Step != pbftPb.Step_REPLY {
-			pbftImpl.Logger.Errorf("[%s] add prepareVote at incorrect step", pbftImpl.LocalPeerId)
-		}
-	} else {
-		pbftImpl.Logger.Errorf("cannot retrieve user state")
-		return
-	}
-
-	err := rvs.AddVote(replyVote)
-	if err != nil {
-		return
+// Quorum 返回和达到 1/3 的判断一致的投票者, 按照 id 排序, 还没有达到 1/3 的时候返回空
+func (rvs *ReplyVoteSet) Quorum() []string {
+	if !rvs.Maj13 {
+		return nil
 	}
-
-	if rvs.Maj13 {
-		pbftImpl.Logger.Infof("[%s] up to 1/3 consistent reply message", replyVote.UserId)
-		state.EnterCompleteStage(pbftImpl, replyVote)
-
+	if rvs.Judgement {
+		return voters(rvs.LegalUserVotes)
 	}
+	return voters(rvs.IllegalUserVotes)
 }
 
 // NewReplyVoteSet 创建新的投票集给 reply
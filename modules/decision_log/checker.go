@@ -0,0 +1,327 @@
+package decision_log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 违反的类型
+const (
+	ViolationConflict     = "conflicting_decision" // 同一个请求的决定不一致
+	ViolationMissingReply = "missing_reply"        // 接入节点开始的一轮认证没有返回结果
+	ViolationNoQuorum     = "no_quorum"            // 决定或者结果没有足够的一致投票支持
+	ViolationLivenessGap  = "liveness_gap"         // 有请求等待的时候, 长时间没有任何节点做出决定
+	ViolationSequenceGap  = "sequence_gap"         // 节点的日志之中缺少记录
+)
+
+// Options 检查的参数
+type Options struct {
+	// LivenessGap 有请求等待的时候, 两次决定之间允许的最长间隔, 为 0 的时候不检查活性
+	LivenessGap time.Duration
+}
+
+// Violation 检查发现的一个问题
+type Violation struct {
+	Kind      string    `json:"kind"`
+	Time      time.Time `json:"time"`
+	ChainId   string    `json:"chain_id"`
+	UserId    string    `json:"user_id,omitempty"`
+	RequestId string    `json:"request_id,omitempty"`
+	Nodes     []string  `json:"nodes,omitempty"`
+	Detail    string    `json:"detail"`
+}
+
+// Report 检查的结果
+type Report struct {
+	Nodes      []string    `json:"nodes"`
+	Records    int         `json:"records"`
+	Requests   int         `json:"requests"`  // 接入节点开始的认证
+	Decisions  int         `json:"decisions"` // commit 记录
+	Replies    int         `json:"replies"`   // reply 记录
+	Violations []Violation `json:"violations"`
+}
+
+// Passed 没有发现任何问题的时候返回 true
+func (report *Report) Passed() bool {
+	return len(report.Violations) == 0
+}
+
+// requestKey 一轮认证的标识, 请求 id 只在一条链之中唯一
+type requestKey struct {
+	chainId   string
+	requestId string
+}
+
+// requestRecords 一轮认证在所有节点上的记录
+type requestRecords struct {
+	request *Record  // 接入节点开始这一轮的记录
+	commits []Record // 所有节点做出的决定
+	replies []Record // 接入节点返回的结果
+	ends    []Record // 接入节点超时或者调用者离开的记录
+}
+
+// first 返回这一轮最早的记录
+func (rr *requestRecords) first() Record {
+	all := append(append(append([]Record{}, rr.commits...), rr.replies...), rr.ends...)
+	if rr.request != nil {
+		all = append(all, *rr.request)
+	}
+	first := all[0]
+	for _, record := range all[1:] {
+		if record.Time.Before(first.Time) {
+			first = record
+		}
+	}
+	return first
+}
+
+// Check 合并所有节点的决策日志并进行检查, records 之中每个节点的记录需要保持写入的顺序.
+// 活性的检查比较不同节点记录的时间, 需要节点之间的时钟同步
+func Check(records []Record, opts Options) *Report {
+	report := &Report{Records: len(records), Violations: make([]Violation, 0)}
+	nodes := make(map[string]bool)
+	rounds := make(map[requestKey]*requestRecords)
+	order := make([]requestKey, 0)
+	lastSequence := make(map[string]uint64)
+	for i := range records {
+		record := records[i]
+		nodes[record.Node] = true
+		report.checkSequence(record, lastSequence)
+		key := requestKey{chainId: record.ChainId, requestId: record.RequestId}
+		round, ok := rounds[key]
+		if !ok {
+			round = &requestRecords{}
+			rounds[key] = round
+			order = append(order, key)
+		}
+		switch record.Phase {
+		case PhaseRequest:
+			report.Requests++
+			round.request = &record
+		case PhaseCommit:
+			report.Decisions++
+			round.commits = append(round.commits, record)
+			report.checkQuorum(record, record.Validators*2/3+1)
+		case PhaseReply:
+			report.Replies++
+			round.replies = append(round.replies, record)
+			report.checkQuorum(record, record.Validators/3+1)
+		case PhaseTimeout, PhaseCancel:
+			round.ends = append(round.ends, record)
+		}
+	}
+	for node := range nodes {
+		report.Nodes = append(report.Nodes, node)
+	}
+	sort.Strings(report.Nodes)
+
+	for _, key := range order {
+		round := rounds[key]
+		report.checkConflict(round)
+		report.checkReply(round, nodes)
+	}
+	if opts.LivenessGap > 0 {
+		report.checkLiveness(records, rounds, opts.LivenessGap)
+	}
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		return report.Violations[i].Time.Before(report.Violations[j].Time)
+	})
+	return report
+}
+
+// add 记录一个问题
+func (report *Report) add(kind string, record Record, nodes []string, format string, args ...interface{}) {
+	report.Violations = append(report.Violations, Violation{
+		Kind:      kind,
+		Time:      record.Time,
+		ChainId:   record.ChainId,
+		UserId:    record.UserId,
+		RequestId: record.RequestId,
+		Nodes:     nodes,
+		Detail:    fmt.Sprintf(format, args...),
+	})
+}
+
+// checkSequence 每个节点的序号需要连续, 节点重启之后从 1 重新开始
+func (report *Report) checkSequence(record Record, lastSequence map[string]uint64) {
+	key := record.Node + "/" + record.ChainId
+	last := lastSequence[key]
+	lastSequence[key] = record.Sequence
+	if record.Sequence == last+1 || record.Sequence == 1 {
+		return
+	}
+	report.add(ViolationSequenceGap, record, []string{record.Node},
+		"sequence %d follows %d, records of %s are missing or reordered", record.Sequence, last, record.Node)
+}
+
+// checkQuorum 决定需要由足够多的不同验证者的一致投票支持
+func (report *Report) checkQuorum(record Record, required int) {
+	voters := make(map[string]bool, len(record.Quorum))
+	for _, voter := range record.Quorum {
+		voters[voter] = true
+	}
+	if record.Validators > 0 && len(voters) >= required {
+		return
+	}
+	report.add(ViolationNoQuorum, record, []string{record.Node},
+		"%s %s by %s backed by %d distinct voters %v, %d of %d validators required",
+		record.Phase, judgement(record.Judgement), record.Node, len(voters), record.Quorum, required, record.Validators)
+}
+
+// checkConflict 同一个请求所有的决定以及返回的结果需要一致
+func (report *Report) checkConflict(round *requestRecords) {
+	decided := append(append([]Record{}, round.commits...), round.replies...)
+	if len(decided) == 0 {
+		return
+	}
+	conflicting := false
+	for _, record := range decided[1:] {
+		if record.Judgement != decided[0].Judgement {
+			conflicting = true
+		}
+	}
+	if !conflicting {
+		return
+	}
+	nodes := make([]string, 0, len(decided))
+	parts := make([]string, 0, len(decided))
+	for _, record := range decided {
+		nodes = append(nodes, record.Node)
+		parts = append(parts, fmt.Sprintf("%s %s=%s", record.Phase, record.Node, judgement(record.Judgement)))
+	}
+	report.add(ViolationConflict, round.first(), nodes, "%s", strings.Join(parts, ", "))
+}
+
+// checkReply 接入节点开始的每一轮都需要返回结果, 调用者主动离开的除外.
+// 接入节点的日志不在检查范围之内的时候无法判断, 不进行检查
+func (report *Report) checkReply(round *requestRecords, nodes map[string]bool) {
+	if len(round.replies) > 0 {
+		return
+	}
+	for _, end := range round.ends {
+		if end.Phase == PhaseCancel {
+			return
+		}
+	}
+	if round.request != nil {
+		request := *round.request
+		if len(round.ends) > 0 {
+			report.add(ViolationMissingReply, request, []string{request.Node}, "timed out after %s with %d decisions",
+				round.ends[0].Time.Sub(request.Time), len(round.commits))
+		} else {
+			report.add(ViolationMissingReply, request, []string{request.Node},
+				"no reply recorded by %s, %d decisions", request.Node, len(round.commits))
+		}
+		return
+	}
+	// 只有决定而没有开始的记录, 接入节点的日志缺少了这一轮
+	if len(round.commits) > 0 && nodes[round.commits[0].AccessId] {
+		commit := round.commits[0]
+		report.add(ViolationMissingReply, commit, []string{commit.AccessId},
+			"%d decisions but access node %s recorded neither the request nor a reply", len(round.commits), commit.AccessId)
+	}
+}
+
+// checkLiveness 在有请求等待的时间之内, 查找两次决定之间超过 gap 的间隔.
+// 请求从接入节点开始这一轮一直等待到返回结果, 超时或者调用者离开, 没有结束的请求一直等待到日志的最后
+func (report *Report) checkLiveness(records []Record, rounds map[requestKey]*requestRecords, gap time.Duration) {
+	type interval struct {
+		start, end time.Time
+		requestIds []string
+	}
+	logEnd := make(map[string]time.Time)
+	progress := make(map[string][]time.Time)
+	for _, record := range records {
+		if record.Time.After(logEnd[record.ChainId]) {
+			logEnd[record.ChainId] = record.Time
+		}
+		if record.Phase == PhaseCommit || record.Phase == PhaseReply {
+			progress[record.ChainId] = append(progress[record.ChainId], record.Time)
+		}
+	}
+	pending := make(map[string][]interval)
+	for key, round := range rounds {
+		if round.request == nil {
+			continue
+		}
+		end := logEnd[key.chainId]
+		for _, record := range append(append([]Record{}, round.replies...), round.ends...) {
+			if record.Node == round.request.Node && record.Time.Before(end) {
+				end = record.Time
+			}
+		}
+		pending[key.chainId] = append(pending[key.chainId],
+			interval{start: round.request.Time, end: end, requestIds: []string{key.requestId}})
+	}
+
+	chainIds := make([]string, 0, len(pending))
+	for chainId := range pending {
+		chainIds = append(chainIds, chainId)
+	}
+	sort.Strings(chainIds)
+	for _, chainId := range chainIds {
+		// 合并重叠的等待区间
+		intervals := pending[chainId]
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i].start.Before(intervals[j].start) })
+		merged := []interval{intervals[0]}
+		for _, next := range intervals[1:] {
+			last := &merged[len(merged)-1]
+			if next.start.After(last.end) {
+				merged = append(merged, next)
+				continue
+			}
+			if next.end.After(last.end) {
+				last.end = next.end
+			}
+			last.requestIds = append(last.requestIds, next.requestIds...)
+		}
+		times := progress[chainId]
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		for _, waiting := range merged {
+			from := waiting.start
+			// 区间结束的时刻作为最后一个点, 这样等待到最后仍然没有决定的区间也会被检查
+			points := append(timesBetween(times, waiting.start, waiting.end), waiting.end)
+			for _, point := range points {
+				if point.Sub(from) > gap {
+					report.Violations = append(report.Violations, Violation{
+						Kind:    ViolationLivenessGap,
+						Time:    from,
+						ChainId: chainId,
+						Detail: fmt.Sprintf("no decision for %s while requests were pending (%d requests in this busy period, e.g. %s)",
+							point.Sub(from), len(waiting.requestIds), strings.Join(limit(waiting.requestIds), ", ")),
+					})
+				}
+				from = point
+			}
+		}
+	}
+}
+
+// timesBetween 返回有序的时间之中位于 (start, end) 之内的部分
+func timesBetween(times []time.Time, start, end time.Time) []time.Time {
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(start) })
+	between := make([]time.Time, 0)
+	for ; i < len(times) && times[i].Before(end); i++ {
+		between = append(between, times[i])
+	}
+	return between
+}
+
+// judgement 判断的简短描述
+func judgement(legal bool) string {
+	if legal {
+		return "legal"
+	}
+	return "illegal"
+}
+
+// limit 最多保留前几项, 避免报告过长
+func limit(items []string) []string {
+	const maxItems = 3
+	if len(items) > maxItems {
+		return items[:maxItems]
+	}
+	return items
+}
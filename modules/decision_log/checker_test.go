@@ -0,0 +1,156 @@
+package decision_log
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"zhanghefan123/security/modules/clock"
+)
+
+// history 按照时间依次写入多个节点的记录
+type history struct {
+	clock     *clock.Virtual
+	sink      *MemorySink
+	recorders map[string]*Recorder
+}
+
+func newHistory() *history {
+	return &history{
+		clock:     clock.NewVirtual(time.Unix(0, 0)),
+		sink:      NewMemorySink(),
+		recorders: make(map[string]*Recorder),
+	}
+}
+
+// at 在 offset 时刻由 node 写入记录
+func (h *history) at(offset time.Duration, node string, record Record) {
+	h.clock.AdvanceTo(time.Unix(0, 0).Add(offset))
+	recorder, ok := h.recorders[node]
+	if !ok {
+		recorder = NewRecorder(node, "chain1", h.clock, h.sink)
+		h.recorders[node] = recorder
+	}
+	record.Validators = 4
+	recorder.Record(record)
+}
+
+// round 一轮正常结束的认证: node1 接入, 四个节点都做出了决定
+func (h *history) round(offset time.Duration, requestId string, legal bool) {
+	all := []string{"node1", "node2", "node3", "node4"}
+	h.at(offset, "node1", Record{Phase: PhaseRequest, UserId: "alice", RequestId: requestId, AccessId: "node1"})
+	for _, node := range all {
+		h.at(offset+time.Millisecond, node, Record{Phase: PhaseCommit, UserId: "alice", RequestId: requestId,
+			AccessId: "node1", Judgement: legal, Quorum: all[:3]})
+	}
+	h.at(offset+2*time.Millisecond, "node1", Record{Phase: PhaseReply, UserId: "alice", RequestId: requestId,
+		AccessId: "node1", Judgement: legal, Quorum: all[:2]})
+}
+
+// kinds 返回所有问题的类型
+func kinds(report *Report) []string {
+	result := make([]string, 0, len(report.Violations))
+	for _, violation := range report.Violations {
+		result = append(result, violation.Kind)
+	}
+	return result
+}
+
+func TestCheckCleanHistory(t *testing.T) {
+	h := newHistory()
+	h.round(0, "r1", true)
+	h.round(time.Second, "r2", true)
+	report := Check(h.sink.Records(), Options{LivenessGap: 100 * time.Millisecond})
+	require.True(t, report.Passed(), kinds(report))
+	require.Equal(t, []string{"node1", "node2", "node3", "node4"}, report.Nodes)
+	require.Equal(t, 2, report.Requests)
+	require.Equal(t, 8, report.Decisions)
+	require.Equal(t, 2, report.Replies)
+}
+
+func TestCheckViolations(t *testing.T) {
+	h := newHistory()
+	h.round(0, "r1", true)
+	// node4 对 r2 做出了不同的决定
+	h.round(time.Second, "r2", true)
+	h.at(time.Second+time.Millisecond, "node4", Record{Phase: PhaseCommit, UserId: "alice", RequestId: "r2",
+		AccessId: "node1", Judgement: false, Quorum: []string{"node4", "node4", "node4"}})
+	// r3 只有开始, 之后 5 秒没有任何决定
+	h.at(2*time.Second, "node1", Record{Phase: PhaseRequest, UserId: "bob", RequestId: "r3", AccessId: "node1"})
+	h.at(7*time.Second, "node1", Record{Phase: PhaseTimeout, UserId: "bob", RequestId: "r3", AccessId: "node1"})
+	// 调用者离开的请求不需要返回结果
+	h.at(8*time.Second, "node1", Record{Phase: PhaseRequest, UserId: "carol", RequestId: "r4", AccessId: "node1"})
+	h.at(8*time.Second, "node1", Record{Phase: PhaseCancel, UserId: "carol", RequestId: "r4", AccessId: "node1"})
+	records := h.sink.Records()
+	// node2 的日志丢失了一条记录
+	for i, record := range records {
+		if record.Node == "node2" && record.RequestId == "r1" {
+			records = append(records[:i], records[i+1:]...)
+			break
+		}
+	}
+
+	report := Check(records, Options{LivenessGap: time.Second})
+	require.Equal(t, []string{ViolationConflict, ViolationSequenceGap, ViolationNoQuorum, ViolationMissingReply,
+		ViolationLivenessGap}, kinds(report))
+	require.Contains(t, report.Violations[0].Detail, "commit node4=illegal")
+	require.Equal(t, "r2", report.Violations[0].RequestId)
+	require.Equal(t, []string{"node2"}, report.Violations[1].Nodes)
+	require.Contains(t, report.Violations[2].Detail, "1 distinct voters")
+	require.Contains(t, report.Violations[3].Detail, "timed out after 5s")
+	require.Contains(t, report.Violations[4].Detail, "no decision for 5s")
+
+	// 不检查活性的时候没有 liveness_gap
+	require.NotContains(t, kinds(Check(records, Options{})), ViolationLivenessGap)
+
+	var buffer bytes.Buffer
+	require.NoError(t, report.WriteText(&buffer))
+	require.Contains(t, buffer.String(), "FAILED")
+	require.Contains(t, buffer.String(), "conflicting_decision: 1")
+}
+
+func TestCheckReplyWithoutQuorum(t *testing.T) {
+	h := newHistory()
+	h.at(0, "node1", Record{Phase: PhaseRequest, UserId: "alice", RequestId: "r1", AccessId: "node1"})
+	h.at(time.Millisecond, "node1", Record{Phase: PhaseReply, UserId: "alice", RequestId: "r1", AccessId: "node1",
+		Judgement: true, Quorum: []string{"node4"}})
+	report := Check(h.sink.Records(), Options{})
+	require.Equal(t, []string{ViolationNoQuorum}, kinds(report))
+}
+
+func TestFileSinkRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions", "chain1.jsonl")
+	sink, err := OpenFile(path)
+	require.NoError(t, err)
+	recorder := NewRecorder("node1", "chain1", nil, sink)
+	recorder.Record(Record{Phase: PhaseRequest, UserId: "alice", RequestId: "r1"})
+	recorder.Record(Record{Phase: PhaseReply, UserId: "alice", RequestId: "r1", Judgement: true,
+		Quorum: []string{"node1", "node2"}, Validators: 4})
+	require.NoError(t, recorder.Err())
+	require.NoError(t, sink.Close())
+
+	records, err := ReadFiles(path)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.Equal(t, uint64(2), records[1].Sequence)
+	require.Equal(t, "node1", records[1].Node)
+	require.Equal(t, []string{"node1", "node2"}, records[1].Quorum)
+
+	// 没有配置决策日志的时候记录不会产生任何效果
+	var empty *Recorder
+	empty.Record(Record{Phase: PhaseRequest})
+	require.Nil(t, NewRecorder("node1", "chain1", nil, nil))
+}
+
+func TestReadTruncatedLastLine(t *testing.T) {
+	content := `{"node":"node1","sequence":1,"phase":"request","request_id":"r1"}` + "\n" + `{"node":"node1","seq`
+	records, err := Read(strings.NewReader(content))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	_, err = Read(strings.NewReader("not json\n" + content))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 1")
+}
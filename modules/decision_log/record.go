@@ -0,0 +1,205 @@
+package decision_log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"zhanghefan123/security/modules/clock"
+)
+
+// 记录的阶段
+const (
+	PhaseRequest = "request" // 接入节点开始一轮认证
+	PhaseCommit  = "commit"  // 节点收到了超过 2/3 一致的 commit 投票, 做出了决定
+	PhaseReply   = "reply"   // 接入节点收到了超过 1/3 一致的 reply 投票, 将结果返回给调用者
+	PhaseTimeout = "timeout" // 接入节点等待结果超时
+	PhaseCancel  = "cancel"  // 调用者在结果返回之前离开
+)
+
+// Record 决策日志之中的一条记录, 每个节点按照发生的顺序写入自己的日志
+type Record struct {
+	Time       time.Time `json:"time"`
+	Node       string    `json:"node"`
+	ChainId    string    `json:"chain_id"`
+	Sequence   uint64    `json:"sequence"` // 节点写入的第几条记录, 从 1 开始, 重启之后重新计数
+	View       uint64    `json:"view"`
+	Primary    string    `json:"primary,omitempty"`
+	Phase      string    `json:"phase"`
+	UserId     string    `json:"user_id"`
+	RequestId  string    `json:"request_id"`
+	AccessId   string    `json:"access_id,omitempty"`
+	Judgement  bool      `json:"judgement"`            // commit 以及 reply 阶段的判断, true 表示合法用户
+	Quorum     []string  `json:"quorum,omitempty"`     // 给出相同判断的投票者, 即这个决定的法定人数证书
+	Validators int       `json:"validators,omitempty"` // 做出决定的时候验证者的数量
+}
+
+// Sink 决策记录的输出位置, 需要支持并发写入
+type Sink interface {
+	Write(record *Record) error
+}
+
+// Recorder 一个节点的决策记录器, 填写节点, 链, 时间以及序号之后写入 Sink.
+// 空的 Recorder 不记录任何内容, 因此没有配置决策日志的节点可以直接调用
+type Recorder struct {
+	mutex    sync.Mutex
+	node     string
+	chainId  string
+	clock    clock.Clock
+	sink     Sink
+	sequence uint64
+	err      error // 第一次写入失败的错误
+}
+
+// NewRecorder 创建节点的决策记录器, sink 为空的时候返回空的记录器
+func NewRecorder(node, chainId string, clk clock.Clock, sink Sink) *Recorder {
+	if sink == nil {
+		return nil
+	}
+	if clk == nil {
+		clk = clock.Real
+	}
+	return &Recorder{node: node, chainId: chainId, clock: clk, sink: sink}
+}
+
+// Record 写入一条记录, 写入失败的时候只保留第一次的错误, 不影响共识
+func (r *Recorder) Record(record Record) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sequence++
+	record.Time = r.clock.Now()
+	record.Node = r.node
+	record.ChainId = r.chainId
+	record.Sequence = r.sequence
+	if err := r.sink.Write(&record); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+// Err 返回第一次写入失败的错误
+func (r *Recorder) Err() error {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// FileSink 将记录以每行一个 json 的格式追加到文件之中
+type FileSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// OpenFile 打开决策日志文件, 目录不存在的时候会进行创建, 重启之后在原来的内容之后追加
+func OpenFile(path string) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+// Write 写入一条记录, 每条记录只进行一次写入
+func (s *FileSink) Write(record *Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close 关闭文件
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.file.Close()
+}
+
+// MemorySink 将记录保存在内存之中, 进程内的集群的所有节点可以共用
+type MemorySink struct {
+	mutex   sync.Mutex
+	records []Record
+}
+
+// NewMemorySink 创建内存之中的决策日志
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Write 保存一条记录
+func (s *MemorySink) Write(record *Record) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records = append(s.records, *record)
+	return nil
+}
+
+// Records 按照写入的顺序返回所有记录的拷贝
+func (s *MemorySink) Records() []Record {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Record{}, s.records...)
+}
+
+// Read 读取每行一个 json 的决策日志. 节点崩溃的时候最后一行可能只写入了一部分,
+// 没有以换行结尾并且无法解析的最后一行会被忽略
+func Read(r io.Reader) ([]Record, error) {
+	reader := bufio.NewReader(r)
+	records := make([]Record, 0)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		complete := err == nil
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			record := Record{}
+			if unmarshalErr := json.Unmarshal(trimmed, &record); unmarshalErr != nil {
+				if !complete {
+					break
+				}
+				return nil, fmt.Errorf("line %d: %w", lineNumber, unmarshalErr)
+			}
+			records = append(records, record)
+		}
+		if !complete {
+			break
+		}
+	}
+	return records, nil
+}
+
+// ReadFiles 依次读取多个节点的决策日志
+func ReadFiles(paths ...string) ([]Record, error) {
+	records := make([]Record, 0)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		fileRecords, err := Read(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read decision log %s failed, %w", path, err)
+		}
+		records = append(records, fileRecords...)
+	}
+	return records, nil
+}
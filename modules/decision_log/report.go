@@ -0,0 +1,56 @@
+package decision_log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteJSON 将检查结果输出为 json
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteText 将检查结果输出为便于阅读的文本, 先按照类型汇总, 再按照时间列出每个问题
+func (report *Report) WriteText(w io.Writer) error {
+	var builder strings.Builder
+	status := "PASSED"
+	if !report.Passed() {
+		status = "FAILED"
+	}
+	fmt.Fprintf(&builder, "nodes: %d\trecords: %d\trequests: %d\tdecisions: %d\treplies: %d\t%s\n",
+		len(report.Nodes), report.Records, report.Requests, report.Decisions, report.Replies, status)
+	counts := make(map[string]int)
+	for _, violation := range report.Violations {
+		counts[violation.Kind]++
+	}
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(&builder, "  %s: %d\n", kind, counts[kind])
+	}
+	if len(report.Violations) > 0 {
+		builder.WriteString("violations:\n")
+	}
+	for _, violation := range report.Violations {
+		fmt.Fprintf(&builder, "  %s\t%s\tchain %s", violation.Time.Format(time.RFC3339Nano), violation.Kind,
+			violation.ChainId)
+		if violation.UserId != "" {
+			fmt.Fprintf(&builder, "\tuser %s", violation.UserId)
+		}
+		if violation.RequestId != "" {
+			fmt.Fprintf(&builder, "\trequest %s", violation.RequestId)
+		}
+		fmt.Fprintf(&builder, "\n    %s\n", violation.Detail)
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
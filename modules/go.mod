@@ -6,6 +6,7 @@ require (
 	chainmaker.org/chainmaker/lws v1.1.0
 	github.com/gogo/protobuf v1.3.2
 	github.com/grpc-ecosystem/go-grpc-middleware v1.2.2
+	github.com/spf13/viper v1.9.0
 	github.com/stretchr/testify v1.8.0
 	go.uber.org/zap v1.17.0
 	google.golang.org/grpc v1.40.0
@@ -0,0 +1,291 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// StateFileName 配置目录之中记录节点进程的文件, 多次执行命令的时候通过它找到已经启动的节点
+	StateFileName = "cluster.json"
+	// DefaultStopTimeout 默认的等待节点优雅退出的时间, 超过之后强制结束
+	DefaultStopTimeout = 30 * time.Second
+	// pollInterval 检查进程是否退出以及健康检查的间隔
+	pollInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrNodeNotFound   = errors.New("node not found in config directory")
+	ErrNodeRunning    = errors.New("node is already running")
+	ErrNodeNotRunning = errors.New("node is not running")
+)
+
+// processState 节点进程的记录
+type processState struct {
+	Pid     int       `json:"pid"`
+	Started time.Time `json:"started"`
+}
+
+// clusterState 配置目录之中记录的所有节点进程
+type clusterState struct {
+	Nodes map[string]processState `json:"nodes"`
+}
+
+// Status 节点进程的状态
+type Status struct {
+	Node    *Node
+	Pid     int // 没有运行的时候为 0
+	Started time.Time
+}
+
+// Running 节点是否正在运行
+func (status Status) Running() bool {
+	return status.Pid != 0
+}
+
+// Launcher 在本机上以独立进程的方式启动配置目录之中的节点, 进程的记录保存在配置目录之中,
+// 因此之后的命令可以停止或者重启之前启动的节点. 多个命令同时修改同一个配置目录是不安全的
+type Launcher struct {
+	Dir    string  // 生成的配置目录
+	Binary string  // starter 可执行文件, 节点通过 starter start -c 启动
+	Nodes  []*Node // 配置目录之中所有的节点
+	mutex  sync.Mutex
+	state  clusterState
+}
+
+// Open 读取配置目录之中的节点以及之前启动的进程
+func Open(dir, binary string) (*Launcher, error) {
+	nodes, err := Discover(dir)
+	if err != nil {
+		return nil, err
+	}
+	l := &Launcher{Dir: dir, Binary: binary, Nodes: nodes, state: clusterState{Nodes: make(map[string]processState)}}
+	content, err := ioutil.ReadFile(l.statePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &l.state); err != nil {
+		return nil, fmt.Errorf("parse %s failed, %w", l.statePath(), err)
+	}
+	if l.state.Nodes == nil {
+		l.state.Nodes = make(map[string]processState)
+	}
+	return l, nil
+}
+
+// statePath 进程记录文件的路径
+func (l *Launcher) statePath() string {
+	return filepath.Join(l.Dir, StateFileName)
+}
+
+// save 保存进程的记录, 调用者需要持有锁
+func (l *Launcher) save() error {
+	content, err := json.MarshalIndent(l.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.statePath(), content, 0644)
+}
+
+// Node 根据 id 查找节点
+func (l *Launcher) Node(id string) (*Node, error) {
+	for _, node := range l.Nodes {
+		if node.Id == id {
+			return node, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, id)
+}
+
+// Select 根据 id 查找多个节点, 没有指定的时候返回所有的节点
+func (l *Launcher) Select(ids []string) ([]*Node, error) {
+	if len(ids) == 0 {
+		return l.Nodes, nil
+	}
+	nodes := make([]*Node, 0, len(ids))
+	for _, id := range ids {
+		node, err := l.Node(id)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Status 返回节点进程的状态, 已经退出的进程会从记录之中移除
+func (l *Launcher) Status(node *Node) Status {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.status(node)
+}
+
+// status 返回节点进程的状态, 调用者需要持有锁
+func (l *Launcher) status(node *Node) Status {
+	process, ok := l.state.Nodes[node.Id]
+	if !ok {
+		return Status{Node: node}
+	}
+	if !alive(process.Pid, node.ConfigFile) {
+		delete(l.state.Nodes, node.Id)
+		_ = l.save()
+		return Status{Node: node}
+	}
+	return Status{Node: node, Pid: process.Pid, Started: process.Started}
+}
+
+// Start 在节点目录之中启动节点进程, 标准输出以及标准错误追加到 ConsoleFile.
+// 节点进程在新的会话之中运行, 命令退出之后节点继续运行
+func (l *Launcher) Start(node *Node) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if status := l.status(node); status.Running() {
+		return status.Pid, fmt.Errorf("%w: %s pid %d", ErrNodeRunning, node.Id, status.Pid)
+	}
+	console, err := os.OpenFile(node.ConsoleFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer console.Close()
+	cmd := exec.Command(l.Binary, "start", "-c", node.ConfigFile)
+	cmd.Dir = node.Dir
+	cmd.Stdout = console
+	cmd.Stderr = console
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err = cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start %s failed, %w", node.Id, err)
+	}
+	// 进程退出的时候进行回收, 避免长时间运行的调用者 (例如测试) 把僵尸进程当成仍在运行
+	go func() { _ = cmd.Wait() }()
+	l.state.Nodes[node.Id] = processState{Pid: cmd.Process.Pid, Started: time.Now()}
+	return cmd.Process.Pid, l.save()
+}
+
+// Stop 发送 SIGTERM 让节点优雅退出, 超过 timeout 之后发送 SIGKILL
+func (l *Launcher) Stop(node *Node, timeout time.Duration) error {
+	if err := l.Signal(node, syscall.SIGTERM); err != nil {
+		return err
+	}
+	if l.wait(node, timeout) {
+		return nil
+	}
+	return l.Kill(node)
+}
+
+// Kill 发送 SIGKILL 立即结束节点进程并等待进程退出, 用来模拟节点崩溃
+func (l *Launcher) Kill(node *Node) error {
+	if err := l.Signal(node, syscall.SIGKILL); err != nil {
+		return err
+	}
+	if !l.wait(node, DefaultStopTimeout) {
+		return fmt.Errorf("%s did not exit after SIGKILL", node.Id)
+	}
+	return nil
+}
+
+// Signal 向节点进程发送信号, 例如 SIGSTOP 以及 SIGCONT 可以模拟节点暂停
+func (l *Launcher) Signal(node *Node, signal syscall.Signal) error {
+	status := l.Status(node)
+	if !status.Running() {
+		return fmt.Errorf("%w: %s", ErrNodeNotRunning, node.Id)
+	}
+	return syscall.Kill(status.Pid, signal)
+}
+
+// Restart 停止正在运行的节点之后重新启动
+func (l *Launcher) Restart(node *Node, timeout time.Duration) (int, error) {
+	if l.Status(node).Running() {
+		if err := l.Stop(node, timeout); err != nil {
+			return 0, err
+		}
+	}
+	return l.Start(node)
+}
+
+// wait 等待节点进程退出, 超时的时候返回 false
+func (l *Launcher) wait(node *Node, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if !l.Status(node).Running() {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// HealthCheck 检查节点是否可以提供服务, 返回空表示健康
+type HealthCheck func(ctx context.Context, node *Node) error
+
+// WaitHealthy 等待所有节点的健康检查通过, ctx 结束的时候返回最后一次检查的错误.
+// 等待期间进程退出的节点直接返回错误
+func (l *Launcher) WaitHealthy(ctx context.Context, nodes []*Node, check HealthCheck) error {
+	pending := append([]*Node{}, nodes...)
+	lastErrs := make(map[string]error)
+	for {
+		remaining := pending[:0]
+		for _, node := range pending {
+			if !l.Status(node).Running() {
+				return fmt.Errorf("%s exited, see %s", node.Id, node.ConsoleFile)
+			}
+			callCtx, cancel := context.WithTimeout(ctx, time.Second)
+			err := check(callCtx, node)
+			cancel()
+			if err != nil {
+				lastErrs[node.Id] = err
+				remaining = append(remaining, node)
+			}
+		}
+		pending = remaining
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			var buffer bytes.Buffer
+			for _, node := range pending {
+				fmt.Fprintf(&buffer, "; %s: %v", node.Id, lastErrs[node.Id])
+			}
+			return fmt.Errorf("%d nodes not healthy%s", len(pending), buffer.String())
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// alive 判断进程是否仍在运行. 在有 /proc 的系统上同时检查进程的命令行包含节点的配置文件,
+// 避免进程 id 被其他进程重新使用之后误判, 并且不把已经退出但是没有被回收的进程当成仍在运行
+func alive(pid int, configFile string) bool {
+	if pid <= 0 {
+		return false
+	}
+	if err := syscall.Kill(pid, 0); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+	if stat, err := ioutil.ReadFile(filepath.Join(procDir, "stat")); err == nil {
+		// 第三个字段为进程状态, 命令名称可能包含空格, 因此从最后一个右括号之后开始解析
+		if i := bytes.LastIndexByte(stat, ')'); i >= 0 && i+2 < len(stat) && stat[i+2] == 'Z' {
+			return false
+		}
+	}
+	if cmdline, err := ioutil.ReadFile(filepath.Join(procDir, "cmdline")); err == nil {
+		return bytes.Contains(cmdline, []byte(configFile))
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package launcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNode 模拟 starter start, 输出启动参数之后一直运行, 收到 SIGTERM 的时候退出
+const fakeNode = `#!/bin/sh
+trap 'echo stopping; exit 0' TERM
+echo "started $@"
+while true; do sleep 0.05; done
+`
+
+// writeCluster 生成包含 count 个节点的配置目录, 以及一个没有节点配置文件的 ca 目录
+func writeCluster(t *testing.T, count int) string {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "ca"), 0755))
+	for i := count; i >= 1; i-- {
+		nodeDir := filepath.Join(dir, fmt.Sprintf("node%d", i))
+		require.NoError(t, os.MkdirAll(filepath.Join(nodeDir, "config"), 0755))
+		config := fmt.Sprintf("log:\n  config_file: config/log.yml\nrpc:\n  host: 0.0.0.0\n  port: %d\n"+
+			"  tls:\n    mode: disable\n  admin:\n    enabled: %t\n    token: token%d\n", 12300+i, i%2 == 1, i)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(nodeDir, ConfigFileName), []byte(config), 0644))
+		logConfig := "log:\n  system:\n    file_path: log/system.log\n"
+		require.NoError(t, ioutil.WriteFile(filepath.Join(nodeDir, "config", "log.yml"), []byte(logConfig), 0644))
+	}
+	return dir
+}
+
+// writeFakeBinary 写入模拟节点的脚本
+func writeFakeBinary(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "starter")
+	require.NoError(t, ioutil.WriteFile(path, []byte(fakeNode), 0755))
+	return path
+}
+
+func TestDiscover(t *testing.T) {
+	dir := writeCluster(t, 10)
+	nodes, err := Discover(dir)
+	require.NoError(t, err)
+	require.Len(t, nodes, 10)
+	require.Equal(t, "node1", nodes[0].Id)
+	require.Equal(t, "node2", nodes[1].Id)
+	require.Equal(t, "node10", nodes[9].Id)
+	require.Equal(t, "127.0.0.1:12301", nodes[0].RPCAddr)
+	require.Equal(t, "token1", nodes[0].AdminToken)
+	require.Empty(t, nodes[1].AdminToken)
+	require.False(t, nodes[0].TLS)
+	require.Equal(t, filepath.Join(nodes[0].Dir, "log", "system.log"), nodes[0].LogFile)
+
+	_, err = Discover(t.TempDir())
+	require.Error(t, err)
+}
+
+func TestLauncherLifecycle(t *testing.T) {
+	dir := writeCluster(t, 2)
+	binary := writeFakeBinary(t)
+	l, err := Open(dir, binary)
+	require.NoError(t, err)
+	node, err := l.Node("node1")
+	require.NoError(t, err)
+	_, err = l.Node("node9")
+	require.True(t, errors.Is(err, ErrNodeNotFound))
+	defer func() {
+		for _, node := range l.Nodes {
+			_ = l.Kill(node)
+		}
+	}()
+
+	pid, err := l.Start(node)
+	require.NoError(t, err)
+	require.Equal(t, pid, l.Status(node).Pid)
+	_, err = l.Start(node)
+	require.True(t, errors.Is(err, ErrNodeRunning))
+	require.False(t, l.Status(l.Nodes[1]).Running())
+
+	// 健康检查在节点输出启动信息之后通过
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, l.WaitHealthy(ctx, []*Node{node}, func(ctx context.Context, node *Node) error {
+		content, err := ioutil.ReadFile(node.ConsoleFile)
+		if err != nil || !bytes.Contains(content, []byte("started start -c "+node.ConfigFile)) {
+			return errors.New("not started")
+		}
+		return nil
+	}))
+	// 没有运行的节点直接失败
+	require.Error(t, l.WaitHealthy(ctx, l.Nodes[1:], func(context.Context, *Node) error { return nil }))
+
+	// 之后执行的命令通过记录文件找到正在运行的节点
+	reopened, err := Open(dir, binary)
+	require.NoError(t, err)
+	require.Equal(t, pid, reopened.Status(reopened.Nodes[0]).Pid)
+
+	require.NoError(t, reopened.Stop(reopened.Nodes[0], 5*time.Second))
+	require.False(t, l.Status(node).Running())
+	content, err := ioutil.ReadFile(node.ConsoleFile)
+	require.NoError(t, err)
+	require.Contains(t, string(content), "stopping")
+	require.True(t, errors.Is(l.Stop(node, time.Second), ErrNodeNotRunning))
+
+	restarted, err := l.Restart(node, time.Second)
+	require.NoError(t, err)
+	require.NotEqual(t, pid, restarted)
+	require.NoError(t, l.Kill(node))
+	require.False(t, l.Status(node).Running())
+}
+
+// syncBuffer 可以被多个协程同时读写的缓冲区
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func TestFollowLogs(t *testing.T) {
+	dir := t.TempDir()
+	nodes := []*Node{
+		{Id: "node1", LogFile: filepath.Join(dir, "node1.log"), ConsoleFile: filepath.Join(dir, "node1.console")},
+		{Id: "node10", ConsoleFile: filepath.Join(dir, "node10.console")},
+	}
+	require.NoError(t, ioutil.WriteFile(nodes[0].LogFile, []byte("a\nb\nc\npartial"), 0644))
+	require.NoError(t, ioutil.WriteFile(nodes[1].ConsoleFile, []byte("x\n"), 0644))
+
+	var buffer bytes.Buffer
+	require.NoError(t, FollowLogs(context.Background(), &buffer, nodes, LogOptions{Lines: 2}))
+	require.Equal(t, "node1  | b\nnode1  | c\nnode10 | x\n", buffer.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	output := &syncBuffer{}
+	done := make(chan error, 1)
+	go func() { done <- FollowLogs(ctx, output, nodes[:1], LogOptions{Lines: 1, Follow: true}) }()
+	waitFor := func(expected string) {
+		require.Eventually(t, func() bool { return strings.Contains(output.String(), expected) },
+			5*time.Second, 10*time.Millisecond)
+	}
+	waitFor("node1 | c\n")
+
+	// 写完没有结束的行
+	file, err := os.OpenFile(nodes[0].LogFile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(" line\nd\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	waitFor("node1 | partial line\nnode1 | d\n")
+
+	// 日志轮转之后读取新的文件
+	require.NoError(t, os.Rename(nodes[0].LogFile, nodes[0].LogFile+".1"))
+	require.NoError(t, ioutil.WriteFile(nodes[0].LogFile, []byte("rotated\n"), 0644))
+	waitFor("node1 | rotated\n")
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, 1, strings.Count(output.String(), "node1 | c\n"))
+}
@@ -0,0 +1,188 @@
+package launcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// followInterval 跟踪日志的时候检查文件变化的间隔
+const followInterval = 200 * time.Millisecond
+
+// LogOptions 输出节点日志的选项
+type LogOptions struct {
+	Lines   int  // 开始的时候输出每个节点最后的行数, 小于 0 的时候输出全部内容
+	Follow  bool // 是否持续输出新写入的内容, 直到 ctx 结束
+	Console bool // 输出进程的标准输出以及标准错误而不是系统日志
+}
+
+// prefixWriter 为每一行加上节点前缀, 多个节点共享同一个输出
+type prefixWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	width  int
+}
+
+// writeLine 写入一行带有节点前缀的日志
+func (w *prefixWriter) writeLine(id string, line []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, err := fmt.Fprintf(w.writer, "%-*s | %s\n", w.width, id, bytes.TrimRight(line, "\r\n"))
+	return err
+}
+
+// LogPath 返回节点需要输出的日志文件, 没有配置系统日志文件的时候使用标准输出
+func (node *Node) LogPath(console bool) string {
+	if console || node.LogFile == "" {
+		return node.ConsoleFile
+	}
+	return node.LogFile
+}
+
+// FollowLogs 将多个节点的日志汇总到 w, 每一行以节点 id 作为前缀. 日志轮转 (链接指向新文件)
+// 以及文件被截断之后从头开始读取新的内容
+func FollowLogs(ctx context.Context, w io.Writer, nodes []*Node, options LogOptions) error {
+	writer := &prefixWriter{writer: w}
+	for _, node := range nodes {
+		if len(node.Id) > writer.width {
+			writer.width = len(node.Id)
+		}
+	}
+	if !options.Follow {
+		for _, node := range nodes {
+			if err := tail(writer, node.Id, node.LogPath(options.Console), options.Lines); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(nodes))
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node *Node) {
+			defer wg.Done()
+			if err := follow(ctx, writer, node.Id, node.LogPath(options.Console), options.Lines); err != nil {
+				errs <- fmt.Errorf("follow %s failed, %w", node.Id, err)
+			}
+		}(node)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// tail 输出文件最后的 lines 行, 文件不存在的时候不输出任何内容
+func tail(writer *prefixWriter, id, path string, lines int) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = tailFile(writer, id, file, lines)
+	return err
+}
+
+// tailFile 输出文件最后的 lines 行, 返回读取结束的位置. 没有换行符的最后一行不输出, 留给之后继续读取
+func tailFile(writer *prefixWriter, id string, file *os.File, lines int) (int64, error) {
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return 0, err
+	}
+	end := bytes.LastIndexByte(content, '\n') + 1
+	complete := bytes.SplitAfter(content[:end], []byte("\n"))
+	complete = complete[:len(complete)-1]
+	if lines >= 0 && len(complete) > lines {
+		complete = complete[len(complete)-lines:]
+	}
+	for _, line := range complete {
+		if err = writer.writeLine(id, line); err != nil {
+			return 0, err
+		}
+	}
+	return int64(end), nil
+}
+
+// follow 输出文件最后的 lines 行之后持续输出新写入的行, 直到 ctx 结束
+func follow(ctx context.Context, writer *prefixWriter, id, path string, lines int) error {
+	var file *os.File
+	var reader *bufio.Reader
+	var offset int64
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+	for {
+		if file == nil {
+			// 文件还没有创建的时候等待节点写入
+			if opened, err := os.Open(path); err == nil {
+				file = opened
+				if offset, err = tailFile(writer, id, file, lines); err != nil {
+					return err
+				}
+				if _, err = file.Seek(offset, io.SeekStart); err != nil {
+					return err
+				}
+				reader = bufio.NewReader(file)
+				// 之后重新打开的文件都是新的内容, 需要全部输出
+				lines = -1
+			}
+		}
+		if file != nil {
+			for {
+				line, err := reader.ReadBytes('\n')
+				if err == io.EOF {
+					// 保留没有写完的行, 下次从这一行的开头重新读取
+					if _, err = file.Seek(offset, io.SeekStart); err != nil {
+						return err
+					}
+					reader.Reset(file)
+					break
+				}
+				if err != nil {
+					return err
+				}
+				offset += int64(len(line))
+				if err = writer.writeLine(id, line); err != nil {
+					return err
+				}
+			}
+			if reopen(file, path, offset) {
+				file.Close()
+				file, offset = nil, 0
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(followInterval):
+		}
+	}
+}
+
+// reopen 判断是否需要重新打开文件: 路径指向了新的文件 (日志轮转) 或者文件被截断
+func reopen(file *os.File, path string, offset int64) bool {
+	current, err := file.Stat()
+	if err != nil {
+		return true
+	}
+	if current.Size() < offset {
+		return true
+	}
+	latest, err := os.Stat(path)
+	if err != nil {
+		// 轮转的过程之中链接可能暂时不存在, 继续读取旧文件
+		return false
+	}
+	return !os.SameFile(current, latest)
+}
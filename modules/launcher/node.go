@@ -0,0 +1,126 @@
+package launcher
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ConfigFileName 节点目录之中的节点配置文件
+	ConfigFileName = "chainmaker.yml"
+	// ConsoleFileName 节点进程的标准输出以及标准错误, 位于节点目录之中
+	ConsoleFileName = "console.log"
+	// nodeDirPrefix 生成的配置目录之中节点目录的前缀
+	nodeDirPrefix = "node"
+)
+
+// Node 生成的配置目录之中的一个节点
+type Node struct {
+	Id          string // 节点目录的名称, 例如 node1
+	Dir         string // 节点目录, 节点进程在这个目录之中运行
+	ConfigFile  string // 节点配置文件
+	RPCAddr     string // rpc 服务的地址, 监听 0.0.0.0 的时候使用 127.0.0.1
+	AdminToken  string // 管理服务启用的时候的管理凭证
+	TLS         bool   // rpc 服务是否启用了 tls
+	LogFile     string // 系统日志, 为空的时候只有标准输出
+	ConsoleFile string // 进程的标准输出以及标准错误
+}
+
+// Discover 查找配置目录之中所有包含节点配置文件的 node 目录, 按照编号排序
+func Discover(dir string) ([]*Node, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*Node, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), nodeDirPrefix) {
+			continue
+		}
+		nodeDir, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if _, err = os.Stat(filepath.Join(nodeDir, ConfigFileName)); err != nil {
+			continue
+		}
+		node, err := loadNode(entry.Name(), nodeDir)
+		if err != nil {
+			return nil, fmt.Errorf("load %s failed, %w", entry.Name(), err)
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no node directory with %s found in %s", ConfigFileName, dir)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodeLess(nodes[i].Id, nodes[j].Id) })
+	return nodes, nil
+}
+
+// nodeLess 按照编号比较节点, node10 排在 node9 之后
+func nodeLess(a, b string) bool {
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, nodeDirPrefix))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, nodeDirPrefix))
+	if errA != nil || errB != nil || na == nb {
+		return a < b
+	}
+	return na < nb
+}
+
+// loadNode 读取节点配置之中的 rpc 服务以及日志文件
+func loadNode(id, nodeDir string) (*Node, error) {
+	node := &Node{
+		Id:          id,
+		Dir:         nodeDir,
+		ConfigFile:  filepath.Join(nodeDir, ConfigFileName),
+		ConsoleFile: filepath.Join(nodeDir, ConsoleFileName),
+	}
+	config := viper.New()
+	config.SetConfigFile(node.ConfigFile)
+	if err := config.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	port := config.GetInt("rpc.port")
+	if port == 0 {
+		return nil, fmt.Errorf("rpc.port is not set in %s", node.ConfigFile)
+	}
+	host := config.GetString("rpc.host")
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	node.RPCAddr = net.JoinHostPort(host, strconv.Itoa(port))
+	if config.GetBool("rpc.admin.enabled") {
+		node.AdminToken = config.GetString("rpc.admin.token")
+	}
+	tlsMode := config.GetString("rpc.tls.mode")
+	node.TLS = tlsMode != "" && tlsMode != "disable"
+
+	// 日志配置之中的相对路径相对于节点目录
+	logConfigFile := config.GetString("log.config_file")
+	if logConfigFile == "" {
+		return node, nil
+	}
+	logConfig := viper.New()
+	logConfig.SetConfigFile(resolve(nodeDir, logConfigFile))
+	if err := logConfig.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	if logFile := logConfig.GetString("log.system.file_path"); logFile != "" {
+		node.LogFile = resolve(nodeDir, logFile)
+	}
+	return node, nil
+}
+
+// resolve 将相对路径转换为相对于 dir 的路径
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,275 @@
+package lifecycle
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Logger 生命周期管理器使用的日志记录器
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// entry 管理器之中的一个模块以及它的状态
+type entry struct {
+	module Module
+	state  State
+	err    error
+}
+
+// Manager 模块的生命周期管理器:
+// 按照依赖关系初始化以及启动模块, 启动失败的时候按照相反的顺序停止已经启动的模块, 停止的时候按照相反的顺序停止所有的模块
+type Manager struct {
+	// operationMutex 保证 Init, Start, Stop 不会同时执行
+	operationMutex sync.Mutex
+
+	// stateMutex 保护下面所有的字段, 健康检查在启动的过程之中也可以进行
+	stateMutex sync.Mutex
+
+	// log 日志记录器, 可以为空
+	log Logger
+
+	// entries 按照注册的顺序记录的模块
+	entries []*entry
+
+	// index 模块名称 -> 模块
+	index map[string]*entry
+}
+
+// NewManager 创建生命周期管理器
+func NewManager(log Logger) *Manager {
+	return &Manager{
+		log:     log,
+		entries: make([]*entry, 0),
+		index:   make(map[string]*entry),
+	}
+}
+
+// Register 注册模块, 依赖的模块可以在之后注册, 在初始化的时候进行检查
+func (m *Manager) Register(module Module) error {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	if _, ok := m.index[module.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrModuleExisted, module.Name)
+	}
+	e := &entry{module: module, state: StateRegistered}
+	m.entries = append(m.entries, e)
+	m.index[module.Name] = e
+	return nil
+}
+
+// order 按照依赖关系对模块进行排序, 没有依赖关系的模块保持注册的顺序
+func (m *Manager) order() ([]*entry, error) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(m.entries))
+	ordered := make([]*entry, 0, len(m.entries))
+	var visit func(e *entry) error
+	visit = func(e *entry) error {
+		switch marks[e.module.Name] {
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, e.module.Name)
+		case visited:
+			return nil
+		}
+		marks[e.module.Name] = visiting
+		for _, dependency := range e.module.DependsOn {
+			dependencyEntry, ok := m.index[dependency]
+			if !ok {
+				return fmt.Errorf("%w: %s depends on %s", ErrUnknownDependency, e.module.Name, dependency)
+			}
+			if err := visit(dependencyEntry); err != nil {
+				return err
+			}
+		}
+		marks[e.module.Name] = visited
+		ordered = append(ordered, e)
+		return nil
+	}
+	for _, e := range m.entries {
+		if err := visit(e); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// setState 修改模块的状态
+func (m *Manager) setState(e *entry, state State, err error) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	e.state = state
+	e.err = err
+}
+
+// getState 获取模块的状态
+func (m *Manager) getState(e *entry) State {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	return e.state
+}
+
+// Init 按照依赖关系初始化所有还没有初始化的模块, 遇到失败的模块立即返回
+func (m *Manager) Init() error {
+	m.operationMutex.Lock()
+	defer m.operationMutex.Unlock()
+	ordered, err := m.order()
+	if err != nil {
+		return err
+	}
+	for idx, e := range ordered {
+		if m.getState(e) != StateRegistered {
+			continue
+		}
+		if e.module.Init != nil {
+			if err = e.module.Init(); err != nil {
+				m.setState(e, StateFailed, err)
+				m.errorf("init module[%s] failed, %s", e.module.Name, err)
+				return fmt.Errorf("init module[%s] failed, %w", e.module.Name, err)
+			}
+		}
+		m.setState(e, StateInitialized, nil)
+		m.infof("INIT STEP (%d/%d) => init module[%s] success :)", idx+1, len(ordered), e.module.Name)
+	}
+	return nil
+}
+
+// Start 按照依赖关系启动所有已经初始化或者已经停止的模块,
+// 某个模块启动失败的时候按照相反的顺序停止这一次已经启动的模块
+func (m *Manager) Start() error {
+	m.operationMutex.Lock()
+	defer m.operationMutex.Unlock()
+	ordered, err := m.order()
+	if err != nil {
+		return err
+	}
+	started := make([]*entry, 0, len(ordered))
+	for idx, e := range ordered {
+		state := m.getState(e)
+		if state == StateStarted {
+			continue
+		}
+		if state != StateInitialized && state != StateStopped {
+			err = fmt.Errorf("start module[%s] failed, module is %s", e.module.Name, state)
+			m.errorf("%s", err)
+			m.rollback(started)
+			return err
+		}
+		if e.module.Start != nil {
+			if err = e.module.Start(); err != nil {
+				m.setState(e, StateFailed, err)
+				m.errorf("start module[%s] failed, %s", e.module.Name, err)
+				m.rollback(started)
+				return fmt.Errorf("start module[%s] failed, %w", e.module.Name, err)
+			}
+		}
+		m.setState(e, StateStarted, nil)
+		started = append(started, e)
+		m.infof("START STEP (%d/%d) => start module[%s] success :)", idx+1, len(ordered), e.module.Name)
+	}
+	return nil
+}
+
+// rollback 按照相反的顺序停止已经启动的模块
+func (m *Manager) rollback(started []*entry) {
+	for idx := len(started) - 1; idx >= 0; idx-- {
+		e := started[idx]
+		m.stopEntry(e)
+		m.infof("ROLLBACK => stop module[%s]", e.module.Name)
+	}
+}
+
+// stopEntry 停止一个模块, 停止失败的模块也会被标记为已经停止
+func (m *Manager) stopEntry(e *entry) error {
+	var err error
+	if e.module.Stop != nil {
+		err = e.module.Stop()
+	}
+	m.setState(e, StateStopped, err)
+	return err
+}
+
+// Stop 按照和启动相反的顺序停止所有已经启动的模块, 某个模块停止失败不影响其他模块的停止, 返回第一个错误
+func (m *Manager) Stop() error {
+	m.operationMutex.Lock()
+	defer m.operationMutex.Unlock()
+	ordered, err := m.order()
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for idx := len(ordered) - 1; idx >= 0; idx-- {
+		e := ordered[idx]
+		if m.getState(e) != StateStarted {
+			continue
+		}
+		if err = m.stopEntry(e); err != nil {
+			m.errorf("stop module[%s] failed, %s", e.module.Name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("stop module[%s] failed, %w", e.module.Name, err)
+			}
+			continue
+		}
+		m.infof("STOP STEP (%d/%d) => stop module[%s] success :)", len(ordered)-idx, len(ordered), e.module.Name)
+	}
+	return firstErr
+}
+
+// Health 按照注册的顺序返回所有模块的健康状态, 已经启动的模块会进行健康检查
+func (m *Manager) Health() []ModuleHealth {
+	m.stateMutex.Lock()
+	healths := make([]ModuleHealth, 0, len(m.entries))
+	checks := make([]Function, 0, len(m.entries))
+	for _, e := range m.entries {
+		healths = append(healths, ModuleHealth{Name: e.module.Name, State: e.state, Err: e.err})
+		checks = append(checks, e.module.Health)
+	}
+	m.stateMutex.Unlock()
+
+	// 健康检查可能比较耗时, 不持有锁
+	for idx := range healths {
+		if healths[idx].State != StateStarted {
+			continue
+		}
+		healths[idx].Healthy = true
+		if checks[idx] != nil {
+			if err := checks[idx](); err != nil {
+				healths[idx].Healthy = false
+				healths[idx].Err = err
+			}
+		}
+	}
+	return healths
+}
+
+// State 返回模块所处的阶段
+func (m *Manager) State(name string) (State, bool) {
+	m.stateMutex.Lock()
+	defer m.stateMutex.Unlock()
+	e, ok := m.index[name]
+	if !ok {
+		return StateRegistered, false
+	}
+	return e.state, true
+}
+
+// infof 输出日志
+func (m *Manager) infof(format string, args ...interface{}) {
+	if m.log != nil {
+		m.log.Infof(format, args...)
+	}
+}
+
+// errorf 输出错误日志
+func (m *Manager) errorf(format string, args ...interface{}) {
+	if m.log != nil {
+		m.log.Errorf(format, args...)
+	}
+}
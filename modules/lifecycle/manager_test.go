@@ -0,0 +1,106 @@
+package lifecycle
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recorder 记录模块生命周期函数的调用顺序
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) module(name string, dependsOn ...string) Module {
+	return Module{
+		Name:      name,
+		DependsOn: dependsOn,
+		Init:      func() error { r.calls = append(r.calls, "init "+name); return nil },
+		Start:     func() error { r.calls = append(r.calls, "start "+name); return nil },
+		Stop:      func() error { r.calls = append(r.calls, "stop "+name); return nil },
+	}
+}
+
+func TestManagerDependencyOrder(t *testing.T) {
+	r := &recorder{}
+	manager := NewManager(nil)
+	// 共识模块先注册, 但是依赖网络服务以及请求池
+	require.NoError(t, manager.Register(r.module("consensus", "net", "pool")))
+	require.NoError(t, manager.Register(r.module("pool")))
+	require.NoError(t, manager.Register(r.module("net")))
+	require.ErrorIs(t, manager.Register(r.module("net")), ErrModuleExisted)
+
+	require.NoError(t, manager.Init())
+	require.NoError(t, manager.Start())
+	require.NoError(t, manager.Stop())
+	require.Equal(t, []string{
+		"init net", "init pool", "init consensus",
+		"start net", "start pool", "start consensus",
+		"stop consensus", "stop pool", "stop net",
+	}, r.calls)
+
+	// 再次停止不会重复调用
+	r.calls = nil
+	require.NoError(t, manager.Stop())
+	require.Empty(t, r.calls)
+}
+
+func TestManagerRollbackOnStartFailure(t *testing.T) {
+	r := &recorder{}
+	manager := NewManager(nil)
+	require.NoError(t, manager.Register(r.module("pool")))
+	require.NoError(t, manager.Register(r.module("net")))
+	failed := r.module("consensus", "pool", "net")
+	startErr := errors.New("boom")
+	failed.Start = func() error { return startErr }
+	require.NoError(t, manager.Register(failed))
+
+	require.NoError(t, manager.Init())
+	r.calls = nil
+	require.ErrorIs(t, manager.Start(), startErr)
+	require.Equal(t, []string{"start pool", "start net", "stop net", "stop pool"}, r.calls)
+
+	healths := manager.Health()
+	require.Len(t, healths, 3)
+	for _, health := range healths[:2] {
+		require.Equal(t, StateStopped, health.State)
+		require.False(t, health.Healthy)
+	}
+	require.Equal(t, StateFailed, healths[2].State)
+	require.ErrorIs(t, healths[2].Err, startErr)
+}
+
+func TestManagerDependencyErrors(t *testing.T) {
+	manager := NewManager(nil)
+	require.NoError(t, manager.Register(Module{Name: "a", DependsOn: []string{"b"}}))
+	require.ErrorIs(t, manager.Init(), ErrUnknownDependency)
+	require.NoError(t, manager.Register(Module{Name: "b", DependsOn: []string{"a"}}))
+	require.ErrorIs(t, manager.Init(), ErrDependencyCycle)
+}
+
+func TestManagerHealth(t *testing.T) {
+	unhealthy := errors.New("journal broken")
+	var healthErr error
+	manager := NewManager(nil)
+	require.NoError(t, manager.Register(Module{Name: "pool", Health: func() error { return healthErr }}))
+	require.NoError(t, manager.Register(Module{Name: "net"}))
+
+	require.NoError(t, manager.Init())
+	healths := manager.Health()
+	require.Equal(t, StateInitialized, healths[0].State)
+	require.False(t, healths[0].Healthy)
+
+	require.NoError(t, manager.Start())
+	healths = manager.Health()
+	require.True(t, healths[0].Healthy)
+	require.True(t, healths[1].Healthy)
+
+	healthErr = unhealthy
+	healths = manager.Health()
+	require.False(t, healths[0].Healthy)
+	require.ErrorIs(t, healths[0].Err, unhealthy)
+	state, ok := manager.State("pool")
+	require.True(t, ok)
+	require.Equal(t, StateStarted, state)
+}
@@ -0,0 +1,59 @@
+package lifecycle
+
+import "errors"
+
+var (
+	ErrModuleExisted     = errors.New("module already registered")
+	ErrUnknownDependency = errors.New("unknown dependency")
+	ErrDependencyCycle   = errors.New("dependency cycle")
+)
+
+// Function 模块生命周期之中的一个步骤, 为空的时候直接跳过
+type Function func() error
+
+// Module 由生命周期管理器进行管理的模块
+type Module struct {
+	Name      string   // 模块的名称, 在同一个管理器之中唯一
+	DependsOn []string // 依赖的模块, 这些模块会在本模块之前初始化以及启动, 在本模块之后停止
+	Init      Function // 初始化
+	Start     Function // 启动
+	Stop      Function // 停止
+	Health    Function // 运行时的健康检查, 只在模块启动之后调用, 为空的时候启动之后总是健康的
+}
+
+// State 模块所处的阶段
+type State int
+
+const (
+	StateRegistered  State = iota // 已经注册, 还没有初始化
+	StateInitialized              // 已经初始化
+	StateStarted                  // 已经启动
+	StateStopped                  // 已经停止
+	StateFailed                   // 初始化或者启动失败
+)
+
+// String 返回阶段的名称
+func (s State) String() string {
+	switch s {
+	case StateRegistered:
+		return "registered"
+	case StateInitialized:
+		return "initialized"
+	case StateStarted:
+		return "started"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ModuleHealth 模块的健康状态
+type ModuleHealth struct {
+	Name    string // 模块的名称
+	State   State  // 模块所处的阶段
+	Healthy bool   // 模块已经启动并且健康检查通过
+	Err     error  // 最近一次失败的原因
+}
@@ -1,23 +1,66 @@
 package manager
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"zhanghefan123/security/modules/blockchain"
 	"zhanghefan123/security/protocol"
 )
 
-// ChainManager 区块链的管理器
+// ErrChainNotFound 请求的链不存在
+var ErrChainNotFound = errors.New("chain not found")
+
+// ChainManager 区块链的管理器, 多条链共享同一个网络, 每条链有自己的验证者, 共识模块以及请求池
 
 type ChainManager struct {
-	// net 网络服务
+	// net 网络服务, 所有的链共享
 	net protocol.Net
 
-	// blockchain 区块链
-	blockchain *blockchain.Blockchain
+	// blockchains 链 id -> 区块链
+	blockchains map[string]*blockchain.Blockchain
+
+	// chainIds 按照配置文件之中的顺序记录链 id, 第一条链是默认链
+	chainIds []string
 
 	// readyC 有事件出现
 	readyC chan struct{}
+
+	// reloadMutex 保证同一时间只有一次重新加载
+	reloadMutex sync.Mutex
 }
 
 func NewChainManager() *ChainManager {
-	return &ChainManager{}
+	return &ChainManager{
+		blockchains: make(map[string]*blockchain.Blockchain),
+	}
+}
+
+// Net 返回链管理器所管理的网络
+func (manager *ChainManager) Net() protocol.Net {
+	return manager.net
+}
+
+// GetBlockchain 返回链 id 对应的区块链, 链 id 为空的时候返回默认链
+func (manager *ChainManager) GetBlockchain(chainId string) (*blockchain.Blockchain, error) {
+	if chainId == "" {
+		if len(manager.chainIds) == 0 {
+			return nil, ErrChainNotFound
+		}
+		chainId = manager.chainIds[0]
+	}
+	chain, ok := manager.blockchains[chainId]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChainNotFound, chainId)
+	}
+	return chain, nil
+}
+
+// Blockchains 按照配置的顺序返回所有的区块链
+func (manager *ChainManager) Blockchains() []*blockchain.Blockchain {
+	blockchains := make([]*blockchain.Blockchain, 0, len(manager.chainIds))
+	for _, chainId := range manager.chainIds {
+		blockchains = append(blockchains, manager.blockchains[chainId])
+	}
+	return blockchains
 }
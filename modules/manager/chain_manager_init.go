@@ -17,8 +17,9 @@ import (
 )
 
 var (
-	log                                = logger.GetLogger(logger.MODULE_BLOCKCHAIN)
-	ErrNotSupportedMultipleBlockchains = errors.New("not supported multiple blockchains")
+	log                       = logger.GetLogger(logger.MODULE_BLOCKCHAIN)
+	ErrNoBlockchainConfigured = errors.New("no blockchain configured")
+	ErrDuplicatedChainId      = errors.New("duplicated chain id")
 )
 
 // Init 用来进行网络的初始化
@@ -123,14 +124,25 @@ func (manager *ChainManager) initNet() error {
 	return nil
 }
 
-// initBlockChain 进行区块链的初始化
+// initBlockChain 进行区块链的初始化, 每条链有自己的消息总线, 共享同一个网络
 func (manager *ChainManager) initBlockChain() error {
 	blockchains := localconf.ChainMakerConfig.GetBlockChains()
-	if len(blockchains) != 1 {
-		return ErrNotSupportedMultipleBlockchains
+	if len(blockchains) == 0 {
+		return ErrNoBlockchainConfigured
+	}
+	for _, chainConfig := range blockchains {
+		if err := manager.initOneBlockChain(chainConfig.ChainId, chainConfig.Genesis); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initOneBlockChain 初始化一条区块链
+func (manager *ChainManager) initOneBlockChain(chainId string, genesis string) error {
+	if _, ok := manager.blockchains[chainId]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicatedChainId, chainId)
 	}
-	genesis := blockchains[0].Genesis
-	chainId := blockchains[0].ChainId
 	if !filepath.IsAbs(genesis) { // 判断创世区块是否是绝对路径，如果不是绝对路径，那么就将相对路径转换为绝对路径
 		var err error
 		genesis, err = filepath.Abs(genesis)
@@ -145,7 +157,8 @@ func (manager *ChainManager) initBlockChain() error {
 		errMsg := fmt.Sprintf("init blockchain[%s] failed, %s", chainId, err.Error())
 		return errors.New(errMsg)
 	}
-	manager.blockchain = blockchain
+	manager.blockchains[chainId] = blockchain
+	manager.chainIds = append(manager.chainIds, chainId)
 	log.Infof("init blockchain[%s] success!", chainId)
 	return nil
 }
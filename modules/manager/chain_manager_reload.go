@@ -0,0 +1,158 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+	"zhanghefan123/security/localconf"
+	"zhanghefan123/security/modules/net"
+)
+
+// blackListNet 重新加载黑名单所需要的网络模块的能力, 由 LibP2pNet 进行实现
+type blackListNet interface {
+	AddBlackAddress(addr string) error
+	RemoveBlackAddress(addr string) error
+	AddBlackPeerId(pid string) error
+	RemoveBlackPeerId(pid string) error
+}
+
+// faultNetService 重新加载故障注入配置所需要的网络服务的能力, 由 modules/net 的 NetService 进行实现
+type faultNetService interface {
+	FaultInjector() *net.FaultInjector
+}
+
+// ReloadReport 重新加载配置文件的结果
+type ReloadReport struct {
+	Applied         []string // 已经生效的配置项
+	RestartRequired []string // 需要重启节点才能生效的配置项
+	Failed          []string // 应用失败的配置项以及失败的原因, 这些配置项保持原来的值
+}
+
+// Reload 重新读取配置文件, 和正在运行的配置进行比较, 可以在运行时修改的配置项立即生效, 其他配置项需要重启节点
+func (manager *ChainManager) Reload() (*ReloadReport, error) {
+	manager.reloadMutex.Lock()
+	defer manager.reloadMutex.Unlock()
+
+	loaded, err := localconf.LoadLocalConfig()
+	if err != nil {
+		return nil, err
+	}
+	running := localconf.ChainMakerConfig
+	report := &ReloadReport{
+		Applied:         make([]string, 0),
+		RestartRequired: make([]string, 0),
+		Failed:          make([]string, 0),
+	}
+	applied := make([]localconf.ConfigChange, 0)
+	for _, change := range localconf.DiffLocalConfig(running, loaded) {
+		if !change.Live {
+			report.RestartRequired = append(report.RestartRequired, change.Key)
+			continue
+		}
+		if err = manager.applyNetChange(change.Key, running, loaded); err != nil {
+			log.Errorf("reload option [%s] failed, %s", change.Key, err)
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %s", change.Key, err))
+			continue
+		}
+		applied = append(applied, change)
+		report.Applied = append(report.Applied, change.Key)
+	}
+
+	// 没有单独配置验证者的链使用 seeds 作为验证者, 验证者集合在共识模块初始化的时候确定
+	for _, change := range applied {
+		if change.Key != "net.seeds" {
+			continue
+		}
+		for _, chainConfig := range running.GetBlockChains() {
+			if len(chainConfig.Validators) == 0 {
+				report.RestartRequired = append(report.RestartRequired,
+					fmt.Sprintf("blockchain[%s].validators", chainConfig.ChainId))
+			}
+		}
+	}
+
+	localconf.ApplyLiveChanges(loaded, applied)
+	log.Infof("config reloaded, applied: %v, restart required: %v, failed: %v",
+		report.Applied, report.RestartRequired, report.Failed)
+	return report, nil
+}
+
+// applyNetChange 将种子节点, 黑名单以及故障注入的修改应用到网络模块, 其他配置项只需要修改配置
+func (manager *ChainManager) applyNetChange(key string, running, loaded *localconf.CMConfig) error {
+	if strings.HasPrefix(key, "net.faults.") {
+		return manager.applyFaults(loaded.NetConfig.Faults)
+	}
+	switch key {
+	case "net.seeds":
+		return manager.net.RefreshSeeds(loaded.NetConfig.Seeds)
+	case "net.blacklist.addresses":
+		blackList, err := manager.blackListNet()
+		if err != nil {
+			return err
+		}
+		return applyListChange(running.NetConfig.BlackList.Addresses, loaded.NetConfig.BlackList.Addresses,
+			blackList.AddBlackAddress, blackList.RemoveBlackAddress)
+	case "net.blacklist.node_ids":
+		blackList, err := manager.blackListNet()
+		if err != nil {
+			return err
+		}
+		return applyListChange(running.NetConfig.BlackList.NodeIds, loaded.NetConfig.BlackList.NodeIds,
+			blackList.AddBlackPeerId, blackList.RemoveBlackPeerId)
+	default:
+		return nil
+	}
+}
+
+// applyFaults 使用新的故障注入配置替换每条链的网络服务的配置, 分区的时间不重新计算
+func (manager *ChainManager) applyFaults(faults localconf.FaultsConfig) error {
+	config, err := net.FaultConfigFromLocalConf(faults)
+	if err != nil {
+		return err
+	}
+	for _, chain := range manager.Blockchains() {
+		netService, ok := chain.NetService().(faultNetService)
+		if !ok || netService.FaultInjector() == nil {
+			continue
+		}
+		if err = netService.FaultInjector().Update(config, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blackListNet 获取网络模块的黑名单能力
+func (manager *ChainManager) blackListNet() (blackListNet, error) {
+	blackList, ok := manager.net.(blackListNet)
+	if !ok {
+		return nil, fmt.Errorf("net provider does not support blacklist")
+	}
+	return blackList, nil
+}
+
+// applyListChange 添加新列表之中多出来的元素, 删除旧列表之中多出来的元素
+func applyListChange(oldItems, newItems []string, add, remove func(string) error) error {
+	oldSet := make(map[string]struct{}, len(oldItems))
+	for _, item := range oldItems {
+		oldSet[item] = struct{}{}
+	}
+	newSet := make(map[string]struct{}, len(newItems))
+	for _, item := range newItems {
+		newSet[item] = struct{}{}
+		if _, ok := oldSet[item]; ok {
+			continue
+		}
+		if err := add(item); err != nil {
+			return err
+		}
+	}
+	for _, item := range oldItems {
+		if _, ok := newSet[item]; ok {
+			continue
+		}
+		if err := remove(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
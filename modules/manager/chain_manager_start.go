@@ -19,15 +19,36 @@ func (manager *ChainManager) Start() error {
 	tls := false
 	engine.InitCryptoEngine(localconf.ChainMakerConfig.CryptoEngine, tls)
 
-	// 3. 进行区块链的启动
-	// sync.Map 的 range 方法之中传入的函数，如果返回 false 则停止继续
-	go startBlockChain(manager.blockchain)
+	// 3. 进行区块链的启动, 某条链启动失败的时候停止已经启动的链
+	if err := manager.startBlockChains(); err != nil {
+		return err
+	}
 
 	// 4. 关闭 readyC 代表启动好了
 	close(manager.readyC)
 	return nil
 }
 
-// startBlockChain 启动区块链
-func startBlockChain(chain *blockchain.Blockchain) {
+// startBlockChains 按照配置的顺序启动所有的区块链, 启动失败的时候按照相反的顺序停止已经启动的链
+func (manager *ChainManager) startBlockChains() error {
+	blockchains := manager.Blockchains()
+	for idx, chain := range blockchains {
+		if err := startBlockChain(chain); err != nil {
+			for started := idx - 1; started >= 0; started-- {
+				_ = blockchains[started].Stop()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// startBlockChain 启动区块链, 区块链内部的模块启动失败的时候已经启动的模块会被停止
+func startBlockChain(chain *blockchain.Blockchain) error {
+	if err := chain.Start(); err != nil {
+		log.Errorf("start blockchain[%s] failed, %s", chain.ChainId(), err)
+		return err
+	}
+	log.Infof("start blockchain[%s] success!", chain.ChainId())
+	return nil
 }
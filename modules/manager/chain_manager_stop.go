@@ -1,15 +1,50 @@
 package manager
 
-// Stop 进行 chain_manager 的停止
-func (manager *ChainManager) Stop() {
-	// 停止 manager 所管理的 blockchain
-	manager.blockchain.Stop()
+import (
+	"context"
+	"fmt"
+)
+
+// Drain 所有的链不再接受新的请求, 等待已经接受的请求结束, ctx 结束的时候返回错误
+func (manager *ChainManager) Drain(ctx context.Context) error {
+	// 先让所有的链同时开始排空, 之后在同一个截止时间之内等待, 排空需要的时间不会按链的数量叠加
+	blockchains := manager.Blockchains()
+	drainedCs := make([]<-chan struct{}, len(blockchains))
+	for idx, chain := range blockchains {
+		drainedCs[idx] = chain.Drain()
+	}
+	for idx, chain := range blockchains {
+		select {
+		case <-drainedCs[idx]:
+			log.Infof("blockchain[%s] drained", chain.ChainId())
+		case <-ctx.Done():
+			return fmt.Errorf("drain blockchain[%s] failed, %w", chain.ChainId(), ctx.Err())
+		}
+	}
+	return nil
+}
+
+// Stop 进行 chain_manager 的停止, 某个模块停止失败不影响其他模块的停止, 返回第一个错误
+func (manager *ChainManager) Stop() error {
+	var firstErr error
+
+	// 按照和初始化相反的顺序停止 manager 所管理的 blockchain
+	blockchains := manager.Blockchains()
+	for idx := len(blockchains) - 1; idx >= 0; idx-- {
+		if err := blockchains[idx].Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
 
 	// 停止所依赖的网络模块
 	if err := manager.net.Stop(); err != nil {
 		log.Errorf("chain manager net stop err:%v", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	} else {
+		// 打印停止
+		log.Infof("chain manager net stop success")
 	}
-
-	// 打印停止
-	log.Infof("chain manager net stop success")
+	return firstErr
 }
@@ -0,0 +1,298 @@
+package net
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+	"zhanghefan123/security/localconf"
+	"zhanghefan123/security/modules/clock"
+	netPb "zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// Direction 消息的方向
+type Direction string
+
+const (
+	DirectionSend Direction = "send" // 发送的消息
+	DirectionRecv Direction = "recv" // 接收的消息
+	DirectionBoth Direction = "both" // 发送以及接收的消息, 规则的方向为空的时候等同于 both
+)
+
+// FaultVerdict 故障注入过滤器对一条消息的决定
+type FaultVerdict struct {
+	Drop       bool          // 是否丢弃消息
+	Delay      time.Duration // 延迟处理消息的时间
+	Duplicates int           // 额外重复的次数
+}
+
+// FaultFilter 网络服务在发送以及接收路径上的故障注入过滤器, peer 为对端节点的 id, pubsub 广播的时候为空
+type FaultFilter interface {
+	Decide(direction Direction, peer string, msgType netPb.NetMsg_MsgType) FaultVerdict
+}
+
+// FaultRule 对匹配方向, 对端节点以及消息类型的消息注入故障, 对端节点以及消息类型为空的时候匹配所有的消息
+type FaultRule struct {
+	Direction     Direction
+	Peers         []string
+	MsgTypes      []netPb.NetMsg_MsgType
+	DropRate      float64       // 丢弃的概率
+	Delay         time.Duration // 固定的延迟
+	Jitter        time.Duration // [0, Jitter) 之间的随机延迟
+	DuplicateRate float64       // 重复发送一次的概率
+	ReorderRate   float64       // 乱序的概率, 乱序的消息额外延迟 [0, ReorderWindow)
+	ReorderWindow time.Duration
+}
+
+// Partition 在 [Start, End) 期间将 Nodes 和其他节点隔离, 时间从网络服务启动开始计算, End 为 0 表示一直隔离
+type Partition struct {
+	Nodes []string
+	Start time.Duration
+	End   time.Duration
+}
+
+// FaultConfig 故障注入的配置
+type FaultConfig struct {
+	Enabled    bool
+	Seed       int64 // 随机数种子, 为 0 的时候使用当前时间
+	Rules      []FaultRule
+	Partitions []Partition
+}
+
+// FaultStats 故障注入的统计
+type FaultStats struct {
+	Dropped    uint64 // 丢弃的消息数量
+	Delayed    uint64 // 延迟的消息数量
+	Duplicated uint64 // 重复的消息数量
+}
+
+// FaultInjector 根据 FaultConfig 进行决定的故障注入过滤器, 配置可以在运行时替换
+type FaultInjector struct {
+	mutex   sync.Mutex
+	localId string
+	clock   clock.Clock
+	start   time.Time
+	config  FaultConfig
+	rng     *rand.Rand
+	stats   FaultStats
+}
+
+// NewFaultInjector 创建故障注入过滤器, localId 为本节点的 id, clk 为空的时候使用系统时钟
+func NewFaultInjector(localId string, clk clock.Clock, config FaultConfig) (*FaultInjector, error) {
+	if clk == nil {
+		clk = clock.Real
+	}
+	fi := &FaultInjector{
+		localId: localId,
+		clock:   clk,
+		start:   clk.Now(),
+	}
+	if err := fi.Update(config, false); err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// Update 替换故障注入的配置, restart 为 true 的时候分区的时间从现在重新开始计算
+func (fi *FaultInjector) Update(config FaultConfig, restart bool) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	fi.config = config
+	fi.rng = rand.New(rand.NewSource(seed))
+	if restart {
+		fi.start = fi.clock.Now()
+	}
+	return nil
+}
+
+// Config 返回当前的配置
+func (fi *FaultInjector) Config() FaultConfig {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	return fi.config
+}
+
+// Elapsed 返回分区计时开始之后经过的时间
+func (fi *FaultInjector) Elapsed() time.Duration {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	return fi.clock.Now().Sub(fi.start)
+}
+
+// Stats 返回故障注入的统计
+func (fi *FaultInjector) Stats() FaultStats {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	return fi.stats
+}
+
+// Decide 先检查分区, 被分区隔离的消息直接丢弃, 之后依次应用所有匹配的规则, 延迟以及重复次数进行累加
+func (fi *FaultInjector) Decide(direction Direction, peer string, msgType netPb.NetMsg_MsgType) FaultVerdict {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	verdict := FaultVerdict{}
+	if !fi.config.Enabled {
+		return verdict
+	}
+
+	elapsed := fi.clock.Now().Sub(fi.start)
+	for _, partition := range fi.config.Partitions {
+		if partition.active(elapsed) && partition.separates(fi.localId, peer) {
+			verdict.Drop = true
+			fi.stats.Dropped++
+			return verdict
+		}
+	}
+
+	for _, rule := range fi.config.Rules {
+		if !rule.matches(direction, peer, msgType) {
+			continue
+		}
+		if rule.DropRate > 0 && fi.rng.Float64() < rule.DropRate {
+			verdict = FaultVerdict{Drop: true}
+			fi.stats.Dropped++
+			return verdict
+		}
+		verdict.Delay += rule.Delay
+		if rule.Jitter > 0 {
+			verdict.Delay += time.Duration(fi.rng.Int63n(int64(rule.Jitter)))
+		}
+		if rule.ReorderRate > 0 && rule.ReorderWindow > 0 && fi.rng.Float64() < rule.ReorderRate {
+			verdict.Delay += time.Duration(fi.rng.Int63n(int64(rule.ReorderWindow)))
+		}
+		if rule.DuplicateRate > 0 && fi.rng.Float64() < rule.DuplicateRate {
+			verdict.Duplicates++
+		}
+	}
+	if verdict.Delay > 0 {
+		fi.stats.Delayed++
+	}
+	fi.stats.Duplicated += uint64(verdict.Duplicates)
+	return verdict
+}
+
+// Validate 检查配置之中的概率, 时间以及方向是否合法
+func (c FaultConfig) Validate() error {
+	for i, rule := range c.Rules {
+		switch rule.Direction {
+		case "", DirectionSend, DirectionRecv, DirectionBoth:
+		default:
+			return fmt.Errorf("fault rule %d: unknown direction %q", i, rule.Direction)
+		}
+		for _, rate := range []float64{rule.DropRate, rule.DuplicateRate, rule.ReorderRate} {
+			if rate < 0 || rate > 1 {
+				return fmt.Errorf("fault rule %d: rate %v out of range [0, 1]", i, rate)
+			}
+		}
+		if rule.Delay < 0 || rule.Jitter < 0 || rule.ReorderWindow < 0 {
+			return fmt.Errorf("fault rule %d: negative duration", i)
+		}
+	}
+	for i, partition := range c.Partitions {
+		if len(partition.Nodes) == 0 {
+			return fmt.Errorf("partition %d: no nodes", i)
+		}
+		if partition.Start < 0 || partition.End < 0 || (partition.End != 0 && partition.End <= partition.Start) {
+			return fmt.Errorf("partition %d: invalid interval [%s, %s)", i, partition.Start, partition.End)
+		}
+	}
+	return nil
+}
+
+// FaultConfigFromLocalConf 将 chainmaker.yml 之中的 net.faults 转换为 FaultConfig
+func FaultConfigFromLocalConf(faults localconf.FaultsConfig) (FaultConfig, error) {
+	config := FaultConfig{
+		Enabled:    faults.Enabled,
+		Seed:       faults.Seed,
+		Rules:      make([]FaultRule, 0, len(faults.Rules)),
+		Partitions: make([]Partition, 0, len(faults.Partitions)),
+	}
+	for i, rule := range faults.Rules {
+		msgTypes, err := ParseMsgTypes(rule.MsgTypes)
+		if err != nil {
+			return FaultConfig{}, fmt.Errorf("fault rule %d: %v", i, err)
+		}
+		config.Rules = append(config.Rules, FaultRule{
+			Direction:     Direction(rule.Direction),
+			Peers:         rule.Peers,
+			MsgTypes:      msgTypes,
+			DropRate:      rule.DropRate,
+			Delay:         rule.Delay,
+			Jitter:        rule.Jitter,
+			DuplicateRate: rule.DuplicateRate,
+			ReorderRate:   rule.ReorderRate,
+			ReorderWindow: rule.ReorderWindow,
+		})
+	}
+	for _, partition := range faults.Partitions {
+		config.Partitions = append(config.Partitions, Partition{
+			Nodes: partition.Nodes,
+			Start: partition.Start,
+			End:   partition.End,
+		})
+	}
+	return config, config.Validate()
+}
+
+// ParseMsgTypes 根据名称解析消息类型, 例如 CONSENSUS_MSG
+func ParseMsgTypes(names []string) ([]netPb.NetMsg_MsgType, error) {
+	msgTypes := make([]netPb.NetMsg_MsgType, 0, len(names))
+	for _, name := range names {
+		value, ok := netPb.NetMsg_MsgType_value[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown msg type %q", name)
+		}
+		msgTypes = append(msgTypes, netPb.NetMsg_MsgType(value))
+	}
+	return msgTypes, nil
+}
+
+// matches 判断规则是否匹配消息
+func (r FaultRule) matches(direction Direction, peer string, msgType netPb.NetMsg_MsgType) bool {
+	if r.Direction != "" && r.Direction != DirectionBoth && r.Direction != direction {
+		return false
+	}
+	if len(r.Peers) > 0 && !containsString(r.Peers, peer) {
+		return false
+	}
+	if len(r.MsgTypes) == 0 {
+		return true
+	}
+	for _, t := range r.MsgTypes {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// active 判断分区在 elapsed 时刻是否生效
+func (p Partition) active(elapsed time.Duration) bool {
+	return elapsed >= p.Start && (p.End == 0 || elapsed < p.End)
+}
+
+// separates 判断分区是否隔离了本节点和对端节点, pubsub 广播的对端为空, 只有本节点被隔离的时候才丢弃
+func (p Partition) separates(localId, peer string) bool {
+	localIsolated := containsString(p.Nodes, localId)
+	if peer == "" {
+		return localIsolated
+	}
+	return localIsolated != containsString(p.Nodes, peer)
+}
+
+// containsString 判断列表之中是否包含 s
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
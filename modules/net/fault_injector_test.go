@@ -0,0 +1,149 @@
+package net
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"zhanghefan123/security/localconf"
+	"zhanghefan123/security/modules/clock"
+	netPb "zhanghefan123/security/protobuf/pb-go/net"
+)
+
+func TestFaultInjectorDisabled(t *testing.T) {
+	injector, err := NewFaultInjector("node1", nil, FaultConfig{
+		Rules: []FaultRule{{DropRate: 1}},
+	})
+	require.NoError(t, err)
+	verdict := injector.Decide(DirectionSend, "node2", netPb.NetMsg_CONSENSUS_MSG)
+	require.Equal(t, FaultVerdict{}, verdict)
+}
+
+func TestFaultInjectorPartition(t *testing.T) {
+	start := time.Unix(0, 0)
+	clk := clock.NewVirtual(start)
+	config := FaultConfig{
+		Enabled: true,
+		Partitions: []Partition{{
+			Nodes: []string{"node2"},
+			Start: 10 * time.Second,
+			End:   40 * time.Second,
+		}},
+	}
+	node1, err := NewFaultInjector("node1", clk, config)
+	require.NoError(t, err)
+	node2, err := NewFaultInjector("node2", clk, config)
+	require.NoError(t, err)
+
+	// 分区开始之前
+	require.False(t, node1.Decide(DirectionSend, "node2", netPb.NetMsg_CONSENSUS_MSG).Drop)
+
+	clk.AdvanceTo(start.Add(10 * time.Second))
+	require.True(t, node1.Decide(DirectionSend, "node2", netPb.NetMsg_CONSENSUS_MSG).Drop)
+	require.True(t, node1.Decide(DirectionRecv, "node2", netPb.NetMsg_TX).Drop)
+	require.False(t, node1.Decide(DirectionSend, "node3", netPb.NetMsg_CONSENSUS_MSG).Drop)
+	require.True(t, node2.Decide(DirectionSend, "node1", netPb.NetMsg_CONSENSUS_MSG).Drop)
+	// pubsub 广播只有被隔离的节点丢弃
+	require.True(t, node2.Decide(DirectionSend, "", netPb.NetMsg_TX).Drop)
+	require.False(t, node1.Decide(DirectionSend, "", netPb.NetMsg_TX).Drop)
+
+	clk.AdvanceTo(start.Add(40 * time.Second))
+	require.False(t, node1.Decide(DirectionSend, "node2", netPb.NetMsg_CONSENSUS_MSG).Drop)
+	require.Equal(t, uint64(2), node1.Stats().Dropped)
+
+	// 重新计时之后分区再次生效
+	require.NoError(t, node1.Update(config, true))
+	clk.AdvanceTo(start.Add(55 * time.Second))
+	require.True(t, node1.Decide(DirectionSend, "node2", netPb.NetMsg_CONSENSUS_MSG).Drop)
+}
+
+func TestFaultInjectorRules(t *testing.T) {
+	injector, err := NewFaultInjector("node1", nil, FaultConfig{
+		Enabled: true,
+		Seed:    1,
+		Rules: []FaultRule{
+			{
+				Direction: DirectionSend,
+				Peers:     []string{"node2"},
+				MsgTypes:  []netPb.NetMsg_MsgType{netPb.NetMsg_CONSENSUS_MSG},
+				DropRate:  1,
+			},
+			{
+				Direction:     DirectionRecv,
+				Delay:         100 * time.Millisecond,
+				Jitter:        50 * time.Millisecond,
+				DuplicateRate: 1,
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.True(t, injector.Decide(DirectionSend, "node2", netPb.NetMsg_CONSENSUS_MSG).Drop)
+	require.Equal(t, FaultVerdict{}, injector.Decide(DirectionSend, "node2", netPb.NetMsg_TX))
+	require.Equal(t, FaultVerdict{}, injector.Decide(DirectionSend, "node3", netPb.NetMsg_CONSENSUS_MSG))
+
+	verdict := injector.Decide(DirectionRecv, "node2", netPb.NetMsg_CONSENSUS_MSG)
+	require.False(t, verdict.Drop)
+	require.Equal(t, 1, verdict.Duplicates)
+	require.GreaterOrEqual(t, verdict.Delay, 100*time.Millisecond)
+	require.Less(t, verdict.Delay, 150*time.Millisecond)
+
+	stats := injector.Stats()
+	require.Equal(t, FaultStats{Dropped: 1, Delayed: 1, Duplicated: 1}, stats)
+}
+
+func TestFaultInjectorSeedIsDeterministic(t *testing.T) {
+	config := FaultConfig{
+		Enabled: true,
+		Seed:    42,
+		Rules:   []FaultRule{{DropRate: 0.5, Jitter: time.Second}},
+	}
+	decide := func() []FaultVerdict {
+		injector, err := NewFaultInjector("node1", nil, config)
+		require.NoError(t, err)
+		verdicts := make([]FaultVerdict, 0, 32)
+		for i := 0; i < 32; i++ {
+			verdicts = append(verdicts, injector.Decide(DirectionSend, "node2", netPb.NetMsg_CONSENSUS_MSG))
+		}
+		return verdicts
+	}
+	require.Equal(t, decide(), decide())
+}
+
+func TestFaultConfigValidate(t *testing.T) {
+	require.Error(t, FaultConfig{Rules: []FaultRule{{Direction: "sideways"}}}.Validate())
+	require.Error(t, FaultConfig{Rules: []FaultRule{{DropRate: 1.5}}}.Validate())
+	require.Error(t, FaultConfig{Rules: []FaultRule{{Delay: -time.Second}}}.Validate())
+	require.Error(t, FaultConfig{Partitions: []Partition{{Start: time.Second}}}.Validate())
+	require.Error(t, FaultConfig{Partitions: []Partition{{
+		Nodes: []string{"node2"}, Start: 40 * time.Second, End: 10 * time.Second,
+	}}}.Validate())
+	require.NoError(t, FaultConfig{Partitions: []Partition{{
+		Nodes: []string{"node2"}, Start: 10 * time.Second,
+	}}}.Validate())
+}
+
+func TestFaultConfigFromLocalConf(t *testing.T) {
+	config, err := FaultConfigFromLocalConf(localconf.FaultsConfig{
+		Enabled: true,
+		Rules: []localconf.FaultRuleConfig{{
+			Direction: "send",
+			MsgTypes:  []string{"CONSENSUS_MSG"},
+			Delay:     time.Second,
+		}},
+		Partitions: []localconf.PartitionConfig{{
+			Nodes: []string{"node2"},
+			Start: 10 * time.Second,
+			End:   40 * time.Second,
+		}},
+	})
+	require.NoError(t, err)
+	require.True(t, config.Enabled)
+	require.Equal(t, []netPb.NetMsg_MsgType{netPb.NetMsg_CONSENSUS_MSG}, config.Rules[0].MsgTypes)
+	require.Equal(t, DirectionSend, config.Rules[0].Direction)
+	require.Equal(t, 40*time.Second, config.Partitions[0].End)
+
+	_, err = FaultConfigFromLocalConf(localconf.FaultsConfig{
+		Rules: []localconf.FaultRuleConfig{{MsgTypes: []string{"NO_SUCH_MSG"}}},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package net
+
+import (
+	"sort"
+	"sync"
+	netPb "zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// MsgStat 某个方向上某种类型的消息的数量
+type MsgStat struct {
+	Direction Direction
+	MsgType   netPb.NetMsg_MsgType
+	Count     uint64
+}
+
+// msgKey MsgCounter 的键
+type msgKey struct {
+	direction Direction
+	msgType   netPb.NetMsg_MsgType
+}
+
+// MsgCounter 统计网络服务真正发送以及接收的消息数量, 被故障注入丢弃的消息不计入, 重复的消息每一份都计入
+type MsgCounter struct {
+	mutex  sync.Mutex
+	counts map[msgKey]uint64
+}
+
+// NewMsgCounter 创建消息计数器
+func NewMsgCounter() *MsgCounter {
+	return &MsgCounter{counts: make(map[msgKey]uint64)}
+}
+
+// Add 记录一条消息
+func (c *MsgCounter) Add(direction Direction, msgType netPb.NetMsg_MsgType) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[msgKey{direction: direction, msgType: msgType}]++
+}
+
+// Stats 返回按照方向以及消息类型排序的统计
+func (c *MsgCounter) Stats() []MsgStat {
+	c.mutex.Lock()
+	stats := make([]MsgStat, 0, len(c.counts))
+	for key, count := range c.counts {
+		stats = append(stats, MsgStat{Direction: key.direction, MsgType: key.msgType, Count: count})
+	}
+	c.mutex.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Direction != stats[j].Direction {
+			return stats[i].Direction < stats[j].Direction
+		}
+		return stats[i].MsgType < stats[j].MsgType
+	})
+	return stats
+}
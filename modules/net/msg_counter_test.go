@@ -0,0 +1,23 @@
+package net
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	netPb "zhanghefan123/security/protobuf/pb-go/net"
+)
+
+func TestMsgCounter(t *testing.T) {
+	counter := NewMsgCounter()
+	require.Empty(t, counter.Stats())
+
+	counter.Add(DirectionSend, netPb.NetMsg_CONSENSUS_MSG)
+	counter.Add(DirectionSend, netPb.NetMsg_CONSENSUS_MSG)
+	counter.Add(DirectionRecv, netPb.NetMsg_CONSENSUS_MSG)
+	counter.Add(DirectionSend, netPb.NetMsg_TX)
+
+	require.Equal(t, []MsgStat{
+		{Direction: DirectionRecv, MsgType: netPb.NetMsg_CONSENSUS_MSG, Count: 1},
+		{Direction: DirectionSend, MsgType: netPb.NetMsg_TX, Count: 1},
+		{Direction: DirectionSend, MsgType: netPb.NetMsg_CONSENSUS_MSG, Count: 2},
+	}, counter.Stats())
+}
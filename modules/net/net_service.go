@@ -11,6 +11,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"strings"
 	"sync"
+	"time"
 	"zhanghefan123/security/common/msgbus"
 	rootLog "zhanghefan123/security/logger"
 	"zhanghefan123/security/net-common/common/priorityblocker"
@@ -52,6 +53,8 @@ type NetService struct {
 	logger               *rootLog.CMLogger
 	consensusNodeIds     map[string]struct{}
 	consensusNodeIdsLock sync.RWMutex
+	faultFilter          FaultFilter // zhf add code, fault injection on the send and receive paths
+	msgCounter           *MsgCounter // zhf add code, counts the msgs actually sent and received
 }
 
 // NewNetService create a new net service instance.
@@ -62,6 +65,7 @@ func NewNetService(chainId string, localNet protocol.Net) *NetService {
 		localNet:         localNet,
 		consensusNodeIds: make(map[string]struct{}),
 		logger:           logger,
+		msgCounter:       NewMsgCounter(),
 	}
 	return ns
 }
@@ -118,7 +122,7 @@ func (ns *NetService) isConsensusNodeIdListEmpty() bool {
 	return len(ns.consensusNodeIds) == 0
 }
 
-func (ns *NetService) consensusBroadcastMsg(msg []byte, topic string) error {
+func (ns *NetService) consensusBroadcastMsg(msg []byte, msgType netPb.NetMsg_MsgType, topic string) error {
 	consensusNodeIdList := ns.getConsensusNodeIdList()
 	if len(consensusNodeIdList) == 0 {
 		return nil
@@ -133,7 +137,9 @@ func (ns *NetService) consensusBroadcastMsg(msg []byte, topic string) error {
 		}
 		go func() {
 			defer wg.Done()
-			if err := ns.localNet.SendMsg(ns.chainId, to, topic, msg); err != nil {
+			if err := ns.withFaults(DirectionSend, to, msgType, func() error {
+				return ns.localNet.SendMsg(ns.chainId, to, topic, msg)
+			}); err != nil {
 				ns.logger.Warnf("[NetService] send consensus broadcast msg failed, %s", err.Error())
 			}
 		}()
@@ -145,7 +151,7 @@ func (ns *NetService) consensusBroadcastMsg(msg []byte, topic string) error {
 // ConsensusBroadcastMsg only broadcast a net msg to other consensus nodes belongs to the same chain.
 func (ns *NetService) ConsensusBroadcastMsg(msg []byte, msgType netPb.NetMsg_MsgType) error {
 	pbMsg := NewNetMsg(msg, msgType, "")
-	return ns.consensusBroadcastMsg(msg, CreateFlagWithPrefixAndMsgType(consensusTopicNamePrefix, pbMsg.Type))
+	return ns.consensusBroadcastMsg(msg, msgType, CreateFlagWithPrefixAndMsgType(consensusTopicNamePrefix, pbMsg.Type))
 }
 
 // ConsensusSubscribe create a listener for receiving the msg
@@ -173,7 +179,10 @@ func (ns *NetService) SendMsg(msg []byte, msgType netPb.NetMsg_MsgType, to ...st
 		if n == ns.localNet.GetNodeUid() {
 			continue
 		}
-		err := ns.localNet.SendMsg(ns.chainId, n, msgFlag, msg)
+		to := n
+		err := ns.withFaults(DirectionSend, to, msgType, func() error {
+			return ns.localNet.SendMsg(ns.chainId, to, msgFlag, msg)
+		})
 		if err != nil {
 			ns.logger.Debugf("[NetService] send msg failed(to:%s, flag:%s), %s", n, msgFlag, err.Error())
 			return err
@@ -192,7 +201,9 @@ func (ns *NetService) ReceiveMsg(msgType netPb.NetMsg_MsgType, handler protocol.
 
 func (ns *NetService) receiveMsg(handler protocol.MsgHandler, flag string, msgType netPb.NetMsg_MsgType) error {
 	h := func(from string, data []byte) error {
-		err := handler(from, data, msgType)
+		err := ns.withFaults(DirectionRecv, from, msgType, func() error {
+			return handler(from, data, msgType)
+		})
 		if err != nil {
 			return err
 		}
@@ -384,10 +395,11 @@ func handleMsgBusSubscriberOnMessageBroadcast(
 	msgType netPb.NetMsg_MsgType,
 	logMsgDescription string,
 	netMsg *netPb.NetMsg) error {
-	// 这里仅仅会进行共识消息的发送 NetMsg_CONSENSUS_MSG
+	// 共识消息发送给所有的共识节点, 其他消息 (例如 NetMsg_TX 广播的待处理请求) 通过 pubsub 进行广播
 	if (msgType == netPb.NetMsg_CONSENSUS_MSG) && !netService.isConsensusNodeIdListEmpty() {
 		if err := netService.consensusBroadcastMsg(
 			netMsg.GetPayload(),
+			msgType,
 			CreateFlagWithPrefixAndMsgType(msgBusConsensusTopicPrefix, msgType),
 		); err != nil {
 			netService.logger.Debugf(
@@ -397,21 +409,21 @@ func handleMsgBusSubscriberOnMessageBroadcast(
 			)
 			return err
 		}
+	} else {
+		if err := netService.withFaults(DirectionSend, "", msgType, func() error {
+			return netService.broadcastMsg(
+				netMsg.GetPayload(),
+				CreateFlagWithPrefixAndMsgType(msgBusTopicPrefix, msgType),
+			)
+		}); err != nil {
+			netService.logger.Debugf(
+				"[NetService/msg-bus %s subscriber] broadcast failed, %s",
+				logMsgDescription,
+				err.Error(),
+			)
+			return err
+		}
 	}
-	// 不会出现 else 的情况
-	//else {
-	//	if err := netService.broadcastMsg(
-	//		netMsg.GetPayload(),
-	//		CreateFlagWithPrefixAndMsgType(msgBusTopicPrefix, msgType),
-	//	); err != nil {
-	//		netService.logger.Debugf(
-	//			"[NetService/msg-bus %s subscriber] broadcast failed, %s",
-	//			logMsgDescription,
-	//			err.Error(),
-	//		)
-	//		return err
-	//	}
-	//}
 	netService.logger.Debugf("[NetService/msg-bus %s subscriber] broadcast ok", logMsgDescription)
 	return nil
 }
@@ -422,13 +434,15 @@ func handleMsgBusSubscriberOnMessageSend(
 	logMsgDescription string,
 	netMsg *netPb.NetMsg) error {
 	go func() {
-		if err := netService.localNet.SendMsg(
-			netService.chainId, netMsg.To, CreateFlagWithPrefixAndMsgType(
-				msgBusMsgFlagPrefix,
-				msgType,
-			),
-			netMsg.GetPayload(),
-		); err != nil {
+		if err := netService.withFaults(DirectionSend, netMsg.To, msgType, func() error {
+			return netService.localNet.SendMsg(
+				netService.chainId, netMsg.To, CreateFlagWithPrefixAndMsgType(
+					msgBusMsgFlagPrefix,
+					msgType,
+				),
+				netMsg.GetPayload(),
+			)
+		}); err != nil {
 			netService.logger.Debugf(
 				"[NetService/msg-bus %s subscriber] send msg failed (size:%d) (reason:%s) (to:%s)",
 				logMsgDescription,
@@ -590,10 +604,63 @@ func CreateMsgHandlerForMsgBus(
 			)
 			return nil
 		}
-		pbMsg := NewNetMsg(data, msgType, node)
-		netService.msgBus.Publish(topic, pbMsg)
+		return netService.withFaults(DirectionRecv, node, msgType, func() error {
+			pbMsg := NewNetMsg(data, msgType, node)
+			netService.msgBus.Publish(topic, pbMsg)
+			return nil
+		})
+	}
+}
+
+// withFaults passes the msg through the fault filter before running action. A dropped msg returns nil,
+// a delayed msg runs action later and its error is only logged. Every successful run is counted. zhf add code
+func (ns *NetService) withFaults(direction Direction, peer string, msgType netPb.NetMsg_MsgType,
+	send func() error) error {
+	action := func() error {
+		if err := send(); err != nil {
+			return err
+		}
+		ns.msgCounter.Add(direction, msgType)
 		return nil
 	}
+	if ns.faultFilter == nil {
+		return action()
+	}
+	verdict := ns.faultFilter.Decide(direction, peer, msgType)
+	if verdict.Drop {
+		ns.logger.Debugf("[NetService] fault injection dropped %s msg (type:%s) (peer:%s)",
+			direction, msgType.String(), peer)
+		return nil
+	}
+	run := func() error {
+		for i := 0; i <= verdict.Duplicates; i++ {
+			if err := action(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if verdict.Delay <= 0 {
+		return run()
+	}
+	time.AfterFunc(verdict.Delay, func() {
+		if err := run(); err != nil {
+			ns.logger.Debugf("[NetService] delayed %s msg failed (type:%s) (peer:%s), %s",
+				direction, msgType.String(), peer, err.Error())
+		}
+	})
+	return nil
+}
+
+// FaultInjector returns the fault injector of the net service, nil if the fault filter is not an injector.
+func (ns *NetService) FaultInjector() *FaultInjector {
+	injector, _ := ns.faultFilter.(*FaultInjector)
+	return injector
+}
+
+// MsgStats returns the number of msgs sent and received by the net service. zhf add code
+func (ns *NetService) MsgStats() []MsgStat {
+	return ns.msgCounter.Stats()
 }
 
 // bindMsgBus bind a msgbus.MessageBus.
@@ -724,6 +791,17 @@ func (ns *NetService) initBindMsgBus() error {
 		return err
 	}
 
+	// subscribe the topic that the pending requests are gossiped to
+	if err := ns.subscribeTopicForMsgBus(
+		txPoolMsgHandler,
+		CreateFlagWithPrefixAndMsgType(
+			msgBusTopicPrefix,
+			netPb.NetMsg_TX,
+		),
+	); err != nil {
+		return err
+	}
+
 	// subscribe a tx pool msg subscriber for receiving tx msg from msg-bus then broadcast the msg to consensus nodes.
 	txPoolSubscriber := &TxPoolMsgSubscriber{
 		netService: ns,
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package net
 
 import (
+	"zhanghefan123/security/localconf"
 	"zhanghefan123/security/modules/utils"
 	"zhanghefan123/security/protocol"
 )
@@ -20,13 +21,24 @@ func (nsf *NetServiceFactory) NewNetService(net protocol.Net, chainId string, op
 	//初始化工厂实例
 	ns := NewNetService(chainId, net)
 
+	// 根据 net.faults 创建故障注入过滤器, 没有启用的时候也会创建, 以便通过管理接口在运行时启用
+	faultConfig, err := FaultConfigFromLocalConf(localconf.ChainMakerConfig.NetConfig.Faults)
+	if err != nil {
+		return nil, err
+	}
+	injector, err := NewFaultInjector(net.GetNodeUid(), nil, faultConfig)
+	if err != nil {
+		return nil, err
+	}
+	ns.faultFilter = injector
+
 	// 应用选项
 	if err := ns.Apply(opts...); err != nil {
 		return nil, err
 	}
 
 	// 设置共识节点的 id
-	ns.consensusNodeIds = utils.GetValidatorsMapFromLocalConfig()
+	ns.consensusNodeIds = utils.GetValidatorsMapOfChain(chainId)
 
 	// 进行结果的返回
 	return ns, nil
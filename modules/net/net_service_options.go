@@ -43,3 +43,11 @@ func WithConsensusNodeUid(consensusNodeUid ...string) NetServiceOption {
 		return nil
 	}
 }
+
+// WithFaultFilter set the fault filter on the send and receive paths, nil disables fault injection.
+func WithFaultFilter(filter FaultFilter) NetServiceOption {
+	return func(ns *NetService) error {
+		ns.faultFilter = filter
+		return nil
+	}
+}
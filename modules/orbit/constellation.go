@@ -0,0 +1,168 @@
+package orbit
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"time"
+)
+
+// DefaultMinAltitude 默认的链路离地面的最低高度 (km), 低于它的链路被大气层遮挡
+const DefaultMinAltitude = 80.0
+
+var ErrNoSatellites = errors.New("constellation has no satellites")
+
+// Constellation 星座, 根据卫星的位置计算星间链路的可见性以及传播时延
+type Constellation struct {
+	Satellites  []Elements
+	MinAltitude float64 // 链路离地面的最低高度 (km), 为 0 的时候使用 DefaultMinAltitude, 小于 0 的时候只要求不穿过地球
+	MaxRange    float64 // 星间链路的最远距离 (km), 为 0 的时候不限制
+}
+
+// NewConstellation 创建使用默认遮挡高度并且不限制距离的星座
+func NewConstellation(satellites []Elements) (*Constellation, error) {
+	if len(satellites) == 0 {
+		return nil, ErrNoSatellites
+	}
+	return &Constellation{Satellites: satellites}, nil
+}
+
+// Link 两颗卫星之间的链路
+type Link struct {
+	Visible  bool          // 是否可以通信, 经过多跳的时候表示存在一条路径
+	Distance float64       // 链路 (或者路径) 的长度 (km)
+	Delay    time.Duration // 传播时延
+	Hops     int           // 经过的星间链路数量, 直接可见的时候为 1
+}
+
+// Snapshot 星座在某个时刻的状态, 最短路径在查询的时候缓存, 不能被多个协程同时使用
+type Snapshot struct {
+	At        time.Time
+	Positions []Vector
+	links     [][]Link
+	routes    map[int][]Link // 每个源卫星的最短路径, 第一次查询的时候计算
+}
+
+// Snapshot 计算 t 时刻所有卫星的位置以及两两之间的直接链路
+func (c *Constellation) Snapshot(t time.Time) *Snapshot {
+	n := len(c.Satellites)
+	snapshot := &Snapshot{
+		At:        t,
+		Positions: make([]Vector, n),
+		links:     make([][]Link, n),
+		routes:    make(map[int][]Link),
+	}
+	for i, satellite := range c.Satellites {
+		snapshot.Positions[i] = satellite.Position(t)
+	}
+	blocking := EarthRadius + DefaultMinAltitude
+	if c.MinAltitude > 0 {
+		blocking = EarthRadius + c.MinAltitude
+	} else if c.MinAltitude < 0 {
+		blocking = EarthRadius
+	}
+	for i := range snapshot.links {
+		snapshot.links[i] = make([]Link, n)
+	}
+	for i := 0; i < n; i++ {
+		snapshot.links[i][i] = Link{Visible: true}
+		for j := i + 1; j < n; j++ {
+			a, b := snapshot.Positions[i], snapshot.Positions[j]
+			distance := b.Sub(a).Norm()
+			link := Link{Distance: distance, Delay: propagationDelay(distance)}
+			link.Visible = (c.MaxRange <= 0 || distance <= c.MaxRange) && segmentClearance(a, b) > blocking
+			if link.Visible {
+				link.Hops = 1
+			}
+			snapshot.links[i][j], snapshot.links[j][i] = link, link
+		}
+	}
+	return snapshot
+}
+
+// Link 返回 from 和 to 之间的直接链路, 不可见的时候 Delay 为直线距离对应的时延
+func (s *Snapshot) Link(from, to int) Link {
+	return s.links[from][to]
+}
+
+// Route 返回 from 到 to 经过可见星间链路的最短时延路径, 中间可以经过星座之中的任意卫星
+func (s *Snapshot) Route(from, to int) Link {
+	routes, ok := s.routes[from]
+	if !ok {
+		routes = s.shortestPaths(from)
+		s.routes[from] = routes
+	}
+	return routes[to]
+}
+
+// shortestPaths 使用 dijkstra 计算 from 到所有卫星的最短路径
+func (s *Snapshot) shortestPaths(from int) []Link {
+	n := len(s.links)
+	routes := make([]Link, n)
+	distances := make([]float64, n)
+	for i := range distances {
+		distances[i] = math.Inf(1)
+	}
+	distances[from] = 0
+	routes[from] = Link{Visible: true}
+	queue := &pathQueue{{satellite: from}}
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(pathItem)
+		if current.distance > distances[current.satellite] {
+			continue
+		}
+		for next, link := range s.links[current.satellite] {
+			if !link.Visible || next == current.satellite {
+				continue
+			}
+			distance := current.distance + link.Distance
+			if distance < distances[next] {
+				distances[next] = distance
+				routes[next] = Link{Visible: true, Distance: distance, Delay: propagationDelay(distance),
+					Hops: routes[current.satellite].Hops + 1}
+				heap.Push(queue, pathItem{satellite: next, distance: distance})
+			}
+		}
+	}
+	return routes
+}
+
+// pathItem dijkstra 队列之中的卫星
+type pathItem struct {
+	satellite int
+	distance  float64
+}
+
+// pathQueue 按照距离排序的最小堆
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int { return len(q) }
+
+func (q pathQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+
+func (q pathQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// segmentClearance 返回线段 ab 离地心最近的距离
+func segmentClearance(a, b Vector) float64 {
+	ab := b.Sub(a)
+	length2 := ab.Dot(ab)
+	if length2 == 0 {
+		return a.Norm()
+	}
+	t := math.Max(0, math.Min(1, -a.Dot(ab)/length2))
+	return Vector{a.X + t*ab.X, a.Y + t*ab.Y, a.Z + t*ab.Z}.Norm()
+}
+
+// propagationDelay 返回光在真空之中传播 distance (km) 的时间
+func propagationDelay(distance float64) time.Duration {
+	return time.Duration(distance / SpeedOfLight * float64(time.Second))
+}
@@ -0,0 +1,124 @@
+package orbit
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	EarthRadius   = 6378.137        // 地球赤道半径 (km)
+	EarthMu       = 398600.4418     // 地球引力常数 (km^3/s^2)
+	EarthJ2       = 1.08262668e-3   // 地球扁率引起的 J2 摄动系数
+	SpeedOfLight  = 299792.458      // 真空之中的光速 (km/s)
+	secondsPerDay = 86400.0         // 一天的秒数
+	keplerEpsilon = 1e-12           // 求解开普勒方程的精度
+	keplerMaxIter = 50              // 求解开普勒方程的最大迭代次数
+	degree        = math.Pi / 180.0 // 一度对应的弧度
+)
+
+// Vector 地心惯性坐标系之中的位置 (km)
+type Vector struct {
+	X, Y, Z float64
+}
+
+// Sub 返回 v - o
+func (v Vector) Sub(o Vector) Vector {
+	return Vector{v.X - o.X, v.Y - o.Y, v.Z - o.Z}
+}
+
+// Dot 返回 v 和 o 的点积
+func (v Vector) Dot(o Vector) float64 {
+	return v.X*o.X + v.Y*o.Y + v.Z*o.Z
+}
+
+// Norm 返回 v 的长度
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Elements 卫星在 Epoch 时刻的平均轨道根数, 角度使用弧度
+type Elements struct {
+	Name          string
+	Epoch         time.Time
+	SemiMajorAxis float64 // 半长轴 (km)
+	Eccentricity  float64 // 偏心率
+	Inclination   float64 // 轨道倾角
+	RAAN          float64 // 升交点赤经
+	ArgPerigee    float64 // 近地点幅角
+	MeanAnomaly   float64 // 平近点角
+	MeanMotion    float64 // 平均角速度 (rad/s)
+}
+
+// Altitude 返回轨道半长轴对应的高度 (km)
+func (e Elements) Altitude() float64 {
+	return e.SemiMajorAxis - EarthRadius
+}
+
+// Period 返回轨道周期
+func (e Elements) Period() time.Duration {
+	return time.Duration(2 * math.Pi / e.MeanMotion * float64(time.Second))
+}
+
+// Position 返回卫星在 t 时刻的位置. 使用二体运动加上 J2 引起的升交点赤经, 近地点幅角以及平近点角的长期漂移,
+// 不考虑大气阻力以及短周期摄动, 对于几个小时之内的链路可见性以及时延足够准确, 但是不能替代 SGP4
+func (e Elements) Position(t time.Time) Vector {
+	dt := t.Sub(e.Epoch).Seconds()
+	raanRate, argPerigeeRate, meanAnomalyRate := e.secularRates()
+	raan := e.RAAN + raanRate*dt
+	argPerigee := e.ArgPerigee + argPerigeeRate*dt
+	meanAnomaly := math.Mod(e.MeanAnomaly+meanAnomalyRate*dt, 2*math.Pi)
+
+	eccentricAnomaly := solveKepler(meanAnomaly, e.Eccentricity)
+	trueAnomaly := 2 * math.Atan2(math.Sqrt(1+e.Eccentricity)*math.Sin(eccentricAnomaly/2),
+		math.Sqrt(1-e.Eccentricity)*math.Cos(eccentricAnomaly/2))
+	radius := e.SemiMajorAxis * (1 - e.Eccentricity*math.Cos(eccentricAnomaly))
+
+	// 从轨道平面旋转到地心惯性坐标系
+	u := argPerigee + trueAnomaly
+	cosRAAN, sinRAAN := math.Cos(raan), math.Sin(raan)
+	cosU, sinU := math.Cos(u), math.Sin(u)
+	cosI, sinI := math.Cos(e.Inclination), math.Sin(e.Inclination)
+	return Vector{
+		X: radius * (cosRAAN*cosU - sinRAAN*sinU*cosI),
+		Y: radius * (sinRAAN*cosU + cosRAAN*sinU*cosI),
+		Z: radius * sinU * sinI,
+	}
+}
+
+// secularRates 返回 J2 引起的升交点赤经, 近地点幅角以及平近点角的变化率 (rad/s)
+func (e Elements) secularRates() (float64, float64, float64) {
+	p := e.SemiMajorAxis * (1 - e.Eccentricity*e.Eccentricity)
+	factor := 1.5 * EarthJ2 * (EarthRadius / p) * (EarthRadius / p) * e.MeanMotion
+	sinI2 := math.Sin(e.Inclination) * math.Sin(e.Inclination)
+	raanRate := -factor * math.Cos(e.Inclination)
+	argPerigeeRate := factor * (2 - 2.5*sinI2)
+	meanAnomalyRate := e.MeanMotion + factor*math.Sqrt(1-e.Eccentricity*e.Eccentricity)*(1-1.5*sinI2)
+	return raanRate, argPerigeeRate, meanAnomalyRate
+}
+
+// solveKepler 使用牛顿迭代求解开普勒方程 E - e sin(E) = M
+func solveKepler(meanAnomaly, eccentricity float64) float64 {
+	eccentricAnomaly := meanAnomaly
+	if eccentricity > 0.8 {
+		eccentricAnomaly = math.Pi
+	}
+	for i := 0; i < keplerMaxIter; i++ {
+		step := (eccentricAnomaly - eccentricity*math.Sin(eccentricAnomaly) - meanAnomaly) /
+			(1 - eccentricity*math.Cos(eccentricAnomaly))
+		eccentricAnomaly -= step
+		if math.Abs(step) < keplerEpsilon {
+			break
+		}
+	}
+	return eccentricAnomaly
+}
+
+// meanMotionOf 返回半长轴对应的平均角速度 (rad/s)
+func meanMotionOf(semiMajorAxis float64) float64 {
+	return math.Sqrt(EarthMu / (semiMajorAxis * semiMajorAxis * semiMajorAxis))
+}
+
+// semiMajorAxisOf 返回平均角速度对应的半长轴 (km)
+func semiMajorAxisOf(meanMotion float64) float64 {
+	return math.Cbrt(EarthMu / (meanMotion * meanMotion))
+}
@@ -0,0 +1,132 @@
+package orbit
+
+import (
+	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issTLE 国际空间站的两行根数
+const issTLE = `ISS (ZARYA)
+1 25544U 98067A   08264.51782528 -.00002182  00000-0 -11606-4 0  2927
+2 25544  51.6416 247.4627 0006703 130.5360 325.0288 15.72125391563537
+`
+
+// ring 一个轨道面之中均匀分布的 satellites 颗卫星
+func ring(t *testing.T, satellites int) *Constellation {
+	elements, err := Walker{Satellites: satellites, Planes: 1}.Elements()
+	require.NoError(t, err)
+	c, err := NewConstellation(elements)
+	require.NoError(t, err)
+	return c
+}
+
+func TestParseTLE(t *testing.T) {
+	satellites, err := ReadTLE(strings.NewReader(issTLE))
+	require.NoError(t, err)
+	require.Len(t, satellites, 1)
+	iss := satellites[0]
+	require.Equal(t, "ISS (ZARYA)", iss.Name)
+	require.Equal(t, time.Date(2008, time.September, 20, 12, 25, 40, 0, time.UTC), iss.Epoch.Round(time.Second))
+	require.InDelta(t, 51.6416, iss.Inclination/degree, 1e-9)
+	require.InDelta(t, 0.0006703, iss.Eccentricity, 1e-12)
+	require.InDelta(t, 91.6, iss.Period().Minutes(), 0.1)
+	require.InDelta(t, 350, iss.Altitude(), 10)
+
+	// 位置始终在近地点以及远地点之间
+	for offset := time.Duration(0); offset < 2*time.Hour; offset += 7 * time.Minute {
+		radius := iss.Position(iss.Epoch.Add(offset)).Norm()
+		require.InDelta(t, iss.SemiMajorAxis, radius, iss.SemiMajorAxis*iss.Eccentricity+1e-6)
+	}
+
+	lines := strings.Split(issTLE, "\n")
+	_, err = ParseTLE("", lines[1][:68]+"0", lines[2])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "checksum")
+	_, err = ReadTLE(strings.NewReader(lines[0] + "\n" + lines[1]))
+	require.Error(t, err)
+}
+
+func TestWalkerGeometry(t *testing.T) {
+	_, err := Walker{Satellites: 10, Planes: 3}.Elements()
+	require.Error(t, err)
+	_, err = Walker{Satellites: 12, Planes: 3, Phasing: 3}.Elements()
+	require.Error(t, err)
+
+	elements, err := Walker{Satellites: 24, Planes: 3, Phasing: 1}.Elements()
+	require.NoError(t, err)
+	require.Len(t, elements, 24)
+	require.InDelta(t, 120, elements[8].RAAN/degree, 1e-9)
+	// 相邻轨道面之间的相位差为 F * 360 / T
+	require.InDelta(t, 15, elements[8].MeanAnomaly/degree, 1e-9)
+
+	c := ring(t, 12)
+	radius := EarthRadius + DefaultAltitude
+	now := J2000.Add(17 * time.Minute)
+	snapshot := c.Snapshot(now)
+	for _, position := range snapshot.Positions {
+		require.InDelta(t, radius, position.Norm(), 1e-6)
+	}
+	// 同一个轨道面之中相邻的卫星相隔 30 度
+	chord := 2 * radius * math.Sin(15*degree)
+	link := snapshot.Link(0, 1)
+	require.True(t, link.Visible)
+	require.Equal(t, 1, link.Hops)
+	require.InDelta(t, chord, link.Distance, 1e-6)
+	require.Equal(t, time.Duration(chord/SpeedOfLight*float64(time.Second)), link.Delay)
+}
+
+func TestVisibilityAndRouting(t *testing.T) {
+	c := ring(t, 12)
+	snapshot := c.Snapshot(J2000)
+	// 相隔 90 度以及 180 度的卫星之间的链路穿过地球
+	require.False(t, snapshot.Link(0, 3).Visible)
+	require.False(t, snapshot.Link(0, 6).Visible)
+	require.False(t, snapshot.Link(0, 2).Visible)
+
+	route := snapshot.Route(0, 6)
+	require.True(t, route.Visible)
+	require.Equal(t, 6, route.Hops)
+	require.InDelta(t, 6*snapshot.Link(0, 1).Distance, route.Distance, 1e-6)
+
+	// 相隔 45 度的卫星之间的链路没有穿过地球, 但是离地面的高度低于大气层
+	grazing := ring(t, 8)
+	require.False(t, grazing.Snapshot(J2000).Link(0, 1).Visible)
+	grazing.MinAltitude = -1
+	require.True(t, grazing.Snapshot(J2000).Link(0, 1).Visible)
+
+	// 限制链路的距离之后没有任何可见的链路
+	c.MaxRange = 1000
+	require.False(t, c.Snapshot(J2000).Route(0, 1).Visible)
+}
+
+func TestScheduleSummary(t *testing.T) {
+	c := ring(t, 12)
+	period := c.Satellites[0].Period()
+	schedule, err := c.NewSchedule(J2000, period, time.Minute, Placement{Satellites: []int{0, 1, 6}})
+	require.NoError(t, err)
+	require.Len(t, schedule.Steps, int(period/time.Minute)+1)
+	require.Equal(t, schedule.Steps[3], schedule.At(3*time.Minute+time.Second))
+
+	summaries := schedule.Summary()
+	require.Len(t, summaries, 3)
+	// 同一个轨道面之中卫星的相对位置不变
+	require.Equal(t, 1.0, summaries[0].VisibleRatio)
+	require.Equal(t, 0, summaries[0].Outages)
+	require.InDelta(t, float64(summaries[0].MinDelay), float64(summaries[0].MaxDelay), float64(time.Microsecond))
+	require.Equal(t, 0.0, summaries[1].VisibleRatio)
+	require.Equal(t, 1, summaries[1].Outages)
+
+	// 多跳的时候经过中间的卫星转发
+	schedule, err = c.NewSchedule(J2000, period, time.Minute, DefaultPlacement(7))
+	require.NoError(t, err)
+	for _, summary := range schedule.Summary() {
+		require.Equal(t, 1.0, summary.VisibleRatio)
+		require.Equal(t, summary.To-summary.From, summary.MaxHops)
+	}
+
+	_, err = c.NewSchedule(J2000, period, time.Minute, Placement{Satellites: []int{12}})
+	require.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package orbit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Placement 节点所在的卫星, 第 i 个节点位于星座之中的第 Satellites[i] 颗卫星
+type Placement struct {
+	Satellites []int
+	MultiHop   bool // 不可直接可见的节点之间是否经过其他卫星转发
+}
+
+// DefaultPlacement 前 nodes 颗卫星各放置一个节点, 使用多跳路由
+func DefaultPlacement(nodes int) Placement {
+	satellites := make([]int, nodes)
+	for i := range satellites {
+		satellites[i] = i
+	}
+	return Placement{Satellites: satellites, MultiHop: true}
+}
+
+// Validate 检查节点所在的卫星都在星座之中
+func (p Placement) Validate(c *Constellation) error {
+	if len(p.Satellites) == 0 {
+		return fmt.Errorf("no node is placed on the constellation")
+	}
+	for i, satellite := range p.Satellites {
+		if satellite < 0 || satellite >= len(c.Satellites) {
+			return fmt.Errorf("node %d is placed on satellite %d, the constellation has %d satellites", i+1,
+				satellite, len(c.Satellites))
+		}
+	}
+	return nil
+}
+
+// Links 返回 t 时刻节点两两之间的链路, 结果按照节点的顺序排列
+func (c *Constellation) Links(t time.Time, placement Placement) [][]Link {
+	snapshot := c.Snapshot(t)
+	links := make([][]Link, len(placement.Satellites))
+	for i, from := range placement.Satellites {
+		links[i] = make([]Link, len(placement.Satellites))
+		for j, to := range placement.Satellites {
+			if placement.MultiHop {
+				links[i][j] = snapshot.Route(from, to)
+			} else {
+				links[i][j] = snapshot.Link(from, to)
+			}
+		}
+	}
+	return links
+}
+
+// Step 时间表之中的一个采样时刻
+type Step struct {
+	Offset time.Duration // 相对于时间表开始的偏移
+	Links  [][]Link      // 节点两两之间的链路
+}
+
+// PairSummary 一对节点在整个时间表之中的链路统计
+type PairSummary struct {
+	From         int           `json:"from"`
+	To           int           `json:"to"`
+	VisibleRatio float64       `json:"visible_ratio"` // 可以通信的采样时刻所占的比例
+	Outages      int           `json:"outages"`       // 从可以通信变为中断的次数
+	MinDelay     time.Duration `json:"min_delay"`     // 可以通信的时候的最小时延
+	MaxDelay     time.Duration `json:"max_delay"`     // 可以通信的时候的最大时延
+	MaxHops      int           `json:"max_hops"`
+}
+
+// Schedule 从 Start 开始每隔 Interval 采样一次节点之间的链路
+type Schedule struct {
+	Start     time.Time
+	Interval  time.Duration
+	Placement Placement
+	Steps     []Step
+}
+
+// NewSchedule 计算 [start, start + duration] 之间每隔 interval 的节点链路
+func (c *Constellation) NewSchedule(start time.Time, duration, interval time.Duration, placement Placement) (*Schedule, error) {
+	if err := placement.Validate(c); err != nil {
+		return nil, err
+	}
+	if interval <= 0 || duration < 0 {
+		return nil, fmt.Errorf("invalid schedule: duration %s, interval %s", duration, interval)
+	}
+	schedule := &Schedule{Start: start, Interval: interval, Placement: placement}
+	for offset := time.Duration(0); offset <= duration; offset += interval {
+		schedule.Steps = append(schedule.Steps, Step{Offset: offset, Links: c.Links(start.Add(offset), placement)})
+	}
+	return schedule, nil
+}
+
+// At 返回 offset 时刻所在的采样, offset 超过时间表之后返回最后一个采样
+func (s *Schedule) At(offset time.Duration) Step {
+	index := int(offset / s.Interval)
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(s.Steps) {
+		index = len(s.Steps) - 1
+	}
+	return s.Steps[index]
+}
+
+// Summary 返回每一对节点 (From < To) 的链路统计
+func (s *Schedule) Summary() []PairSummary {
+	nodes := len(s.Placement.Satellites)
+	summaries := make([]PairSummary, 0, nodes*(nodes-1)/2)
+	for from := 0; from < nodes; from++ {
+		for to := from + 1; to < nodes; to++ {
+			summary := PairSummary{From: from, To: to}
+			visible, previous := 0, true
+			for _, step := range s.Steps {
+				link := step.Links[from][to]
+				if !link.Visible {
+					if previous {
+						summary.Outages++
+					}
+					previous = false
+					continue
+				}
+				previous = true
+				if visible == 0 || link.Delay < summary.MinDelay {
+					summary.MinDelay = link.Delay
+				}
+				if link.Delay > summary.MaxDelay {
+					summary.MaxDelay = link.Delay
+				}
+				if link.Hops > summary.MaxHops {
+					summary.MaxHops = link.Hops
+				}
+				visible++
+			}
+			if len(s.Steps) > 0 {
+				summary.VisibleRatio = float64(visible) / float64(len(s.Steps))
+			}
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries
+}
@@ -0,0 +1,155 @@
+package orbit
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tleLineLength 两行根数每一行的长度, 最后一个字符为校验和
+const tleLineLength = 69
+
+// ParseTLE 解析一组两行根数, name 为空的时候使用卫星编号
+func ParseTLE(name, line1, line2 string) (Elements, error) {
+	line1, line2 = strings.TrimRight(line1, " \r"), strings.TrimRight(line2, " \r")
+	for i, line := range []string{line1, line2} {
+		if len(line) < tleLineLength || line[0] != byte('1'+i) || line[1] != ' ' {
+			return Elements{}, fmt.Errorf("tle line %d: expect %d characters starting with \"%d \"", i+1,
+				tleLineLength, i+1)
+		}
+		if checksum := tleChecksum(line); int(line[68]-'0') != checksum {
+			return Elements{}, fmt.Errorf("tle line %d: checksum %c, expect %d", i+1, line[68], checksum)
+		}
+	}
+	if catalog := strings.TrimSpace(line1[2:7]); catalog != strings.TrimSpace(line2[2:7]) {
+		return Elements{}, fmt.Errorf("tle lines belong to different satellites %s and %s", catalog,
+			strings.TrimSpace(line2[2:7]))
+	}
+	if name = strings.TrimSpace(strings.TrimPrefix(name, "0 ")); name == "" {
+		name = strings.TrimSpace(line1[2:7])
+	}
+
+	epoch, err := parseTLEEpoch(line1[18:32])
+	if err != nil {
+		return Elements{}, err
+	}
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"inclination", line2[8:16]},
+		{"raan", line2[17:25]},
+		{"eccentricity", "0." + strings.TrimSpace(line2[26:33])},
+		{"argument of perigee", line2[34:42]},
+		{"mean anomaly", line2[43:51]},
+		{"mean motion", line2[52:63]},
+	}
+	values := make([]float64, len(fields))
+	for i, field := range fields {
+		if values[i], err = strconv.ParseFloat(strings.TrimSpace(field.value), 64); err != nil {
+			return Elements{}, fmt.Errorf("tle %s: invalid %s %q", name, field.name, field.value)
+		}
+	}
+	if values[5] <= 0 {
+		return Elements{}, fmt.Errorf("tle %s: mean motion must be positive", name)
+	}
+	meanMotion := values[5] * 2 * math.Pi / secondsPerDay
+	return Elements{
+		Name:          name,
+		Epoch:         epoch,
+		SemiMajorAxis: semiMajorAxisOf(meanMotion),
+		Eccentricity:  values[2],
+		Inclination:   values[0] * degree,
+		RAAN:          values[1] * degree,
+		ArgPerigee:    values[3] * degree,
+		MeanAnomaly:   values[4] * degree,
+		MeanMotion:    meanMotion,
+	}, nil
+}
+
+// ReadTLE 读取多组两行根数, 每一组之前可以有一行卫星名称, 空行被忽略
+func ReadTLE(r io.Reader) ([]Elements, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), " \r"); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	satellites := make([]Elements, 0, len(lines)/2)
+	for i := 0; i < len(lines); {
+		name := ""
+		if !strings.HasPrefix(lines[i], "1 ") {
+			name = lines[i]
+			i++
+		}
+		if i+1 >= len(lines) {
+			return nil, fmt.Errorf("tle set %d is incomplete", len(satellites)+1)
+		}
+		satellite, err := ParseTLE(name, lines[i], lines[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("tle set %d: %w", len(satellites)+1, err)
+		}
+		satellites = append(satellites, satellite)
+		i += 2
+	}
+	if len(satellites) == 0 {
+		return nil, fmt.Errorf("no tle found")
+	}
+	return satellites, nil
+}
+
+// LoadTLE 读取文件之中的两行根数
+func LoadTLE(path string) ([]Elements, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	satellites, err := ReadTLE(file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s failed, %w", path, err)
+	}
+	return satellites, nil
+}
+
+// tleChecksum 计算一行根数的校验和: 所有数字之和加上负号的个数, 取个位
+func tleChecksum(line string) int {
+	sum := 0
+	for _, c := range line[:tleLineLength-1] {
+		switch {
+		case c >= '0' && c <= '9':
+			sum += int(c - '0')
+		case c == '-':
+			sum++
+		}
+	}
+	return sum % 10
+}
+
+// parseTLEEpoch 解析两位年份以及带有小数的年积日, 57 之前的年份属于 21 世纪
+func parseTLEEpoch(value string) (time.Time, error) {
+	year, err := strconv.Atoi(strings.TrimSpace(value[:2]))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid tle epoch year %q", value[:2])
+	}
+	day, err := strconv.ParseFloat(strings.TrimSpace(value[2:]), 64)
+	if err != nil || day < 1 {
+		return time.Time{}, fmt.Errorf("invalid tle epoch day %q", value[2:])
+	}
+	if year < 57 {
+		year += 2000
+	} else {
+		year += 1900
+	}
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return start.Add(time.Duration((day - 1) * secondsPerDay * float64(time.Second))), nil
+}
@@ -0,0 +1,91 @@
+package orbit
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	DefaultAltitude    = 550.0 // 默认的轨道高度 (km)
+	DefaultInclination = 53.0  // 默认的轨道倾角 (度)
+	DefaultSpread      = 360.0 // 默认的升交点分布范围, 360 度为 walker delta, 180 度为 walker star
+)
+
+// J2000 默认的轨道根数历元
+var J2000 = time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// Walker walker 星座 i:T/P/F 的参数, 所有卫星使用相同高度以及倾角的圆轨道
+type Walker struct {
+	Satellites  int       // 卫星总数 T
+	Planes      int       // 轨道面数量 P
+	Phasing     int       // 相位因子 F, 取值 [0, P)
+	Altitude    float64   // 轨道高度 (km), 为 0 的时候使用 DefaultAltitude
+	Inclination float64   // 轨道倾角 (度), 为 0 的时候使用 DefaultInclination
+	Spread      float64   // 升交点分布范围 (度), 为 0 的时候使用 DefaultSpread
+	Epoch       time.Time // 轨道根数的历元, 为零值的时候使用 J2000
+}
+
+// withDefaults 填充没有设置的参数
+func (w Walker) withDefaults() Walker {
+	if w.Altitude == 0 {
+		w.Altitude = DefaultAltitude
+	}
+	if w.Inclination == 0 {
+		w.Inclination = DefaultInclination
+	}
+	if w.Spread == 0 {
+		w.Spread = DefaultSpread
+	}
+	if w.Epoch.IsZero() {
+		w.Epoch = J2000
+	}
+	return w
+}
+
+// Validate 检查星座的参数
+func (w Walker) Validate() error {
+	w = w.withDefaults()
+	if w.Satellites <= 0 || w.Planes <= 0 || w.Satellites%w.Planes != 0 {
+		return fmt.Errorf("walker: %d satellites can not be divided into %d planes", w.Satellites, w.Planes)
+	}
+	if w.Phasing < 0 || w.Phasing >= w.Planes {
+		return fmt.Errorf("walker: phasing %d out of range [0, %d)", w.Phasing, w.Planes)
+	}
+	if w.Altitude < 0 {
+		return fmt.Errorf("walker: negative altitude %v", w.Altitude)
+	}
+	if w.Inclination < 0 || w.Inclination > 180 || w.Spread < 0 || w.Spread > 360 {
+		return fmt.Errorf("walker: inclination %v or spread %v out of range", w.Inclination, w.Spread)
+	}
+	return nil
+}
+
+// Elements 生成星座之中所有卫星的轨道根数, 依次为第一个轨道面的所有卫星, 第二个轨道面的所有卫星, 等等
+func (w Walker) Elements() ([]Elements, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	w = w.withDefaults()
+	perPlane := w.Satellites / w.Planes
+	semiMajorAxis := EarthRadius + w.Altitude
+	meanMotion := meanMotionOf(semiMajorAxis)
+	satellites := make([]Elements, 0, w.Satellites)
+	for plane := 0; plane < w.Planes; plane++ {
+		raan := float64(plane) * w.Spread / float64(w.Planes)
+		for slot := 0; slot < perPlane; slot++ {
+			// 相邻轨道面之间的相位差为 F * 360 / T
+			anomaly := float64(slot)*360/float64(perPlane) + float64(plane*w.Phasing)*360/float64(w.Satellites)
+			satellites = append(satellites, Elements{
+				Name:          fmt.Sprintf("sat-%d-%d", plane, slot),
+				Epoch:         w.Epoch,
+				SemiMajorAxis: semiMajorAxis,
+				Inclination:   w.Inclination * degree,
+				RAAN:          raan * degree,
+				MeanAnomaly:   math.Mod(anomaly, 360) * degree,
+				MeanMotion:    meanMotion,
+			})
+		}
+	}
+	return satellites, nil
+}
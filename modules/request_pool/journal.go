@@ -0,0 +1,220 @@
+package request_pool
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+	"zhanghefan123/security/common/wal"
+)
+
+// journalRecordType 日志记录的类型
+type journalRecordType uint8
+
+const (
+	recordSubmit journalRecordType = iota + 1 // 请求进入了请求池
+	recordFinish                              // 请求已经结束, 不需要在重启之后重新提交
+)
+
+// journalRecord 日志之中的一条记录
+type journalRecord struct {
+	Type             journalRecordType `json:"type"`
+	RequestId        string            `json:"request_id"`
+	UserId           string            `json:"user_id,omitempty"`
+	ClientRequestIds []string          `json:"client_request_ids,omitempty"` // 客户端指定的请求 id, 重启之后同一个用户仍然可以通过它们获取结果
+	Message          []byte            `json:"message,omitempty"`            // 序列化之后的请求
+	Result           []byte            `json:"result,omitempty"`             // 序列化之后的结果, 请求被放弃的时候为空
+	Time             time.Time         `json:"time"`
+}
+
+// Journal 基于 wal 的请求日志, 请求进入请求池的时候写入 submit 记录, 结束的时候写入 finish 记录,
+// 重启之后没有 finish 记录的请求会被重新提交, 宽限期之内的结果会被重新加载
+type Journal struct {
+	// mutex 保护下面所有的字段
+	mutex sync.Mutex
+
+	// log 底层的 wal, 每条记录都带有校验和, 重启的时候会丢弃损坏的记录
+	log *wal.Log
+
+	// lastIndex 最后一条记录的索引
+	lastIndex uint64
+
+	// gracePeriod 结果的保留时间
+	gracePeriod time.Duration
+
+	// outstanding 还没有结束的请求, 请求 id -> submit 记录的索引
+	outstanding map[string]uint64
+
+	// finished 宽限期之内结束的请求, 请求 id -> finish 记录的索引以及时间
+	finished map[string]finishedEntry
+}
+
+// finishedEntry 结束的请求在日志之中的位置
+type finishedEntry struct {
+	index uint64
+	time  time.Time
+}
+
+// OpenJournal 打开指定目录下的请求日志, 目录不存在的时候会进行创建
+func OpenJournal(path string, gracePeriod time.Duration) (*Journal, error) {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultResultGracePeriod
+	}
+	log, err := wal.Open(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	lastIndex, err := log.LastIndex()
+	if err != nil {
+		_ = log.Close()
+		return nil, err
+	}
+	return &Journal{
+		log:         log,
+		lastIndex:   lastIndex,
+		gracePeriod: gracePeriod,
+		outstanding: make(map[string]uint64),
+		finished:    make(map[string]finishedEntry),
+	}, nil
+}
+
+// replay 读取日志之中所有的记录, 返回没有结束的请求以及宽限期之内的结果
+func (j *Journal) replay() (pending []*journalRecord, results []*journalRecord, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	firstIndex, err := j.log.FirstIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+	submits := make(map[string]*journalRecord)
+	finishes := make(map[string]*journalRecord)
+	order := make([]string, 0)
+	for index := firstIndex; index != 0 && index <= j.lastIndex; index++ {
+		data, err := j.log.Read(index)
+		if err != nil {
+			return nil, nil, err
+		}
+		record := &journalRecord{}
+		if err = json.Unmarshal(data, record); err != nil {
+			return nil, nil, err
+		}
+		switch record.Type {
+		case recordSubmit:
+			submits[record.RequestId] = record
+			order = append(order, record.RequestId)
+			j.outstanding[record.RequestId] = index
+		case recordFinish:
+			delete(j.outstanding, record.RequestId)
+			finishes[record.RequestId] = record
+			j.finished[record.RequestId] = finishedEntry{index: index, time: record.Time}
+		}
+	}
+
+	// 按照提交的顺序返回没有结束的请求
+	for _, requestId := range order {
+		if _, ok := j.outstanding[requestId]; ok {
+			pending = append(pending, submits[requestId])
+		}
+	}
+	for requestId, record := range finishes {
+		if record.Result != nil && time.Since(record.Time) <= j.gracePeriod {
+			results = append(results, record)
+		} else {
+			delete(j.finished, requestId)
+		}
+	}
+	return pending, results, j.compact()
+}
+
+// append 写入一条记录
+func (j *Journal) append(record *journalRecord) (uint64, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return 0, err
+	}
+	if err = j.log.Write(j.lastIndex+1, data); err != nil {
+		return 0, err
+	}
+	j.lastIndex++
+	return j.lastIndex, nil
+}
+
+// submit 记录进入请求池的请求
+func (j *Journal) submit(requestId, userId string, clientRequestIds []string, message []byte) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	index, err := j.append(&journalRecord{
+		Type:             recordSubmit,
+		RequestId:        requestId,
+		UserId:           userId,
+		ClientRequestIds: clientRequestIds,
+		Message:          message,
+		Time:             time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	j.outstanding[requestId] = index
+	return nil
+}
+
+// finish 记录结束的请求, result 为空表示请求被放弃了
+func (j *Journal) finish(requestId, userId string, clientRequestIds []string, result []byte) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	now := time.Now()
+	index, err := j.append(&journalRecord{
+		Type:             recordFinish,
+		RequestId:        requestId,
+		UserId:           userId,
+		ClientRequestIds: clientRequestIds,
+		Result:           result,
+		Time:             now,
+	})
+	if err != nil {
+		return err
+	}
+	delete(j.outstanding, requestId)
+	if result != nil {
+		j.finished[requestId] = finishedEntry{index: index, time: now}
+	}
+	return j.compact()
+}
+
+// compact 删除不再需要的记录, 只保留没有结束的请求以及宽限期之内的结果
+func (j *Journal) compact() error {
+	firstIndex, err := j.log.FirstIndex()
+	if err != nil || firstIndex == 0 {
+		return err
+	}
+	// 没有需要保留的记录的时候只保留最后一条
+	keep := j.lastIndex
+	for _, index := range j.outstanding {
+		if index < keep {
+			keep = index
+		}
+	}
+	for requestId, entry := range j.finished {
+		if time.Since(entry.time) > j.gracePeriod {
+			delete(j.finished, requestId)
+			continue
+		}
+		if entry.index < keep {
+			keep = entry.index
+		}
+	}
+	if keep <= firstIndex {
+		return nil
+	}
+	return j.log.TruncateFront(keep)
+}
+
+// Close 关闭请求日志
+func (j *Journal) Close() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if err := j.log.Sync(); err != nil {
+		return err
+	}
+	return j.log.Close()
+}
@@ -0,0 +1,82 @@
+package request_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+func newJournalPool(t *testing.T, dir string) *RequestPool {
+	journal, err := OpenJournal(dir, time.Minute)
+	require.NoError(t, err)
+	pool := NewRequestPool(10, nil, WithJournal(journal), WithResultGracePeriod(time.Minute))
+	_, err = pool.Recover()
+	require.NoError(t, err)
+	return pool
+}
+
+func TestJournalResubmitAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	// 1. 两个请求进入请求池, 其中一个得到了结果, 然后节点停止
+	pool := newJournalPool(t, dir)
+	finished, _ := newTestRequest(context.Background(), "finished")
+	finished.ClientRequestId = "client1"
+	unfinished, _ := newTestRequest(context.Background(), "unfinished")
+	require.NoError(t, pool.AddRequest(finished))
+	require.NoError(t, pool.AddRequest(unfinished))
+	pool.Start()
+	reply := &pb.RpcMessage{Type: pb.RpcMessageType_AuthReply, Content: []byte("legal")}
+	takeRequest(t, pool).Reply(reply)
+	pool.Stop()
+	require.NoError(t, pool.CloseJournal())
+
+	// 2. 重启之后没有结束的请求被重新提交, 并且保留原来的请求 id
+	restarted := newJournalPool(t, dir)
+	defer restarted.Stop()
+	restarted.Start()
+	recovered := takeRequest(t, restarted)
+	require.Equal(t, unfinished.RequestId, recovered.RequestId)
+	require.Equal(t, "unfinished", recovered.UserId)
+	_, requestStatus := restarted.Result(unfinished.RequestId, "unfinished")
+	require.Equal(t, RequestPending, requestStatus)
+
+	// 3. 重启之前的结果在宽限期之内仍然可以获取, 其他用户无法获取
+	result, requestStatus := restarted.Result(finished.RequestId, "finished")
+	require.Equal(t, RequestFinished, requestStatus)
+	require.Equal(t, reply.Content, result.Content)
+	_, requestStatus = restarted.Result(finished.RequestId, "someone else")
+	require.Equal(t, RequestUnknown, requestStatus)
+	result, requestStatus = restarted.Result("client1", "finished")
+	require.Equal(t, RequestFinished, requestStatus)
+	require.Equal(t, reply.Content, result.Content)
+	_, requestStatus = restarted.Result("client1", "someone else")
+	require.Equal(t, RequestUnknown, requestStatus)
+
+	// 4. 恢复的请求没有等待者, 结果只会进入结果缓存
+	require.False(t, recovered.Reply(reply))
+	_, requestStatus = restarted.Result(unfinished.RequestId, "unfinished")
+	require.Equal(t, RequestFinished, requestStatus)
+}
+
+func TestJournalAbandonedRequestNotResubmitted(t *testing.T) {
+	dir := t.TempDir()
+
+	pool := newJournalPool(t, dir)
+	request, _ := newTestRequest(context.Background(), "user")
+	require.NoError(t, pool.AddRequest(request))
+	pool.Start()
+	takeRequest(t, pool).Close()
+	pool.Stop()
+	require.NoError(t, pool.CloseJournal())
+
+	restarted := newJournalPool(t, dir)
+	defer restarted.Stop()
+	recovered, err := restarted.Recover()
+	require.NoError(t, err)
+	require.Equal(t, 0, recovered)
+	require.Equal(t, 0, restarted.Stats().Queues[PriorityNormal].Depth)
+}
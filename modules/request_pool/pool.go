@@ -1,37 +1,466 @@
 package request_pool
 
-import pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"sync"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
 
-// RequestPool 交易池
+var (
+	// ErrPoolFull 请求所在优先级的队列已经满了, 请求被直接拒绝
+	ErrPoolFull = errors.New("request pool is full")
+	// ErrPoolStopped 请求池已经停止
+	ErrPoolStopped = errors.New("request pool is stopped")
+	// ErrPoolDraining 请求池正在等待已经接受的请求结束, 不再接受新的请求
+	ErrPoolDraining = errors.New("request pool is draining")
+)
+
+// RequestStatus 请求的状态
+type RequestStatus int
+
+const (
+	RequestUnknown  RequestStatus = iota // 请求不存在或者结果已经超过了宽限期
+	RequestPending                       // 请求正在排队或者共识
+	RequestFinished                      // 请求已经结束, 结果在宽限期之内
+)
+
+// PoolOption 请求池的可选配置
+type PoolOption func(rp *RequestPool)
+
+// WithJournal 使用请求日志, 请求在重启之后会被重新提交
+func WithJournal(journal *Journal) PoolOption {
+	return func(rp *RequestPool) {
+		rp.journal = journal
+	}
+}
+
+// WithResultGracePeriod 设置结果的保留时间
+func WithResultGracePeriod(gracePeriod time.Duration) PoolOption {
+	return func(rp *RequestPool) {
+		rp.results = NewResultCache(gracePeriod)
+	}
+}
+
+// RequestPool 请求池, 每个优先级有自己的队列, 同一个用户的并发请求会被合并
 type RequestPool struct {
-	// MaxSize 缓存大小
+	// MaxSize 每个优先级队列的最大长度
 	MaxSize int
 
-	// RequestChan 缓存
+	// RequestChan 按照优先级将请求分发给共识模块, 没有缓冲, 保证共识模块每次取到的都是当前优先级最高的请求
 	RequestChan chan *Request
+
+	// classifier 决定请求的优先级
+	classifier Classifier
+
+	// mutex 保护下面所有的字段
+	mutex sync.Mutex
+
+	// queues 每个优先级的等待队列
+	queues [NumPriorities][]*Request
+
+	// pending 排队中以及共识中的请求, 用于合并同一个用户的请求
+	pending map[string]*Request
+
+	// requests 排队中以及共识中的请求, 请求 id -> 请求
+	requests map[string]*Request
+
+	// clients 排队中以及共识中的请求, 用户以及客户端请求 id -> 请求
+	clients map[string]*Request
+
+	// journal 请求日志, 为空的时候请求不会被持久化
+	journal *Journal
+
+	// journalErrors 写入请求日志失败的次数
+	journalErrors uint64
+
+	// journalErr 最近一次写入请求日志的错误, 写入成功之后清空, 用于健康检查
+	journalErr error
+
+	// journalWrites 在锁之外进行中的结束记录的写入, 全部完成之前排空不会结束
+	journalWrites int
+
+	// results 宽限期之内的结果
+	results *ResultCache
+
+	// stats 每个优先级的统计信息
+	stats [NumPriorities]queueCounters
+
+	// deduplicated 被合并的请求数量
+	deduplicated uint64
+
+	// stopped 请求池是否已经停止
+	stopped bool
+
+	// draining 请求池是否正在排空, 排空的时候不再接受新的请求, 已经接受的请求继续交给共识模块
+	draining bool
+
+	// drainedC 排空的时候所有已经接受的请求都结束之后关闭
+	drainedC chan struct{}
+
+	// notifyC 有新的请求进入队列的时候进行通知
+	notifyC chan struct{}
+
+	// stopC 停止分发协程
+	stopC chan struct{}
+
+	// startOnce, stopOnce 保证只会启动和停止一次
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewRequestPool 新的请求处理池, queueSize 为每个优先级队列的最大长度, classifier 为空的时候所有请求都是普通优先级
+func NewRequestPool(queueSize int, classifier Classifier, opts ...PoolOption) *RequestPool {
+	if classifier == nil {
+		classifier = func(string) Priority { return PriorityNormal }
+	}
+	rp := &RequestPool{
+		MaxSize:     queueSize,
+		RequestChan: make(chan *Request),
+		classifier:  classifier,
+		pending:     make(map[string]*Request),
+		requests:    make(map[string]*Request),
+		clients:     make(map[string]*Request),
+		results:     NewResultCache(DefaultResultGracePeriod),
+		notifyC:     make(chan struct{}, 1),
+		stopC:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(rp)
+	}
+	return rp
+}
+
+// Recover 从请求日志之中恢复重启之前没有结束的请求以及宽限期之内的结果, 返回恢复的请求数量
+func (rp *RequestPool) Recover() (int, error) {
+	if rp.journal == nil {
+		return 0, nil
+	}
+	pending, results, err := rp.journal.replay()
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range results {
+		message := &pb.RpcMessage{}
+		if err = proto.Unmarshal(record.Result, message); err != nil {
+			return 0, err
+		}
+		rp.results.put(record.RequestId, record.UserId, record.ClientRequestIds, message, record.Time)
+	}
+
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	recovered := 0
+	for _, record := range pending {
+		request, err := newRecoveredRequest(record)
+		if err != nil {
+			return recovered, err
+		}
+		// 恢复的请求在重启之前已经被接受了, 不再进行准入控制
+		rp.enqueue(request, rp.classifier(request.UserId))
+		recovered++
+	}
+	if recovered > 0 {
+		rp.notify()
+	}
+	return recovered, nil
+}
+
+// Start 启动分发协程
+func (rp *RequestPool) Start() {
+	rp.startOnce.Do(func() {
+		go rp.dispatch()
+	})
 }
 
-// Request 请求
-type Request struct {
-	// 请求的内容
-	Message *pb.RpcMessage
+// Stop 停止分发协程, 队列之中还没有被处理的请求会被关闭, 调用者会立即收到结果
+func (rp *RequestPool) Stop() {
+	rp.stopOnce.Do(func() {
+		rp.mutex.Lock()
+		rp.stopped = true
+		queued := make([]*Request, 0)
+		for priority := range rp.queues {
+			queued = append(queued, rp.queues[priority]...)
+			rp.queues[priority] = nil
+		}
+		rp.mutex.Unlock()
 
-	// 结果的返回的 channel
-	ResponseChan chan *pb.RpcMessage
+		close(rp.stopC)
+		for _, request := range queued {
+			request.Close()
+		}
+	})
 }
 
-// NewRequestPool 新的请求处理池
-func NewRequestPool(channelSize int) *RequestPool {
-	return &RequestPool{
-		MaxSize:     channelSize,
-		RequestChan: make(chan *Request, channelSize),
+// Drain 不再接受新的请求, 已经接受的请求继续交给共识模块,
+// 返回的 channel 在所有已经接受的请求都得到结果或者被关闭之后关闭
+func (rp *RequestPool) Drain() <-chan struct{} {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if !rp.draining {
+		rp.draining = true
+		rp.drainedC = make(chan struct{})
+		rp.checkDrained()
 	}
+	return rp.drainedC
 }
 
-// NewRequest 创建新的结果
-func NewRequest(message *pb.RpcMessage, resultChan chan *pb.RpcMessage) *Request {
-	return &Request{
-		Message:      message,
-		ResponseChan: resultChan,
+// checkDrained 排空的时候没有剩余的请求并且请求日志写入完成就关闭 drainedC, 调用者需要持有锁
+func (rp *RequestPool) checkDrained() {
+	if !rp.draining || len(rp.requests) > 0 || rp.journalWrites > 0 {
+		return
+	}
+	select {
+	case <-rp.drainedC:
+	default:
+		close(rp.drainedC)
+	}
+}
+
+// CloseJournal 关闭请求日志, 在请求池以及共识模块都停止之后调用
+func (rp *RequestPool) CloseJournal() error {
+	if rp.journal == nil {
+		return nil
+	}
+	return rp.journal.Close()
+}
+
+// AddRequest 将请求放入请求池, 不会阻塞:
+// 同一个用户已经有请求在排队或者共识的时候合并到已有的请求之中, 队列满了的时候返回 ErrPoolFull
+func (rp *RequestPool) AddRequest(request *Request) error {
+	rp.mutex.Lock()
+	if rp.stopped {
+		rp.mutex.Unlock()
+		return ErrPoolStopped
+	}
+	if rp.draining {
+		rp.mutex.Unlock()
+		return ErrPoolDraining
+	}
+
+	// 1. 合并同一个用户的同一个请求 id 或者同一个用户的请求, 不同用户的请求即使请求 id 相同也不会合并
+	// 合并之后调用者拿到的是已有请求的 id
+	if existed, ok := rp.requests[request.RequestId]; ok && request.RequestId != "" && existed.UserId == request.UserId && existed.merge(request) {
+		rp.merged(existed, request)
+		rp.mutex.Unlock()
+		return nil
+	}
+	if request.UserId != "" {
+		if existed, ok := rp.pending[request.UserId]; ok && existed.merge(request) {
+			rp.merged(existed, request)
+			rp.mutex.Unlock()
+			return nil
+		}
+	}
+
+	// 2. 准入控制
+	priority := rp.classifier(request.UserId)
+	if rp.MaxSize > 0 && len(rp.queues[priority]) >= rp.MaxSize {
+		rp.stats[priority].rejected++
+		rp.mutex.Unlock()
+		return ErrPoolFull
+	}
+
+	// 3. 写入请求日志, 写入失败的请求不会被接受
+	// 预先指定的请求 id 已经被其他用户的请求使用的时候同样重新生成
+	if _, taken := rp.requests[request.RequestId]; taken || request.RequestId == "" {
+		request.RequestId = newRequestId()
+	}
+	if request.ClientRequestId != "" {
+		request.clientRequestIds = []string{request.ClientRequestId}
+	}
+	if rp.journal != nil {
+		message, err := proto.Marshal(request.Message)
+		if err == nil {
+			err = rp.journal.submit(request.RequestId, request.UserId, request.clientRequestIds, message)
+		}
+		rp.journalErr = err
+		if err != nil {
+			rp.journalErrors++
+			rp.mutex.Unlock()
+			return err
+		}
+	}
+
+	// 4. 进入对应优先级的队列
+	rp.enqueue(request, priority)
+	rp.stats[priority].accepted++
+	rp.mutex.Unlock()
+
+	rp.notify()
+	return nil
+}
+
+// enqueue 将请求放入对应优先级的队列, 调用者需要持有锁
+func (rp *RequestPool) enqueue(request *Request, priority Priority) {
+	request.Priority = priority
+	request.EnqueueTime = time.Now()
+	request.pool = rp
+	request.watch()
+	rp.queues[priority] = append(rp.queues[priority], request)
+	rp.requests[request.RequestId] = request
+	if request.UserId != "" {
+		rp.pending[request.UserId] = request
+	}
+	for _, clientRequestId := range request.clientRequestIds {
+		rp.clients[clientKey(request.UserId, clientRequestId)] = request
+	}
+}
+
+// merged 请求合并到已有的请求之后, 调用者拿到已有请求的 id, 并且可以通过自己的客户端请求 id 获取已有请求的结果,
+// 调用者需要持有锁
+func (rp *RequestPool) merged(existed *Request, request *Request) {
+	request.RequestId = existed.RequestId
+	if request.ClientRequestId != "" {
+		existed.clientRequestIds = append(existed.clientRequestIds, request.ClientRequestId)
+		rp.clients[clientKey(existed.UserId, request.ClientRequestId)] = existed
+	}
+	rp.deduplicated++
+}
+
+// notify 通知分发协程有新的请求
+func (rp *RequestPool) notify() {
+	select {
+	case rp.notifyC <- struct{}{}:
+	default:
+	}
+}
+
+// complete 请求开始返回结果或者被关闭之后, 从 pending 之中移除, 之后同一个用户的请求会发起新的一轮,
+// 结果会在宽限期之内保存. 请求池停止之后被关闭的请求不会记录为结束, 重启之后会被重新提交
+func (rp *RequestPool) complete(request *Request, result *pb.RpcMessage) {
+	rp.mutex.Lock()
+	if existed, ok := rp.pending[request.UserId]; ok && existed == request {
+		delete(rp.pending, request.UserId)
+	}
+	if existed, ok := rp.requests[request.RequestId]; ok && existed == request {
+		delete(rp.requests, request.RequestId)
+	}
+	for _, clientRequestId := range request.clientRequestIds {
+		key := clientKey(request.UserId, clientRequestId)
+		if existed, ok := rp.clients[key]; ok && existed == request {
+			delete(rp.clients, key)
+		}
+	}
+	if result != nil {
+		rp.results.put(request.RequestId, request.UserId, request.clientRequestIds, result, time.Now())
+	}
+	if rp.journal == nil || (result == nil && rp.stopped) {
+		rp.checkDrained()
+		rp.mutex.Unlock()
+		return
+	}
+	rp.journalWrites++
+	rp.mutex.Unlock()
+
+	// 写入以及压缩请求日志的时候不持有请求池的锁, 请求日志有自己的锁
+	var data []byte
+	var err error
+	if result != nil {
+		data, err = proto.Marshal(result)
+	}
+	if err == nil {
+		err = rp.journal.finish(request.RequestId, request.UserId, request.clientRequestIds, data)
+	}
+
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rp.journalWrites--
+	rp.journalErr = err
+	if err != nil {
+		rp.journalErrors++
+	}
+	// 请求日志写入完成之后再通知排空的等待者
+	rp.checkDrained()
+}
+
+// Health 请求池的健康检查, 请求池停止或者最近一次写入请求日志失败的时候返回错误
+func (rp *RequestPool) Health() error {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if rp.stopped {
+		return ErrPoolStopped
+	}
+	if rp.journalErr != nil {
+		return fmt.Errorf("request journal unavailable, %w", rp.journalErr)
+	}
+	return nil
+}
+
+// Result 根据请求 id 或者用户自己的客户端请求 id 获取请求的状态, 请求结束之后在宽限期之内可以获取结果,
+// 只有请求所属的用户可以获取
+func (rp *RequestPool) Result(requestId string, userId string) (*pb.RpcMessage, RequestStatus) {
+	if message, owner, ok := rp.results.get(requestId); ok && owner == userId {
+		return message, RequestFinished
+	}
+	if resolved, ok := rp.results.resolve(userId, requestId); ok {
+		if message, _, ok := rp.results.get(resolved); ok {
+			return message, RequestFinished
+		}
+	}
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	if request, ok := rp.requests[requestId]; ok && request.UserId == userId {
+		return nil, RequestPending
+	}
+	if _, ok := rp.clients[clientKey(userId, requestId)]; ok {
+		return nil, RequestPending
+	}
+	return nil, RequestUnknown
+}
+
+// pop 取出优先级最高的请求, 没有请求的时候返回 nil
+func (rp *RequestPool) pop() *Request {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	for priority := range rp.queues {
+		if len(rp.queues[priority]) > 0 {
+			request := rp.queues[priority][0]
+			rp.queues[priority][0] = nil
+			rp.queues[priority] = rp.queues[priority][1:]
+			return request
+		}
+	}
+	return nil
+}
+
+// pushFront 将请求放回队列的头部, 在有更高优先级的请求到来的时候使用
+func (rp *RequestPool) pushFront(request *Request) {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	rp.queues[request.Priority] = append([]*Request{request}, rp.queues[request.Priority]...)
+}
+
+// dispatch 分发协程, 每次把优先级最高的请求交给共识模块
+func (rp *RequestPool) dispatch() {
+	for {
+		request := rp.pop()
+		if request == nil {
+			select {
+			case <-rp.notifyC:
+				continue
+			case <-rp.stopC:
+				return
+			}
+		}
+
+		// 所有调用者都已经离开了, 不需要再交给共识模块
+		if request.Cancelled() {
+			request.Close()
+			continue
+		}
+
+		select {
+		case rp.RequestChan <- request:
+			rp.recordDispatch(request)
+		case <-rp.notifyC:
+			// 有新的请求到来, 放回队列重新选择优先级最高的请求
+			rp.pushFront(request)
+		case <-rp.stopC:
+			request.Close()
+			return
+		}
 	}
 }
@@ -0,0 +1,211 @@
+package request_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+func newTestRequest(ctx context.Context, userId string) (*Request, chan *pb.RpcMessage) {
+	resultChan := make(chan *pb.RpcMessage, 1)
+	return NewRequest(ctx, userId, &pb.RpcMessage{Type: pb.RpcMessageType_AuthRequest}, resultChan), resultChan
+}
+
+func takeRequest(t *testing.T, pool *RequestPool) *Request {
+	select {
+	case request := <-pool.RequestChan:
+		return request
+	case <-time.After(time.Second):
+		t.Fatal("no request dispatched")
+		return nil
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	classifier := NewUserListClassifier([]string{"emergency"}, []string{"operator"})
+	pool := NewRequestPool(10, classifier)
+	defer pool.Stop()
+
+	for _, userId := range []string{"normal", "operator", "emergency"} {
+		request, _ := newTestRequest(context.Background(), userId)
+		require.NoError(t, pool.AddRequest(request))
+	}
+	pool.Start()
+
+	require.Equal(t, "emergency", takeRequest(t, pool).UserId)
+	require.Equal(t, "operator", takeRequest(t, pool).UserId)
+	require.Equal(t, "normal", takeRequest(t, pool).UserId)
+
+	stats := pool.Stats()
+	for _, queue := range stats.Queues {
+		require.Equal(t, uint64(1), queue.Dispatched, queue.Priority.String())
+		require.Equal(t, 0, queue.Depth)
+	}
+}
+
+func TestDeduplicateFanOut(t *testing.T) {
+	pool := NewRequestPool(10, nil)
+	defer pool.Stop()
+
+	first, firstChan := newTestRequest(context.Background(), "user")
+	second, secondChan := newTestRequest(context.Background(), "user")
+	require.NoError(t, pool.AddRequest(first))
+	require.NoError(t, pool.AddRequest(second))
+	require.Equal(t, uint64(1), pool.Stats().Deduplicated)
+
+	pool.Start()
+	request := takeRequest(t, pool)
+	require.Same(t, first, request)
+
+	reply := &pb.RpcMessage{Type: pb.RpcMessageType_AuthReply}
+	require.True(t, request.Reply(reply))
+	request.Close()
+	require.Same(t, reply, <-firstChan)
+	require.Same(t, reply, <-secondChan)
+
+	// 结果返回之后同一个用户的请求会发起新的一轮
+	third, _ := newTestRequest(context.Background(), "user")
+	require.NoError(t, pool.AddRequest(third))
+	require.Same(t, third, takeRequest(t, pool))
+}
+
+func TestClientRequestIdScopedToUser(t *testing.T) {
+	pool := NewRequestPool(10, nil)
+	defer pool.Stop()
+
+	// 不同用户使用相同的客户端请求 id 不会合并, 共识之中的请求 id 由请求池生成
+	alice, aliceChan := newTestRequest(context.Background(), "alice")
+	alice.ClientRequestId = "r1"
+	mallory, _ := newTestRequest(context.Background(), "mallory")
+	mallory.ClientRequestId = "r1"
+	require.NoError(t, pool.AddRequest(alice))
+	require.NoError(t, pool.AddRequest(mallory))
+	require.NotEqual(t, "r1", alice.RequestId)
+	require.NotEqual(t, alice.RequestId, mallory.RequestId)
+	require.Zero(t, pool.Stats().Deduplicated)
+
+	// 服务端预先指定的请求 id 已经被其他用户使用的时候不会合并, 而是重新生成
+	forged, _ := newTestRequest(context.Background(), "mallory2")
+	forged.RequestId = alice.RequestId
+	require.NoError(t, pool.AddRequest(forged))
+	require.NotEqual(t, alice.RequestId, forged.RequestId)
+	require.Zero(t, pool.Stats().Deduplicated)
+
+	// 合并到已有请求之中的请求的客户端请求 id 同样可以获取结果
+	retry, retryChan := newTestRequest(context.Background(), "alice")
+	retry.ClientRequestId = "r2"
+	require.NoError(t, pool.AddRequest(retry))
+	require.Equal(t, alice.RequestId, retry.RequestId)
+
+	for _, clientRequestId := range []string{"r1", "r2", alice.RequestId} {
+		_, requestStatus := pool.Result(clientRequestId, "alice")
+		require.Equal(t, RequestPending, requestStatus, clientRequestId)
+	}
+	_, requestStatus := pool.Result("r2", "mallory")
+	require.Equal(t, RequestUnknown, requestStatus)
+
+	pool.Start()
+	request := takeRequest(t, pool)
+	require.Same(t, alice, request)
+	reply := &pb.RpcMessage{Type: pb.RpcMessageType_AuthReply, Content: []byte("alice")}
+	require.True(t, request.Reply(reply))
+	require.Same(t, reply, <-aliceChan)
+	require.Same(t, reply, <-retryChan)
+
+	for _, clientRequestId := range []string{"r1", "r2", alice.RequestId} {
+		result, requestStatus := pool.Result(clientRequestId, "alice")
+		require.Equal(t, RequestFinished, requestStatus, clientRequestId)
+		require.Same(t, reply, result)
+	}
+	// 其他用户的同一个客户端请求 id 对应的是自己的请求
+	_, requestStatus = pool.Result("r1", "mallory")
+	require.Equal(t, RequestPending, requestStatus)
+	_, requestStatus = pool.Result(alice.RequestId, "mallory")
+	require.Equal(t, RequestUnknown, requestStatus)
+}
+
+func TestAdmissionControl(t *testing.T) {
+	pool := NewRequestPool(2, nil)
+	defer pool.Stop()
+
+	for _, userId := range []string{"a", "b"} {
+		request, _ := newTestRequest(context.Background(), userId)
+		require.NoError(t, pool.AddRequest(request))
+	}
+	request, _ := newTestRequest(context.Background(), "c")
+	require.ErrorIs(t, pool.AddRequest(request), ErrPoolFull)
+
+	stats := pool.Stats()
+	normal := stats.Queues[PriorityNormal]
+	require.Equal(t, 2, normal.Depth)
+	require.Equal(t, uint64(2), normal.Accepted)
+	require.Equal(t, uint64(1), normal.Rejected)
+}
+
+func TestCancelledRequestDropped(t *testing.T) {
+	pool := NewRequestPool(10, nil)
+	defer pool.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, cancelledChan := newTestRequest(ctx, "cancelled")
+	require.NoError(t, pool.AddRequest(cancelled))
+	cancel()
+	require.Eventually(t, cancelled.Cancelled, time.Second, 10*time.Millisecond)
+
+	alive, _ := newTestRequest(context.Background(), "alive")
+	require.NoError(t, pool.AddRequest(alive))
+	pool.Start()
+
+	require.Same(t, alive, takeRequest(t, pool))
+	_, ok := <-cancelledChan
+	require.False(t, ok)
+}
+
+func TestStopClosesQueuedRequests(t *testing.T) {
+	pool := NewRequestPool(10, nil)
+	request, resultChan := newTestRequest(context.Background(), "user")
+	require.NoError(t, pool.AddRequest(request))
+	require.NoError(t, pool.Health())
+
+	pool.Stop()
+	require.ErrorIs(t, pool.Health(), ErrPoolStopped)
+	_, ok := <-resultChan
+	require.False(t, ok)
+
+	another, _ := newTestRequest(context.Background(), "another")
+	require.ErrorIs(t, pool.AddRequest(another), ErrPoolStopped)
+}
+
+func TestDrainWaitsForAcceptedRequests(t *testing.T) {
+	pool := NewRequestPool(10, nil)
+	defer pool.Stop()
+	first, firstChan := newTestRequest(context.Background(), "first")
+	second, _ := newTestRequest(context.Background(), "second")
+	require.NoError(t, pool.AddRequest(first))
+	require.NoError(t, pool.AddRequest(second))
+	pool.Start()
+
+	// 排空之后不再接受新的请求, 已经接受的请求继续分发
+	drainedC := pool.Drain()
+	late, _ := newTestRequest(context.Background(), "late")
+	require.ErrorIs(t, pool.AddRequest(late), ErrPoolDraining)
+
+	takeRequest(t, pool).Reply(&pb.RpcMessage{Type: pb.RpcMessageType_AuthReply})
+	require.NotNil(t, <-firstChan)
+	select {
+	case <-drainedC:
+		t.Fatal("drained before all accepted requests finished")
+	default:
+	}
+
+	takeRequest(t, pool).Close()
+	select {
+	case <-drainedC:
+	case <-time.After(time.Second):
+		t.Fatal("not drained after all accepted requests finished")
+	}
+	require.Equal(t, drainedC, pool.Drain())
+}
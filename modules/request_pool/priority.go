@@ -0,0 +1,48 @@
+package request_pool
+
+// Priority 请求的优先级, 数值越小优先级越高
+type Priority int
+
+const (
+	PriorityEmergency Priority = iota // 紧急用户, 最先被处理
+	PriorityOperator                  // 运维人员
+	PriorityNormal                    // 普通用户
+)
+
+// NumPriorities 优先级的数量, 每一个优先级都有自己的队列
+const NumPriorities = int(PriorityNormal) + 1
+
+// String 优先级的名称
+func (p Priority) String() string {
+	switch p {
+	case PriorityEmergency:
+		return "emergency"
+	case PriorityOperator:
+		return "operator"
+	case PriorityNormal:
+		return "normal"
+	default:
+		return "unknown"
+	}
+}
+
+// Classifier 根据用户 id 决定请求的优先级
+type Classifier func(userId string) Priority
+
+// NewUserListClassifier 根据配置之中的用户列表决定优先级, 不在列表之中的用户为普通优先级
+func NewUserListClassifier(emergencyUsers []string, operatorUsers []string) Classifier {
+	priorities := make(map[string]Priority, len(emergencyUsers)+len(operatorUsers))
+	for _, userId := range operatorUsers {
+		priorities[userId] = PriorityOperator
+	}
+	// 同时出现在两个列表之中的用户按照更高的优先级处理
+	for _, userId := range emergencyUsers {
+		priorities[userId] = PriorityEmergency
+	}
+	return func(userId string) Priority {
+		if priority, ok := priorities[userId]; ok {
+			return priority
+		}
+		return PriorityNormal
+	}
+}
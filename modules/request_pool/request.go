@@ -0,0 +1,216 @@
+package request_pool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"google.golang.org/protobuf/proto"
+	"sync"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// waiter 等待请求结果的调用者
+type waiter struct {
+	// ctx 调用者的上下文
+	ctx context.Context
+
+	// responseChan 结果返回的 channel
+	responseChan chan *pb.RpcMessage
+}
+
+// Request 请求, 同一个用户的并发请求会合并成为一个请求, 结果会返回给所有的等待者
+type Request struct {
+	// Ctx 这一轮请求的上下文, 所有的等待者都离开之后会被取消
+	Ctx context.Context
+
+	// UserId 请求所对应的用户, 用于合并重复的请求
+	UserId string
+
+	// RequestId 请求的唯一标识, 也是共识之中的请求 id, 在加入请求池的时候生成,
+	// 只有服务端自己 (例如仿真器) 可以预先指定, 不能使用客户端发送的请求 id
+	RequestId string
+
+	// ClientRequestId 客户端指定的请求 id, 只用于同一个用户重新获取结果
+	ClientRequestId string
+
+	// Priority 请求的优先级, 在加入请求池的时候确定
+	Priority Priority
+
+	// 请求的内容
+	Message *pb.RpcMessage
+
+	// EnqueueTime 进入请求池的时间
+	EnqueueTime time.Time
+
+	// cancel 取消 Ctx
+	cancel context.CancelFunc
+
+	// mutex 保护 waiters, activeWaiters 以及 detached
+	mutex sync.Mutex
+
+	// waiters 所有等待结果的调用者
+	waiters []*waiter
+
+	// activeWaiters 还没有离开的调用者的数量
+	activeWaiters int
+
+	// detached 已经开始返回结果, 不再接受新的等待者
+	detached bool
+
+	// pool 请求所在的请求池
+	pool *RequestPool
+
+	// clientRequestIds 这个请求以及合并到这个请求之中的请求的客户端请求 id, 由请求池的锁保护
+	clientRequestIds []string
+
+	// doneC 请求结束之后关闭, 用于停止监听等待者的协程
+	doneC chan struct{}
+
+	// closeOnce 保证结果的 channel 只会被关闭一次
+	closeOnce sync.Once
+}
+
+// NewRequest 创建新的请求, ctx 为调用者的上下文, 调用者离开之后可以放弃这个请求
+func NewRequest(ctx context.Context, userId string, message *pb.RpcMessage, resultChan chan *pb.RpcMessage) *Request {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	roundCtx, cancel := context.WithCancel(context.Background())
+	return &Request{
+		Ctx:     roundCtx,
+		UserId:  userId,
+		Message: message,
+		cancel:  cancel,
+		waiters: []*waiter{{ctx: ctx, responseChan: resultChan}},
+		doneC:   make(chan struct{}),
+	}
+}
+
+// newRecoveredRequest 根据日志之中的记录恢复重启之前没有结束的请求, 恢复的请求没有等待者, 结果只会进入结果缓存
+func newRecoveredRequest(record *journalRecord) (*Request, error) {
+	message := &pb.RpcMessage{}
+	if err := proto.Unmarshal(record.Message, message); err != nil {
+		return nil, err
+	}
+	roundCtx, cancel := context.WithCancel(context.Background())
+	return &Request{
+		Ctx:              roundCtx,
+		UserId:           record.UserId,
+		RequestId:        record.RequestId,
+		Message:          message,
+		cancel:           cancel,
+		clientRequestIds: record.ClientRequestIds,
+		doneC:            make(chan struct{}),
+	}, nil
+}
+
+// newRequestId 生成随机的请求 id
+func newRequestId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
+
+// clientKey 客户端请求 id 只在同一个用户之内有效, 不同用户可以使用相同的客户端请求 id
+func clientKey(userId, clientRequestId string) string {
+	return userId + "\x00" + clientRequestId
+}
+
+// watch 开始监听所有的等待者, 请求进入请求池之后调用
+func (r *Request) watch() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.activeWaiters = len(r.waiters)
+	for _, w := range r.waiters {
+		go r.watchWaiter(w)
+	}
+}
+
+// watchWaiter 等待者离开之后进行计数, 所有的等待者都离开了就取消这一轮请求
+func (r *Request) watchWaiter(w *waiter) {
+	select {
+	case <-w.ctx.Done():
+		r.mutex.Lock()
+		r.activeWaiters--
+		allLeft := r.activeWaiters == 0
+		r.mutex.Unlock()
+		if allLeft {
+			r.cancel()
+		}
+	case <-r.doneC:
+	}
+}
+
+// merge 将重复请求的等待者合并到当前请求之中, 当前请求已经开始返回结果或者已经被取消的时候返回 false
+func (r *Request) merge(other *Request) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.detached || r.Cancelled() {
+		return false
+	}
+	for _, w := range other.waiters {
+		r.waiters = append(r.waiters, w)
+		r.activeWaiters++
+		go r.watchWaiter(w)
+	}
+	return true
+}
+
+// detach 不再接受新的等待者, 并且从请求池之中移除, result 为空表示请求被放弃了
+func (r *Request) detach(result *pb.RpcMessage) {
+	r.mutex.Lock()
+	if r.detached {
+		r.mutex.Unlock()
+		return
+	}
+	r.detached = true
+	r.mutex.Unlock()
+	if r.pool != nil {
+		r.pool.complete(r, result)
+	}
+}
+
+// Cancelled 判断是否所有的调用者都已经取消了请求
+func (r *Request) Cancelled() bool {
+	select {
+	case <-r.Ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// Reply 将结果返回给所有的等待者, 已经离开的等待者会被跳过, 返回值表示结果是否至少送达了一个等待者
+func (r *Request) Reply(message *pb.RpcMessage) bool {
+	r.detach(message)
+	r.mutex.Lock()
+	waiters := append([]*waiter(nil), r.waiters...)
+	r.mutex.Unlock()
+
+	delivered := false
+	for _, w := range waiters {
+		select {
+		case w.responseChan <- message:
+			delivered = true
+		case <-w.ctx.Done():
+		}
+	}
+	return delivered
+}
+
+// Close 关闭所有等待者的结果 channel, 只能由结果的生产者 (共识模块或者请求池) 调用, 重复调用是安全的
+func (r *Request) Close() {
+	r.detach(nil)
+	r.closeOnce.Do(func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for _, w := range r.waiters {
+			close(w.responseChan)
+		}
+		close(r.doneC)
+		r.cancel()
+	})
+}
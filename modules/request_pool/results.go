@@ -0,0 +1,89 @@
+package request_pool
+
+import (
+	"sync"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// DefaultResultGracePeriod 结果默认的保留时间
+const DefaultResultGracePeriod = 5 * time.Minute
+
+// cachedResult 缓存的结果
+type cachedResult struct {
+	userId     string
+	message    *pb.RpcMessage
+	finishTime time.Time
+}
+
+// ResultCache 在宽限期之内保存请求的结果, 断开连接的调用者可以通过请求 id 或者客户端请求 id 重新获取结果
+type ResultCache struct {
+	mutex       sync.Mutex
+	gracePeriod time.Duration
+	results     map[string]*cachedResult
+	clientIds   map[string]string // clientKey -> 请求 id
+}
+
+// NewResultCache 创建结果缓存
+func NewResultCache(gracePeriod time.Duration) *ResultCache {
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultResultGracePeriod
+	}
+	return &ResultCache{
+		gracePeriod: gracePeriod,
+		results:     make(map[string]*cachedResult),
+		clientIds:   make(map[string]string),
+	}
+}
+
+// put 保存请求的结果, clientRequestIds 为同一个用户可以用来获取结果的客户端请求 id
+func (rc *ResultCache) put(requestId, userId string, clientRequestIds []string, message *pb.RpcMessage, finishTime time.Time) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.prune()
+	if time.Since(finishTime) > rc.gracePeriod {
+		return
+	}
+	rc.results[requestId] = &cachedResult{
+		userId:     userId,
+		message:    message,
+		finishTime: finishTime,
+	}
+	for _, clientRequestId := range clientRequestIds {
+		rc.clientIds[clientKey(userId, clientRequestId)] = requestId
+	}
+}
+
+// get 获取宽限期之内的结果以及结果所属的用户
+func (rc *ResultCache) get(requestId string) (*pb.RpcMessage, string, bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	rc.prune()
+	result, ok := rc.results[requestId]
+	if !ok {
+		return nil, "", false
+	}
+	return result.message, result.userId, true
+}
+
+// resolve 获取用户的客户端请求 id 所对应的请求 id
+func (rc *ResultCache) resolve(userId, clientRequestId string) (string, bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	requestId, ok := rc.clientIds[clientKey(userId, clientRequestId)]
+	return requestId, ok
+}
+
+// prune 删除超过宽限期的结果
+func (rc *ResultCache) prune() {
+	for requestId, result := range rc.results {
+		if time.Since(result.finishTime) > rc.gracePeriod {
+			delete(rc.results, requestId)
+		}
+	}
+	for key, requestId := range rc.clientIds {
+		if _, ok := rc.results[requestId]; !ok {
+			delete(rc.clientIds, key)
+		}
+	}
+}
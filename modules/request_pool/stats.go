@@ -0,0 +1,74 @@
+package request_pool
+
+import (
+	"time"
+)
+
+// queueCounters 每个优先级队列的计数
+type queueCounters struct {
+	accepted   uint64
+	rejected   uint64
+	dispatched uint64
+	totalWait  time.Duration
+	maxWait    time.Duration
+}
+
+// QueueStats 某个优先级队列的统计信息
+type QueueStats struct {
+	Priority   Priority      // 优先级
+	Depth      int           // 当前队列长度
+	Accepted   uint64        // 进入队列的请求数量
+	Rejected   uint64        // 因为队列满了被拒绝的请求数量
+	Dispatched uint64        // 交给共识模块的请求数量
+	AvgWait    time.Duration // 平均排队时间
+	MaxWait    time.Duration // 最长排队时间
+}
+
+// PoolStats 请求池的统计信息
+type PoolStats struct {
+	Queues        []QueueStats // 每个优先级队列的统计信息
+	Pending       int          // 排队中以及共识中的不同用户的数量
+	Deduplicated  uint64       // 被合并的请求数量
+	JournalErrors uint64       // 写入请求日志失败的次数
+}
+
+// recordDispatch 记录请求的排队时间
+func (rp *RequestPool) recordDispatch(request *Request) {
+	wait := time.Since(request.EnqueueTime)
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	counters := &rp.stats[request.Priority]
+	counters.dispatched++
+	counters.totalWait += wait
+	if wait > counters.maxWait {
+		counters.maxWait = wait
+	}
+}
+
+// Stats 返回请求池的统计信息
+func (rp *RequestPool) Stats() *PoolStats {
+	rp.mutex.Lock()
+	defer rp.mutex.Unlock()
+	stats := &PoolStats{
+		Queues:        make([]QueueStats, 0, NumPriorities),
+		Pending:       len(rp.pending),
+		Deduplicated:  rp.deduplicated,
+		JournalErrors: rp.journalErrors,
+	}
+	for priority := range rp.queues {
+		counters := rp.stats[priority]
+		queueStats := QueueStats{
+			Priority:   Priority(priority),
+			Depth:      len(rp.queues[priority]),
+			Accepted:   counters.accepted,
+			Rejected:   counters.rejected,
+			Dispatched: counters.dispatched,
+			MaxWait:    counters.maxWait,
+		}
+		if counters.dispatched > 0 {
+			queueStats.AvgWait = counters.totalWait / time.Duration(counters.dispatched)
+		}
+		stats.Queues = append(stats.Queues, queueStats)
+	}
+	return stats
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"strings"
+	"zhanghefan123/security/localconf"
+)
+
+const (
+	// TLSModeDisable 不使用 tls
+	TLSModeDisable = "disable"
+	// TLSModeOneWay 只有服务端提供证书
+	TLSModeOneWay = "oneway"
+	// TLSModeTwoWay 客户端也需要提供由 ca_file 签发的证书
+	TLSModeTwoWay = "twoway"
+)
+
+// ErrUnknownTLSMode 不支持的 rpc.tls.mode
+var ErrUnknownTLSMode = errors.New("unknown rpc.tls.mode, expect disable, oneway or twoway")
+
+// serverCredentialOptions 根据 rpc.tls 创建 grpc 的传输层凭证, mode 为空或者 disable 的时候不使用 tls
+func serverCredentialOptions() ([]grpc.ServerOption, error) {
+	tlsConfig := localconf.ChainMakerConfig.RpcConfig.TLSConfig
+	mode := strings.ToLower(tlsConfig.Mode)
+	if mode == "" || mode == TLSModeDisable {
+		return nil, nil
+	}
+	if mode != TLSModeOneWay && mode != TLSModeTwoWay {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTLSMode, tlsConfig.Mode)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.PrivKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load rpc tls certificate failed, %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if mode == TLSModeTwoWay {
+		caPem, err := ioutil.ReadFile(tlsConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read rpc tls ca file failed, %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificate found in rpc tls ca file %s", tlsConfig.CAFile)
+		}
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return []grpc.ServerOption{grpc.Creds(credentials.NewTLS(config))}, nil
+}
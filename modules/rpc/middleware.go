@@ -2,15 +2,25 @@ package rpc
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	"net"
+	"strings"
+	"zhanghefan123/security/localconf"
 )
 
 const (
 	//UNKNOWN unknown string
 	UNKNOWN = "unknown"
+	// AdminTokenKey 管理凭证在 metadata 之中的键
+	AdminTokenKey = "admin-token"
+	// adminServicePrefix 管理服务所有方法的前缀
+	adminServicePrefix = "/protos.AdminService/"
 )
 
 // GetClientAddr 进行客户端的地址的获取
@@ -45,3 +55,26 @@ func LoggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnarySe
 	log.Debugf("[%s] call gRPC method: %s, resp detail: %+v", addr, info.FullMethod, resp)
 	return resp, err
 }
+
+// AdminAuthInterceptor 管理凭证拦截器, 只对 AdminService 的方法进行校验
+func AdminAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !strings.HasPrefix(info.FullMethod, adminServicePrefix) {
+		return handler(ctx, req)
+	}
+
+	// 每次调用的时候读取配置, 这样修改凭证之后不需要重启, 重新加载为空或者占位符凭证的时候拒绝所有的调用
+	token := localconf.AdminToken()
+	if localconf.ValidateAdminToken(token) != nil {
+		return nil, status.Error(codes.Unauthenticated, "admin credential not configured")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AdminTokenKey)
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing admin credential")
+	}
+	if subtle.ConstantTimeCompare([]byte(values[0]), []byte(token)) != 1 {
+		log.Warnf("[%s] call admin method %s with invalid credential", GetClientAddr(ctx), info.FullMethod)
+		return nil, status.Error(codes.Unauthenticated, "invalid admin credential")
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"zhanghefan123/security/localconf"
+)
+
+// withAdminToken 临时替换配置之中的管理凭证
+func withAdminToken(t *testing.T, token string) {
+	origin := localconf.ChainMakerConfig
+	t.Cleanup(func() { localconf.ChainMakerConfig = origin })
+	localconf.ChainMakerConfig = &localconf.CMConfig{}
+	localconf.ChainMakerConfig.RpcConfig.AdminConfig.Enabled = true
+	localconf.ChainMakerConfig.RpcConfig.AdminConfig.Token = token
+}
+
+// callAdmin 经过拦截器调用 method, 返回错误码以及处理函数是否被调用
+func callAdmin(ctx context.Context, method string) (codes.Code, bool) {
+	called := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	_, err := AdminAuthInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	return status.Code(err), called
+}
+
+// tokenContext 在 metadata 之中携带管理凭证
+func tokenContext(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AdminTokenKey, token))
+}
+
+func TestAdminAuthInterceptor(t *testing.T) {
+	withAdminToken(t, "secret")
+	const method = adminServicePrefix + "ExpireUserRound"
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected codes.Code
+	}{
+		{"no metadata", context.Background(), codes.Unauthenticated},
+		{"missing token", metadata.NewIncomingContext(context.Background(), metadata.Pairs("chain-id", "chain1")), codes.Unauthenticated},
+		{"wrong token", tokenContext("secreT"), codes.Unauthenticated},
+		{"placeholder token", tokenContext(localconf.PlaceholderAdminToken), codes.Unauthenticated},
+		{"correct token", tokenContext("secret"), codes.OK},
+	}
+	for _, tt := range tests {
+		code, called := callAdmin(tt.ctx, method)
+		require.Equal(t, tt.expected, code, tt.name)
+		require.Equal(t, tt.expected == codes.OK, called, tt.name)
+	}
+
+	// 其他服务的方法不需要管理凭证
+	code, called := callAdmin(context.Background(), "/protos.AuthenticationService/ReplyToAuthenticationRequest")
+	require.Equal(t, codes.OK, code)
+	require.True(t, called)
+}
+
+func TestAdminAuthInterceptorUnusableToken(t *testing.T) {
+	// 配置之中的凭证为空或者是占位符的时候, 携带相同的凭证也不能调用
+	for _, token := range []string{"", localconf.PlaceholderAdminToken} {
+		withAdminToken(t, token)
+		code, called := callAdmin(tokenContext(token), adminServicePrefix+"Shutdown")
+		require.Equal(t, codes.Unauthenticated, code, token)
+		require.False(t, called, token)
+	}
+}
@@ -2,12 +2,20 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"zhanghefan123/security/localconf"
 	"zhanghefan123/security/logger"
+	"zhanghefan123/security/modules/manager"
 	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
 	"zhanghefan123/security/modules/rpc/services"
 )
@@ -15,36 +23,54 @@ import (
 // 获取 RPC_SERVER 的日志记录器
 var log = logger.GetLogger(logger.MODULE_RPC_SERVER)
 
+// ErrStopTimeout 正在处理的请求没有在规定的时间之内返回, 连接被强制关闭
+var ErrStopTimeout = errors.New("rpc server stop timeout, connections closed forcibly")
+
 type RPCServer struct {
-	grpcServer     *grpc.Server       // grpcServer google 官方
-	log            *logger.CMLogger   // log 日志记录器
-	ctx            context.Context    // context 上下文
-	cancelFunction context.CancelFunc // cancelContext 对应的取消函数
-	isShutDown     bool               // isShutDown 是否已经关闭
+	grpcServer     *grpc.Server          // grpcServer google 官方
+	chainManager   *manager.ChainManager // chainManager 链管理器, 服务通过它访问区块链和网络
+	log            *logger.CMLogger      // log 日志记录器
+	ctx            context.Context       // context 上下文
+	cancelFunction context.CancelFunc    // cancelContext 对应的取消函数
+	isShutDown     bool                  // isShutDown 是否已经关闭
+	shutdownC      chan string           // shutdownC 收到关闭请求的时候写入关闭的原因
+	shutdownOnce   sync.Once             // shutdownOnce 保证关闭请求只会被发送一次
+	draining       int32                 // draining 不为 0 的时候不再接受新的请求
 }
 
-func NewRPCServer() (*RPCServer, error) {
+func NewRPCServer(chainManager *manager.ChainManager) (*RPCServer, error) {
+	server := &RPCServer{
+		chainManager: chainManager,
+		log:          logger.GetLogger(logger.MODULE_RPC),
+		shutdownC:    make(chan string, 1),
+	}
+
 	// 1. grpcServer 是内部实际提供服务的
-	grpcServer, err := newGrpc()
+	grpcServer, err := newGrpc(server.DrainInterceptor)
 	if err != nil {
 		fmt.Printf("create grpc server failed, err:%v\n", err)
 		return nil, err
-	} else {
-		return &RPCServer{
-			grpcServer: grpcServer,
-			log:        logger.GetLogger(logger.MODULE_RPC),
-		}, nil
 	}
+	server.grpcServer = grpcServer
+	return server, nil
 }
 
 // 创建一个新的 RPCServer 内部实现
-func newGrpc() (*grpc.Server, error) {
-	// 仅仅简单的添加了一个日志拦截器
+func newGrpc(drainInterceptor grpc.UnaryServerInterceptor) (*grpc.Server, error) {
+	// 日志拦截器, 排空拦截器以及管理凭证拦截器
 	opts := []grpc.ServerOption{
 		grpc_middleware.WithUnaryServerChain(
 			LoggingInterceptor,
+			drainInterceptor,
+			AdminAuthInterceptor,
 		),
 	}
+	// 根据 rpc.tls 决定是否使用 tls
+	credentialOpts, err := serverCredentialOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, credentialOpts...)
 	server := grpc.NewServer(opts...)
 	return server, nil
 }
@@ -55,7 +81,10 @@ func (s *RPCServer) Start() error {
 	s.isShutDown = false
 
 	// 1. 注册 grpc handler
-	err = s.RegisterHandler()
+	if err = s.RegisterHandler(); err != nil {
+		s.log.Errorf("register rpc handler failed, %v", err)
+		return err
+	}
 
 	// 2. 在指定端口上进行监听
 	host := localconf.ChainMakerConfig.RpcConfig.Host
@@ -64,6 +93,7 @@ func (s *RPCServer) Start() error {
 	conn, err := net.Listen("tcp", endPoint)
 	if err != nil {
 		fmt.Printf("create rpc server listener failed, err:%v\n", err)
+		return err
 	}
 
 	// 3. 开始提供服务
@@ -76,14 +106,78 @@ func (s *RPCServer) Start() error {
 
 // RegisterHandler 注册处理器
 func (s *RPCServer) RegisterHandler() error {
-	pb.RegisterAuthenticationServiceServer(s.grpcServer, &services.AuthenticationService{})
+	pb.RegisterAuthenticationServiceServer(s.grpcServer, &services.AuthenticationService{
+		Chains: s.chainManager,
+	})
+
+	// 管理服务需要单独的凭证, 没有开启的时候不进行注册, 凭证为空或者是示例配置之中的占位符的时候拒绝启动
+	adminConfig := localconf.ChainMakerConfig.RpcConfig.AdminConfig
+	if err := adminConfig.Validate(); err != nil {
+		return err
+	}
+	if adminConfig.Enabled {
+		pb.RegisterAdminServiceServer(s.grpcServer, &services.AdminService{
+			Net:          s.chainManager.Net(),
+			Chains:       s.chainManager,
+			ShutdownFunc: s.RequestShutdown,
+			ReloadFunc:   s.chainManager.Reload,
+		})
+		s.log.Infof("admin service registered")
+	}
 	return nil
 }
 
-// Stop 停止 RPCServer
-func (s *RPCServer) Stop() {
+// RequestShutdown 请求关闭节点, 只有第一次请求会生效
+func (s *RPCServer) RequestShutdown(reason string) {
+	s.shutdownOnce.Do(func() {
+		s.log.Infof("shutdown requested, reason: %s", reason)
+		s.shutdownC <- reason
+	})
+}
+
+// ShutdownC 返回关闭请求的 channel, 启动程序监听它来进行优雅关闭
+func (s *RPCServer) ShutdownC() <-chan string {
+	return s.shutdownC
+}
+
+// Drain 不再接受新的请求, 管理服务除外, 正在处理的请求不受影响
+func (s *RPCServer) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+	s.log.Infof("rpc server draining, new requests are rejected")
+}
+
+// DrainInterceptor 排空拦截器, 排空之后拒绝新的请求, 让调用者转向其他节点
+func (s *RPCServer) DrainInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if atomic.LoadInt32(&s.draining) != 0 && !strings.HasPrefix(info.FullMethod, adminServicePrefix) {
+		return nil, status.Error(codes.Unavailable, "node is shutting down")
+	}
+	return handler(ctx, req)
+}
+
+// Stop 停止 RPCServer, 等待正在处理的请求返回, 超过 timeout 之后强制关闭所有连接, timeout 为 0 的时候一直等待
+func (s *RPCServer) Stop(timeout time.Duration) error {
 	s.isShutDown = true
-	s.cancelFunction()
-	s.grpcServer.GracefulStop()
-	s.log.Info("rpc server stop")
+	if s.cancelFunction != nil {
+		s.cancelFunction()
+	}
+	if timeout <= 0 {
+		s.grpcServer.GracefulStop()
+		s.log.Info("rpc server stop")
+		return nil
+	}
+	stoppedC := make(chan struct{})
+	go func() {
+		s.grpcServer.GracefulStop()
+		close(stoppedC)
+	}()
+	select {
+	case <-stoppedC:
+		s.log.Info("rpc server stop")
+		return nil
+	case <-time.After(timeout):
+		s.grpcServer.Stop()
+		<-stoppedC
+		s.log.Warnf("rpc server stop forcibly after %s", timeout)
+		return ErrStopTimeout
+	}
 }
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+	"zhanghefan123/security/localconf"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/variables"
+	"zhanghefan123/security/modules/manager"
+	"zhanghefan123/security/modules/net"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/network/net-libp2p/libp2pnet"
+	"zhanghefan123/security/protocol"
+)
+
+// NetAdmin 管理服务所需要的网络模块的能力, 由 LibP2pNet 进行实现
+type NetAdmin interface {
+	GetNodeUid() string
+	PeerStates() ([]*libp2pnet.PeerState, error)
+	AddBlackAddress(addr string) error
+	RemoveBlackAddress(addr string) error
+	AddBlackPeerId(pid string) error
+	RemoveBlackPeerId(pid string) error
+}
+
+// ConsensusAdmin 管理服务所需要的共识模块的能力, 由 pbft 共识进行实现
+type ConsensusAdmin interface {
+	DumpUserState(userId string) ([]byte, error)
+	ExpireUserRound(userId string) error
+}
+
+// FaultAdmin 管理服务所需要的网络服务的故障注入能力, 由 modules/net 的 NetService 进行实现
+type FaultAdmin interface {
+	FaultInjector() *net.FaultInjector
+}
+
+// StatsAdmin 管理服务所需要的网络服务的消息统计能力, 由 modules/net 的 NetService 进行实现
+type StatsAdmin interface {
+	MsgStats() []net.MsgStat
+}
+
+// AdminService 管理服务, 用于查看和控制正在运行的节点, 只有携带管理凭证的调用者才能访问
+type AdminService struct {
+	pb.UnimplementedAdminServiceServer
+	Net          protocol.Net                          // 节点的网络
+	Chains       ChainResolver                         // 节点的区块链, 根据请求的链 id 进行路由
+	ShutdownFunc func(reason string)                   // 触发节点的优雅关闭
+	ReloadFunc   func() (*manager.ReloadReport, error) // 重新加载配置文件
+}
+
+// netAdmin 获取网络模块的管理能力
+func (admin *AdminService) netAdmin() (NetAdmin, error) {
+	netAdmin, ok := admin.Net.(NetAdmin)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "net provider does not support admin operations")
+	}
+	return netAdmin, nil
+}
+
+// consensusAdmin 获取共识模块的管理能力
+func (admin *AdminService) consensusAdmin(ctx context.Context) (ConsensusAdmin, error) {
+	chain, err := resolveBlockchain(ctx, admin.Chains)
+	if err != nil {
+		return nil, err
+	}
+	if chain.Consensus() == nil {
+		return nil, status.Error(codes.Unavailable, "consensus is not initialized")
+	}
+	consensusAdmin, ok := chain.Consensus().(ConsensusAdmin)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "consensus type does not support admin operations")
+	}
+	return consensusAdmin, nil
+}
+
+// faultInjector 获取调用者指定的链的网络服务的故障注入过滤器
+func (admin *AdminService) faultInjector(ctx context.Context) (*net.FaultInjector, error) {
+	chain, err := resolveBlockchain(ctx, admin.Chains)
+	if err != nil {
+		return nil, err
+	}
+	if chain.NetService() == nil {
+		return nil, status.Error(codes.Unavailable, "net service is not initialized")
+	}
+	faultAdmin, ok := chain.NetService().(FaultAdmin)
+	if !ok || faultAdmin.FaultInjector() == nil {
+		return nil, status.Error(codes.Unimplemented, "net service does not support fault injection")
+	}
+	return faultAdmin.FaultInjector(), nil
+}
+
+// statsAdmin 获取调用者指定的链的网络服务的消息统计能力
+func (admin *AdminService) statsAdmin(ctx context.Context) (StatsAdmin, error) {
+	chain, err := resolveBlockchain(ctx, admin.Chains)
+	if err != nil {
+		return nil, err
+	}
+	if chain.NetService() == nil {
+		return nil, status.Error(codes.Unavailable, "net service is not initialized")
+	}
+	statsAdmin, ok := chain.NetService().(StatsAdmin)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "net service does not support msg stats")
+	}
+	return statsAdmin, nil
+}
+
+// ListPeers 列出所有已知的节点以及它们的连接状态
+func (admin *AdminService) ListPeers(ctx context.Context, in *pb.ListPeersRequest) (*pb.ListPeersReply, error) {
+	netAdmin, err := admin.netAdmin()
+	if err != nil {
+		return nil, err
+	}
+	peerStates, err := netAdmin.PeerStates()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	reply := &pb.ListPeersReply{
+		LocalPeerId: netAdmin.GetNodeUid(),
+		Peers:       make([]*pb.PeerInfo, 0, len(peerStates)),
+	}
+	for _, peerState := range peerStates {
+		state := pb.PeerConnectionState_Disconnected
+		if peerState.Blacklisted {
+			state = pb.PeerConnectionState_Blacklisted
+		} else if peerState.Connected {
+			state = pb.PeerConnectionState_Connected
+		}
+		reply.Peers = append(reply.Peers, &pb.PeerInfo{
+			PeerId:          peerState.PeerId,
+			Addresses:       peerState.Addresses,
+			State:           state,
+			ConnectionCount: int32(peerState.ConnCount),
+			IsSeed:          peerState.IsSeed,
+		})
+	}
+	return reply, nil
+}
+
+// AddBlackList 添加黑名单, 黑名单之中的节点已经建立的连接会被断开
+func (admin *AdminService) AddBlackList(ctx context.Context, in *pb.BlackListRequest) (*pb.AdminReply, error) {
+	netAdmin, err := admin.netAdmin()
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range in.Addresses {
+		if err = netAdmin.AddBlackAddress(address); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "add black address [%s] failed, %v", address, err)
+		}
+	}
+	for _, peerId := range in.PeerIds {
+		if err = netAdmin.AddBlackPeerId(peerId); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "add black peer id [%s] failed, %v", peerId, err)
+		}
+	}
+	return &pb.AdminReply{
+		Message: fmt.Sprintf("%d addresses and %d peer ids added to blacklist", len(in.Addresses), len(in.PeerIds)),
+	}, nil
+}
+
+// RemoveBlackList 移除黑名单
+func (admin *AdminService) RemoveBlackList(ctx context.Context, in *pb.BlackListRequest) (*pb.AdminReply, error) {
+	netAdmin, err := admin.netAdmin()
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range in.Addresses {
+		if err = netAdmin.RemoveBlackAddress(address); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "remove black address [%s] failed, %v", address, err)
+		}
+	}
+	for _, peerId := range in.PeerIds {
+		if err = netAdmin.RemoveBlackPeerId(peerId); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "remove black peer id [%s] failed, %v", peerId, err)
+		}
+	}
+	return &pb.AdminReply{
+		Message: fmt.Sprintf("%d addresses and %d peer ids removed from blacklist", len(in.Addresses), len(in.PeerIds)),
+	}, nil
+}
+
+// DumpUserState 导出用户在 pbft GlobalState 之中的共识状态
+func (admin *AdminService) DumpUserState(ctx context.Context, in *pb.UserStateRequest) (*pb.UserStateReply, error) {
+	consensusAdmin, err := admin.consensusAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	state, err := consensusAdmin.DumpUserState(in.UserId)
+	if err != nil {
+		return nil, consensusError(err)
+	}
+	return &pb.UserStateReply{
+		UserId: in.UserId,
+		State:  state,
+	}, nil
+}
+
+// ExpireUserRound 强制结束用户正在进行的一轮共识, 等待结果的调用者会收到 ConsensusTimeout
+func (admin *AdminService) ExpireUserRound(ctx context.Context, in *pb.UserStateRequest) (*pb.AdminReply, error) {
+	consensusAdmin, err := admin.consensusAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = consensusAdmin.ExpireUserRound(in.UserId); err != nil {
+		return nil, consensusError(err)
+	}
+	return &pb.AdminReply{
+		Message: fmt.Sprintf("round of user [%s] expired", in.UserId),
+	}, nil
+}
+
+// SetLogLevel 修改某个模块的日志级别
+func (admin *AdminService) SetLogLevel(ctx context.Context, in *pb.SetLogLevelRequest) (*pb.AdminReply, error) {
+	if err := localconf.SetLogLevel(in.Module, in.Level); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	module := in.Module
+	if module == "" {
+		module = "default"
+	}
+	return &pb.AdminReply{
+		Message: fmt.Sprintf("log level of [%s] set to %s", module, in.Level),
+	}, nil
+}
+
+// Shutdown 触发节点的优雅关闭, 在关闭开始之前就会返回
+func (admin *AdminService) Shutdown(ctx context.Context, in *pb.ShutdownRequest) (*pb.AdminReply, error) {
+	if admin.ShutdownFunc == nil {
+		return nil, status.Error(codes.Unimplemented, "shutdown is not supported")
+	}
+	admin.ShutdownFunc(in.Reason)
+	return &pb.AdminReply{
+		Message: "shutdown triggered",
+	}, nil
+}
+
+// GetPoolStats 查看请求池每个优先级队列的长度以及排队时间
+func (admin *AdminService) GetPoolStats(ctx context.Context, in *pb.PoolStatsRequest) (*pb.PoolStatsReply, error) {
+	chain, err := resolveBlockchain(ctx, admin.Chains)
+	if err != nil {
+		return nil, err
+	}
+	if chain.RequestPool == nil {
+		return nil, status.Error(codes.Unavailable, "request pool not initialized")
+	}
+	stats := chain.RequestPool.Stats()
+	reply := &pb.PoolStatsReply{
+		Queues:        make([]*pb.QueueStats, 0, len(stats.Queues)),
+		Pending:       int32(stats.Pending),
+		Deduplicated:  stats.Deduplicated,
+		JournalErrors: stats.JournalErrors,
+	}
+	for _, queue := range stats.Queues {
+		reply.Queues = append(reply.Queues, &pb.QueueStats{
+			Priority:      queue.Priority.String(),
+			Depth:         int32(queue.Depth),
+			Accepted:      queue.Accepted,
+			Rejected:      queue.Rejected,
+			Dispatched:    queue.Dispatched,
+			AvgWaitMillis: queue.AvgWait.Milliseconds(),
+			MaxWaitMillis: queue.MaxWait.Milliseconds(),
+		})
+	}
+	return reply, nil
+}
+
+// GetHealth 查看链之中每个模块的生命周期阶段以及健康状态
+func (admin *AdminService) GetHealth(ctx context.Context, in *pb.HealthRequest) (*pb.HealthReply, error) {
+	chain, err := resolveBlockchain(ctx, admin.Chains)
+	if err != nil {
+		return nil, err
+	}
+	healths := chain.Health()
+	reply := &pb.HealthReply{
+		ChainId: chain.ChainId(),
+		Healthy: true,
+		Modules: make([]*pb.ModuleHealth, 0, len(healths)),
+	}
+	for _, health := range healths {
+		moduleHealth := &pb.ModuleHealth{
+			Name:    health.Name,
+			State:   health.State.String(),
+			Healthy: health.Healthy,
+		}
+		if health.Err != nil {
+			moduleHealth.Error = health.Err.Error()
+		}
+		reply.Healthy = reply.Healthy && health.Healthy
+		reply.Modules = append(reply.Modules, moduleHealth)
+	}
+	return reply, nil
+}
+
+// ReloadConfig 重新加载配置文件, 返回已经生效的配置项以及需要重启节点才能生效的配置项
+func (admin *AdminService) ReloadConfig(ctx context.Context, in *pb.ReloadConfigRequest) (*pb.ReloadConfigReply, error) {
+	if admin.ReloadFunc == nil {
+		return nil, status.Error(codes.Unimplemented, "reload is not supported")
+	}
+	report, err := admin.ReloadFunc()
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "reload config failed, %v", err)
+	}
+	return &pb.ReloadConfigReply{
+		Applied:         report.Applied,
+		RestartRequired: report.RestartRequired,
+		Failed:          report.Failed,
+	}, nil
+}
+
+// GetNetFaults 查看网络服务的故障注入配置以及统计
+func (admin *AdminService) GetNetFaults(ctx context.Context, in *pb.GetNetFaultsRequest) (*pb.NetFaultsReply, error) {
+	injector, err := admin.faultInjector(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return netFaultsReply(injector), nil
+}
+
+// SetNetFaults 替换网络服务的故障注入配置, 例如在运行时隔离某个节点
+func (admin *AdminService) SetNetFaults(ctx context.Context, in *pb.SetNetFaultsRequest) (*pb.NetFaultsReply, error) {
+	injector, err := admin.faultInjector(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config, err := faultConfigFromPb(in.Faults)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err = injector.Update(config, in.RestartClock); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return netFaultsReply(injector), nil
+}
+
+// GetNetStats 查看网络服务启动之后发送以及接收的消息数量, 用于压测的时候统计每个节点的消息开销
+func (admin *AdminService) GetNetStats(ctx context.Context, in *pb.NetStatsRequest) (*pb.NetStatsReply, error) {
+	statsAdmin, err := admin.statsAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats := statsAdmin.MsgStats()
+	reply := &pb.NetStatsReply{Counts: make([]*pb.MsgCount, 0, len(stats))}
+	if admin.Net != nil {
+		reply.LocalPeerId = admin.Net.GetNodeUid()
+	}
+	for _, stat := range stats {
+		reply.Counts = append(reply.Counts, &pb.MsgCount{
+			Direction: string(stat.Direction),
+			MsgType:   stat.MsgType.String(),
+			Count:     stat.Count,
+		})
+	}
+	return reply, nil
+}
+
+// netFaultsReply 将故障注入过滤器的配置以及统计转换为 grpc 的返回值
+func netFaultsReply(injector *net.FaultInjector) *pb.NetFaultsReply {
+	config := injector.Config()
+	stats := injector.Stats()
+	faults := &pb.NetFaults{
+		Enabled:    config.Enabled,
+		Seed:       config.Seed,
+		Rules:      make([]*pb.FaultRule, 0, len(config.Rules)),
+		Partitions: make([]*pb.Partition, 0, len(config.Partitions)),
+	}
+	for _, rule := range config.Rules {
+		msgTypes := make([]string, 0, len(rule.MsgTypes))
+		for _, msgType := range rule.MsgTypes {
+			msgTypes = append(msgTypes, msgType.String())
+		}
+		faults.Rules = append(faults.Rules, &pb.FaultRule{
+			Direction:           string(rule.Direction),
+			Peers:               rule.Peers,
+			MsgTypes:            msgTypes,
+			DropRate:            rule.DropRate,
+			DelayMillis:         rule.Delay.Milliseconds(),
+			JitterMillis:        rule.Jitter.Milliseconds(),
+			DuplicateRate:       rule.DuplicateRate,
+			ReorderRate:         rule.ReorderRate,
+			ReorderWindowMillis: rule.ReorderWindow.Milliseconds(),
+		})
+	}
+	for _, partition := range config.Partitions {
+		faults.Partitions = append(faults.Partitions, &pb.Partition{
+			Nodes:       partition.Nodes,
+			StartMillis: partition.Start.Milliseconds(),
+			EndMillis:   partition.End.Milliseconds(),
+		})
+	}
+	return &pb.NetFaultsReply{
+		Faults:        faults,
+		ElapsedMillis: injector.Elapsed().Milliseconds(),
+		Dropped:       stats.Dropped,
+		Delayed:       stats.Delayed,
+		Duplicated:    stats.Duplicated,
+	}
+}
+
+// faultConfigFromPb 将 grpc 请求之中的故障注入配置转换为 net.FaultConfig, 为空的时候关闭故障注入
+func faultConfigFromPb(faults *pb.NetFaults) (net.FaultConfig, error) {
+	config := net.FaultConfig{}
+	if faults == nil {
+		return config, nil
+	}
+	config.Enabled = faults.Enabled
+	config.Seed = faults.Seed
+	for i, rule := range faults.Rules {
+		msgTypes, err := net.ParseMsgTypes(rule.MsgTypes)
+		if err != nil {
+			return config, fmt.Errorf("fault rule %d: %v", i, err)
+		}
+		config.Rules = append(config.Rules, net.FaultRule{
+			Direction:     net.Direction(rule.Direction),
+			Peers:         rule.Peers,
+			MsgTypes:      msgTypes,
+			DropRate:      rule.DropRate,
+			Delay:         time.Duration(rule.DelayMillis) * time.Millisecond,
+			Jitter:        time.Duration(rule.JitterMillis) * time.Millisecond,
+			DuplicateRate: rule.DuplicateRate,
+			ReorderRate:   rule.ReorderRate,
+			ReorderWindow: time.Duration(rule.ReorderWindowMillis) * time.Millisecond,
+		})
+	}
+	for _, partition := range faults.Partitions {
+		config.Partitions = append(config.Partitions, net.Partition{
+			Nodes: partition.Nodes,
+			Start: time.Duration(partition.StartMillis) * time.Millisecond,
+			End:   time.Duration(partition.EndMillis) * time.Millisecond,
+		})
+	}
+	return config, config.Validate()
+}
+
+// consensusError 将共识模块的错误转换为 grpc 的状态码
+func consensusError(err error) error {
+	if err == variables.ErrUserRoundNotFound {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
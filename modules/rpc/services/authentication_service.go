@@ -2,22 +2,44 @@ package services
 
 import (
 	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"zhanghefan123/security/modules/blockchain"
 	"zhanghefan123/security/modules/request_pool"
 	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/modules/session"
 	"zhanghefan123/security/modules/utils"
 )
 
+// RequestIdKey 响应头之中携带请求 id 的键, 调用者超时之后可以通过请求 id 获取结果
+const RequestIdKey = "request-id"
+
 // AuthenticationService 继承了 pb.UnimplementedAuthenticationServiceServer 然后需要进行相应的实现
 type AuthenticationService struct {
 	pb.UnimplementedAuthenticationServiceServer
-	Blockchain *blockchain.Blockchain
+	Chains ChainResolver // 根据请求的链 id 找到对应的区块链
 }
 
 // ReplyToAuthenticationRequest 进行了对请求的回复, 每次请求都会开启一个新的协程, 所以不用在里面再进行开启
 func (auth *AuthenticationService) ReplyToAuthenticationRequest(ctx context.Context, in *pb.AuthenticationRequest) (*pb.AuthenticationReply, error) {
-	// 将用户的请求存放到一个请求池之中，等待进行执行
-	finishChannel := make(chan *pb.RpcMessage)
+	chain, err := resolveBlockchain(ctx, auth.Chains)
+	if err != nil {
+		return nil, err
+	}
+	requestPool := chain.RequestPool
+
+	// 重试的调用者, 结果还在宽限期之内的时候直接返回
+	if in.RequestId != "" {
+		if result, requestStatus := requestPool.Result(in.RequestId, in.UserId); requestStatus == request_pool.RequestFinished {
+			return withSession(chain, replyFromMessage(result))
+		}
+	}
+
+	// 将用户的请求存放到一个请求池之中，等待进行执行, 缓冲为 1 保证共识模块写入结果的时候不会阻塞
+	finishChannel := make(chan *pb.RpcMessage, 1)
 
 	// 创建相应的 pb.RpcMessage
 	message := &pb.RpcMessage{
@@ -25,20 +47,122 @@ func (auth *AuthenticationService) ReplyToAuthenticationRequest(ctx context.Cont
 		Content: utils.MustMarshal(in),
 	}
 
-	// 创建并添加新的请求
-	newRequest := request_pool.NewRequest(message, finishChannel)
-	AddRequest(auth.Blockchain.RequestPool, newRequest)
+	// 创建并添加新的请求, 请求携带调用者的 ctx, 同一个用户的所有调用者都取消之后共识模块会放弃这一轮
+	// 客户端指定的请求 id 只用于这个用户重新获取结果, 共识之中的请求 id 由请求池生成
+	newRequest := request_pool.NewRequest(ctx, in.UserId, message, finishChannel)
+	newRequest.ClientRequestId = in.RequestId
+	if err := AddRequest(requestPool, newRequest); err != nil {
+		return nil, PoolError(err)
+	}
+
+	// 立即将请求 id 放在响应头之中, 调用者超时之后仍然可以通过它获取结果, 发送失败不影响认证本身
+	_ = grpc.SendHeader(ctx, metadata.Pairs(RequestIdKey, newRequest.RequestId))
+
+	// 结果从 finishChannel 之中进行返回, 同时监听调用者的取消
+	select {
+	case result, ok := <-finishChannel:
+		if !ok {
+			return nil, status.Error(codes.Aborted, "authentication request abandoned by consensus")
+		}
+		// 返回认证结果, 认证通过的时候携带会话凭证
+		return withSession(chain, replyFromMessage(result))
+	case <-ctx.Done():
+		return nil, ContextError(ctx.Err())
+	}
+}
+
+// GetAuthenticationResult 根据请求 id 获取宽限期之内的认证结果, 用于断开连接之后重新获取结果
+func (auth *AuthenticationService) GetAuthenticationResult(ctx context.Context, in *pb.AuthenticationResultRequest) (*pb.AuthenticationReply, error) {
+	chain, err := resolveBlockchain(ctx, auth.Chains)
+	if err != nil {
+		return nil, err
+	}
+	result, requestStatus := chain.RequestPool.Result(in.RequestId, in.UserId)
+	switch requestStatus {
+	case request_pool.RequestFinished:
+		return withSession(chain, replyFromMessage(result))
+	case request_pool.RequestPending:
+		return nil, status.Error(codes.Unavailable, "authentication request still pending")
+	default:
+		return nil, status.Error(codes.NotFound, "authentication request not found or expired")
+	}
+}
+
+// ValidateSession 检查会话凭证, 会话不存在, 已经过期或者已经被撤销的时候返回 valid 为 false
+func (auth *AuthenticationService) ValidateSession(ctx context.Context, in *pb.SessionRequest) (*pb.SessionReply, error) {
+	chain, err := resolveBlockchain(ctx, auth.Chains)
+	if err != nil {
+		return nil, err
+	}
+	userSession, err := chain.Sessions.Validate(in.SessionToken)
+	if err != nil {
+		return &pb.SessionReply{Valid: false}, nil
+	}
+	return &pb.SessionReply{UserId: userSession.UserId, Valid: true, ExpireTime: userSession.ExpireTime.Unix()}, nil
+}
+
+// RevokeSession 撤销会话, 返回被撤销的会话, 会话不存在的时候返回 NOT_FOUND
+func (auth *AuthenticationService) RevokeSession(ctx context.Context, in *pb.SessionRequest) (*pb.SessionReply, error) {
+	chain, err := resolveBlockchain(ctx, auth.Chains)
+	if err != nil {
+		return nil, err
+	}
+	userSession, err := chain.Sessions.Revoke(in.SessionToken)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return &pb.SessionReply{UserId: userSession.UserId, Valid: false, ExpireTime: userSession.ExpireTime.Unix()}, nil
+}
 
-	// 结果从 finishChannel 之中进行返回
-	result := <-finishChannel
+// withSession 认证通过的时候为用户签发会话, 同一个请求的结果重复获取的时候返回同一个会话, 会话被撤销之后不再签发
+func withSession(chain *blockchain.Blockchain, reply *pb.AuthenticationReply) (*pb.AuthenticationReply, error) {
+	if reply.Result != pb.AuthenticationResult_LegalUser {
+		return reply, nil
+	}
+	userSession, err := chain.Sessions.Issue(reply.UserId, reply.RequestId)
+	if errors.Is(err, session.ErrSessionRevoked) {
+		return reply, nil
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	reply.SessionToken = userSession.Token
+	reply.SessionExpireTime = userSession.ExpireTime.Unix()
+	return reply, nil
+}
+
+// replyFromMessage 从共识模块返回的 pb.RpcMessage 之中解析认证结果
+func replyFromMessage(result *pb.RpcMessage) *pb.AuthenticationReply {
 	replyMessage := &pb.AuthenticationReply{}
 	utils.MustUnmarshal(result.Content, replyMessage)
-
-	// 返回认证结果
-	return replyMessage, nil
+	return replyMessage
 }
 
 // AddRequest 添加请求
-func AddRequest(requestPool *request_pool.RequestPool, request *request_pool.Request) {
-	requestPool.RequestChan <- request
+func AddRequest(requestPool *request_pool.RequestPool, request *request_pool.Request) error {
+	return requestPool.AddRequest(request)
+}
+
+// PoolError 将请求池的错误转换为 grpc 的状态码, 队列满了的时候返回 RESOURCE_EXHAUSTED 让调用者稍后重试
+func PoolError(err error) error {
+	switch err {
+	case request_pool.ErrPoolFull:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case request_pool.ErrPoolStopped, request_pool.ErrPoolDraining:
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return ContextError(err)
+	}
+}
+
+// ContextError 将 context 的错误转换为 grpc 的状态码
+func ContextError(err error) error {
+	switch err {
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Unknown, err.Error())
+	}
 }
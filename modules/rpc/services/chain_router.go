@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"zhanghefan123/security/modules/blockchain"
+)
+
+// ChainIdKey 请求的 metadata 之中携带链 id 的键, 没有携带的时候使用默认链
+const ChainIdKey = "chain-id"
+
+// ChainResolver 根据链 id 找到对应的区块链, 链 id 为空的时候返回默认链, 由 ChainManager 进行实现
+type ChainResolver interface {
+	GetBlockchain(chainId string) (*blockchain.Blockchain, error)
+}
+
+// ChainIdFromContext 获取调用者在 metadata 之中指定的链 id
+func ChainIdFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(ChainIdKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// resolveBlockchain 将请求路由到调用者指定的链
+func resolveBlockchain(ctx context.Context, chains ChainResolver) (*blockchain.Blockchain, error) {
+	if chains == nil {
+		return nil, status.Error(codes.Unavailable, "no blockchain initialized")
+	}
+	chain, err := chains.GetBlockchain(ChainIdFromContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return chain, nil
+}
@@ -0,0 +1,197 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/byzantine"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/cluster"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/protobuf/pb-go/net"
+	"zhanghefan123/security/protocol"
+)
+
+var (
+	ErrNodeCrashed = errors.New("node is crashed")
+	ErrUnsupported = errors.New("action is not supported by this cluster")
+)
+
+// Cluster 场景运行所在的集群, 进程内的集群以及多进程的集群分别进行实现
+type Cluster interface {
+	// Authenticate 以 nodeId 作为接入节点提交用户的认证请求并等待共识的结果
+	Authenticate(ctx context.Context, nodeId, userId string) (pb.AuthenticationResult, error)
+	// Crash 将节点和网络以及客户端断开, 节点保留自己的状态
+	Crash(nodeId string) error
+	// Restart 恢复崩溃的节点
+	Restart(nodeId string) error
+	// Partition 将 nodes 和其他节点隔离, 和之前的分区同时生效
+	Partition(nodes []string) error
+	// Heal 撤销所有的分区
+	Heal() error
+	// SetByzantine 设置节点的拜占庭行为, 行为为空的时候恢复为诚实节点
+	SetByzantine(nodeId string, behaviours []string) error
+}
+
+// faultState 进程内集群之中崩溃的节点以及生效的分区
+type faultState struct {
+	crashed map[string]bool
+	groups  [][]string
+}
+
+// newFaultState 创建没有故障的状态
+func newFaultState() faultState {
+	return faultState{crashed: make(map[string]bool)}
+}
+
+// separates 判断 from 和 to 之间的消息是否因为崩溃或者分区而无法投递
+func (state faultState) separates(from, to string) bool {
+	if state.crashed[from] || state.crashed[to] {
+		return true
+	}
+	for _, group := range state.groups {
+		if containsString(group, from) != containsString(group, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// InProcess 进程内的 pbft 集群, 故障通过 cluster.Router 的过滤器注入
+type InProcess struct {
+	cluster    *cluster.Cluster
+	validators []string
+	mutex      sync.RWMutex
+	state      faultState
+}
+
+// NewInProcess 按照场景创建并启动进程内的集群, 节点的 id 需要依次为 node1 ... nodeN
+func NewInProcess(s *Scenario, logger protocol.Logger) (*InProcess, error) {
+	nodeIds := cluster.NodeIds(len(s.Nodes))
+	for i, node := range s.Nodes {
+		if node.Id != nodeIds[i] {
+			return nil, fmt.Errorf("in-process cluster needs node ids node1 ... node%d in order, got %s at %d",
+				len(nodeIds), node.Id, i)
+		}
+	}
+	opts := []cluster.Option{cluster.WithTimeout(s.Timeout)}
+	if s.ChainId != "" {
+		opts = append(opts, cluster.WithChainId(s.ChainId))
+	}
+	if logger != nil {
+		opts = append(opts, cluster.WithLogger(logger))
+	}
+	c, err := cluster.NewCluster(len(nodeIds), s.LegalUsers(), opts...)
+	if err != nil {
+		return nil, err
+	}
+	p := &InProcess{cluster: c, validators: nodeIds, state: newFaultState()}
+	c.Router.SetFilter(func(from, to string, msg *net.NetMsg) bool {
+		p.mutex.RLock()
+		defer p.mutex.RUnlock()
+		return !p.state.separates(from, to)
+	})
+	if err = c.Start(); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Cluster 返回底层的集群
+func (p *InProcess) Cluster() *cluster.Cluster {
+	return p.cluster
+}
+
+// Stop 停止集群
+func (p *InProcess) Stop() {
+	p.cluster.Stop()
+}
+
+// Authenticate 通过节点提交认证请求, 崩溃的节点直接返回 ErrNodeCrashed
+func (p *InProcess) Authenticate(ctx context.Context, nodeId, userId string) (pb.AuthenticationResult, error) {
+	p.mutex.RLock()
+	crashed := p.state.crashed[nodeId]
+	p.mutex.RUnlock()
+	if crashed {
+		return pb.AuthenticationResult_ConsensusTimeout, ErrNodeCrashed
+	}
+	type result struct {
+		reply *pb.AuthenticationReply
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		reply, err := p.cluster.Authenticate(nodeId, userId)
+		done <- result{reply: reply, err: err}
+	}()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return pb.AuthenticationResult_ConsensusTimeout, r.err
+		}
+		return r.reply.Result, nil
+	case <-ctx.Done():
+		return pb.AuthenticationResult_ConsensusTimeout, ctx.Err()
+	}
+}
+
+// Crash 丢弃节点收发的所有消息
+func (p *InProcess) Crash(nodeId string) error {
+	if _, err := p.cluster.Node(nodeId); err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.state.crashed[nodeId] = true
+	return nil
+}
+
+// Restart 恢复节点收发消息
+func (p *InProcess) Restart(nodeId string) error {
+	if _, err := p.cluster.Node(nodeId); err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.state.crashed, nodeId)
+	return nil
+}
+
+// Partition 增加一个分区
+func (p *InProcess) Partition(nodes []string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.state.groups = append(p.state.groups, append([]string{}, nodes...))
+	return nil
+}
+
+// Heal 撤销所有的分区
+func (p *InProcess) Heal() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.state.groups = nil
+	return nil
+}
+
+// SetByzantine 解析并设置节点的拜占庭行为
+func (p *InProcess) SetByzantine(nodeId string, behaviours []string) error {
+	behaviour, err := byzantine.ParseAll(behaviours, p.validators)
+	if err != nil {
+		return err
+	}
+	if behaviour == nil {
+		return p.cluster.SetByzantine(nodeId)
+	}
+	return p.cluster.SetByzantine(nodeId, behaviour)
+}
+
+// containsString 判断列表之中是否包含 s
+func containsString(items []string, s string) bool {
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
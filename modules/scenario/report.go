@@ -0,0 +1,159 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"zhanghefan123/security/modules/bench"
+)
+
+// EventRecord 执行过的一个故障事件
+type EventRecord struct {
+	At         time.Duration `json:"at"`      // 计划执行的时间
+	Applied    time.Duration `json:"applied"` // 实际执行的时间
+	Action     string        `json:"action"`
+	Nodes      []string      `json:"nodes,omitempty"`
+	Behaviours []string      `json:"behaviours,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// WorkloadReport 一个工作负载的压测报告
+type WorkloadReport struct {
+	Name   string        `json:"name"`
+	At     time.Duration `json:"at"`
+	Report *bench.Report `json:"report,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// AssertionResult 一个断言的检查结果
+type AssertionResult struct {
+	Assertion string `json:"assertion"`
+	Passed    bool   `json:"passed"`
+	Detail    string `json:"detail,omitempty"`
+	failed    bool
+}
+
+// fail 记录检查的细节, failed 为 true 的时候断言失败
+func (result *AssertionResult) fail(failed bool, format string, args ...interface{}) {
+	result.Detail = fmt.Sprintf(format, args...)
+	result.failed = result.failed || failed
+}
+
+// Report 一次场景运行的报告, 所有的事件都执行成功并且所有的断言都通过的时候 Passed 为 true
+type Report struct {
+	Name       string            `json:"name"`
+	Seed       int64             `json:"seed"`
+	Elapsed    time.Duration     `json:"elapsed"`
+	Passed     bool              `json:"passed"`
+	Events     []EventRecord     `json:"events"`
+	Workloads  []WorkloadReport  `json:"workloads"`
+	Assertions []AssertionResult `json:"assertions"`
+}
+
+// passed 判断场景是否通过
+func (report *Report) passed() bool {
+	for _, event := range report.Events {
+		if event.Error != "" {
+			return false
+		}
+	}
+	for _, workload := range report.Workloads {
+		if workload.Error != "" {
+			return false
+		}
+	}
+	for _, assertion := range report.Assertions {
+		if !assertion.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// describe 断言的简短描述
+func describe(assertion AssertionSpec) string {
+	var builder strings.Builder
+	builder.WriteString(assertion.Type)
+	if assertion.Workload != "" {
+		fmt.Fprintf(&builder, " workload=%s", assertion.Workload)
+	}
+	if len(assertion.Users) > 0 {
+		fmt.Fprintf(&builder, " users=%s", strings.Join(assertion.Users, ","))
+	}
+	if assertion.Expect != "" {
+		fmt.Fprintf(&builder, " expect=%s", assertion.Expect)
+	}
+	switch assertion.Type {
+	case AssertSuccessRate:
+		fmt.Fprintf(&builder, " min=%v", assertion.Min)
+	case AssertTimeoutRate:
+		fmt.Fprintf(&builder, " max=%v", assertion.Max)
+	case AssertLatency:
+		fmt.Fprintf(&builder, " %s<=%s", assertion.Percentile, assertion.MaxLatency)
+	}
+	return builder.String()
+}
+
+// WriteJSON 将报告输出为 json, 工作负载的报告不包含每个请求的结果
+func (report *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// WriteText 将报告输出为便于阅读的文本
+func (report *Report) WriteText(w io.Writer) error {
+	var builder strings.Builder
+	status := "PASSED"
+	if !report.Passed {
+		status = "FAILED"
+	}
+	fmt.Fprintf(&builder, "scenario: %s\tseed: %d\telapsed: %s\t%s\n",
+		report.Name, report.Seed, report.Elapsed.Round(time.Millisecond), status)
+	if len(report.Events) > 0 {
+		builder.WriteString("events:\n")
+	}
+	for _, event := range report.Events {
+		fmt.Fprintf(&builder, "  %s\t(at %s)\t%s %s", event.Applied.Round(time.Millisecond), event.At, event.Action,
+			strings.Join(event.Nodes, ","))
+		if len(event.Behaviours) > 0 {
+			fmt.Fprintf(&builder, " [%s]", strings.Join(event.Behaviours, ", "))
+		}
+		if event.Error != "" {
+			fmt.Fprintf(&builder, "\terror: %s", event.Error)
+		}
+		builder.WriteString("\n")
+	}
+	for _, workload := range report.Workloads {
+		fmt.Fprintf(&builder, "workload %s (at %s):\n", workload.Name, workload.At)
+		if workload.Error != "" {
+			fmt.Fprintf(&builder, "  error: %s\n", workload.Error)
+		}
+		if workload.Report == nil {
+			continue
+		}
+		total := workload.Report.Total
+		fmt.Fprintf(&builder, "  requests: %d\tsucceeded: %d\ttimeouts: %d\terrors: %d\tthroughput: %.2f/s\n",
+			total.Requests, total.Succeeded, total.Timeouts, total.Errors, total.Throughput)
+		fmt.Fprintf(&builder, "  latency ms\tp50: %.2f\tp95: %.2f\tp99: %.2f\tmax: %.2f\n",
+			total.Latency.P50, total.Latency.P95, total.Latency.P99, total.Latency.Max)
+	}
+	if len(report.Assertions) > 0 {
+		builder.WriteString("assertions:\n")
+	}
+	for _, assertion := range report.Assertions {
+		mark := "ok  "
+		if !assertion.Passed {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&builder, "  %s %s", mark, assertion.Assertion)
+		if assertion.Detail != "" {
+			fmt.Fprintf(&builder, "\t%s", assertion.Detail)
+		}
+		builder.WriteString("\n")
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
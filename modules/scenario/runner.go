@@ -0,0 +1,357 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"zhanghefan123/security/modules/bench"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// runner 一次场景运行的状态
+type runner struct {
+	scenario  *Scenario
+	cluster   Cluster
+	start     time.Time
+	mutex     sync.Mutex
+	crashed   map[string]bool // 崩溃的节点
+	byzantine map[string]bool // 拜占庭节点
+	events    []EventRecord
+}
+
+// Run 在集群上运行场景: 按照计划执行故障事件, 并行执行所有的工作负载, 全部结束之后检查断言.
+// 集群需要已经启动, Run 不会停止集群
+func Run(ctx context.Context, s *Scenario, c Cluster) (*Report, error) {
+	s.setDefaults()
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	r := &runner{
+		scenario:  s,
+		cluster:   c,
+		crashed:   make(map[string]bool),
+		byzantine: make(map[string]bool),
+	}
+	r.start = time.Now()
+	for _, node := range s.Nodes {
+		if len(node.Byzantine) > 0 {
+			r.apply(FaultSpec{Action: ActionByzantine, Nodes: []string{node.Id}, Behaviours: node.Byzantine})
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, fault := range s.sortedFaults() {
+			if !r.waitUntil(ctx, fault.At) {
+				return
+			}
+			r.apply(fault)
+		}
+	}()
+	workloads := make([]WorkloadReport, len(s.Workloads))
+	for i, workload := range s.Workloads {
+		wg.Add(1)
+		go func(i int, workload WorkloadSpec) {
+			defer wg.Done()
+			workloads[i] = r.runWorkload(ctx, i, workload)
+		}(i, workload)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Name:      s.Name,
+		Seed:      s.Seed,
+		Events:    r.events,
+		Workloads: workloads,
+	}
+	for _, assertion := range s.Assertions {
+		report.Assertions = append(report.Assertions, r.check(ctx, assertion, workloads))
+	}
+	report.Elapsed = time.Since(r.start)
+	report.Passed = report.passed()
+	return report, nil
+}
+
+// waitUntil 等待到场景开始之后的 at 时刻, ctx 被取消的时候返回 false
+func (r *runner) waitUntil(ctx context.Context, at time.Duration) bool {
+	timer := time.NewTimer(time.Until(r.start.Add(at)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// apply 执行故障事件并记录结果, 每个节点的错误单独记录
+func (r *runner) apply(fault FaultSpec) {
+	record := EventRecord{
+		At:         fault.At,
+		Applied:    time.Since(r.start),
+		Action:     fault.Action,
+		Nodes:      fault.Nodes,
+		Behaviours: fault.Behaviours,
+	}
+	errs := make([]string, 0)
+	if fault.Action == ActionPartition {
+		if err := r.cluster.Partition(fault.Nodes); err != nil {
+			errs = append(errs, err.Error())
+		}
+	} else if fault.Action == ActionHeal {
+		if err := r.cluster.Heal(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, nodeId := range fault.Nodes {
+		var err error
+		switch fault.Action {
+		case ActionCrash:
+			if err = r.cluster.Crash(nodeId); err == nil {
+				r.setState(r.crashed, nodeId, true)
+			}
+		case ActionRestart:
+			if err = r.cluster.Restart(nodeId); err == nil {
+				r.setState(r.crashed, nodeId, false)
+			}
+		case ActionByzantine:
+			if err = r.cluster.SetByzantine(nodeId, fault.Behaviours); err == nil {
+				r.setState(r.byzantine, nodeId, true)
+			}
+		case ActionHonest:
+			if err = r.cluster.SetByzantine(nodeId, nil); err == nil {
+				r.setState(r.byzantine, nodeId, false)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", nodeId, err))
+		}
+	}
+	record.Error = strings.Join(errs, "; ")
+	r.mutex.Lock()
+	r.events = append(r.events, record)
+	r.mutex.Unlock()
+}
+
+// setState 修改节点的崩溃或者拜占庭状态
+func (r *runner) setState(state map[string]bool, nodeId string, value bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if value {
+		state[nodeId] = true
+	} else {
+		delete(state, nodeId)
+	}
+}
+
+// honestNodes 返回场景结束的时候存活的诚实节点
+func (r *runner) honestNodes() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	nodeIds := make([]string, 0, len(r.scenario.Nodes))
+	for _, nodeId := range r.scenario.NodeIds() {
+		if !r.crashed[nodeId] && !r.byzantine[nodeId] {
+			nodeIds = append(nodeIds, nodeId)
+		}
+	}
+	return nodeIds
+}
+
+// runWorkload 在 At 时刻开始执行工作负载
+func (r *runner) runWorkload(ctx context.Context, index int, workload WorkloadSpec) WorkloadReport {
+	report := WorkloadReport{Name: workload.Name, At: workload.At}
+	if !r.waitUntil(ctx, workload.At) {
+		report.Error = ctx.Err().Error()
+		return report
+	}
+	users, err := r.scenario.userPicker(workload)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	nodeIds := workload.Nodes
+	if len(nodeIds) == 0 {
+		nodeIds = r.scenario.NodeIds()
+	}
+	targets := make([]bench.Target, 0, len(nodeIds))
+	for _, nodeId := range nodeIds {
+		nodeId := nodeId
+		targets = append(targets, bench.Target{
+			Name: nodeId,
+			Authenticate: func(ctx context.Context, userId string) (pb.AuthenticationResult, error) {
+				return r.cluster.Authenticate(ctx, nodeId, userId)
+			},
+		})
+	}
+	seed := r.scenario.Seed
+	if seed != 0 {
+		// 每个工作负载使用不同但是确定的种子
+		seed += int64(index)
+	}
+	report.Report, err = bench.Run(ctx, bench.Config{
+		Concurrency: workload.Concurrency,
+		Rate:        workload.Rate,
+		Arrival:     workload.Arrival,
+		Duration:    workload.Duration,
+		Requests:    workload.Requests,
+		Timeout:     r.scenario.Timeout,
+		Seed:        seed,
+		Users:       users,
+	}, targets)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// samples 返回断言统计范围之内的所有请求
+func samples(workloads []WorkloadReport, name string) []bench.Sample {
+	all := make([]bench.Sample, 0)
+	for _, workload := range workloads {
+		if workload.Report == nil || (name != "" && workload.Name != name) {
+			continue
+		}
+		all = append(all, workload.Report.Samples...)
+	}
+	return all
+}
+
+// check 检查一个断言
+func (r *runner) check(ctx context.Context, assertion AssertionSpec, workloads []WorkloadReport) AssertionResult {
+	result := AssertionResult{Assertion: describe(assertion)}
+	scoped := samples(workloads, assertion.Workload)
+	switch assertion.Type {
+	case AssertCorrect:
+		wrong := make([]string, 0)
+		for _, sample := range scoped {
+			if sample.Succeeded() && sample.Result != r.scenario.Expected(sample.UserId) {
+				wrong = append(wrong, fmt.Sprintf("%s via %s got %s", sample.UserId, sample.Target, sample.Result))
+			}
+		}
+		result.fail(len(wrong) > 0, "%d of %d requests disagree with the registry", len(wrong), len(scoped))
+		if len(wrong) > 0 {
+			result.Detail += ": " + strings.Join(limit(wrong), ", ")
+		}
+	case AssertResult:
+		for _, user := range assertion.Users {
+			expected := r.expected(assertion, user)
+			seen := 0
+			for _, sample := range scoped {
+				if sample.UserId != user {
+					continue
+				}
+				seen++
+				if sample.Err != nil || sample.Result != expected {
+					result.fail(true, "%s via %s got %s", user, sample.Target, describeSample(sample))
+					return result
+				}
+			}
+			if seen == 0 {
+				result.fail(true, "no request of %s", user)
+				return result
+			}
+		}
+	case AssertAgreement:
+		nodeIds := r.honestNodes()
+		if len(nodeIds) == 0 {
+			result.fail(true, "no live honest node")
+			return result
+		}
+		for _, user := range assertion.Users {
+			expected := r.expected(assertion, user)
+			for _, nodeId := range nodeIds {
+				callCtx, cancel := context.WithTimeout(ctx, r.scenario.Timeout)
+				got, err := r.cluster.Authenticate(callCtx, nodeId, user)
+				cancel()
+				if err != nil || got != expected {
+					result.fail(true, "%s via %s got %s, expect %s", user, nodeId,
+						describeSample(bench.Sample{Result: got, Err: err}), expected)
+					return result
+				}
+			}
+		}
+	case AssertSuccessRate:
+		rate := ratio(scoped, bench.Sample.Succeeded)
+		result.fail(len(scoped) == 0 || rate < assertion.Min, "success rate %.4f of %d requests, expect >= %.4f",
+			rate, len(scoped), assertion.Min)
+	case AssertTimeoutRate:
+		rate := ratio(scoped, bench.Sample.TimedOut)
+		result.fail(rate > assertion.Max, "timeout rate %.4f of %d requests, expect <= %.4f",
+			rate, len(scoped), assertion.Max)
+	case AssertLatency:
+		latency, ok := percentileOf(scoped, assertion.Percentile)
+		result.fail(!ok || latency > assertion.MaxLatency, "%s latency %s of succeeded requests, expect <= %s",
+			assertion.Percentile, latency, assertion.MaxLatency)
+	}
+	if !result.failed {
+		result.Passed = true
+	}
+	return result
+}
+
+// expected 断言期望的认证结果, 没有指定的时候按照认证域判断
+func (r *runner) expected(assertion AssertionSpec, userId string) pb.AuthenticationResult {
+	if assertion.Expect != "" {
+		return pb.AuthenticationResult(pb.AuthenticationResult_value[assertion.Expect])
+	}
+	return r.scenario.Expected(userId)
+}
+
+// ratio 计算满足条件的请求的比例
+func ratio(scoped []bench.Sample, match func(bench.Sample) bool) float64 {
+	if len(scoped) == 0 {
+		return 0
+	}
+	count := 0
+	for _, sample := range scoped {
+		if match(sample) {
+			count++
+		}
+	}
+	return float64(count) / float64(len(scoped))
+}
+
+// percentileOf 按照 nearest-rank 方法计算成功的请求的延迟百分位数, 没有成功的请求的时候返回 false
+func percentileOf(scoped []bench.Sample, percentile string) (time.Duration, bool) {
+	latencies := make([]time.Duration, 0, len(scoped))
+	for _, sample := range scoped {
+		if sample.Succeeded() {
+			latencies = append(latencies, sample.Latency)
+		}
+	}
+	if len(latencies) == 0 {
+		return 0, false
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rank := int(math.Ceil(percentiles[percentile] * float64(len(latencies))))
+	if rank < 1 {
+		rank = 1
+	}
+	return latencies[rank-1], true
+}
+
+// describeSample 请求结果的简短描述
+func describeSample(sample bench.Sample) string {
+	if sample.Err != nil {
+		return "error: " + sample.Err.Error()
+	}
+	return sample.Result.String()
+}
+
+// limit 最多保留前几个错误, 避免报告过长
+func limit(items []string) []string {
+	const maxItems = 5
+	if len(items) > maxItems {
+		return append(items[:maxItems:maxItems], fmt.Sprintf("... %d more", len(items)-maxItems))
+	}
+	return items
+}
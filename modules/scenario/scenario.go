@@ -0,0 +1,324 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/viper"
+	"sort"
+	"time"
+	"zhanghefan123/security/modules/bench"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft/byzantine"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+const (
+	ConsensusPbft      = "pbft"           // 目前支持的共识
+	DefaultTimeout     = 10 * time.Second // 默认的单个认证请求的超时时间
+	DefaultLegalPrefix = "legal-user-"    // registry.generate 生成的合法用户的前缀
+	illegalPrefix      = "illegal-user-"  // workload.illegal_users 生成的非法用户的前缀
+	defaultWorkload    = "workload"       // 没有名称的工作负载的默认名称前缀
+	maxGeneratedUsers  = 1 << 20          // 生成用户数量的上限, 避免写错的场景文件占满内存
+	defaultZipfS       = 1.1              // 默认的 zipf 分布的参数
+)
+
+// percentiles 延迟断言支持的百分位数
+var percentiles = map[string]float64{"p50": 0.50, "p95": 0.95, "p99": 0.99}
+
+// 故障事件的动作
+const (
+	ActionCrash     = "crash"     // 节点和网络以及客户端断开, 保留状态, 之后可以 restart
+	ActionRestart   = "restart"   // 恢复崩溃的节点
+	ActionPartition = "partition" // 将 nodes 和其他节点隔离, nodes 之间仍然可以通信, 多个分区同时生效
+	ActionHeal      = "heal"      // 撤销所有的分区
+	ActionByzantine = "byzantine" // 将 nodes 切换为 behaviours 描述的拜占庭行为
+	ActionHonest    = "honest"    // 将 nodes 恢复为诚实节点
+)
+
+// 断言的类型
+const (
+	AssertCorrect     = "correct"      // 所有得到判断的请求的结果都和认证域一致
+	AssertResult      = "result"       // users 的所有请求的结果都为 expect, expect 为空的时候按照认证域判断
+	AssertAgreement   = "agreement"    // 场景结束之后通过每个存活的诚实节点认证 users, 结果都为 expect
+	AssertSuccessRate = "success_rate" // 得到判断的请求的比例不小于 min
+	AssertTimeoutRate = "timeout_rate" // 超时的请求的比例不大于 max
+	AssertLatency     = "latency"      // 延迟的百分位数 percentile 不大于 max_latency
+)
+
+var (
+	ErrNoNodes         = errors.New("scenario declares no nodes")
+	ErrUnknownNode     = errors.New("unknown node in scenario")
+	ErrNoWorkloadUsers = errors.New("workload has no users, set users, illegal_users or registry")
+)
+
+// Scenario 场景文件, 描述节点, 共识, 认证域, 工作负载, 故障计划以及断言.
+// 同一个场景既可以在进程内的集群上运行, 也可以在多进程的集群上运行
+type Scenario struct {
+	Name       string          `mapstructure:"name"`
+	Seed       int64           `mapstructure:"seed"`      // 工作负载选择用户以及泊松到达的随机数种子, 为 0 的时候使用当前时间
+	Consensus  string          `mapstructure:"consensus"` // 共识的名称, 默认为 pbft
+	ChainId    string          `mapstructure:"chain_id"`  // 链 id, 为空的时候使用节点的默认链
+	Timeout    time.Duration   `mapstructure:"timeout"`   // 单个认证请求的超时时间
+	Nodes      []NodeSpec      `mapstructure:"nodes"`
+	Registry   RegistrySpec    `mapstructure:"registry"`
+	Workloads  []WorkloadSpec  `mapstructure:"workloads"`
+	Faults     []FaultSpec     `mapstructure:"faults"`
+	Assertions []AssertionSpec `mapstructure:"assertions"`
+}
+
+// NodeSpec 场景之中的一个节点
+type NodeSpec struct {
+	Id        string   `mapstructure:"id"`
+	Addr      string   `mapstructure:"addr"`      // 多进程集群之中节点 rpc 服务的地址, 进程内的集群忽略
+	Byzantine []string `mapstructure:"byzantine"` // 节点一开始的拜占庭行为, 例如 equivocate 或者 forge_voter:node2
+}
+
+// RegistrySpec 认证域之中注册的合法用户.
+// 进程内的集群使用这里的用户创建节点, 多进程集群的认证域由节点的配置文件决定, 这里的内容需要和配置文件一致
+type RegistrySpec struct {
+	LegalUsers []string `mapstructure:"legal_users"`
+	Generate   int      `mapstructure:"generate"` // 额外生成的合法用户的数量, 用户 id 为 prefix1 ... prefixN
+	Prefix     string   `mapstructure:"prefix"`   // 生成的合法用户的前缀, 默认为 legal-user-
+}
+
+// WorkloadSpec 一个工作负载, 在场景开始之后的 At 时刻开始, 和其他工作负载以及故障事件并行执行
+type WorkloadSpec struct {
+	Name         string        `mapstructure:"name"`
+	At           time.Duration `mapstructure:"at"`
+	Nodes        []string      `mapstructure:"nodes"`         // 接入节点, 请求轮流分配, 为空的时候使用所有节点
+	Users        []string      `mapstructure:"users"`         // 请求的用户, 为空的时候使用认证域之中的合法用户
+	IllegalUsers int           `mapstructure:"illegal_users"` // 额外加入的非法用户的数量
+	Distribution string        `mapstructure:"distribution"`  // 用户的分布, 和 bench 命令相同
+	ZipfS        float64       `mapstructure:"zipf_s"`
+	Concurrency  int           `mapstructure:"concurrency"`
+	Rate         float64       `mapstructure:"rate"` // 为 0 表示闭环模式
+	Arrival      string        `mapstructure:"arrival"`
+	Duration     time.Duration `mapstructure:"duration"`
+	Requests     int           `mapstructure:"requests"`
+}
+
+// FaultSpec 故障计划之中的一个事件, 在场景开始之后的 At 时刻执行
+type FaultSpec struct {
+	At         time.Duration `mapstructure:"at"`
+	Action     string        `mapstructure:"action"`
+	Nodes      []string      `mapstructure:"nodes"`
+	Behaviours []string      `mapstructure:"behaviours"` // byzantine 动作使用的拜占庭行为
+}
+
+// AssertionSpec 场景结束之后检查的断言, Workload 为空的时候统计所有的工作负载
+type AssertionSpec struct {
+	Type       string        `mapstructure:"type"`
+	Workload   string        `mapstructure:"workload"`
+	Users      []string      `mapstructure:"users"`
+	Expect     string        `mapstructure:"expect"` // LegalUser 或者 IllegalUser
+	Min        float64       `mapstructure:"min"`
+	Max        float64       `mapstructure:"max"`
+	Percentile string        `mapstructure:"percentile"` // p50, p95 或者 p99
+	MaxLatency time.Duration `mapstructure:"max_latency"`
+}
+
+// Load 读取并检查场景文件
+func Load(path string) (*Scenario, error) {
+	scenarioViper := viper.New()
+	scenarioViper.SetConfigFile(path)
+	if err := scenarioViper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read scenario file %s failed, %w", path, err)
+	}
+	s := &Scenario{}
+	if err := scenarioViper.Unmarshal(s); err != nil {
+		return nil, fmt.Errorf("parse scenario file %s failed, %w", path, err)
+	}
+	s.setDefaults()
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario file %s, %w", path, err)
+	}
+	return s, nil
+}
+
+// setDefaults 填充没有设置的配置项
+func (s *Scenario) setDefaults() {
+	if s.Consensus == "" {
+		s.Consensus = ConsensusPbft
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = DefaultTimeout
+	}
+	if s.Registry.Prefix == "" {
+		s.Registry.Prefix = DefaultLegalPrefix
+	}
+	for i := range s.Workloads {
+		if s.Workloads[i].Name == "" {
+			s.Workloads[i].Name = fmt.Sprintf("%s%d", defaultWorkload, i+1)
+		}
+		if s.Workloads[i].ZipfS == 0 {
+			s.Workloads[i].ZipfS = defaultZipfS
+		}
+	}
+}
+
+// NodeIds 返回场景之中所有节点的 id
+func (s *Scenario) NodeIds() []string {
+	nodeIds := make([]string, 0, len(s.Nodes))
+	for _, node := range s.Nodes {
+		nodeIds = append(nodeIds, node.Id)
+	}
+	return nodeIds
+}
+
+// LegalUsers 返回认证域之中所有的合法用户
+func (s *Scenario) LegalUsers() []string {
+	users := append([]string{}, s.Registry.LegalUsers...)
+	return append(users, bench.GenerateUsers(s.Registry.Prefix, s.Registry.Generate)...)
+}
+
+// Expected 根据认证域判断用户的认证结果
+func (s *Scenario) Expected(userId string) pb.AuthenticationResult {
+	for _, user := range s.LegalUsers() {
+		if user == userId {
+			return pb.AuthenticationResult_LegalUser
+		}
+	}
+	return pb.AuthenticationResult_IllegalUser
+}
+
+// Validate 检查节点, 故障事件, 工作负载以及断言是否合法
+func (s *Scenario) Validate() error {
+	if s.Consensus != ConsensusPbft {
+		return fmt.Errorf("unsupported consensus %q, expect %s", s.Consensus, ConsensusPbft)
+	}
+	if len(s.Nodes) == 0 {
+		return ErrNoNodes
+	}
+	known := make(map[string]bool, len(s.Nodes))
+	for i, node := range s.Nodes {
+		if node.Id == "" {
+			return fmt.Errorf("node %d has no id", i)
+		}
+		if known[node.Id] {
+			return fmt.Errorf("duplicate node %s", node.Id)
+		}
+		known[node.Id] = true
+	}
+	checkNodes := func(what string, nodes []string) error {
+		for _, nodeId := range nodes {
+			if !known[nodeId] {
+				return fmt.Errorf("%s: %w %s", what, ErrUnknownNode, nodeId)
+			}
+		}
+		return nil
+	}
+	for _, node := range s.Nodes {
+		if _, err := byzantine.ParseAll(node.Byzantine, s.NodeIds()); err != nil {
+			return fmt.Errorf("node %s: %v", node.Id, err)
+		}
+	}
+	if s.Registry.Generate < 0 || s.Registry.Generate > maxGeneratedUsers {
+		return fmt.Errorf("registry: invalid generate %d", s.Registry.Generate)
+	}
+
+	workloads := make(map[string]bool, len(s.Workloads))
+	for _, workload := range s.Workloads {
+		what := "workload " + workload.Name
+		if workloads[workload.Name] {
+			return fmt.Errorf("duplicate workload %s", workload.Name)
+		}
+		workloads[workload.Name] = true
+		if err := checkNodes(what, workload.Nodes); err != nil {
+			return err
+		}
+		if workload.At < 0 || workload.IllegalUsers < 0 || workload.IllegalUsers > maxGeneratedUsers {
+			return fmt.Errorf("%s: negative start time or invalid illegal_users", what)
+		}
+		if _, err := s.userPicker(workload); err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+		if workload.Duration <= 0 && workload.Requests <= 0 {
+			return fmt.Errorf("%s: %w", what, bench.ErrUnboundedBench)
+		}
+	}
+
+	for i, fault := range s.Faults {
+		what := fmt.Sprintf("fault %d (%s at %s)", i, fault.Action, fault.At)
+		if fault.At < 0 {
+			return fmt.Errorf("%s: negative time", what)
+		}
+		switch fault.Action {
+		case ActionCrash, ActionRestart, ActionPartition, ActionHonest:
+			if len(fault.Nodes) == 0 {
+				return fmt.Errorf("%s: no nodes", what)
+			}
+		case ActionByzantine:
+			if len(fault.Nodes) == 0 || len(fault.Behaviours) == 0 {
+				return fmt.Errorf("%s: needs nodes and behaviours", what)
+			}
+			if _, err := byzantine.ParseAll(fault.Behaviours, s.NodeIds()); err != nil {
+				return fmt.Errorf("%s: %v", what, err)
+			}
+		case ActionHeal:
+		default:
+			return fmt.Errorf("%s: unknown action", what)
+		}
+		if err := checkNodes(what, fault.Nodes); err != nil {
+			return err
+		}
+	}
+
+	for i, assertion := range s.Assertions {
+		what := fmt.Sprintf("assertion %d (%s)", i, assertion.Type)
+		if assertion.Workload != "" && !workloads[assertion.Workload] {
+			return fmt.Errorf("%s: unknown workload %s", what, assertion.Workload)
+		}
+		if assertion.Expect != "" {
+			if _, ok := pb.AuthenticationResult_value[assertion.Expect]; !ok {
+				return fmt.Errorf("%s: unknown result %q", what, assertion.Expect)
+			}
+		}
+		switch assertion.Type {
+		case AssertCorrect:
+		case AssertResult, AssertAgreement:
+			if len(assertion.Users) == 0 {
+				return fmt.Errorf("%s: no users", what)
+			}
+		case AssertSuccessRate:
+			if assertion.Min < 0 || assertion.Min > 1 {
+				return fmt.Errorf("%s: min %v out of range [0, 1]", what, assertion.Min)
+			}
+		case AssertTimeoutRate:
+			if assertion.Max < 0 || assertion.Max > 1 {
+				return fmt.Errorf("%s: max %v out of range [0, 1]", what, assertion.Max)
+			}
+		case AssertLatency:
+			if _, ok := percentiles[assertion.Percentile]; !ok {
+				return fmt.Errorf("%s: unknown percentile %q, expect p50, p95 or p99", what, assertion.Percentile)
+			}
+			if assertion.MaxLatency <= 0 {
+				return fmt.Errorf("%s: max_latency must be positive", what)
+			}
+		default:
+			return fmt.Errorf("%s: unknown assertion type", what)
+		}
+	}
+	return nil
+}
+
+// userPicker 创建工作负载的用户分布
+func (s *Scenario) userPicker(workload WorkloadSpec) (bench.UserPicker, error) {
+	if workload.Distribution == bench.DistributionUnique {
+		return bench.NewUserPicker(workload.Distribution, workload.Users, workload.ZipfS)
+	}
+	users := workload.Users
+	if len(users) == 0 {
+		users = s.LegalUsers()
+	}
+	users = append(append([]string{}, users...), bench.GenerateUsers(illegalPrefix, workload.IllegalUsers)...)
+	if len(users) == 0 {
+		return nil, ErrNoWorkloadUsers
+	}
+	return bench.NewUserPicker(workload.Distribution, users, workload.ZipfS)
+}
+
+// sortedFaults 返回按照时间排序的故障事件, 同一时刻的事件保持文件之中的顺序
+func (s *Scenario) sortedFaults() []FaultSpec {
+	faults := append([]FaultSpec{}, s.Faults...)
+	sort.SliceStable(faults, func(i, j int) bool { return faults[i].At < faults[j].At })
+	return faults
+}
@@ -0,0 +1,129 @@
+package scenario
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// runInProcess 在进程内的集群上运行场景
+func runInProcess(t *testing.T, s *Scenario) *Report {
+	c, err := NewInProcess(s, nil)
+	require.NoError(t, err)
+	t.Cleanup(c.Stop)
+	report, err := Run(context.Background(), s, c)
+	require.NoError(t, err)
+	var buffer bytes.Buffer
+	require.NoError(t, report.WriteText(&buffer))
+	t.Log(buffer.String())
+	return report
+}
+
+func TestLoad(t *testing.T) {
+	s, err := Load(filepath.Join("testdata", "byzantine.yml"))
+	require.NoError(t, err)
+	require.Equal(t, "byzantine-switches", s.Name)
+	require.Equal(t, ConsensusPbft, s.Consensus)
+	require.Equal(t, []string{"node1", "node2", "node3", "node4"}, s.NodeIds())
+	require.Equal(t, "127.0.0.1:12304", s.Nodes[3].Addr)
+	require.Equal(t, []string{"equivocate"}, s.Nodes[3].Byzantine)
+	require.Len(t, s.LegalUsers(), 10)
+	require.Equal(t, pb.AuthenticationResult_LegalUser, s.Expected("legal-user-8"))
+	require.Equal(t, pb.AuthenticationResult_IllegalUser, s.Expected("mallory"))
+	require.Equal(t, 100*time.Millisecond, s.Faults[0].At)
+	require.Equal(t, []string{"forge_voter:node2", "wrong_reply"}, s.Faults[0].Behaviours)
+	require.Equal(t, float64(1), s.Assertions[1].Min)
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Scenario {
+		s := &Scenario{
+			Nodes:     []NodeSpec{{Id: "node1"}, {Id: "node2"}},
+			Registry:  RegistrySpec{LegalUsers: []string{"alice"}},
+			Workloads: []WorkloadSpec{{Name: "w", Requests: 1}},
+		}
+		s.setDefaults()
+		return s
+	}
+	require.NoError(t, valid().Validate())
+
+	cases := map[string]func(s *Scenario){
+		"consensus":      func(s *Scenario) { s.Consensus = "raft" },
+		"duplicate node": func(s *Scenario) { s.Nodes[1].Id = "node1" },
+		"unknown node":   func(s *Scenario) { s.Faults = []FaultSpec{{Action: ActionCrash, Nodes: []string{"node9"}}} },
+		"unknown action": func(s *Scenario) { s.Faults = []FaultSpec{{Action: "reboot", Nodes: []string{"node1"}}} },
+		"behaviour": func(s *Scenario) {
+			s.Faults = []FaultSpec{{Action: ActionByzantine, Nodes: []string{"node1"}, Behaviours: []string{"lazy"}}}
+		},
+		"unbounded":        func(s *Scenario) { s.Workloads[0].Requests = 0 },
+		"no users":         func(s *Scenario) { s.Registry.LegalUsers = nil },
+		"unknown workload": func(s *Scenario) { s.Assertions = []AssertionSpec{{Type: AssertCorrect, Workload: "x"}} },
+		"percentile": func(s *Scenario) {
+			s.Assertions = []AssertionSpec{{Type: AssertLatency, Percentile: "p90", MaxLatency: time.Second}}
+		},
+		"expect": func(s *Scenario) {
+			s.Assertions = []AssertionSpec{{Type: AssertResult, Users: []string{"alice"}, Expect: "Legal"}}
+		},
+	}
+	for name, modify := range cases {
+		s := valid()
+		modify(s)
+		require.Error(t, s.Validate(), name)
+	}
+}
+
+func TestRunByzantineScenario(t *testing.T) {
+	s, err := Load(filepath.Join("testdata", "byzantine.yml"))
+	require.NoError(t, err)
+	report := runInProcess(t, s)
+	require.True(t, report.Passed)
+	// node4 一开始的行为以及两个计划之中的事件
+	require.Len(t, report.Events, 3)
+	require.Equal(t, 60, report.Workloads[0].Report.Total.Requests)
+}
+
+func TestRunCrashScenario(t *testing.T) {
+	s, err := Load(filepath.Join("testdata", "crash.yml"))
+	require.NoError(t, err)
+	report := runInProcess(t, s)
+	require.True(t, report.Passed)
+	require.Equal(t, 20, report.Workloads[0].Report.Total.Succeeded)
+	require.Equal(t, 4, report.Workloads[1].Report.Total.Succeeded)
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	s := &Scenario{
+		Name:     "failing",
+		Timeout:  300 * time.Millisecond,
+		Nodes:    []NodeSpec{{Id: "node1"}, {Id: "node2"}, {Id: "node3"}, {Id: "node4"}},
+		Registry: RegistrySpec{LegalUsers: []string{"alice"}},
+		Workloads: []WorkloadSpec{{
+			Name:     "through-crashed",
+			At:       50 * time.Millisecond,
+			Nodes:    []string{"node3"},
+			Requests: 2,
+		}},
+		Faults: []FaultSpec{{Action: ActionCrash, Nodes: []string{"node3"}}},
+		Assertions: []AssertionSpec{
+			{Type: AssertSuccessRate, Min: 1},
+			{Type: AssertResult, Users: []string{"alice"}},
+			{Type: AssertTimeoutRate, Max: 1},
+			// node3 崩溃之后仍然有 3 个诚实节点
+			{Type: AssertAgreement, Users: []string{"alice"}, Expect: "LegalUser"},
+		},
+	}
+	report := runInProcess(t, s)
+	require.False(t, report.Passed)
+	require.False(t, report.Assertions[0].Passed)
+	require.False(t, report.Assertions[1].Passed)
+	require.True(t, report.Assertions[2].Passed)
+	require.True(t, report.Assertions[3].Passed, report.Assertions[3].Detail)
+
+	var buffer bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buffer))
+	require.Contains(t, buffer.String(), `"passed": false`)
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"zhanghefan123/security/modules/clock"
+)
+
+// DefaultTTL 会话默认的有效期
+const DefaultTTL = time.Hour
+
+// tokenBytes 会话凭证的随机字节数
+const tokenBytes = 32
+
+var (
+	// ErrSessionNotFound 会话不存在, 已经过期或者已经被撤销
+	ErrSessionNotFound = errors.New("session not found or expired")
+	// ErrSessionRevoked 请求对应的会话已经被撤销, 重新获取结果的时候不再签发
+	ErrSessionRevoked = errors.New("session of the request has been revoked")
+)
+
+// Session 认证通过之后接入节点为用户签发的会话
+type Session struct {
+	Token      string    // 会话凭证
+	UserId     string    // 会话所属的用户
+	RequestId  string    // 签发会话的认证请求
+	ExpireTime time.Time // 过期时间
+	revoked    bool
+}
+
+// Store 保存一条链上签发的会话, 会话只保存在内存之中, 节点重启之后用户需要重新认证
+type Store struct {
+	mutex    sync.Mutex
+	clock    clock.Clock
+	ttl      time.Duration
+	sessions map[string]*Session // 会话凭证 -> 会话
+	requests map[string]*Session // 请求 id -> 会话, 调用者重新获取结果的时候返回同一个会话
+}
+
+// NewStore 创建会话存储, ttl 不大于 0 的时候使用 DefaultTTL
+func NewStore(clk clock.Clock, ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{
+		clock:    clk,
+		ttl:      ttl,
+		sessions: make(map[string]*Session),
+		requests: make(map[string]*Session),
+	}
+}
+
+// Issue 为认证通过的请求签发会话, 同一个请求在会话过期之前只签发一次
+func (s *Store) Issue(userId, requestId string) (Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune()
+	if existed, ok := s.requests[requestId]; ok {
+		if existed.revoked {
+			return Session{}, ErrSessionRevoked
+		}
+		return *existed, nil
+	}
+	token, err := newToken()
+	if err != nil {
+		return Session{}, err
+	}
+	session := &Session{
+		Token:      token,
+		UserId:     userId,
+		RequestId:  requestId,
+		ExpireTime: s.clock.Now().Add(s.ttl),
+	}
+	s.sessions[token] = session
+	s.requests[requestId] = session
+	return *session, nil
+}
+
+// Validate 返回凭证对应的有效会话
+func (s *Store) Validate(token string) (Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune()
+	session, ok := s.sessions[token]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	return *session, nil
+}
+
+// Revoke 撤销会话, 返回被撤销的会话, 签发它的请求在会话过期之前不会再签发新的会话
+func (s *Store) Revoke(token string) (Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune()
+	session, ok := s.sessions[token]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	session.revoked = true
+	delete(s.sessions, token)
+	return *session, nil
+}
+
+// Len 返回有效会话的数量
+func (s *Store) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prune()
+	return len(s.sessions)
+}
+
+// prune 删除过期的会话, 被撤销的会话在过期之后才从 requests 之中删除
+func (s *Store) prune() {
+	now := s.clock.Now()
+	for requestId, session := range s.requests {
+		if now.After(session.ExpireTime) {
+			delete(s.requests, requestId)
+			delete(s.sessions, session.Token)
+		}
+	}
+}
+
+// newToken 生成随机的会话凭证
+func newToken() (string, error) {
+	buffer := make([]byte, tokenBytes)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", fmt.Errorf("generate session token failed, %w", err)
+	}
+	return hex.EncodeToString(buffer), nil
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"zhanghefan123/security/modules/clock"
+)
+
+func TestIssueAndValidate(t *testing.T) {
+	clk := clock.NewVirtual(time.Unix(0, 0))
+	store := NewStore(clk, time.Minute)
+	issued, err := store.Issue("alice", "request1")
+	require.NoError(t, err)
+	require.Len(t, issued.Token, 2*tokenBytes)
+	require.Equal(t, time.Unix(60, 0), issued.ExpireTime)
+
+	// 同一个请求重复获取结果的时候返回同一个会话, 不同的请求签发不同的会话
+	again, err := store.Issue("alice", "request1")
+	require.NoError(t, err)
+	require.Equal(t, issued, again)
+	other, err := store.Issue("alice", "request2")
+	require.NoError(t, err)
+	require.NotEqual(t, issued.Token, other.Token)
+
+	validated, err := store.Validate(issued.Token)
+	require.NoError(t, err)
+	require.Equal(t, "alice", validated.UserId)
+	_, err = store.Validate("unknown")
+	require.ErrorIs(t, err, ErrSessionNotFound)
+
+	// 过期之后会话失效, 同一个请求可以重新签发
+	clk.AdvanceTo(time.Unix(61, 0))
+	_, err = store.Validate(issued.Token)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+	require.Equal(t, 0, store.Len())
+	renewed, err := store.Issue("alice", "request1")
+	require.NoError(t, err)
+	require.NotEqual(t, issued.Token, renewed.Token)
+}
+
+func TestRevoke(t *testing.T) {
+	clk := clock.NewVirtual(time.Unix(0, 0))
+	store := NewStore(clk, 0)
+	issued, err := store.Issue("alice", "request1")
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(0, 0).Add(DefaultTTL), issued.ExpireTime)
+
+	revoked, err := store.Revoke(issued.Token)
+	require.NoError(t, err)
+	require.Equal(t, "alice", revoked.UserId)
+	_, err = store.Validate(issued.Token)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+	_, err = store.Revoke(issued.Token)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+
+	// 重新获取同一个请求的结果不能绕过撤销, 会话过期之后限制解除
+	_, err = store.Issue("alice", "request1")
+	require.ErrorIs(t, err, ErrSessionRevoked)
+	clk.AdvanceTo(issued.ExpireTime.Add(time.Second))
+	_, err = store.Issue("alice", "request1")
+	require.NoError(t, err)
+}
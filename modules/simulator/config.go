@@ -0,0 +1,74 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"zhanghefan123/security/modules/consensus_algorithms"
+	"zhanghefan123/security/protocol"
+)
+
+const (
+	DefaultChainId      = "chain1"              // 默认的链 id
+	DefaultQueueSize    = 100                   // 默认的请求池队列大小
+	DefaultLatency      = 10 * time.Millisecond // 默认的链路时延
+	DefaultHorizon      = 5 * time.Minute       // 默认的最长仿真时间 (虚拟时间)
+	DefaultSettleWindow = 5 * time.Millisecond  // 默认的静止判定窗口 (真实时间)
+	requestIdPrefix     = "sim-request-"        // 仿真请求 id 的前缀, 保证每次运行的请求 id 相同
+)
+
+var (
+	ErrUnknownConsensus = errors.New("consensus type has no registered provider")
+	ErrInvalidNodes     = errors.New("simulation needs at least one node")
+)
+
+// Request 仿真之中的一次认证请求, 在虚拟时间 At 由 Node 接入
+type Request struct {
+	At     time.Duration `json:"at"`
+	Node   string        `json:"node"`
+	UserId string        `json:"user_id"`
+}
+
+// Config 仿真的配置
+type Config struct {
+	Seed          int64                                      // 随机数种子, 决定链路的时延以及丢包
+	Nodes         int                                        // 节点的数量, 节点 id 为 node1 ... nodeN
+	ChainId       string                                     // 链 id
+	LegalUsers    []string                                   // 认证域之中注册的合法用户
+	ConsensusType consensus_algorithms.ConsensusProtocolType // 使用的共识, 需要已经注册了 consensus_provider
+	Requests      []Request                                  // 认证请求
+	Link          LinkModel                                  // 链路模型, 为空的时候使用 DefaultLatency 的固定时延
+	Horizon       time.Duration                              // 最长的仿真时间 (虚拟时间)
+	SettleWindow  time.Duration                              // 投递一个事件之后等待节点处理完成的窗口 (真实时间)
+	QueueSize     int                                        // 每个节点请求池的队列大小
+	Logger        protocol.Logger                            // 所有节点使用的日志记录器
+}
+
+// withDefaults 填充没有设置的配置项
+func (c Config) withDefaults() Config {
+	if c.ChainId == "" {
+		c.ChainId = DefaultChainId
+	}
+	if c.Link == nil {
+		c.Link = FixedLink{Latency: DefaultLatency}
+	}
+	if c.Horizon <= 0 {
+		c.Horizon = DefaultHorizon
+	}
+	if c.SettleWindow <= 0 {
+		c.SettleWindow = DefaultSettleWindow
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultQueueSize
+	}
+	return c
+}
+
+// NodeIds 返回 n 个节点的 id: node1 ... nodeN
+func NodeIds(n int) []string {
+	nodeIds := make([]string, n)
+	for i := range nodeIds {
+		nodeIds[i] = fmt.Sprintf("node%d", i+1)
+	}
+	return nodeIds
+}
@@ -0,0 +1,63 @@
+package simulator
+
+import (
+	"container/heap"
+	"time"
+	"zhanghefan123/security/common/msgbus"
+	"zhanghefan123/security/protobuf/pb-go/net"
+)
+
+// eventKind 调度队列之中事件的类型
+type eventKind int
+
+const (
+	kindRequest eventKind = iota // 请求到达接入节点
+	kindDeliver                  // 消息到达目的节点
+)
+
+// event 调度队列之中的事件, 按照虚拟时间以及加入的顺序执行
+type event struct {
+	at      time.Duration
+	seq     uint64
+	kind    eventKind
+	request int          // kindRequest: 请求在配置之中的位置
+	from    string       // kindDeliver: 发送节点
+	to      string       // kindDeliver: 接收节点
+	topic   msgbus.Topic // kindDeliver: 接收节点上发布的主题
+	msg     *net.NetMsg  // kindDeliver: 消息
+}
+
+// eventQueue 事件的最小堆
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at == q[j].at {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at < q[j].at
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return e
+}
+
+// peek 返回最早的事件
+func (q eventQueue) peek() *event {
+	if len(q) == 0 {
+		return nil
+	}
+	return q[0]
+}
+
+var _ heap.Interface = (*eventQueue)(nil)
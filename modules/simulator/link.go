@@ -0,0 +1,31 @@
+package simulator
+
+import (
+	"math/rand"
+	"time"
+)
+
+// LinkModel 链路模型, 决定每一条消息的传播时延以及是否丢失, 随机性只能来自传入的 rng 才能保证仿真可以复现
+type LinkModel interface {
+	// Delay 返回 from 在虚拟时间 now 发往 to 的消息的传播时延, 返回 false 表示消息丢失
+	Delay(from, to string, now time.Duration, rng *rand.Rand) (time.Duration, bool)
+}
+
+// FixedLink 所有链路相同的时延模型: Latency 加上 [0, Jitter] 之间均匀分布的抖动, 以 LossRate 的概率丢包
+type FixedLink struct {
+	Latency  time.Duration
+	Jitter   time.Duration
+	LossRate float64
+}
+
+// Delay 实现 LinkModel
+func (l FixedLink) Delay(from, to string, now time.Duration, rng *rand.Rand) (time.Duration, bool) {
+	if l.LossRate > 0 && rng.Float64() < l.LossRate {
+		return 0, false
+	}
+	delay := l.Latency
+	if l.Jitter > 0 {
+		delay += time.Duration(rng.Int63n(int64(l.Jitter) + 1))
+	}
+	return delay, true
+}
@@ -0,0 +1,81 @@
+package simulator
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zhanghefan123/security/modules/orbit"
+)
+
+// DefaultOrbitResolution 默认的星座采样间隔, 同一个间隔之内的消息使用相同的卫星位置
+const DefaultOrbitResolution = time.Second
+
+// OrbitLink 根据星座之中卫星的运动决定链路的模型: node1 ... nodeN 依次位于 Placement 之中的卫星上,
+// 仿真的虚拟时间 0 对应星座的 Start 时刻. 节点之间没有可见的链路 (或者多跳路径) 的时候消息丢失,
+// 否则时延为传播时延加上每一跳的 Processing 以及 [0, Jitter] 之间的抖动, 以 LossRate 的概率丢包
+type OrbitLink struct {
+	Constellation *orbit.Constellation
+	Placement     orbit.Placement
+	Start         time.Time     // 虚拟时间 0 对应的星座时刻, 为零值的时候使用第一颗卫星的历元
+	Resolution    time.Duration // 星座的采样间隔, 为 0 的时候使用 DefaultOrbitResolution
+	Processing    time.Duration // 每一跳的处理时延
+	Jitter        time.Duration
+	LossRate      float64
+
+	mutex    sync.Mutex
+	sampled  time.Duration // 缓存的链路对应的采样时刻
+	links    [][]orbit.Link
+	hasCache bool
+}
+
+// Delay 实现 LinkModel
+func (l *OrbitLink) Delay(from, to string, now time.Duration, rng *rand.Rand) (time.Duration, bool) {
+	fromIndex, fromOk := nodeIndex(from)
+	toIndex, toOk := nodeIndex(to)
+	if !fromOk || !toOk || fromIndex >= len(l.Placement.Satellites) || toIndex >= len(l.Placement.Satellites) {
+		return 0, false
+	}
+	link := l.linksAt(now)[fromIndex][toIndex]
+	if !link.Visible {
+		return 0, false
+	}
+	if l.LossRate > 0 && rng.Float64() < l.LossRate {
+		return 0, false
+	}
+	delay := link.Delay + time.Duration(link.Hops)*l.Processing
+	if l.Jitter > 0 {
+		delay += time.Duration(rng.Int63n(int64(l.Jitter) + 1))
+	}
+	return delay, true
+}
+
+// linksAt 返回 now 所在的采样时刻节点之间的链路
+func (l *OrbitLink) linksAt(now time.Duration) [][]orbit.Link {
+	resolution := l.Resolution
+	if resolution <= 0 {
+		resolution = DefaultOrbitResolution
+	}
+	sampled := now - now%resolution
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.hasCache || l.sampled != sampled {
+		start := l.Start
+		if start.IsZero() {
+			start = l.Constellation.Satellites[0].Epoch
+		}
+		l.links = l.Constellation.Links(start.Add(sampled), l.Placement)
+		l.sampled, l.hasCache = sampled, true
+	}
+	return l.links
+}
+
+// nodeIndex 解析 NodeIds 生成的节点 id, node1 对应 0
+func nodeIndex(nodeId string) (int, bool) {
+	index, err := strconv.Atoi(strings.TrimPrefix(nodeId, "node"))
+	if err != nil || index <= 0 {
+		return 0, false
+	}
+	return index - 1, true
+}
@@ -0,0 +1,436 @@
+package simulator
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"zhanghefan123/security/common/msgbus"
+	consensusutils "zhanghefan123/security/consensus-utils"
+	"zhanghefan123/security/modules/clock"
+	"zhanghefan123/security/modules/consensus_provider"
+	"zhanghefan123/security/modules/request_pool"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/modules/utils"
+	"zhanghefan123/security/protobuf/pb-go/net"
+	"zhanghefan123/security/protocol"
+	"zhanghefan123/security/protocol/test"
+)
+
+// Epoch 虚拟时间的起点, 轨迹之中的时间都是相对于它的偏移
+var Epoch = time.Unix(0, 0).UTC()
+
+// recvTopics 发送主题对应的接收主题, 和 NetService 保持一致
+var recvTopics = map[msgbus.Topic]msgbus.Topic{
+	msgbus.SendConsensusMsg: msgbus.RecvConsensusMsg,
+	msgbus.SendTxPoolMsg:    msgbus.RecvTxPoolMsg,
+}
+
+// node 仿真之中的一个节点
+type node struct {
+	id     string
+	bus    msgbus.MessageBus
+	pool   *request_pool.RequestPool
+	engine protocol.ConsensusEngine
+}
+
+// outMessage 节点发出的还没有调度的消息
+type outMessage struct {
+	from  string
+	topic msgbus.Topic
+	msg   *net.NetMsg
+}
+
+// Simulator 确定性的离散事件仿真器.
+// 所有的节点共享一个虚拟时钟, 消息的投递由调度队列按照虚拟时间逐个执行, 每执行一个事件都等待所有节点处理完成,
+// 然后将这期间产生的消息按照固定的顺序排序之后再交给链路模型, 因此同样的配置以及种子总是产生同样的轨迹
+type Simulator struct {
+	config  Config
+	clock   *clock.Virtual
+	rng     *rand.Rand
+	nodeIds []string
+	nodes   map[string]*node
+	queue   eventQueue
+	seq     uint64
+	trace   *Trace
+
+	replaying   bool         // 是否在回放轨迹
+	replaySends []TraceEvent // 回放的时候按照顺序使用原始轨迹之中的时延以及丢包
+	replayIndex int
+
+	activity uint64 // 节点产生消息或者返回结果的次数, 用于判断节点是否处理完成
+	mutex    sync.Mutex
+	outbox   []*outMessage
+	replies  []TraceEvent
+}
+
+// New 根据配置创建仿真器, 使用 consensus_provider 之中注册的共识
+func New(config Config) (*Simulator, error) {
+	config = config.withDefaults()
+	if config.Nodes <= 0 {
+		return nil, ErrInvalidNodes
+	}
+	provider := consensus_provider.GetConsensusProvider(config.ConsensusType)
+	if provider == nil {
+		return nil, ErrUnknownConsensus
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = test.HoleLogger{}
+	}
+
+	s := &Simulator{
+		config:  config,
+		clock:   clock.NewVirtual(Epoch),
+		rng:     rand.New(rand.NewSource(config.Seed)),
+		nodeIds: NodeIds(config.Nodes),
+		nodes:   make(map[string]*node),
+		trace: &Trace{Header: TraceHeader{
+			Seed:          config.Seed,
+			Nodes:         config.Nodes,
+			ChainId:       config.ChainId,
+			LegalUsers:    config.LegalUsers,
+			ConsensusType: config.ConsensusType,
+			Requests:      config.Requests,
+			Horizon:       config.Horizon,
+		}},
+	}
+
+	for _, nodeId := range s.nodeIds {
+		n := &node{
+			id:   nodeId,
+			bus:  msgbus.NewMessageBus(),
+			pool: request_pool.NewRequestPool(config.QueueSize, nil),
+		}
+		engine, err := provider(&consensusutils.ConsensusImplConfig{
+			ChainId:     config.ChainId,
+			NodeId:      nodeId,
+			MsgBus:      n.bus,
+			Logger:      logger,
+			RequestPool: n.pool,
+			Clock:       s.clock,
+			Validators:  s.nodeIds,
+			LegalUsers:  append([]string{}, config.LegalUsers...),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create consensus of %s failed, %v", nodeId, err)
+		}
+		n.engine = engine
+		for topic := range recvTopics {
+			n.bus.Register(topic, &port{simulator: s, nodeId: nodeId})
+		}
+		s.nodes[nodeId] = n
+	}
+	return s, nil
+}
+
+// Replay 按照轨迹的配置重新运行仿真, 时延以及丢包使用轨迹之中记录的值, 返回第一个和原始轨迹不一致的事件
+func Replay(trace *Trace, logger protocol.Logger) (*Trace, error) {
+	header := trace.Header
+	s, err := New(Config{
+		Seed:          header.Seed,
+		Nodes:         header.Nodes,
+		ChainId:       header.ChainId,
+		LegalUsers:    header.LegalUsers,
+		ConsensusType: header.ConsensusType,
+		Requests:      header.Requests,
+		Horizon:       header.Horizon,
+		Logger:        logger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range trace.Events {
+		if event.Kind == EventSend {
+			s.replaySends = append(s.replaySends, event)
+		}
+	}
+	s.replaying = true
+
+	replayed, err := s.Run()
+	if err != nil {
+		return replayed, err
+	}
+	if divergence := Compare(trace, replayed); divergence != nil {
+		return replayed, divergence
+	}
+	return replayed, nil
+}
+
+// Run 运行仿真直到没有待执行的事件或者超过了最长仿真时间, 返回记录的轨迹
+func (s *Simulator) Run() (*Trace, error) {
+	for _, nodeId := range s.nodeIds {
+		n := s.nodes[nodeId]
+		n.pool.Start()
+		if err := n.engine.Start(); err != nil {
+			return nil, fmt.Errorf("start consensus of %s failed, %v", nodeId, err)
+		}
+	}
+	defer s.stop()
+
+	for i, request := range s.config.Requests {
+		s.schedule(&event{at: request.At, kind: kindRequest, request: i})
+	}
+
+	for {
+		s.settle()
+		if err := s.flush(); err != nil {
+			return s.trace, err
+		}
+		if !s.step() {
+			break
+		}
+	}
+	return s.trace, nil
+}
+
+// stop 停止所有的节点, 消息总线关闭之后仍在发送的协程会向已经关闭的 channel 写入, 因此不关闭消息总线
+func (s *Simulator) stop() {
+	for _, nodeId := range s.nodeIds {
+		n := s.nodes[nodeId]
+		_ = n.engine.Stop()
+		n.pool.Stop()
+	}
+}
+
+// now 返回当前的虚拟时间
+func (s *Simulator) now() time.Duration {
+	return s.clock.Now().Sub(Epoch)
+}
+
+// schedule 将事件加入调度队列
+func (s *Simulator) schedule(e *event) {
+	s.seq++
+	e.seq = s.seq
+	heap.Push(&s.queue, e)
+}
+
+// record 记录轨迹事件
+func (s *Simulator) record(event TraceEvent) {
+	s.trace.Events = append(s.trace.Events, event)
+}
+
+// step 执行下一个事件, 同一时刻先触发计时器再执行调度队列之中的事件, 没有事件或者超过最长仿真时间的时候返回 false
+func (s *Simulator) step() bool {
+	next := s.queue.peek()
+	deadline, hasTimer := s.clock.NextDeadline()
+	if hasTimer && (next == nil || deadline.Sub(Epoch) <= next.at) {
+		if deadline.Sub(Epoch) > s.config.Horizon {
+			return false
+		}
+		fired := s.clock.AdvanceTo(deadline)
+		s.record(TraceEvent{At: s.now(), Kind: EventTimer, Fired: fired})
+		return true
+	}
+	if next == nil || next.at > s.config.Horizon {
+		return false
+	}
+	heap.Pop(&s.queue)
+	s.clock.AdvanceTo(Epoch.Add(next.at))
+
+	switch next.kind {
+	case kindRequest:
+		s.submit(next.request)
+	case kindDeliver:
+		s.record(TraceEvent{
+			At:      s.now(),
+			Kind:    EventDeliver,
+			From:    next.from,
+			To:      next.to,
+			MsgType: next.msg.Type.String(),
+			Payload: next.msg.Payload,
+		})
+		// 和 NetService 一样, 接收方看到的 To 为发送节点
+		s.nodes[next.to].bus.Publish(next.topic, &net.NetMsg{
+			Payload: next.msg.Payload,
+			Type:    next.msg.Type,
+			To:      next.from,
+		})
+	}
+	return true
+}
+
+// submit 将第 index 个请求提交到接入节点的请求池, 在新的协程之中等待结果
+func (s *Simulator) submit(index int) {
+	request := s.config.Requests[index]
+	requestId := fmt.Sprintf("%s%d", requestIdPrefix, index)
+	s.record(TraceEvent{
+		At:        s.now(),
+		Kind:      EventRequest,
+		To:        request.Node,
+		UserId:    request.UserId,
+		RequestId: requestId,
+	})
+
+	n, ok := s.nodes[request.Node]
+	if !ok {
+		s.addReply(request, requestId, "unknown node")
+		return
+	}
+	finishChannel := make(chan *pb.RpcMessage, 1)
+	message := &pb.RpcMessage{
+		Type:    pb.RpcMessageType_AuthRequest,
+		Content: utils.MustMarshal(&pb.AuthenticationRequest{UserId: request.UserId}),
+	}
+	poolRequest := request_pool.NewRequest(context.Background(), request.UserId, message, finishChannel)
+	poolRequest.RequestId = requestId
+	if err := n.pool.AddRequest(poolRequest); err != nil {
+		s.addReply(request, requestId, err.Error())
+		return
+	}
+	go func() {
+		result, ok := <-finishChannel
+		if !ok {
+			s.addReply(request, requestId, "abandoned")
+			return
+		}
+		reply := &pb.AuthenticationReply{}
+		utils.MustUnmarshal(result.Content, reply)
+		s.addReply(request, requestId, reply.Result.String())
+	}()
+}
+
+// addReply 记录接入节点返回的结果, 在下一次 flush 的时候写入轨迹
+func (s *Simulator) addReply(request Request, requestId string, result string) {
+	s.mutex.Lock()
+	s.replies = append(s.replies, TraceEvent{
+		Kind:      EventReply,
+		From:      request.Node,
+		UserId:    request.UserId,
+		RequestId: requestId,
+		Result:    result,
+	})
+	s.mutex.Unlock()
+	atomic.AddUint64(&s.activity, 1)
+}
+
+// settle 等待所有的节点处理完成: 在 SettleWindow 之内没有新的消息, 结果以及计时器
+func (s *Simulator) settle() {
+	last := s.fingerprint()
+	for {
+		time.Sleep(s.config.SettleWindow)
+		current := s.fingerprint()
+		if current == last {
+			return
+		}
+		last = current
+	}
+}
+
+// fingerprint 节点活动的指纹
+func (s *Simulator) fingerprint() [2]uint64 {
+	return [2]uint64{atomic.LoadUint64(&s.activity), s.clock.Generation()}
+}
+
+// flush 将上一个事件执行期间产生的消息以及结果按照固定的顺序写入轨迹, 并且根据链路模型调度消息的投递
+func (s *Simulator) flush() error {
+	s.mutex.Lock()
+	outbox, replies := s.outbox, s.replies
+	s.outbox, s.replies = nil, nil
+	s.mutex.Unlock()
+
+	now := s.now()
+	sends := make([]*event, 0, len(outbox))
+	for _, out := range outbox {
+		if out.msg.To != "" {
+			if _, ok := s.nodes[out.msg.To]; ok {
+				sends = append(sends, &event{from: out.from, to: out.msg.To, topic: recvTopics[out.topic], msg: out.msg})
+			}
+			continue
+		}
+		for _, nodeId := range s.nodeIds {
+			if nodeId != out.from {
+				sends = append(sends, &event{from: out.from, to: nodeId, topic: recvTopics[out.topic], msg: out.msg})
+			}
+		}
+	}
+	sort.SliceStable(sends, func(i, j int) bool {
+		a, b := sends[i], sends[j]
+		if a.from != b.from {
+			return a.from < b.from
+		}
+		if a.to != b.to {
+			return a.to < b.to
+		}
+		if a.topic != b.topic {
+			return a.topic < b.topic
+		}
+		if a.msg.Type != b.msg.Type {
+			return a.msg.Type < b.msg.Type
+		}
+		return bytes.Compare(a.msg.Payload, b.msg.Payload) < 0
+	})
+
+	for _, send := range sends {
+		sendEvent := TraceEvent{
+			At:      now,
+			Kind:    EventSend,
+			From:    send.from,
+			To:      send.to,
+			MsgType: send.msg.Type.String(),
+			Payload: send.msg.Payload,
+		}
+		delay, delivered, err := s.decide(sendEvent)
+		if err != nil {
+			return err
+		}
+		sendEvent.Delay, sendEvent.Dropped = delay, !delivered
+		s.record(sendEvent)
+		if delivered {
+			send.at = now + delay
+			send.kind = kindDeliver
+			s.schedule(send)
+		}
+	}
+
+	sort.Slice(replies, func(i, j int) bool {
+		return replies[i].RequestId < replies[j].RequestId
+	})
+	for _, reply := range replies {
+		reply.At = now
+		s.record(reply)
+	}
+	return nil
+}
+
+// decide 决定一条消息的时延以及是否送达, 回放的时候使用原始轨迹之中的值
+func (s *Simulator) decide(send TraceEvent) (time.Duration, bool, error) {
+	if !s.replaying {
+		delay, delivered := s.config.Link.Delay(send.From, send.To, send.At, s.rng)
+		return delay, delivered, nil
+	}
+	if s.replayIndex >= len(s.replaySends) {
+		return 0, false, &DivergenceError{Index: len(s.trace.Events), Actual: &send}
+	}
+	expected := s.replaySends[s.replayIndex]
+	s.replayIndex++
+	if expected.At != send.At || expected.From != send.From || expected.To != send.To ||
+		expected.MsgType != send.MsgType || !bytes.Equal(expected.Payload, send.Payload) {
+		return 0, false, &DivergenceError{Index: len(s.trace.Events), Expected: &expected, Actual: &send}
+	}
+	return expected.Delay, !expected.Dropped, nil
+}
+
+// port 订阅某个节点的发送主题, 将节点发出的消息放入 outbox
+type port struct {
+	simulator *Simulator
+	nodeId    string
+}
+
+// OnMessage 收到节点发送的消息
+func (p *port) OnMessage(message *msgbus.Message) {
+	if msg, ok := message.Payload.(*net.NetMsg); ok {
+		p.simulator.mutex.Lock()
+		p.simulator.outbox = append(p.simulator.outbox, &outMessage{from: p.nodeId, topic: message.Topic, msg: msg})
+		p.simulator.mutex.Unlock()
+		atomic.AddUint64(&p.simulator.activity, 1)
+	}
+}
+
+// OnQuit 消息总线关闭
+func (p *port) OnQuit() {}
@@ -0,0 +1,146 @@
+package simulator
+
+import (
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+	consensusutils "zhanghefan123/security/consensus-utils"
+	"zhanghefan123/security/modules/consensus_algorithms"
+	"zhanghefan123/security/modules/consensus_algorithms/pbft"
+	"zhanghefan123/security/modules/consensus_provider"
+	"zhanghefan123/security/modules/orbit"
+	"zhanghefan123/security/protocol"
+)
+
+func init() {
+	consensus_provider.RegisterConsensusProvider(consensus_algorithms.ConsensusType_PBFT,
+		func(config *consensusutils.ConsensusImplConfig) (protocol.ConsensusEngine, error) {
+			return pbft.New(config)
+		})
+}
+
+// testConfig 4 个节点, 一个合法用户以及一个非法用户, 带有抖动以及丢包的链路
+func testConfig(seed int64) Config {
+	return Config{
+		Seed:          seed,
+		Nodes:         4,
+		LegalUsers:    []string{"alice"},
+		ConsensusType: consensus_algorithms.ConsensusType_PBFT,
+		Requests: []Request{
+			{At: 0, Node: "node1", UserId: "alice"},
+			{At: 5 * time.Millisecond, Node: "node3", UserId: "mallory"},
+		},
+		Link: FixedLink{Latency: 20 * time.Millisecond, Jitter: 10 * time.Millisecond},
+	}
+}
+
+// run 创建并运行仿真
+func run(t *testing.T, config Config) *Trace {
+	s, err := New(config)
+	require.NoError(t, err)
+	trace, err := s.Run()
+	require.NoError(t, err)
+	return trace
+}
+
+func TestSimulatorAuthenticates(t *testing.T) {
+	trace := run(t, testConfig(1))
+	replies := trace.Replies()
+	require.Len(t, replies, 2)
+	require.Equal(t, "alice", replies[0].UserId)
+	require.Equal(t, "LegalUser", replies[0].Result)
+	require.Equal(t, "mallory", replies[1].UserId)
+	require.Equal(t, "IllegalUser", replies[1].Result)
+	for _, reply := range replies {
+		// 每一轮至少需要 pending request, prePrepare, prepare, commit 以及 reply 的传播时延
+		require.Greater(t, reply.At, 3*20*time.Millisecond)
+	}
+}
+
+func TestSimulatorIsDeterministic(t *testing.T) {
+	first := run(t, testConfig(7))
+	second := run(t, testConfig(7))
+	require.Nil(t, Compare(first, second))
+
+	other := run(t, testConfig(8))
+	require.NotNil(t, Compare(first, other))
+}
+
+func TestSimulatorConsensusTimeout(t *testing.T) {
+	config := testConfig(3)
+	config.Link = FixedLink{Latency: 20 * time.Millisecond, LossRate: 1}
+	trace := run(t, config)
+	replies := trace.Replies()
+	require.Len(t, replies, 2)
+	for _, reply := range replies {
+		require.Equal(t, "ConsensusTimeout", reply.Result)
+		require.GreaterOrEqual(t, reply.At, 30*time.Second)
+	}
+}
+
+func TestSimulatorReplay(t *testing.T) {
+	config := testConfig(11)
+	config.Link = FixedLink{Latency: 20 * time.Millisecond, Jitter: 30 * time.Millisecond, LossRate: 0.05}
+	trace := run(t, config)
+
+	path := filepath.Join(t.TempDir(), "trace.json")
+	require.NoError(t, trace.Save(path))
+	loaded, err := LoadTrace(path)
+	require.NoError(t, err)
+	require.Nil(t, Compare(trace, loaded))
+
+	replayed, err := Replay(loaded, nil)
+	require.NoError(t, err)
+	require.Nil(t, Compare(trace, replayed))
+
+	// 篡改其中一条消息之后回放会在这条消息的位置报告不一致
+	for i, event := range loaded.Events {
+		if event.Kind == EventSend {
+			loaded.Events[i].Payload = append([]byte{}, event.Payload...)
+			loaded.Events[i].Payload[0] ^= 0xff
+			_, err = Replay(loaded, nil)
+			divergence, ok := err.(*DivergenceError)
+			require.True(t, ok, "%v", err)
+			require.Equal(t, i, divergence.Index)
+			break
+		}
+	}
+}
+
+func TestSimulatorOrbitLink(t *testing.T) {
+	// 12 颗卫星的一个轨道面, 4 个节点相隔 90 度, 节点之间的直线穿过地球, 只能经过中间的卫星转发
+	elements, err := orbit.Walker{Satellites: 12, Planes: 1}.Elements()
+	require.NoError(t, err)
+	constellation, err := orbit.NewConstellation(elements)
+	require.NoError(t, err)
+	link := &OrbitLink{
+		Constellation: constellation,
+		Placement:     orbit.Placement{Satellites: []int{0, 3, 6, 9}, MultiHop: true},
+		Processing:    time.Millisecond,
+	}
+	hop := constellation.Snapshot(orbit.J2000).Link(0, 1).Delay + time.Millisecond
+	delay, delivered := link.Delay("node1", "node2", 0, nil)
+	require.True(t, delivered)
+	require.InDelta(t, float64(3*hop), float64(delay), float64(time.Microsecond))
+
+	config := testConfig(5)
+	config.Link = link
+	replies := run(t, config).Replies()
+	require.Len(t, replies, 2)
+	require.Equal(t, "LegalUser", replies[0].Result)
+	require.Greater(t, replies[0].At, 3*3*hop)
+
+	// 没有多跳路由的时候节点之间无法通信
+	config.Link = &OrbitLink{Constellation: constellation, Placement: orbit.Placement{Satellites: []int{0, 3, 6, 9}}}
+	for _, reply := range run(t, config).Replies() {
+		require.Equal(t, "ConsensusTimeout", reply.Result)
+	}
+}
+
+func TestSimulatorUnknownConsensus(t *testing.T) {
+	_, err := New(Config{Nodes: 4, ConsensusType: 99})
+	require.Equal(t, ErrUnknownConsensus, err)
+	_, err = New(Config{ConsensusType: consensus_algorithms.ConsensusType_PBFT})
+	require.Equal(t, ErrInvalidNodes, err)
+}
@@ -0,0 +1,124 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"time"
+	"zhanghefan123/security/modules/consensus_algorithms"
+)
+
+// 轨迹之中的事件类型
+const (
+	EventRequest = "request" // 用户的请求到达接入节点
+	EventSend    = "send"    // 节点发出一条消息, 记录这条消息的时延或者丢失
+	EventDeliver = "deliver" // 消息到达目的节点
+	EventTimer   = "timer"   // 节点的计时器到期
+	EventReply   = "reply"   // 接入节点返回认证结果
+)
+
+// TraceEvent 轨迹之中的一个事件, At 为事件发生的虚拟时间
+type TraceEvent struct {
+	At        time.Duration `json:"at"`
+	Kind      string        `json:"kind"`
+	From      string        `json:"from,omitempty"`
+	To        string        `json:"to,omitempty"`
+	MsgType   string        `json:"msg_type,omitempty"`
+	Payload   []byte        `json:"payload,omitempty"`
+	Delay     time.Duration `json:"delay,omitempty"`
+	Dropped   bool          `json:"dropped,omitempty"`
+	UserId    string        `json:"user_id,omitempty"`
+	RequestId string        `json:"request_id,omitempty"`
+	Result    string        `json:"result,omitempty"`
+	Fired     int           `json:"fired,omitempty"`
+}
+
+// TraceHeader 复现一次仿真需要的配置
+type TraceHeader struct {
+	Seed          int64                                      `json:"seed"`
+	Nodes         int                                        `json:"nodes"`
+	ChainId       string                                     `json:"chain_id"`
+	LegalUsers    []string                                   `json:"legal_users"`
+	ConsensusType consensus_algorithms.ConsensusProtocolType `json:"consensus_type"`
+	Requests      []Request                                  `json:"requests"`
+	Horizon       time.Duration                              `json:"horizon"`
+}
+
+// Trace 一次仿真的完整轨迹, 可以保存到文件之中并且逐个事件地进行回放
+type Trace struct {
+	Header TraceHeader  `json:"header"`
+	Events []TraceEvent `json:"events"`
+}
+
+// DivergenceError 回放的时候第一个和原始轨迹不一致的事件
+type DivergenceError struct {
+	Index    int         // 事件在轨迹之中的位置
+	Expected *TraceEvent // 原始轨迹之中的事件, 原始轨迹更短的时候为空
+	Actual   *TraceEvent // 回放产生的事件, 回放更早结束的时候为空
+}
+
+// Error 实现 error 接口
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("replay diverged at event %d: expected %s, got %s",
+		e.Index, describeEvent(e.Expected), describeEvent(e.Actual))
+}
+
+// describeEvent 事件的简短描述, 用于错误信息
+func describeEvent(event *TraceEvent) string {
+	if event == nil {
+		return "end of trace"
+	}
+	return fmt.Sprintf("%s at %s from [%s] to [%s] %s (%d bytes)",
+		event.Kind, event.At, event.From, event.To, event.MsgType, len(event.Payload))
+}
+
+// Replies 返回轨迹之中所有的认证结果
+func (t *Trace) Replies() []TraceEvent {
+	replies := make([]TraceEvent, 0)
+	for _, event := range t.Events {
+		if event.Kind == EventReply {
+			replies = append(replies, event)
+		}
+	}
+	return replies
+}
+
+// Save 将轨迹保存为 json 文件
+func (t *Trace) Save(path string) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// LoadTrace 从 json 文件之中加载轨迹
+func LoadTrace(path string) (*Trace, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trace := &Trace{}
+	if err = json.Unmarshal(data, trace); err != nil {
+		return nil, fmt.Errorf("parse trace %s failed, %v", path, err)
+	}
+	return trace, nil
+}
+
+// Compare 逐个事件地比较两个轨迹, 完全一致的时候返回 nil
+func Compare(expected, actual *Trace) *DivergenceError {
+	for i := 0; i < len(expected.Events) || i < len(actual.Events); i++ {
+		var e, a *TraceEvent
+		if i < len(expected.Events) {
+			e = &expected.Events[i]
+		}
+		if i < len(actual.Events) {
+			a = &actual.Events[i]
+		}
+		if e == nil || a == nil || !reflect.DeepEqual(*e, *a) {
+			return &DivergenceError{Index: i, Expected: e, Actual: a}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+//go:build go1.18
+// +build go1.18
+
+package utils
+
+import (
+	"github.com/golang/protobuf/proto"
+	"testing"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// 运行方式: go test -run '^$' -fuzz FuzzUnmarshalRpcMessage ./utils
+
+func FuzzUnmarshalRpcMessage(f *testing.F) {
+	f.Add(MustMarshal(&pb.RpcMessage{
+		Type:    pb.RpcMessageType_AuthRequest,
+		Content: MustMarshal(&pb.AuthenticationRequest{UserId: "alice", RequestId: "request1"}),
+	}))
+	f.Add(MustMarshal(&pb.RpcMessage{
+		Type:    pb.RpcMessageType_AuthReply,
+		Content: MustMarshal(&pb.AuthenticationReply{UserId: "alice", Result: pb.AuthenticationResult_LegalUser}),
+	}))
+	f.Add([]byte{})
+	f.Add([]byte{0x12, 0x03, 0x0a, 0x01, 0xff})
+	f.Add([]byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		message := new(pb.RpcMessage)
+		if Unmarshal(data, message) != nil {
+			return
+		}
+		decoded := new(pb.RpcMessage)
+		if err := Unmarshal(MustMarshal(message), decoded); err != nil || !proto.Equal(message, decoded) {
+			t.Fatalf("re-encoded rpc message changed: %v != %v, %v", message, decoded, err)
+		}
+		// 接入节点按照消息的类型解析其中的内容, 内容同样来自客户端
+		switch message.Type {
+		case pb.RpcMessageType_AuthRequest:
+			_ = Unmarshal(message.Content, new(pb.AuthenticationRequest))
+		case pb.RpcMessageType_AuthReply:
+			_ = Unmarshal(message.Content, new(pb.AuthenticationReply))
+		}
+	})
+}
@@ -1,6 +1,10 @@
 package utils
 
-import "github.com/gogo/protobuf/proto"
+// 生成的消息既有 protoc-gen-go 生成的, 也有 gogo 生成的, 使用 golang/protobuf 的 API 两者都可以处理
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+)
 
 // MustMarshal marshals protobuf message to byte slice or panic when marshal twice both failed
 func MustMarshal(msg proto.Message) (data []byte) {
@@ -22,7 +26,20 @@ func MustMarshal(msg proto.Message) (data []byte) {
 	return
 }
 
-// MustUnmarshal from byte slice to protobuf message or panic
+// Unmarshal from byte slice to protobuf message, returns error instead of panic while decoding untrusted bytes
+func Unmarshal(b []byte, msg proto.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unmarshal %T panic: %v", msg, r)
+		}
+	}()
+	if err = proto.Unmarshal(b, msg); err != nil {
+		return fmt.Errorf("unmarshal %T: %w", msg, err)
+	}
+	return nil
+}
+
+// MustUnmarshal from byte slice to protobuf message or panic, only used for bytes produced locally
 func MustUnmarshal(b []byte, msg proto.Message) {
 	if err := proto.Unmarshal(b, msg); err != nil {
 		panic(err)
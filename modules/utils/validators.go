@@ -7,7 +7,7 @@ import (
 
 func GetValidatorsFromLocalConfig() []string {
 	seeds := localconf.ChainMakerConfig.NetConfig.Seeds
-	validators := make([]string, len(seeds))
+	validators := make([]string, 0, len(seeds))
 	for _, multiAddr := range seeds {
 		differentParts := strings.Split(multiAddr, "/")
 		lastPart := differentParts[len(differentParts)-1]
@@ -26,3 +26,36 @@ func GetValidatorsMapFromLocalConfig() map[string]struct{} {
 	}
 	return validators
 }
+
+// GetValidatorsOfChain 获取某条链的验证者, 链没有单独配置验证者的时候使用 seeds 之中的节点
+func GetValidatorsOfChain(chainId string) []string {
+	chainConfig, ok := localconf.ChainMakerConfig.GetBlockChain(chainId)
+	if !ok || len(chainConfig.Validators) == 0 {
+		return GetValidatorsFromLocalConfig()
+	}
+	validators := make([]string, len(chainConfig.Validators))
+	copy(validators, chainConfig.Validators)
+	return validators
+}
+
+// GetValidatorsMapOfChain 获取某条链的验证者集合
+func GetValidatorsMapOfChain(chainId string) map[string]struct{} {
+	validators := make(map[string]struct{})
+	for _, validator := range GetValidatorsOfChain(chainId) {
+		validators[validator] = struct{}{}
+	}
+	return validators
+}
+
+// GetLegalUsersOfChain 获取某条链的认证域之中注册的合法用户
+func GetLegalUsersOfChain(chainId string) map[string]interface{} {
+	legalUsers := make(map[string]interface{})
+	chainConfig, ok := localconf.ChainMakerConfig.GetBlockChain(chainId)
+	if !ok {
+		return legalUsers
+	}
+	for _, user := range chainConfig.LegalUsers {
+		legalUsers[user] = struct{}{}
+	}
+	return legalUsers
+}
@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"zhanghefan123/security/localconf"
+)
+
+func TestValidatorsOfChain(t *testing.T) {
+	origin := localconf.ChainMakerConfig
+	defer func() { localconf.ChainMakerConfig = origin }()
+
+	localconf.ChainMakerConfig = &localconf.CMConfig{}
+	localconf.ChainMakerConfig.NetConfig.Seeds = []string{
+		"/ip4/127.0.0.1/tcp/11301/p2p/QmNode1",
+		"/ip4/127.0.0.1/tcp/11302/p2p/QmNode2",
+	}
+	localconf.ChainMakerConfig.BlockChainConfig = []localconf.BlockchainConfig{
+		{ChainId: "chain1"},
+		{ChainId: "chain2", Validators: []string{"QmNode2"}, LegalUsers: []string{"alice"}},
+	}
+
+	// 没有单独配置验证者的链使用 seeds 之中的节点
+	require.Equal(t, []string{"QmNode1", "QmNode2"}, GetValidatorsOfChain("chain1"))
+	require.Equal(t, []string{"QmNode2"}, GetValidatorsOfChain("chain2"))
+	require.Equal(t, map[string]struct{}{"QmNode2": {}}, GetValidatorsMapOfChain("chain2"))
+
+	// 每条链有自己的认证域
+	require.Empty(t, GetLegalUsersOfChain("chain1"))
+	require.Contains(t, GetLegalUsersOfChain("chain2"), "alice")
+	require.Empty(t, GetLegalUsersOfChain("unknown"))
+}
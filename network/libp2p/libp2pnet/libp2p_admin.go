@@ -0,0 +1,187 @@
+package libp2pnet
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ErrorNetNotRunning will be returned if the operation needs a running net.
+var ErrorNetNotRunning = errors.New("net is not running")
+
+// PeerState is the connection state of a peer known by the local node.
+type PeerState struct {
+	PeerId      string
+	Addresses   []string
+	Connected   bool
+	Blacklisted bool
+	ConnCount   int
+	IsSeed      bool
+}
+
+// parseBlackAddress split a black address (e.g. 192.168.1.14:8080 or 192.168.1.14) into ip and port,
+// port will be -1 if not given.
+func parseBlackAddress(addr string) (string, int, error) {
+	s := strings.Split(strings.ReplaceAll(addr, "：", ":"), ":")
+	ip := s[0]
+	var port = -1
+	if len(s) > 1 {
+		p, err := strconv.Atoi(s[1])
+		if err != nil {
+			return "", 0, err
+		}
+		port = p
+	}
+	return ip, port, nil
+}
+
+// remoteIPAndPort return ip and port of the remote side of the connection given.
+func remoteIPAndPort(conn network.Conn) (string, int) {
+	s := strings.Split(conn.RemoteMultiaddr().String(), "/")
+	if len(s) < 5 {
+		return "", 0
+	}
+	port, _ := strconv.Atoi(s[4])
+	return s[2], port
+}
+
+// PeerStates return the connection states of all peers known by the local node,
+// including the seeds which have not been connected yet.
+func (ln *LibP2pNet) PeerStates() ([]*PeerState, error) {
+	if !ln.IsRunning() {
+		return nil, ErrorNetNotRunning
+	}
+	self := ln.libP2pHost.host.ID()
+	states := make(map[peer.ID]*PeerState)
+	order := make([]peer.ID, 0)
+	getState := func(pid peer.ID) *PeerState {
+		if s, ok := states[pid]; ok {
+			return s
+		}
+		s := &PeerState{PeerId: pid.Pretty(), Addresses: make([]string, 0)}
+		states[pid] = s
+		order = append(order, pid)
+		return s
+	}
+	// seeds
+	if ln.libP2pHost.connSupervisor != nil {
+		for _, info := range ln.libP2pHost.connSupervisor.getPeerAddrInfos() {
+			s := getState(info.ID)
+			s.IsSeed = true
+			for _, addr := range info.Addrs {
+				s.Addresses = append(s.Addresses, addr.String())
+			}
+		}
+	}
+	// peers in peerstore
+	for _, pid := range ln.libP2pHost.host.Peerstore().Peers() {
+		if pid == self {
+			continue
+		}
+		s := getState(pid)
+		if len(s.Addresses) == 0 {
+			for _, addr := range ln.libP2pHost.host.Peerstore().Addrs(pid) {
+				s.Addresses = append(s.Addresses, addr.String())
+			}
+		}
+	}
+	// connection state
+	for _, pid := range order {
+		s := states[pid]
+		conns := ln.libP2pHost.connManager.GetConns(pid)
+		s.ConnCount = len(conns)
+		s.Connected = len(conns) > 0
+		s.Blacklisted = ln.libP2pHost.blackList.ContainsPeerId(pid)
+		for _, conn := range conns {
+			if conn == nil || conn.RemoteMultiaddr() == nil {
+				continue
+			}
+			ip, port := remoteIPAndPort(conn)
+			if ln.libP2pHost.blackList.ContainsIPAndPort(ip, port) {
+				s.Blacklisted = true
+			}
+		}
+	}
+	result := make([]*PeerState, 0, len(order))
+	for _, pid := range order {
+		result = append(result, states[pid])
+	}
+	return result, nil
+}
+
+// AddBlackAddress add an address to blacklist at runtime, connections from the address will be closed.
+// If the net has not been started, the address will be added to prepare.
+func (ln *LibP2pNet) AddBlackAddress(addr string) error {
+	ip, port, err := parseBlackAddress(addr)
+	if err != nil {
+		ln.log.Errorf("[Net] parse port failed, %s", err.Error())
+		return err
+	}
+	if !ln.IsRunning() {
+		ln.prepare.AddBlackAddress(addr)
+		return nil
+	}
+	ln.libP2pHost.blackList.AddIPAndPort(ip, port)
+	for _, conn := range ln.libP2pHost.host.Network().Conns() {
+		connIp, connPort := remoteIPAndPort(conn)
+		if ln.libP2pHost.blackList.ContainsIPAndPort(connIp, connPort) {
+			ln.log.Infof("[Net] close connection of black address(remote addr:%s)", conn.RemoteMultiaddr().String())
+			_ = conn.Close()
+		}
+	}
+	ln.log.Infof("[Net] black address added[%s]", addr)
+	return nil
+}
+
+// RemoveBlackAddress remove an address from blacklist at runtime.
+func (ln *LibP2pNet) RemoveBlackAddress(addr string) error {
+	ip, port, err := parseBlackAddress(addr)
+	if err != nil {
+		ln.log.Errorf("[Net] parse port failed, %s", err.Error())
+		return err
+	}
+	ln.prepare.lock.Lock()
+	delete(ln.prepare.blackAddresses, addr)
+	ln.prepare.lock.Unlock()
+	ln.libP2pHost.blackList.RemoveIPAndPort(ip, port)
+	ln.log.Infof("[Net] black address removed[%s]", addr)
+	return nil
+}
+
+// AddBlackPeerId add a peer id to blacklist at runtime, connections of the peer will be closed.
+// If the net has not been started, the peer id will be added to prepare.
+func (ln *LibP2pNet) AddBlackPeerId(pidStr string) error {
+	pid, err := peer.Decode(pidStr)
+	if err != nil {
+		ln.log.Errorf("[Net] decode pid failed(pid:%s), %s", pidStr, err.Error())
+		return err
+	}
+	if !ln.IsRunning() {
+		ln.prepare.AddBlackPeerId(pidStr)
+		return nil
+	}
+	ln.libP2pHost.blackList.AddPeerId(pid)
+	if err = ln.libP2pHost.host.Network().ClosePeer(pid); err != nil {
+		ln.log.Warnf("[Net] close black peer failed(pid:%s), %s", pidStr, err.Error())
+	}
+	ln.log.Infof("[Net] black peer id added[%s]", pidStr)
+	return nil
+}
+
+// RemoveBlackPeerId remove a peer id from blacklist at runtime.
+func (ln *LibP2pNet) RemoveBlackPeerId(pidStr string) error {
+	pid, err := peer.Decode(pidStr)
+	if err != nil {
+		ln.log.Errorf("[Net] decode pid failed(pid:%s), %s", pidStr, err.Error())
+		return err
+	}
+	ln.prepare.lock.Lock()
+	delete(ln.prepare.blackPeerIds, pidStr)
+	ln.prepare.lock.Unlock()
+	ln.libP2pHost.blackList.RemovePeerId(pid)
+	ln.log.Infof("[Net] black peer id removed[%s]", pidStr)
+	return nil
+}
@@ -7,8 +7,8 @@ SPDX-License-Identifier: Apache-2.0
 package libp2pnet
 
 import (
+	"crypto/ed25519"
 	"encoding/pem"
-	"strconv"
 	"strings"
 	"sync"
 
@@ -155,16 +155,10 @@ func (l *LibP2pNetPrepare) AddBlackPeerId(pid string) {
 func (ln *LibP2pNet) prepareBlackList() error {
 	ln.log.Info("[Net] preparing blacklist...")
 	for addr := range ln.prepare.blackAddresses {
-		s := strings.Split(addr, ":")
-		ip := s[0]
-		var port = -1
-		var err error
-		if len(s) > 1 {
-			port, err = strconv.Atoi(s[1])
-			if err != nil {
-				ln.log.Errorf("[Net] parse port failed, %s", err.Error())
-				return err
-			}
+		ip, port, err := parseBlackAddress(addr)
+		if err != nil {
+			ln.log.Errorf("[Net] parse port failed, %s", err.Error())
+			return err
 		}
 		ln.libP2pHost.blackList.AddIPAndPort(ip, port)
 		ln.log.Infof("[Net] black address found[%s]", addr)
@@ -323,7 +317,12 @@ func (ln *LibP2pNet) prepareKey() (crypto.PrivKey, error) {
 		ln.log.Errorf("[Net] parse pem to private key failed, %s", err.Error())
 		return nil, err
 	}
-	privKey, _, err = crypto.KeyPairFromStdKey(privateKey.ToStandardKey())
+	stdKey := privateKey.ToStandardKey()
+	// libp2p only accepts a pointer to an ed25519 private key
+	if ed25519Key, ok := stdKey.(ed25519.PrivateKey); ok {
+		stdKey = &ed25519Key
+	}
+	privKey, _, err = crypto.KeyPairFromStdKey(stdKey)
 	if err != nil {
 		ln.log.Errorf("[Net] parse private key to priv key failed, %s", err.Error())
 		return nil, err
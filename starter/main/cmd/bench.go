@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"zhanghefan123/security/modules/bench"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// outputCSV 每个节点一行的 csv, 便于绘图
+const outputCSV = "csv"
+
+// benchOptions 压测命令的参数
+type benchOptions struct {
+	conn         clientOptions // 连接节点的参数, --timeout 为单个请求的超时时间
+	targets      []string      // 被压测的节点的地址, 为空的时候使用 --addr
+	concurrency  int
+	rate         float64
+	arrival      string
+	duration     time.Duration
+	requests     int
+	users        int     // 生成的用户数量
+	userPrefix   string  // 生成的用户 id 的前缀
+	userFile     string  // 从文件之中读取用户 id, 设置之后忽略 --users
+	distribution string  // 用户的分布
+	zipfS        float64 // zipf 分布的参数
+	seed         int64
+	output       string // 输出格式 text, json 或者 csv
+	samples      string // 每个请求的结果输出的 csv 文件
+}
+
+// CreateBenchCmd 创建压测命令, 对一个或者多个节点的认证服务进行压测并统计延迟以及吞吐量
+func CreateBenchCmd() *cobra.Command {
+	opts := &benchOptions{}
+	var benchCmd = &cobra.Command{
+		Use:   "bench",
+		Short: "Benchmark the authentication service",
+		Long: "Drive the authentication service of one or more nodes with closed loop or open loop load, " +
+			"report latency percentiles, throughput, timeout rate and per node msg counts",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runBench(opts); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	flags := benchCmd.Flags()
+	opts.conn.addFlags(flags)
+	flags.StringSliceVar(&opts.targets, "targets", nil, "comma separated rpc addresses of the nodes, --addr if not set")
+	flags.IntVarP(&opts.concurrency, "concurrency", "c", bench.DefaultConcurrency, "max number of requests in flight")
+	flags.Float64Var(&opts.rate, "rate", 0, "open loop arrivals per second, 0 for closed loop")
+	flags.StringVar(&opts.arrival, "arrival", bench.ArrivalConstant, "open loop arrival process, constant or poisson")
+	flags.DurationVarP(&opts.duration, "duration", "d", bench.DefaultDuration, "how long to issue requests, 0 to only limit --requests")
+	flags.IntVarP(&opts.requests, "requests", "n", 0, "max number of requests, 0 for no limit")
+	flags.IntVar(&opts.users, "users", 1000, "number of generated user ids")
+	flags.StringVar(&opts.userPrefix, "user-prefix", "bench-user-", "prefix of the generated user ids")
+	flags.StringVar(&opts.userFile, "user-file", "", "read user ids from a file, one per line, instead of generating them")
+	flags.StringVar(&opts.distribution, "distribution", bench.DistributionUniform,
+		"how user ids are picked: uniform, zipf, sequential or unique")
+	flags.Float64Var(&opts.zipfS, "zipf-s", 1.1, "skew of the zipf distribution, must be greater than 1")
+	flags.Int64Var(&opts.seed, "seed", 0, "random seed of user picking and poisson arrivals, current time if 0")
+	flags.StringVarP(&opts.output, "output", "o", outputText, "output format, text, json or csv")
+	flags.StringVar(&opts.samples, "samples", "", "write the result of every request to a csv file")
+	return benchCmd
+}
+
+// runBench 连接所有节点并进行压测, 之后输出报告
+func runBench(opts *benchOptions) error {
+	if opts.output != outputText && opts.output != outputJSON && opts.output != outputCSV {
+		return fmt.Errorf("unknown output format %s, expect text, json or csv", opts.output)
+	}
+	users, err := opts.userPicker()
+	if err != nil {
+		return err
+	}
+	addrs := opts.targets
+	if len(addrs) == 0 {
+		addrs = []string{opts.conn.addr}
+	}
+	targets := make([]bench.Target, 0, len(addrs))
+	for _, addr := range addrs {
+		connOpts := opts.conn
+		connOpts.addr = addr
+		conn, err := connOpts.dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		targets = append(targets, opts.target(addr, conn))
+	}
+
+	// 收到中断信号之后不再发起新的请求, 仍然输出已经完成的请求的报告
+	ctx, cancel := interruptContext()
+	defer cancel()
+	report, err := bench.Run(ctx, bench.Config{
+		Concurrency: opts.concurrency,
+		Rate:        opts.rate,
+		Arrival:     opts.arrival,
+		Duration:    opts.duration,
+		Requests:    opts.requests,
+		Timeout:     opts.conn.timeout,
+		Seed:        opts.seed,
+		Users:       users,
+	}, targets)
+	if err != nil {
+		return err
+	}
+	if opts.samples != "" {
+		if err = writeSamples(opts.samples, report); err != nil {
+			return err
+		}
+	}
+	switch opts.output {
+	case outputJSON:
+		return report.WriteJSON(os.Stdout)
+	case outputCSV:
+		return report.WriteCSV(os.Stdout)
+	default:
+		return report.WriteText(os.Stdout)
+	}
+}
+
+// interruptContext 创建收到中断信号之后被取消的上下文
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signalChan:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signalChan)
+	}()
+	return ctx, cancel
+}
+
+// userPicker 根据参数创建用户的分布
+func (opts *benchOptions) userPicker() (bench.UserPicker, error) {
+	if opts.distribution == bench.DistributionUnique {
+		return bench.NewUserPicker(opts.distribution, []string{opts.userPrefix}, opts.zipfS)
+	}
+	var userIds []string
+	if opts.userFile != "" {
+		var err error
+		if userIds, err = readUserIds(opts.userFile); err != nil {
+			return nil, err
+		}
+	} else {
+		userIds = bench.GenerateUsers(opts.userPrefix, opts.users)
+	}
+	return bench.NewUserPicker(opts.distribution, userIds, opts.zipfS)
+}
+
+// target 将一个节点的连接封装为压测的目标, 设置了管理凭证的时候通过管理服务统计节点的消息数量
+func (opts *benchOptions) target(addr string, conn *grpc.ClientConn) bench.Target {
+	authClient := pb.NewAuthenticationServiceClient(conn)
+	target := bench.Target{
+		Name: addr,
+		Authenticate: func(ctx context.Context, userId string) (pb.AuthenticationResult, error) {
+			reply, err := authClient.ReplyToAuthenticationRequest(opts.conn.withMetadata(ctx),
+				&pb.AuthenticationRequest{UserId: userId})
+			if err != nil {
+				return pb.AuthenticationResult_ConsensusTimeout, err
+			}
+			return reply.Result, nil
+		},
+	}
+	if opts.conn.adminToken == "" {
+		return target
+	}
+	adminClient := pb.NewAdminServiceClient(conn)
+	target.CountMessages = func(ctx context.Context) (map[string]uint64, error) {
+		reply, err := adminClient.GetNetStats(opts.conn.withMetadata(ctx), &pb.NetStatsRequest{})
+		if err != nil {
+			return nil, err
+		}
+		counts := make(map[string]uint64, len(reply.Counts))
+		for _, count := range reply.Counts {
+			counts[strings.Join([]string{count.Direction, count.MsgType}, "/")] = count.Count
+		}
+		return counts, nil
+	}
+	return target
+}
+
+// writeSamples 将每个请求的结果写入 csv 文件
+func writeSamples(path string, report *bench.Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = report.WriteSamplesCSV(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"os"
+	"time"
+	rpcserver "zhanghefan123/security/modules/rpc"
+	"zhanghefan123/security/modules/rpc/services"
+)
+
+const (
+	// outputText 便于阅读的输出格式
+	outputText = "text"
+	// outputJSON 每个结果一行 json, 便于脚本处理
+	outputJSON = "json"
+)
+
+// clientOptions 客户端命令的公共参数
+type clientOptions struct {
+	addr       string        // 节点 rpc 服务的地址
+	chainId    string        // 请求的链 id, 为空的时候使用节点的默认链
+	adminToken string        // 管理凭证, 调用管理服务的时候需要
+	tls        bool          // 是否使用 tls
+	caFile     string        // 用来验证服务端证书的 ca, 为空的时候使用系统的根证书
+	certFile   string        // 客户端证书, 服务端为 twoway 模式的时候需要
+	keyFile    string        // 客户端证书对应的私钥
+	serverName string        // 验证服务端证书时使用的名称, 为空的时候使用地址之中的主机名
+	timeout    time.Duration // 每次调用的超时时间
+	output     string        // 输出格式 text 或者 json
+}
+
+// clientOpts 客户端命令解析出来的参数
+var clientOpts = &clientOptions{}
+
+// CreateClientCmd 创建客户端命令, 通过 rpc 调用节点的认证服务以及管理服务
+func CreateClientCmd() *cobra.Command {
+	var clientCmd = &cobra.Command{
+		Use:   "client",
+		Short: "Call the rpc services of a node",
+		Long:  "Call the authentication and admin services of a node, output as text or json",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if clientOpts.output != outputText && clientOpts.output != outputJSON {
+				return fmt.Errorf("unknown output format %s, expect text or json", clientOpts.output)
+			}
+			return nil
+		},
+	}
+	flags := clientCmd.PersistentFlags()
+	clientOpts.addFlags(flags)
+	flags.StringVarP(&clientOpts.output, "output", "o", outputText, "output format, text or json")
+
+	clientCmd.AddCommand(createAuthCmd(), createResultCmd(), createBatchAuthCmd(), createSessionCmd(), createStatusCmd(), createAdminCmd())
+	return clientCmd
+}
+
+// addFlags 注册连接节点所需要的参数, 客户端命令以及压测命令共用
+func (opts *clientOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&opts.addr, "addr", "a", "127.0.0.1:12301", "rpc address of the node")
+	flags.StringVar(&opts.chainId, "chain-id", "", "chain id of the request, default chain of the node if not set")
+	flags.StringVar(&opts.adminToken, "admin-token", os.Getenv("SECURITY_ADMIN_TOKEN"),
+		"admin credential, default $SECURITY_ADMIN_TOKEN")
+	flags.BoolVar(&opts.tls, "tls", false, "connect with tls")
+	flags.StringVar(&opts.caFile, "ca-file", "", "ca used to verify the node, system roots if not set")
+	flags.StringVar(&opts.certFile, "cert-file", "", "client certificate, required by twoway tls")
+	flags.StringVar(&opts.keyFile, "key-file", "", "private key of the client certificate")
+	flags.StringVar(&opts.serverName, "server-name", "", "name used to verify the node certificate")
+	flags.DurationVar(&opts.timeout, "timeout", 60*time.Second, "timeout of each call")
+}
+
+// transportCredentials 根据 tls 参数创建传输层凭证
+func (opts *clientOptions) transportCredentials() (grpc.DialOption, error) {
+	if !opts.tls {
+		return grpc.WithInsecure(), nil
+	}
+	config := &tls.Config{ServerName: opts.serverName, MinVersion: tls.VersionTLS12}
+	if opts.caFile != "" {
+		caPem, err := ioutil.ReadFile(opts.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file failed, %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("no certificate found in ca file %s", opts.caFile)
+		}
+	}
+	if opts.certFile != "" || opts.keyFile != "" {
+		if opts.certFile == "" || opts.keyFile == "" {
+			return nil, errors.New("--cert-file and --key-file must be set together")
+		}
+		certificate, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate failed, %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(config)), nil
+}
+
+// dial 连接节点的 rpc 服务
+func (opts *clientOptions) dial() (*grpc.ClientConn, error) {
+	credentialOpt, err := opts.transportCredentials()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(opts.addr, credentialOpt)
+	if err != nil {
+		return nil, fmt.Errorf("connect %s failed, %w", opts.addr, err)
+	}
+	return conn, nil
+}
+
+// callContext 创建一次调用的上下文, 在 metadata 之中携带链 id 以及管理凭证
+func (opts *clientOptions) callContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	return opts.withMetadata(ctx), cancel
+}
+
+// withMetadata 在 ctx 的 metadata 之中携带链 id 以及管理凭证
+func (opts *clientOptions) withMetadata(ctx context.Context) context.Context {
+	pairs := make([]string, 0, 4)
+	if opts.chainId != "" {
+		pairs = append(pairs, services.ChainIdKey, opts.chainId)
+	}
+	if opts.adminToken != "" {
+		pairs = append(pairs, rpcserver.AdminTokenKey, opts.adminToken)
+	}
+	if len(pairs) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+	}
+	return ctx
+}
+
+// printReply 按照输出格式打印结果, text 格式使用 formatText 生成的内容
+func (opts *clientOptions) printReply(reply proto.Message, formatText func() string) error {
+	if opts.output == outputJSON {
+		// 输出零值的字段, 否则 LegalUser 这样的枚举值不会出现在结果之中
+		content, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(reply)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(content))
+		return nil
+	}
+	fmt.Println(formatText())
+	return nil
+}
+
+// runWithConn 连接节点之后执行调用, 出错的时候打印错误并以 1 退出
+func runWithConn(call func(conn *grpc.ClientConn) error) {
+	conn, err := clientOpts.dial()
+	if err == nil {
+		err = call(conn)
+		_ = conn.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
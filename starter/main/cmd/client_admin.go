@@ -0,0 +1,432 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"strings"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// adminCall 调用一个管理服务的方法并打印结果
+func adminCall(call func(client pb.AdminServiceClient) error) {
+	runWithConn(func(conn *grpc.ClientConn) error {
+		return call(pb.NewAdminServiceClient(conn))
+	})
+}
+
+// printAdminReply 打印管理操作的执行结果
+func printAdminReply(reply *pb.AdminReply, err error) error {
+	if err != nil {
+		return err
+	}
+	return clientOpts.printReply(reply, func() string { return reply.Message })
+}
+
+// createStatusCmd 获取节点每个模块的健康状态
+func createStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the health of every module of the node",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := client.GetHealth(ctx, &pb.HealthRequest{})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string {
+					var builder strings.Builder
+					fmt.Fprintf(&builder, "chain: %s\thealthy: %t", reply.ChainId, reply.Healthy)
+					for _, module := range reply.Modules {
+						fmt.Fprintf(&builder, "\n  %s\t%s\thealthy: %t", module.Name, module.State, module.Healthy)
+						if module.Error != "" {
+							fmt.Fprintf(&builder, "\terror: %s", module.Error)
+						}
+					}
+					return builder.String()
+				})
+			})
+		},
+	}
+}
+
+// createAdminCmd 创建管理服务相关的命令, 需要通过 --admin-token 提供管理凭证
+func createAdminCmd() *cobra.Command {
+	var adminCmd = &cobra.Command{
+		Use:   "admin",
+		Short: "Call the admin service, requires --admin-token",
+	}
+	adminCmd.AddCommand(createPeersCmd(), createBlackListCmd(), createUserStateCmd(), createExpireRoundCmd(),
+		createLogLevelCmd(), createShutdownCmd(), createPoolStatsCmd(), createReloadCmd(), createFaultsCmd(),
+		createNetStatsCmd())
+	return adminCmd
+}
+
+// createPeersCmd 列出节点已知的所有节点
+func createPeersCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "peers",
+		Short: "List the known peers and their connection state",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := client.ListPeers(ctx, &pb.ListPeersRequest{})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string {
+					var builder strings.Builder
+					fmt.Fprintf(&builder, "local: %s", reply.LocalPeerId)
+					for _, peer := range reply.Peers {
+						fmt.Fprintf(&builder, "\n  %s\t%s\tconnections: %d\tseed: %t\t%s", peer.PeerId, peer.State,
+							peer.ConnectionCount, peer.IsSeed, strings.Join(peer.Addresses, ","))
+					}
+					return builder.String()
+				})
+			})
+		},
+	}
+}
+
+// createBlackListCmd 添加或者移除黑名单
+func createBlackListCmd() *cobra.Command {
+	var addresses, peerIds []string
+	var blackListCmd = &cobra.Command{
+		Use:   "blacklist",
+		Short: "Add or remove addresses and peers in the blacklist",
+	}
+	newSubCmd := func(use, short string, remove bool) *cobra.Command {
+		return &cobra.Command{
+			Use:   use,
+			Short: short,
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if len(addresses) == 0 && len(peerIds) == 0 {
+					return fmt.Errorf("at least one of --address and --peer-id is required")
+				}
+				adminCall(func(client pb.AdminServiceClient) error {
+					ctx, cancel := clientOpts.callContext()
+					defer cancel()
+					request := &pb.BlackListRequest{Addresses: addresses, PeerIds: peerIds}
+					if remove {
+						return printAdminReply(client.RemoveBlackList(ctx, request))
+					}
+					return printAdminReply(client.AddBlackList(ctx, request))
+				})
+				return nil
+			},
+		}
+	}
+	blackListCmd.PersistentFlags().StringSliceVar(&addresses, "address", nil, "ip or ip:port, repeatable")
+	blackListCmd.PersistentFlags().StringSliceVar(&peerIds, "peer-id", nil, "peer id, repeatable")
+	blackListCmd.AddCommand(newSubCmd("add", "Add to the blacklist", false),
+		newSubCmd("remove", "Remove from the blacklist", true))
+	return blackListCmd
+}
+
+// createUserStateCmd 打印用户的共识状态
+func createUserStateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "user-state <userId>",
+		Short: "Dump the consensus state of a user",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := client.DumpUserState(ctx, &pb.UserStateRequest{UserId: args[0]})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string { return string(reply.State) })
+			})
+		},
+	}
+}
+
+// createExpireRoundCmd 让用户当前的共识轮次立即超时
+func createExpireRoundCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "expire-round <userId>",
+		Short: "Expire the current consensus round of a user",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				return printAdminReply(client.ExpireUserRound(ctx, &pb.UserStateRequest{UserId: args[0]}))
+			})
+		},
+	}
+}
+
+// createLogLevelCmd 修改日志级别
+func createLogLevelCmd() *cobra.Command {
+	var module string
+	var logLevelCmd = &cobra.Command{
+		Use:   "log-level <DEBUG|INFO|WARN|ERROR>",
+		Short: "Set the log level of a module, or the default level if --module is not set",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				return printAdminReply(client.SetLogLevel(ctx, &pb.SetLogLevelRequest{Module: module, Level: args[0]}))
+			})
+		},
+	}
+	logLevelCmd.Flags().StringVar(&module, "module", "", "module name, e.g. net, consensus")
+	return logLevelCmd
+}
+
+// createShutdownCmd 请求节点优雅关闭
+func createShutdownCmd() *cobra.Command {
+	var reason string
+	var shutdownCmd = &cobra.Command{
+		Use:   "shutdown",
+		Short: "Shutdown the node gracefully",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				return printAdminReply(client.Shutdown(ctx, &pb.ShutdownRequest{Reason: reason}))
+			})
+		},
+	}
+	shutdownCmd.Flags().StringVar(&reason, "reason", "requested by client", "reason written to the node log")
+	return shutdownCmd
+}
+
+// createPoolStatsCmd 打印请求池的统计信息
+func createPoolStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "pool-stats",
+		Short: "Show the statistics of the request pool",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := client.GetPoolStats(ctx, &pb.PoolStatsRequest{})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string {
+					var builder strings.Builder
+					fmt.Fprintf(&builder, "pending: %d\tdeduplicated: %d\tjournal errors: %d",
+						reply.Pending, reply.Deduplicated, reply.JournalErrors)
+					for _, queue := range reply.Queues {
+						fmt.Fprintf(&builder, "\n  %s\tdepth: %d\taccepted: %d\trejected: %d\tdispatched: %d\twait avg/max: %dms/%dms",
+							queue.Priority, queue.Depth, queue.Accepted, queue.Rejected, queue.Dispatched,
+							queue.AvgWaitMillis, queue.MaxWaitMillis)
+					}
+					return builder.String()
+				})
+			})
+		},
+	}
+}
+
+// createNetStatsCmd 打印网络服务发送以及接收的消息数量
+func createNetStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "net-stats",
+		Short: "Show the number of msgs sent and received by the net service",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := client.GetNetStats(ctx, &pb.NetStatsRequest{})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string {
+					var builder strings.Builder
+					fmt.Fprintf(&builder, "local peer: %s", reply.LocalPeerId)
+					for _, count := range reply.Counts {
+						fmt.Fprintf(&builder, "\n  %s\t%s\t%d", count.Direction, count.MsgType, count.Count)
+					}
+					return builder.String()
+				})
+			})
+		},
+	}
+}
+
+// createReloadCmd 重新加载节点的配置文件
+func createReloadCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "reload",
+		Short: "Reload the config file of the node",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := client.ReloadConfig(ctx, &pb.ReloadConfigRequest{})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string {
+					return fmt.Sprintf("applied: %v\nrestart required: %v\nfailed: %v",
+						reply.Applied, reply.RestartRequired, reply.Failed)
+				})
+			})
+		},
+	}
+}
+
+// createFaultsCmd 查看以及修改网络服务的故障注入配置
+func createFaultsCmd() *cobra.Command {
+	var faultsCmd = &cobra.Command{
+		Use:   "faults",
+		Short: "Show or change the fault injection of the net service",
+	}
+	faultsCmd.AddCommand(createFaultsShowCmd(), createFaultsIsolateCmd(), createFaultsRuleCmd(),
+		createFaultsClearCmd())
+	return faultsCmd
+}
+
+// printNetFaults 打印故障注入的配置以及统计
+func printNetFaults(reply *pb.NetFaultsReply, err error) error {
+	if err != nil {
+		return err
+	}
+	return clientOpts.printReply(reply, func() string {
+		var builder strings.Builder
+		faults := reply.GetFaults()
+		fmt.Fprintf(&builder, "enabled: %t\tseed: %d\telapsed: %s\tdropped: %d\tdelayed: %d\tduplicated: %d",
+			faults.GetEnabled(), faults.GetSeed(), time.Duration(reply.ElapsedMillis)*time.Millisecond,
+			reply.Dropped, reply.Delayed, reply.Duplicated)
+		for _, partition := range faults.GetPartitions() {
+			end := "end"
+			if partition.EndMillis != 0 {
+				end = (time.Duration(partition.EndMillis) * time.Millisecond).String()
+			}
+			fmt.Fprintf(&builder, "\n  partition %v\t[%s, %s)", partition.Nodes,
+				time.Duration(partition.StartMillis)*time.Millisecond, end)
+		}
+		for _, rule := range faults.GetRules() {
+			fmt.Fprintf(&builder, "\n  rule %s\tpeers: %v\ttypes: %v\tdrop: %v\tdelay: %dms+%dms\tduplicate: %v\treorder: %v/%dms",
+				rule.Direction, rule.Peers, rule.MsgTypes, rule.DropRate, rule.DelayMillis, rule.JitterMillis,
+				rule.DuplicateRate, rule.ReorderRate, rule.ReorderWindowMillis)
+		}
+		return builder.String()
+	})
+}
+
+// updateNetFaults 读取当前的故障注入配置, 修改之后写回节点
+func updateNetFaults(restartClock bool, update func(faults *pb.NetFaults)) {
+	adminCall(func(client pb.AdminServiceClient) error {
+		ctx, cancel := clientOpts.callContext()
+		defer cancel()
+		current, err := client.GetNetFaults(ctx, &pb.GetNetFaultsRequest{})
+		if err != nil {
+			return err
+		}
+		faults := current.GetFaults()
+		if faults == nil {
+			faults = &pb.NetFaults{}
+		}
+		update(faults)
+		return printNetFaults(client.SetNetFaults(ctx, &pb.SetNetFaultsRequest{
+			Faults:       faults,
+			RestartClock: restartClock,
+		}))
+	})
+}
+
+// createFaultsShowCmd 打印故障注入的配置以及统计
+func createFaultsShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show the fault injection config and statistics",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				return printNetFaults(client.GetNetFaults(ctx, &pb.GetNetFaultsRequest{}))
+			})
+		},
+	}
+}
+
+// createFaultsIsolateCmd 添加一个分区, 例如 isolate node2 --start 10s --end 40s
+func createFaultsIsolateCmd() *cobra.Command {
+	var start, end time.Duration
+	var restartClock bool
+	var isolateCmd = &cobra.Command{
+		Use:   "isolate <nodeId>...",
+		Short: "Isolate the nodes from the others between --start and --end",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			updateNetFaults(restartClock, func(faults *pb.NetFaults) {
+				faults.Enabled = true
+				faults.Partitions = append(faults.Partitions, &pb.Partition{
+					Nodes:       args,
+					StartMillis: start.Milliseconds(),
+					EndMillis:   end.Milliseconds(),
+				})
+			})
+		},
+	}
+	isolateCmd.Flags().DurationVar(&start, "start", 0, "start of the partition, measured from the start of the net service")
+	isolateCmd.Flags().DurationVar(&end, "end", 0, "end of the partition, 0 means until the node stops")
+	isolateCmd.Flags().BoolVar(&restartClock, "restart-clock", false, "measure --start and --end from now")
+	return isolateCmd
+}
+
+// createFaultsRuleCmd 添加一条故障规则
+func createFaultsRuleCmd() *cobra.Command {
+	rule := &pb.FaultRule{}
+	var delay, jitter, reorderWindow time.Duration
+	var ruleCmd = &cobra.Command{
+		Use:   "rule",
+		Short: "Add a rule which drops, delays, duplicates or reorders the matching messages",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			rule.DelayMillis = delay.Milliseconds()
+			rule.JitterMillis = jitter.Milliseconds()
+			rule.ReorderWindowMillis = reorderWindow.Milliseconds()
+			updateNetFaults(false, func(faults *pb.NetFaults) {
+				faults.Enabled = true
+				faults.Rules = append(faults.Rules, rule)
+			})
+		},
+	}
+	ruleCmd.Flags().StringVar(&rule.Direction, "direction", "both", "send, recv or both")
+	ruleCmd.Flags().StringSliceVar(&rule.Peers, "peer", nil, "peer id, repeatable, empty matches every peer")
+	ruleCmd.Flags().StringSliceVar(&rule.MsgTypes, "msg-type", nil, "msg type, e.g. CONSENSUS_MSG, repeatable")
+	ruleCmd.Flags().Float64Var(&rule.DropRate, "drop", 0, "probability of dropping a message")
+	ruleCmd.Flags().DurationVar(&delay, "delay", 0, "fixed delay of every message")
+	ruleCmd.Flags().DurationVar(&jitter, "jitter", 0, "upper bound of the random extra delay")
+	ruleCmd.Flags().Float64Var(&rule.DuplicateRate, "duplicate", 0, "probability of sending a message twice")
+	ruleCmd.Flags().Float64Var(&rule.ReorderRate, "reorder", 0, "probability of reordering a message")
+	ruleCmd.Flags().DurationVar(&reorderWindow, "reorder-window", 100*time.Millisecond, "upper bound of the reorder delay")
+	return ruleCmd
+}
+
+// createFaultsClearCmd 移除所有的故障规则以及分区
+func createFaultsClearCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "clear",
+		Short: "Remove every fault rule and partition",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			adminCall(func(client pb.AdminServiceClient) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				return printNetFaults(client.SetNetFaults(ctx, &pb.SetNetFaultsRequest{Faults: &pb.NetFaults{}}))
+			})
+		},
+	}
+}
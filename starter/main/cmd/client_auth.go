@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"os"
+	"strings"
+	"sync"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// formatAuthenticationReply 认证结果的 text 格式, 认证通过的时候包含会话凭证
+func formatAuthenticationReply(reply *pb.AuthenticationReply) string {
+	text := fmt.Sprintf("user: %s\tresult: %s\trequest: %s", reply.UserId, reply.Result, reply.RequestId)
+	if reply.SessionToken != "" {
+		text += fmt.Sprintf("\tsession: %s", reply.SessionToken)
+	}
+	return text
+}
+
+// createAuthCmd 提交一个认证请求并等待共识结果
+func createAuthCmd() *cobra.Command {
+	var requestId string
+	var authCmd = &cobra.Command{
+		Use:   "auth <userId>",
+		Short: "Authenticate a user and wait for the consensus result",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runWithConn(func(conn *grpc.ClientConn) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := pb.NewAuthenticationServiceClient(conn).ReplyToAuthenticationRequest(ctx,
+					&pb.AuthenticationRequest{UserId: args[0], RequestId: requestId})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string { return formatAuthenticationReply(reply) })
+			})
+		},
+	}
+	authCmd.Flags().StringVar(&requestId, "request-id", "", "client request id, can be used by the same user to get the result later")
+	return authCmd
+}
+
+// createResultCmd 通过请求 id 获取之前提交的认证请求的结果, 用于断线重连
+func createResultCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "result <userId> <requestId>",
+		Short: "Get the result of a submitted authentication request",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			runWithConn(func(conn *grpc.ClientConn) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := pb.NewAuthenticationServiceClient(conn).GetAuthenticationResult(ctx,
+					&pb.AuthenticationResultRequest{UserId: args[0], RequestId: args[1]})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string { return formatAuthenticationReply(reply) })
+			})
+		},
+	}
+}
+
+// readUserIds 读取文件之中的用户 id, 每行一个, 忽略空行以及 # 开头的注释
+func readUserIds(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	userIds := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		userIds = append(userIds, line)
+	}
+	return userIds, scanner.Err()
+}
+
+// createBatchAuthCmd 并发提交文件之中所有用户的认证请求, 按照文件之中的顺序输出结果
+func createBatchAuthCmd() *cobra.Command {
+	var concurrency int
+	var batchAuthCmd = &cobra.Command{
+		Use:   "batch-auth <file>",
+		Short: "Authenticate every user listed in a file, one user id per line",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			userIds, err := readUserIds(args[0])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			if concurrency <= 0 {
+				concurrency = 1
+			}
+			runWithConn(func(conn *grpc.ClientConn) error {
+				client := pb.NewAuthenticationServiceClient(conn)
+				replies := make([]*pb.AuthenticationReply, len(userIds))
+				errs := make([]error, len(userIds))
+				// 限制同时进行的请求数量
+				tokens := make(chan struct{}, concurrency)
+				var wg sync.WaitGroup
+				for index, userId := range userIds {
+					wg.Add(1)
+					tokens <- struct{}{}
+					go func(index int, userId string) {
+						defer func() {
+							<-tokens
+							wg.Done()
+						}()
+						ctx, cancel := clientOpts.callContext()
+						defer cancel()
+						replies[index], errs[index] = client.ReplyToAuthenticationRequest(ctx,
+							&pb.AuthenticationRequest{UserId: userId})
+					}(index, userId)
+				}
+				wg.Wait()
+
+				failed := 0
+				for index, reply := range replies {
+					if errs[index] != nil {
+						failed++
+						fmt.Fprintf(os.Stderr, "user: %s\terror: %s\n", userIds[index], errs[index])
+						continue
+					}
+					if err := clientOpts.printReply(reply, func() string { return formatAuthenticationReply(reply) }); err != nil {
+						return err
+					}
+				}
+				if failed > 0 {
+					return fmt.Errorf("%d of %d requests failed", failed, len(userIds))
+				}
+				return nil
+			})
+		},
+	}
+	batchAuthCmd.Flags().IntVar(&concurrency, "concurrency", 8, "number of requests in flight")
+	return batchAuthCmd
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"time"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// formatSessionReply 会话的 text 格式
+func formatSessionReply(reply *pb.SessionReply) string {
+	if reply.ExpireTime == 0 {
+		return fmt.Sprintf("user: %s\tvalid: %t", reply.UserId, reply.Valid)
+	}
+	return fmt.Sprintf("user: %s\tvalid: %t\texpire: %s", reply.UserId, reply.Valid,
+		time.Unix(reply.ExpireTime, 0).Format(time.RFC3339))
+}
+
+// createSessionCmd 检查或者撤销认证通过之后签发的会话
+func createSessionCmd() *cobra.Command {
+	var sessionCmd = &cobra.Command{
+		Use:   "session",
+		Short: "Validate or revoke the session issued to an authenticated user",
+	}
+	sessionCmd.AddCommand(
+		newSessionCmd("validate", "Check whether a session token is valid",
+			func(client pb.AuthenticationServiceClient) sessionCall { return client.ValidateSession }),
+		newSessionCmd("revoke", "Revoke a session token",
+			func(client pb.AuthenticationServiceClient) sessionCall { return client.RevokeSession }),
+	)
+	return sessionCmd
+}
+
+// sessionCall 会话相关的 rpc
+type sessionCall func(ctx context.Context, in *pb.SessionRequest, opts ...grpc.CallOption) (*pb.SessionReply, error)
+
+// newSessionCmd 创建以会话凭证为参数的子命令
+func newSessionCmd(use, short string, method func(client pb.AuthenticationServiceClient) sessionCall) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " <sessionToken>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runWithConn(func(conn *grpc.ClientConn) error {
+				ctx, cancel := clientOpts.callContext()
+				defer cancel()
+				reply, err := method(pb.NewAuthenticationServiceClient(conn))(ctx, &pb.SessionRequest{SessionToken: args[0]})
+				if err != nil {
+					return err
+				}
+				return clientOpts.printReply(reply, func() string { return formatSessionReply(reply) })
+			})
+		},
+	}
+}
@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"zhanghefan123/security/modules/launcher"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// clusterStatusTimeout status 命令每个节点健康检查的超时时间, 暂停的节点不会让命令等待太久
+const clusterStatusTimeout = 3 * time.Second
+
+// clusterSignals kill 命令支持的信号
+var clusterSignals = map[string]syscall.Signal{
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+	"INT":  syscall.SIGINT,
+	"HUP":  syscall.SIGHUP,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+}
+
+// clusterOptions 集群命令的公共参数
+type clusterOptions struct {
+	conn   clientOptions // 健康检查连接节点的参数, 节点的地址以及管理凭证来自节点配置
+	dir    string        // 生成的配置目录
+	binary string        // 启动节点使用的 starter, 默认为当前的可执行文件
+}
+
+// nodeStatus status 命令输出的一个节点的状态
+type nodeStatus struct {
+	Node    string    `json:"node"`
+	Running bool      `json:"running"`
+	Pid     int       `json:"pid,omitempty"`
+	Started time.Time `json:"started"`
+	RPCAddr string    `json:"rpc_addr"`
+	Healthy bool      `json:"healthy"`
+	Error   string    `json:"error,omitempty"`
+	LogFile string    `json:"log_file"`
+}
+
+// CreateClusterCmd 创建集群命令, 在本机上以多个进程的方式启动生成的配置目录之中的节点
+func CreateClusterCmd() *cobra.Command {
+	opts := &clusterOptions{}
+	var clusterCmd = &cobra.Command{
+		Use:   "cluster",
+		Short: "Launch and manage a local multi-process cluster from a generated config directory",
+		Long: "Start every node directory with a chainmaker.yml in the config directory as a separate " +
+			"\"starter start\" process, wait for their rpc health checks, aggregate their logs and stop, kill " +
+			"or restart single nodes during experiments. Processes are recorded in cluster.json of the config directory",
+	}
+	flags := clusterCmd.PersistentFlags()
+	opts.conn.addFlags(flags)
+	// 节点的地址以及管理凭证来自节点配置, --admin-token 只在节点配置没有凭证的时候使用
+	_ = flags.MarkHidden("addr")
+	_ = flags.MarkHidden("chain-id")
+	_ = flags.MarkHidden("timeout")
+	flags.StringVar(&opts.dir, "dir", "../../simulation/config", "config directory generated by config generate")
+	flags.StringVar(&opts.binary, "binary", "", "starter executable used to start the nodes, this executable if not set")
+
+	clusterCmd.AddCommand(createClusterUpCmd(opts), createClusterDownCmd(opts), createClusterStatusCmd(opts),
+		createClusterLogsCmd(opts), createClusterKillCmd(opts), createClusterRestartCmd(opts))
+	return clusterCmd
+}
+
+// runCluster 执行集群子命令, 出错的时候以 1 退出
+func runCluster(run func() error) {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// createClusterUpCmd 启动节点并等待健康检查通过
+func createClusterUpCmd(opts *clusterOptions) *cobra.Command {
+	var wait time.Duration
+	var upCmd = &cobra.Command{
+		Use:   "up [node]...",
+		Short: "Start the nodes, all nodes if none is given, and wait until they are healthy",
+		Run: func(cmd *cobra.Command, args []string) {
+			runCluster(func() error {
+				l, nodes, err := opts.open(args)
+				if err != nil {
+					return err
+				}
+				for _, node := range nodes {
+					pid, err := l.Start(node)
+					if errors.Is(err, launcher.ErrNodeRunning) {
+						fmt.Printf("%s already running, pid %d\n", node.Id, pid)
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%s started, pid %d\n", node.Id, pid)
+				}
+				if err = opts.waitHealthy(l, nodes, wait); err != nil {
+					return err
+				}
+				return opts.printStatus(l, nodes, outputText)
+			})
+		},
+	}
+	upCmd.Flags().DurationVar(&wait, "wait", 60*time.Second, "how long to wait for the health checks, 0 to not wait")
+	return upCmd
+}
+
+// createClusterDownCmd 停止节点
+func createClusterDownCmd(opts *clusterOptions) *cobra.Command {
+	var timeout time.Duration
+	var downCmd = &cobra.Command{
+		Use:   "down [node]...",
+		Short: "Stop the nodes with SIGTERM, all nodes if none is given, SIGKILL after --stop-timeout",
+		Run: func(cmd *cobra.Command, args []string) {
+			runCluster(func() error {
+				l, nodes, err := opts.open(args)
+				if err != nil {
+					return err
+				}
+				// 节点的优雅退出可能需要较长的时间, 同时停止所有节点
+				var wg sync.WaitGroup
+				errs := make([]error, len(nodes))
+				for i, node := range nodes {
+					if !l.Status(node).Running() {
+						fmt.Printf("%s not running\n", node.Id)
+						continue
+					}
+					wg.Add(1)
+					go func(i int, node *launcher.Node) {
+						defer wg.Done()
+						if errs[i] = l.Stop(node, timeout); errs[i] == nil {
+							fmt.Printf("%s stopped\n", node.Id)
+						}
+					}(i, node)
+				}
+				wg.Wait()
+				for _, err = range errs {
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		},
+	}
+	downCmd.Flags().DurationVar(&timeout, "stop-timeout", launcher.DefaultStopTimeout,
+		"how long to wait for a graceful shutdown before SIGKILL")
+	return downCmd
+}
+
+// createClusterStatusCmd 输出节点进程以及健康检查的状态
+func createClusterStatusCmd(opts *clusterOptions) *cobra.Command {
+	var output string
+	var statusCmd = &cobra.Command{
+		Use:   "status [node]...",
+		Short: "Show the process and health of the nodes",
+		Run: func(cmd *cobra.Command, args []string) {
+			runCluster(func() error {
+				if output != outputText && output != outputJSON {
+					return fmt.Errorf("unknown output format %s, expect text or json", output)
+				}
+				l, nodes, err := opts.open(args)
+				if err != nil {
+					return err
+				}
+				return opts.printStatus(l, nodes, output)
+			})
+		},
+	}
+	statusCmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, text or json")
+	return statusCmd
+}
+
+// createClusterLogsCmd 汇总输出节点的日志, 每一行以节点 id 作为前缀
+func createClusterLogsCmd(opts *clusterOptions) *cobra.Command {
+	logOptions := launcher.LogOptions{}
+	var logsCmd = &cobra.Command{
+		Use:   "logs [node]...",
+		Short: "Print the logs of the nodes prefixed with the node id, all nodes if none is given",
+		Long: "Print the system log of the nodes, or the process output with --console or when the node " +
+			"has no log file, every line prefixed with the node id. --follow keeps printing until interrupted",
+		Run: func(cmd *cobra.Command, args []string) {
+			runCluster(func() error {
+				_, nodes, err := opts.open(args)
+				if err != nil {
+					return err
+				}
+				ctx, cancel := interruptContext()
+				defer cancel()
+				return launcher.FollowLogs(ctx, os.Stdout, nodes, logOptions)
+			})
+		},
+	}
+	flags := logsCmd.Flags()
+	flags.IntVarP(&logOptions.Lines, "lines", "n", 20, "number of last lines of every node to print first, -1 for all")
+	flags.BoolVarP(&logOptions.Follow, "follow", "f", false, "keep printing new lines")
+	flags.BoolVar(&logOptions.Console, "console", false, "print the stdout and stderr of the processes instead")
+	return logsCmd
+}
+
+// createClusterKillCmd 向节点进程发送信号, 默认 SIGKILL 模拟节点崩溃
+func createClusterKillCmd(opts *clusterOptions) *cobra.Command {
+	var signalName string
+	var killCmd = &cobra.Command{
+		Use:   "kill <node>...",
+		Short: "Send a signal to the nodes, SIGKILL by default to simulate a crash",
+		Long: "Send a signal to the nodes: KILL crashes them, TERM or INT shut them down, HUP reloads their " +
+			"config, STOP and CONT pause and resume them",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runCluster(func() error {
+				name := strings.TrimPrefix(strings.ToUpper(signalName), "SIG")
+				signal, ok := clusterSignals[name]
+				if !ok {
+					return fmt.Errorf("unknown signal %s, expect KILL, TERM, INT, HUP, STOP or CONT", signalName)
+				}
+				l, nodes, err := opts.open(args)
+				if err != nil {
+					return err
+				}
+				for _, node := range nodes {
+					if signal == syscall.SIGKILL {
+						// 等待进程退出, 之后立即执行的 up 或者 restart 不会把它当成仍在运行
+						err = l.Kill(node)
+					} else {
+						err = l.Signal(node, signal)
+					}
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%s sent SIG%s\n", node.Id, name)
+				}
+				return nil
+			})
+		},
+	}
+	killCmd.Flags().StringVarP(&signalName, "signal", "s", "KILL", "signal to send, KILL, TERM, INT, HUP, STOP or CONT")
+	return killCmd
+}
+
+// createClusterRestartCmd 重启节点并等待健康检查通过
+func createClusterRestartCmd(opts *clusterOptions) *cobra.Command {
+	var timeout, wait time.Duration
+	var restartCmd = &cobra.Command{
+		Use:   "restart <node>...",
+		Short: "Stop the nodes if they are running, start them again and wait until they are healthy",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			runCluster(func() error {
+				l, nodes, err := opts.open(args)
+				if err != nil {
+					return err
+				}
+				for _, node := range nodes {
+					pid, err := l.Restart(node, timeout)
+					if err != nil {
+						return err
+					}
+					fmt.Printf("%s restarted, pid %d\n", node.Id, pid)
+				}
+				return opts.waitHealthy(l, nodes, wait)
+			})
+		},
+	}
+	flags := restartCmd.Flags()
+	flags.DurationVar(&timeout, "stop-timeout", launcher.DefaultStopTimeout,
+		"how long to wait for a graceful shutdown before SIGKILL")
+	flags.DurationVar(&wait, "wait", 60*time.Second, "how long to wait for the health checks, 0 to not wait")
+	return restartCmd
+}
+
+// open 读取配置目录并选择参数之中的节点, 没有参数的时候选择所有节点
+func (opts *clusterOptions) open(ids []string) (*launcher.Launcher, []*launcher.Node, error) {
+	binary := opts.binary
+	if binary == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return nil, nil, fmt.Errorf("find starter executable failed, %w", err)
+		}
+		binary = executable
+	}
+	l, err := launcher.Open(opts.dir, binary)
+	if err != nil {
+		return nil, nil, err
+	}
+	nodes, err := l.Select(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l, nodes, nil
+}
+
+// waitHealthy 等待节点的健康检查通过, wait 为 0 的时候不等待
+func (opts *clusterOptions) waitHealthy(l *launcher.Launcher, nodes []*launcher.Node, wait time.Duration) error {
+	if wait <= 0 {
+		return nil
+	}
+	ctx, cancel := interruptContext()
+	defer cancel()
+	ctx, cancelWait := context.WithTimeout(ctx, wait)
+	defer cancelWait()
+	start := time.Now()
+	if err := l.WaitHealthy(ctx, nodes, opts.healthCheck); err != nil {
+		return err
+	}
+	fmt.Printf("%d nodes healthy after %s\n", len(nodes), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// healthCheck 连接节点的 rpc 服务, 启用了管理服务的时候还需要 GetHealth 报告所有模块健康
+func (opts *clusterOptions) healthCheck(ctx context.Context, node *launcher.Node) error {
+	connOpts := opts.conn
+	connOpts.addr = node.RPCAddr
+	if node.AdminToken != "" {
+		connOpts.adminToken = node.AdminToken
+	}
+	if node.TLS && !connOpts.tls {
+		connOpts.tls = true
+		// 生成的配置目录之中所有节点共用一个 ca
+		if caFile := filepath.Join(opts.dir, "ca", "ca.crt"); connOpts.caFile == "" && fileExists(caFile) {
+			connOpts.caFile = caFile
+		}
+	}
+	credentialOpt, err := connOpts.transportCredentials()
+	if err != nil {
+		return err
+	}
+	conn, err := grpc.DialContext(ctx, connOpts.addr, credentialOpt, grpc.WithBlock())
+	if err != nil {
+		return fmt.Errorf("connect %s failed, %w", connOpts.addr, err)
+	}
+	defer conn.Close()
+	if connOpts.adminToken == "" {
+		return nil
+	}
+	reply, err := pb.NewAdminServiceClient(conn).GetHealth(connOpts.withMetadata(ctx), &pb.HealthRequest{})
+	if err != nil {
+		return err
+	}
+	if !reply.Healthy {
+		for _, module := range reply.Modules {
+			if !module.Healthy {
+				return fmt.Errorf("module %s is %s: %s", module.Name, module.State, module.Error)
+			}
+		}
+		return errors.New("node is not healthy")
+	}
+	return nil
+}
+
+// printStatus 输出节点进程以及健康检查的状态
+func (opts *clusterOptions) printStatus(l *launcher.Launcher, nodes []*launcher.Node, output string) error {
+	statuses := make([]nodeStatus, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		process := l.Status(node)
+		statuses[i] = nodeStatus{Node: node.Id, Running: process.Running(), Pid: process.Pid,
+			Started: process.Started, RPCAddr: node.RPCAddr, LogFile: node.LogPath(false)}
+		if !process.Running() {
+			continue
+		}
+		wg.Add(1)
+		go func(status *nodeStatus, node *launcher.Node) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), clusterStatusTimeout)
+			defer cancel()
+			if err := opts.healthCheck(ctx, node); err != nil {
+				status.Error = err.Error()
+				return
+			}
+			status.Healthy = true
+		}(&statuses[i], node)
+	}
+	wg.Wait()
+
+	if output == outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, status := range statuses {
+			if err := encoder.Encode(status); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, status := range statuses {
+		if !status.Running {
+			fmt.Printf("%s\tstopped\t%s\n", status.Node, status.RPCAddr)
+			continue
+		}
+		fmt.Printf("%s\trunning\tpid: %d\tuptime: %s\t%s\thealthy: %t", status.Node, status.Pid,
+			time.Since(status.Started).Round(time.Second), status.RPCAddr, status.Healthy)
+		if status.Error != "" {
+			fmt.Printf("\terror: %s", status.Error)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// fileExists 判断文件是否存在
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
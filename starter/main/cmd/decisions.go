@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"time"
+	"zhanghefan123/security/modules/decision_log"
+)
+
+// CreateDecisionsCmd 创建决策日志命令, 离线检查多个节点记录的共识决策
+func CreateDecisionsCmd() *cobra.Command {
+	var decisionsCmd = &cobra.Command{
+		Use:   "decisions",
+		Short: "Inspect the consensus decision logs written by the nodes",
+	}
+	decisionsCmd.AddCommand(createDecisionsCheckCmd())
+	return decisionsCmd
+}
+
+// createDecisionsCheckCmd 合并多个节点的决策日志并检查安全性以及活性, 发现问题的时候以 1 退出
+func createDecisionsCheckCmd() *cobra.Command {
+	var livenessGap time.Duration
+	var output string
+	var checkCmd = &cobra.Command{
+		Use:   "check <file or directory>...",
+		Short: "Merge decision logs of the nodes and report safety and liveness violations",
+		Long: "Merge the decision logs of the nodes, directories are searched for *.jsonl files, and report " +
+			"conflicting decisions, missing replies, results without quorum, liveness gaps and missing records",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := checkDecisions(args, livenessGap, output); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	checkCmd.Flags().DurationVar(&livenessGap, "liveness-gap", 5*time.Second,
+		"longest time without any decision while requests are pending, 0 to skip the liveness check")
+	checkCmd.Flags().StringVarP(&output, "output", "o", outputText, "output format, text or json")
+	return checkCmd
+}
+
+// checkDecisions 读取并检查决策日志, 之后输出报告
+func checkDecisions(args []string, livenessGap time.Duration, output string) error {
+	if output != outputText && output != outputJSON {
+		return fmt.Errorf("unknown output format %s, expect text or json", output)
+	}
+	paths, err := decisionLogPaths(args)
+	if err != nil {
+		return err
+	}
+	records, err := decision_log.ReadFiles(paths...)
+	if err != nil {
+		return err
+	}
+	report := decision_log.Check(records, decision_log.Options{LivenessGap: livenessGap})
+	if output == outputJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Passed() {
+		return fmt.Errorf("%d violations found in %d decision logs", len(report.Violations), len(paths))
+	}
+	return nil
+}
+
+// decisionLogPaths 将参数之中的目录展开为其中所有的 jsonl 文件
+func decisionLogPaths(args []string) ([]string, error) {
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no decision log found in %s", arg)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
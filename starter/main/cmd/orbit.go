@@ -0,0 +1,399 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"math"
+	"os"
+	"strconv"
+	"time"
+	"zhanghefan123/security/modules/orbit"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+)
+
+// constellationOptions 星座以及节点位置的参数, links 命令以及 drive 命令共用
+type constellationOptions struct {
+	tleFile     string       // 两行根数文件, 设置之后忽略 walker 参数
+	walker      orbit.Walker // walker 星座的参数
+	start       string       // 星座的开始时刻, 为空的时候使用第一颗卫星的历元
+	satellites  []int        // 节点所在的卫星, 为空的时候依次使用前 N 颗卫星
+	nodes       int          // 没有设置 --place 的时候节点的数量
+	singleHop   bool         // 只使用直接可见的链路
+	minAltitude float64
+	maxRange    float64
+	processing  time.Duration // 每一跳的处理时延
+}
+
+// addFlags 注册星座以及节点位置的参数
+func (opts *constellationOptions) addFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&opts.tleFile, "tle", "", "two-line element file of the constellation, walker flags are ignored if set")
+	// 默认为和 iridium 相近的 walker star 星座, 同一个轨道面之中相邻的卫星可以直接通信
+	flags.IntVar(&opts.walker.Satellites, "satellites", 66, "walker constellation: total number of satellites")
+	flags.IntVar(&opts.walker.Planes, "planes", 6, "walker constellation: number of orbital planes")
+	flags.IntVar(&opts.walker.Phasing, "phasing", 2, "walker constellation: phasing factor in [0, planes)")
+	flags.Float64Var(&opts.walker.Altitude, "altitude", 780, "walker constellation: altitude in km")
+	flags.Float64Var(&opts.walker.Inclination, "inclination", 86.4, "walker constellation: inclination in degrees")
+	flags.Float64Var(&opts.walker.Spread, "spread", 180,
+		"walker constellation: raan spread in degrees, 360 for walker delta, 180 for walker star")
+	flags.StringVar(&opts.start, "start", "", "constellation time of offset 0 in RFC3339, epoch of the first satellite if not set")
+	flags.IntSliceVar(&opts.satellites, "place", nil, "satellite index of node1, node2, ..., the first --nodes satellites if not set")
+	flags.IntVar(&opts.nodes, "nodes", 4, "number of nodes when --place is not set")
+	flags.BoolVar(&opts.singleHop, "single-hop", false, "only use direct inter-satellite links, no relaying")
+	flags.Float64Var(&opts.minAltitude, "min-altitude", orbit.DefaultMinAltitude,
+		"lowest altitude in km of a link above the earth, -1 to only require it not to cross the earth")
+	flags.Float64Var(&opts.maxRange, "max-range", 0, "longest inter-satellite link in km, 0 for no limit")
+	flags.DurationVar(&opts.processing, "processing", 0, "processing delay added per hop")
+}
+
+// build 创建星座, 节点的位置以及开始时刻
+func (opts *constellationOptions) build() (*orbit.Constellation, orbit.Placement, time.Time, error) {
+	var start time.Time
+	if opts.start != "" {
+		parsed, err := time.Parse(time.RFC3339, opts.start)
+		if err != nil {
+			return nil, orbit.Placement{}, start, fmt.Errorf("invalid --start, %w", err)
+		}
+		start = parsed
+	}
+	var satellites []orbit.Elements
+	var err error
+	if opts.tleFile != "" {
+		satellites, err = orbit.LoadTLE(opts.tleFile)
+	} else {
+		walker := opts.walker
+		walker.Epoch = start
+		satellites, err = walker.Elements()
+	}
+	if err != nil {
+		return nil, orbit.Placement{}, start, err
+	}
+	if opts.start == "" {
+		start = satellites[0].Epoch
+	}
+	constellation, err := orbit.NewConstellation(satellites)
+	if err != nil {
+		return nil, orbit.Placement{}, start, err
+	}
+	constellation.MinAltitude = opts.minAltitude
+	constellation.MaxRange = opts.maxRange
+
+	placement := orbit.DefaultPlacement(opts.nodes)
+	if len(opts.satellites) > 0 {
+		placement.Satellites = opts.satellites
+	}
+	placement.MultiHop = !opts.singleHop
+	if err = placement.Validate(constellation); err != nil {
+		return nil, orbit.Placement{}, start, err
+	}
+	return constellation, placement, start, nil
+}
+
+// delay 返回链路的传播时延加上每一跳的处理时延
+func (opts *constellationOptions) delay(link orbit.Link) time.Duration {
+	return link.Delay + time.Duration(link.Hops)*opts.processing
+}
+
+// CreateOrbitCmd 创建轨道命令, 根据星座之中卫星的运动计算节点之间的链路并驱动节点的故障注入
+func CreateOrbitCmd() *cobra.Command {
+	var orbitCmd = &cobra.Command{
+		Use:   "orbit",
+		Short: "Model inter-satellite links of a constellation and apply them to the nodes",
+		Long: "Propagate a walker constellation or two-line elements, compute the visibility and propagation " +
+			"delay between the satellites hosting the nodes, print them or drive the fault injection of running nodes",
+	}
+	orbitCmd.AddCommand(createOrbitLinksCmd(), createOrbitDriveCmd())
+	return orbitCmd
+}
+
+// createOrbitLinksCmd 离线计算节点之间的链路并输出统计
+func createOrbitLinksCmd() *cobra.Command {
+	opts := &constellationOptions{}
+	var duration, interval time.Duration
+	var output string
+	var linksCmd = &cobra.Command{
+		Use:   "links",
+		Short: "Print the visibility and delay between the nodes over time",
+		Long: "Sample the links between the nodes every --interval for --duration. text and json print a " +
+			"summary of every pair, csv prints every sample",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := printOrbitLinks(opts, duration, interval, output); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	flags := linksCmd.Flags()
+	opts.addFlags(flags)
+	flags.DurationVarP(&duration, "duration", "d", 100*time.Minute, "how long to sample, about one orbit by default")
+	flags.DurationVar(&interval, "interval", 10*time.Second, "time between two samples")
+	flags.StringVarP(&output, "output", "o", outputText, "output format, text, json or csv")
+	return linksCmd
+}
+
+// printOrbitLinks 计算时间表并按照输出格式打印
+func printOrbitLinks(opts *constellationOptions, duration, interval time.Duration, output string) error {
+	if output != outputText && output != outputJSON && output != outputCSV {
+		return fmt.Errorf("unknown output format %s, expect text, json or csv", output)
+	}
+	constellation, placement, start, err := opts.build()
+	if err != nil {
+		return err
+	}
+	schedule, err := constellation.NewSchedule(start, duration, interval, placement)
+	if err != nil {
+		return err
+	}
+	switch output {
+	case outputCSV:
+		writer := csv.NewWriter(os.Stdout)
+		_ = writer.Write([]string{"offset_ms", "from", "to", "visible", "delay_us", "hops", "distance_km"})
+		for _, step := range schedule.Steps {
+			for from := range step.Links {
+				for to := from + 1; to < len(step.Links); to++ {
+					link := step.Links[from][to]
+					_ = writer.Write([]string{
+						strconv.FormatInt(step.Offset.Milliseconds(), 10),
+						orbitNodeId(from), orbitNodeId(to),
+						strconv.FormatBool(link.Visible),
+						strconv.FormatInt(opts.delay(link).Microseconds(), 10),
+						strconv.Itoa(link.Hops),
+						strconv.FormatFloat(link.Distance, 'f', 1, 64),
+					})
+				}
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case outputJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		for _, summary := range schedule.Summary() {
+			// 输出节点的 id 而不是节点的位置
+			if err = encoder.Encode(struct {
+				orbit.PairSummary
+				From string `json:"from"`
+				To   string `json:"to"`
+			}{summary, orbitNodeId(summary.From), orbitNodeId(summary.To)}); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		fmt.Printf("%d satellites, nodes on %v, %d samples from %s every %s\n", len(constellation.Satellites),
+			placement.Satellites, len(schedule.Steps), start.Format(time.RFC3339), interval)
+		for _, summary := range schedule.Summary() {
+			fmt.Printf("%s <-> %s\tvisible: %5.1f%%\toutages: %d\tdelay: %s - %s\tmax hops: %d\n",
+				orbitNodeId(summary.From), orbitNodeId(summary.To), summary.VisibleRatio*100, summary.Outages,
+				summary.MinDelay.Round(time.Microsecond), summary.MaxDelay.Round(time.Microsecond), summary.MaxHops)
+		}
+		return nil
+	}
+}
+
+// orbitDriver 根据星座的运动不断更新多个节点的故障注入配置
+type orbitDriver struct {
+	opts    *constellationOptions
+	conn    clientOptions
+	addrs   []string
+	conns   []*grpc.ClientConn
+	clients []pb.AdminServiceClient
+	peerIds []string        // 节点在网络之中的 id, 规则以及分区按照它进行匹配
+	base    []*pb.NetFaults // 开始之前的故障注入配置, 链路的规则追加在它后面, 结束之后恢复
+	links   [][]orbit.Link  // 上一次写入的链路, 用于输出链路的变化
+}
+
+// createOrbitDriveCmd 按照星座的运动驱动正在运行的节点的故障注入
+func createOrbitDriveCmd() *cobra.Command {
+	opts := &constellationOptions{}
+	conn := clientOptions{}
+	var targets []string
+	var duration, interval time.Duration
+	var speedup float64
+	var driveCmd = &cobra.Command{
+		Use:   "drive",
+		Short: "Apply the inter-satellite links to the fault injection of running nodes",
+		Long: "Every --interval compute the links between the nodes, node i of --targets is placed on the i-th " +
+			"satellite of --place, and write them to the fault injection of every node through the admin service: " +
+			"a receive delay rule for every reachable peer and a partition for every unreachable peer. " +
+			"The original fault injection config is restored on exit",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := driveOrbit(opts, conn, targets, duration, interval, speedup); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	flags := driveCmd.Flags()
+	opts.addFlags(flags)
+	conn.addFlags(flags)
+	_ = flags.MarkHidden("addr")
+	_ = flags.MarkHidden("nodes")
+	flags.StringSliceVar(&targets, "targets", nil, "comma separated rpc addresses of node1, node2, ...")
+	flags.DurationVarP(&duration, "duration", "d", 0, "how long to drive in wall clock time, 0 until interrupted")
+	flags.DurationVar(&interval, "interval", time.Second, "wall clock time between two updates")
+	flags.Float64Var(&speedup, "speedup", 1, "constellation seconds per wall clock second")
+	return driveCmd
+}
+
+// driveOrbit 连接所有节点, 之后按照间隔更新链路直到结束或者收到中断信号
+func driveOrbit(opts *constellationOptions, conn clientOptions, targets []string, duration, interval time.Duration,
+	speedup float64) error {
+	if len(targets) == 0 {
+		return errors.New("--targets is required")
+	}
+	if conn.adminToken == "" {
+		return errors.New("--admin-token is required to change the fault injection")
+	}
+	if interval <= 0 || speedup <= 0 {
+		return fmt.Errorf("--interval and --speedup must be positive")
+	}
+	opts.nodes = len(targets)
+	if len(opts.satellites) > 0 && len(opts.satellites) != len(targets) {
+		return fmt.Errorf("%d satellites in --place for %d targets", len(opts.satellites), len(targets))
+	}
+	constellation, placement, start, err := opts.build()
+	if err != nil {
+		return err
+	}
+	driver := &orbitDriver{opts: opts, conn: conn, addrs: targets}
+	defer driver.close()
+	for _, addr := range targets {
+		if err = driver.connect(addr); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	began := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		elapsed := time.Since(began)
+		offset := time.Duration(float64(elapsed) * speedup)
+		if err = driver.apply(offset, constellation.Links(start.Add(offset), placement)); err != nil {
+			return err
+		}
+		if duration > 0 && elapsed >= duration {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// connect 连接节点并读取节点的 id 以及当前的故障注入配置
+func (d *orbitDriver) connect(addr string) error {
+	connOpts := d.conn
+	connOpts.addr = addr
+	conn, err := connOpts.dial()
+	if err != nil {
+		return err
+	}
+	client := pb.NewAdminServiceClient(conn)
+	d.conns = append(d.conns, conn)
+	d.clients = append(d.clients, client)
+	d.base = append(d.base, nil)
+	ctx, cancel := d.conn.callContext()
+	defer cancel()
+	peers, err := client.ListPeers(ctx, &pb.ListPeersRequest{})
+	if err != nil {
+		return fmt.Errorf("list peers of %s failed, %w", addr, err)
+	}
+	faults, err := client.GetNetFaults(ctx, &pb.GetNetFaultsRequest{})
+	if err != nil {
+		return fmt.Errorf("get net faults of %s failed, %w", addr, err)
+	}
+	base := faults.GetFaults()
+	if base == nil {
+		base = &pb.NetFaults{}
+	}
+	d.peerIds = append(d.peerIds, peers.LocalPeerId)
+	d.base[len(d.base)-1] = base
+	return nil
+}
+
+// apply 将链路写入每个节点的故障注入配置并输出链路的变化
+func (d *orbitDriver) apply(offset time.Duration, links [][]orbit.Link) error {
+	for i, client := range d.clients {
+		faults := proto.Clone(d.base[i]).(*pb.NetFaults)
+		if !faults.Enabled {
+			// 原来没有启用故障注入的时候只启用链路的规则以及分区
+			faults = &pb.NetFaults{Enabled: true, Seed: faults.Seed}
+		}
+		for j, peerId := range d.peerIds {
+			if j == i {
+				continue
+			}
+			link := links[i][j]
+			if !link.Visible {
+				faults.Partitions = append(faults.Partitions, &pb.Partition{Nodes: []string{peerId}})
+				continue
+			}
+			// 只在接收端延迟, 否则一条消息会在两端各延迟一次, 并且广播的消息在发送端没有对端节点的 id
+			faults.Rules = append(faults.Rules, &pb.FaultRule{
+				Direction:   "recv",
+				Peers:       []string{peerId},
+				DelayMillis: int64(math.Round(float64(d.opts.delay(link)) / float64(time.Millisecond))),
+			})
+		}
+		ctx, cancel := d.conn.callContext()
+		_, err := client.SetNetFaults(ctx, &pb.SetNetFaultsRequest{Faults: faults})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("set net faults of %s failed, %w", d.addrs[i], err)
+		}
+	}
+	d.report(offset, links)
+	return nil
+}
+
+// report 输出和上一次相比可见性发生变化的链路, 第一次输出所有的链路
+func (d *orbitDriver) report(offset time.Duration, links [][]orbit.Link) {
+	for from := range links {
+		for to := from + 1; to < len(links); to++ {
+			link := links[from][to]
+			if d.links != nil && d.links[from][to].Visible == link.Visible {
+				continue
+			}
+			state := "down"
+			if link.Visible {
+				state = fmt.Sprintf("up, delay %s, hops %d", d.opts.delay(link).Round(time.Microsecond), link.Hops)
+			}
+			fmt.Printf("%s\t%s <-> %s\t%s\n", offset.Round(time.Second), orbitNodeId(from), orbitNodeId(to), state)
+		}
+	}
+	d.links = links
+}
+
+// close 恢复每个节点原来的故障注入配置
+func (d *orbitDriver) close() {
+	for i, client := range d.clients {
+		if d.base[i] == nil {
+			continue
+		}
+		ctx, cancel := d.conn.callContext()
+		if _, err := client.SetNetFaults(ctx, &pb.SetNetFaultsRequest{Faults: d.base[i]}); err != nil {
+			fmt.Fprintf(os.Stderr, "restore net faults of %s failed, %v\n", d.addrs[i], err)
+		}
+		cancel()
+	}
+	for _, conn := range d.conns {
+		_ = conn.Close()
+	}
+}
+
+// orbitNodeId 返回第 index 个节点的 id
+func orbitNodeId(index int) string {
+	return fmt.Sprintf("node%d", index+1)
+}
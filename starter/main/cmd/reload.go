@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"os"
+	"zhanghefan123/security/modules/manager"
+)
+
+// ReloadOnSignal 每次收到信号的时候重新加载配置文件, 需要重启才能生效的配置项会打印在日志之中
+func ReloadOnSignal(chainManager *manager.ChainManager, signalChan <-chan os.Signal) {
+	for sig := range signalChan {
+		log.Infof("received signal %s, reload config", sig)
+		report, err := chainManager.Reload()
+		if err != nil {
+			log.Errorf("reload config failed, %s", err.Error())
+			continue
+		}
+		if len(report.RestartRequired) > 0 {
+			log.Warnf("config changes need a restart to take effect: %v", report.RestartRequired)
+		}
+		if len(report.Failed) > 0 {
+			log.Errorf("config changes failed to apply: %v", report.Failed)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"os"
+	"sync"
+	pb "zhanghefan123/security/modules/rpc/protobuf/pb-go"
+	"zhanghefan123/security/modules/scenario"
+)
+
+const (
+	// backendInProcess 在当前进程之中启动集群运行场景
+	backendInProcess = "inprocess"
+	// backendRemote 在已经启动的多进程集群上运行场景, 节点的地址来自场景文件
+	backendRemote = "remote"
+)
+
+// scenarioOptions 场景命令的参数
+type scenarioOptions struct {
+	conn    clientOptions // 连接节点的参数, 节点的地址来自场景文件
+	backend string        // inprocess 或者 remote
+	output  string        // 输出格式 text 或者 json
+}
+
+// CreateScenarioCmd 创建场景命令, 按照场景文件运行可以复现的多节点实验
+func CreateScenarioCmd() *cobra.Command {
+	var scenarioCmd = &cobra.Command{
+		Use:   "scenario",
+		Short: "Run reproducible multi-node experiments described by scenario files",
+	}
+	scenarioCmd.AddCommand(createScenarioRunCmd(), createScenarioValidateCmd())
+	return scenarioCmd
+}
+
+// createScenarioRunCmd 运行场景并输出报告, 场景没有通过的时候以 1 退出
+func createScenarioRunCmd() *cobra.Command {
+	opts := &scenarioOptions{}
+	var runCmd = &cobra.Command{
+		Use:   "run <scenario.yml>",
+		Short: "Run a scenario and report the workloads, fault events and assertions",
+		Long: "Run a scenario against an in-process cluster, or against running nodes with --backend remote. " +
+			"The remote backend injects crashes and partitions through the admin service and needs --admin-token",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runScenario(args[0], opts); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	flags := runCmd.Flags()
+	opts.conn.addFlags(flags)
+	// 节点的地址来自场景文件, --timeout 只限制管理服务的调用
+	_ = flags.MarkHidden("addr")
+	flags.StringVar(&opts.backend, "backend", backendInProcess, "where to run the scenario, inprocess or remote")
+	flags.StringVarP(&opts.output, "output", "o", outputText, "output format, text or json")
+	return runCmd
+}
+
+// createScenarioValidateCmd 检查场景文件
+func createScenarioValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate <scenario.yml>",
+		Short: "Check a scenario file without running it",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			s, err := scenario.Load(args[0])
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("scenario %s is valid: %d nodes, %d workloads, %d faults, %d assertions\n",
+				s.Name, len(s.Nodes), len(s.Workloads), len(s.Faults), len(s.Assertions))
+		},
+	}
+}
+
+// runScenario 加载场景, 在选择的集群上运行并输出报告
+func runScenario(path string, opts *scenarioOptions) error {
+	if opts.output != outputText && opts.output != outputJSON {
+		return fmt.Errorf("unknown output format %s, expect text or json", opts.output)
+	}
+	s, err := scenario.Load(path)
+	if err != nil {
+		return err
+	}
+	var c scenario.Cluster
+	switch opts.backend {
+	case backendInProcess:
+		inProcess, err := scenario.NewInProcess(s, nil)
+		if err != nil {
+			return err
+		}
+		defer inProcess.Stop()
+		c = inProcess
+	case backendRemote:
+		remote, err := newRemoteCluster(s, opts.conn)
+		if err != nil {
+			return err
+		}
+		defer remote.Close()
+		c = remote
+	default:
+		return fmt.Errorf("unknown backend %s, expect %s or %s", opts.backend, backendInProcess, backendRemote)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	report, err := scenario.Run(ctx, s, c)
+	if err != nil {
+		return err
+	}
+	if opts.output == outputJSON {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Passed {
+		return fmt.Errorf("scenario %s failed", s.Name)
+	}
+	return nil
+}
+
+// remoteNode 多进程集群之中的一个节点
+type remoteNode struct {
+	conn   *grpc.ClientConn
+	auth   pb.AuthenticationServiceClient
+	admin  pb.AdminServiceClient
+	peerId string        // 节点在网络之中的 id, 分区按照它进行匹配
+	base   *pb.NetFaults // 场景开始之前的故障注入配置, 场景之中的分区追加在它后面, 结束之后恢复
+}
+
+// remoteCluster 多进程的集群, 通过节点的认证服务提交请求, 通过管理服务的故障注入实现崩溃以及分区.
+// 崩溃的节点和其他节点隔离并且不再接收客户端的请求, 节点进程本身继续运行
+type remoteCluster struct {
+	opts    clientOptions
+	nodeIds []string
+	nodes   map[string]*remoteNode
+	mutex   sync.Mutex
+	crashed map[string]bool
+	groups  [][]string
+}
+
+// newRemoteCluster 连接场景之中所有的节点, 场景包含崩溃或者分区的时候读取每个节点的 id 以及故障注入配置
+func newRemoteCluster(s *scenario.Scenario, opts clientOptions) (*remoteCluster, error) {
+	needFaults := false
+	for _, fault := range s.Faults {
+		if fault.Action != scenario.ActionByzantine && fault.Action != scenario.ActionHonest {
+			needFaults = true
+		}
+	}
+	if needFaults && opts.adminToken == "" {
+		return nil, errors.New("the scenario injects crashes or partitions, --admin-token is required")
+	}
+	c := &remoteCluster{
+		opts:    opts,
+		nodeIds: s.NodeIds(),
+		nodes:   make(map[string]*remoteNode, len(s.Nodes)),
+		crashed: make(map[string]bool),
+	}
+	for _, spec := range s.Nodes {
+		if spec.Addr == "" {
+			c.Close()
+			return nil, fmt.Errorf("node %s has no addr, required by the remote backend", spec.Id)
+		}
+		connOpts := opts
+		connOpts.addr = spec.Addr
+		conn, err := connOpts.dial()
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		node := &remoteNode{
+			conn:  conn,
+			auth:  pb.NewAuthenticationServiceClient(conn),
+			admin: pb.NewAdminServiceClient(conn),
+		}
+		c.nodes[spec.Id] = node
+		if !needFaults {
+			continue
+		}
+		if err = c.load(spec.Id, node); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// load 读取节点的 id 以及当前的故障注入配置
+func (c *remoteCluster) load(nodeId string, node *remoteNode) error {
+	ctx, cancel := c.callContext()
+	defer cancel()
+	peers, err := node.admin.ListPeers(ctx, &pb.ListPeersRequest{})
+	if err != nil {
+		return fmt.Errorf("list peers of %s failed, %w", nodeId, err)
+	}
+	faults, err := node.admin.GetNetFaults(ctx, &pb.GetNetFaultsRequest{})
+	if err != nil {
+		return fmt.Errorf("get net faults of %s failed, %w", nodeId, err)
+	}
+	node.peerId = peers.LocalPeerId
+	node.base = faults.GetFaults()
+	if node.base == nil {
+		node.base = &pb.NetFaults{}
+	}
+	return nil
+}
+
+// callContext 创建一次管理调用的上下文
+func (c *remoteCluster) callContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.timeout)
+	return c.opts.withMetadata(ctx), cancel
+}
+
+// node 查找场景之中的节点
+func (c *remoteCluster) node(nodeId string) (*remoteNode, error) {
+	node, ok := c.nodes[nodeId]
+	if !ok {
+		return nil, fmt.Errorf("unknown node %s", nodeId)
+	}
+	return node, nil
+}
+
+// Close 恢复每个节点原来的故障注入配置并断开连接
+func (c *remoteCluster) Close() {
+	for nodeId, node := range c.nodes {
+		if node.base != nil {
+			ctx, cancel := c.callContext()
+			if _, err := node.admin.SetNetFaults(ctx, &pb.SetNetFaultsRequest{Faults: node.base}); err != nil {
+				fmt.Fprintf(os.Stderr, "restore net faults of %s failed, %v\n", nodeId, err)
+			}
+			cancel()
+		}
+		_ = node.conn.Close()
+	}
+}
+
+// Authenticate 通过节点的认证服务提交请求, 崩溃的节点直接返回 scenario.ErrNodeCrashed
+func (c *remoteCluster) Authenticate(ctx context.Context, nodeId, userId string) (pb.AuthenticationResult, error) {
+	node, err := c.node(nodeId)
+	if err != nil {
+		return pb.AuthenticationResult_ConsensusTimeout, err
+	}
+	c.mutex.Lock()
+	crashed := c.crashed[nodeId]
+	c.mutex.Unlock()
+	if crashed {
+		return pb.AuthenticationResult_ConsensusTimeout, scenario.ErrNodeCrashed
+	}
+	reply, err := node.auth.ReplyToAuthenticationRequest(c.opts.withMetadata(ctx),
+		&pb.AuthenticationRequest{UserId: userId})
+	if err != nil {
+		return pb.AuthenticationResult_ConsensusTimeout, err
+	}
+	return reply.Result, nil
+}
+
+// Crash 将节点和其他节点隔离
+func (c *remoteCluster) Crash(nodeId string) error {
+	return c.update(nodeId, func() { c.crashed[nodeId] = true })
+}
+
+// Restart 撤销节点的隔离
+func (c *remoteCluster) Restart(nodeId string) error {
+	return c.update(nodeId, func() { delete(c.crashed, nodeId) })
+}
+
+// Partition 增加一个分区
+func (c *remoteCluster) Partition(nodes []string) error {
+	for _, nodeId := range nodes {
+		if _, err := c.node(nodeId); err != nil {
+			return err
+		}
+	}
+	return c.update("", func() { c.groups = append(c.groups, append([]string{}, nodes...)) })
+}
+
+// Heal 撤销场景之中所有的分区, 节点原来的分区保持不变
+func (c *remoteCluster) Heal() error {
+	return c.update("", func() { c.groups = nil })
+}
+
+// SetByzantine 多进程的节点不支持在运行期间切换拜占庭行为
+func (c *remoteCluster) SetByzantine(nodeId string, behaviours []string) error {
+	return scenario.ErrUnsupported
+}
+
+// update 修改崩溃以及分区的状态, 之后将新的分区写入每个节点
+func (c *remoteCluster) update(nodeId string, change func()) error {
+	if nodeId != "" {
+		if _, err := c.node(nodeId); err != nil {
+			return err
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	change()
+	partitions := make([]*pb.Partition, 0, len(c.crashed)+len(c.groups))
+	for _, id := range c.nodeIds {
+		if c.crashed[id] {
+			partitions = append(partitions, &pb.Partition{Nodes: c.peerIds([]string{id})})
+		}
+	}
+	for _, group := range c.groups {
+		partitions = append(partitions, &pb.Partition{Nodes: c.peerIds(group)})
+	}
+	for _, id := range c.nodeIds {
+		node := c.nodes[id]
+		if node.base == nil {
+			return errors.New("net faults of the nodes are not loaded")
+		}
+		faults := proto.Clone(node.base).(*pb.NetFaults)
+		if !faults.Enabled && len(partitions) > 0 {
+			// 原来没有启用故障注入的时候只启用场景之中的分区
+			faults = &pb.NetFaults{Enabled: true, Seed: faults.Seed}
+		}
+		faults.Partitions = append(faults.Partitions, partitions...)
+		ctx, cancel := c.callContext()
+		_, err := node.admin.SetNetFaults(ctx, &pb.SetNetFaultsRequest{Faults: faults})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("set net faults of %s failed, %w", id, err)
+		}
+	}
+	return nil
+}
+
+// peerIds 将场景之中的节点 id 转换为节点在网络之中的 id
+func (c *remoteCluster) peerIds(nodeIds []string) []string {
+	peerIds := make([]string, 0, len(nodeIds))
+	for _, nodeId := range nodeIds {
+		peerIds = append(peerIds, c.nodes[nodeId].peerId)
+	}
+	return peerIds
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"time"
+	"zhanghefan123/security/localconf"
+	"zhanghefan123/security/modules/manager"
+	rpcserver "zhanghefan123/security/modules/rpc"
+)
+
+const (
+	// DefaultShutdownGracePeriod 默认等待正在进行的认证请求的时间, 和共识的超时时间一致
+	DefaultShutdownGracePeriod = 30 * time.Second
+	// minRpcStopTimeout 宽限期用完之后仍然留给 rpc 服务返回响应的时间
+	minRpcStopTimeout = time.Second
+)
+
+// ShutdownGracePeriod 从配置文件之中读取关闭的宽限期
+func ShutdownGracePeriod() time.Duration {
+	gracePeriod := localconf.ShutdownGracePeriod()
+	if gracePeriod <= 0 {
+		return DefaultShutdownGracePeriod
+	}
+	return time.Duration(gracePeriod) * time.Second
+}
+
+// Shutdown 优雅关闭节点:
+// 1. rpc 服务以及请求池不再接受新的请求
+// 2. 在宽限期之内等待已经接受的请求完成共识或者超时
+// 3. 停止 rpc 服务, 正在等待的调用者收到响应
+// 4. 停止每条链的共识模块, 网络服务以及请求池, 请求池停止的时候刷新并关闭请求日志, 最后停止网络
+// 任何一步失败都会继续执行后面的步骤, 返回第一个错误
+func Shutdown(rpcServer *rpcserver.RPCServer, chainManager *manager.ChainManager, gracePeriod time.Duration) error {
+	var firstErr error
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+
+	// 1. 不再接受新的请求
+	log.Infof("stop accepting new requests, grace period %s", gracePeriod)
+	rpcServer.Drain()
+
+	// 2. 等待已经接受的请求, 超时的请求没有写入结束记录, 重启之后会被重新提交
+	if err := chainManager.Drain(ctx); err != nil {
+		log.Warnf("in-flight requests not finished within grace period, %s", err.Error())
+		firstErr = err
+	}
+
+	// 3. 停止 rpc 服务
+	stopTimeout := minRpcStopTimeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) > stopTimeout {
+		stopTimeout = time.Until(deadline)
+	}
+	log.Infof("stop the rpc server")
+	if err := rpcServer.Stop(stopTimeout); err != nil {
+		log.Errorf("stop rpc server failed, %s", err.Error())
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// 4. 停止所有的链以及网络
+	log.Infof("stop the chain manager")
+	if err := chainManager.Stop(); err != nil {
+		log.Errorf("stop chain manager failed, %s", err.Error())
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
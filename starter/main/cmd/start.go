@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/spf13/cobra"
 	"os"
+	"os/signal"
+	"syscall"
 	"zhanghefan123/security/localconf"
 	"zhanghefan123/security/logger"
 	"zhanghefan123/security/modules/manager"
@@ -24,7 +26,9 @@ func CreateStartCmd() *cobra.Command {
 			fmt.Println("start the chain")
 			register.RegisterAllComponents()
 			InitLocalConfig(cmd)
-			MainStart()
+			if exitCode := MainStart(); exitCode != 0 {
+				os.Exit(exitCode)
+			}
 		},
 	}
 	AttachFlags(startCmd, []string{flagNameOfConfigFilePath})
@@ -35,14 +39,19 @@ func CreateStartCmd() *cobra.Command {
 func InitLocalConfig(cmd *cobra.Command) {
 	if err := localconf.InitLocalConfig(cmd); err != nil {
 		fmt.Println(err)
-		os.Exit(0)
+		os.Exit(1)
 	}
 }
 
-// MainStart 程序启动逻辑
-func MainStart() {
-	// error 信道
-	errorChan := make(chan error)
+// MainStart 程序启动逻辑, 返回进程的退出码
+func MainStart() int {
+	// error 信道, 带缓冲保证启动失败的时候写入不会阻塞
+	errorChan := make(chan error, 2)
+
+	// 在启动之前就开始监听信号, 启动过程之中收到的信号也会触发关闭
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signalChan)
 
 	// 1. 创建新的 ChainManager
 	// -------------------------------------------------------------------
@@ -55,45 +64,70 @@ func MainStart() {
 	if err != nil {
 		// 打印出错的原因
 		log.Errorf("chainmaker server init failed, %s", err.Error())
-		return
+		return 1
 	}
 	// -------------------------------------------------------------------
 
 	// 3. 创建 rpcServer 并定义日志拦截器
 	// -------------------------------------------------------------------
-	rpcServer, err := rpcserver.NewRPCServer()
+	rpcServer, err := rpcserver.NewRPCServer(chainManager)
 	if err != nil {
 		log.Errorf("chainmaker server init failed, %s", err.Error())
-		return
+		return 1
 	}
 	// -------------------------------------------------------------------
 
-	// 4. 启动链服务
+	// 4. 启动链服务, 启动失败的时候已经启动的模块会被停止
 	// -------------------------------------------------------------------
 	if err := chainManager.Start(); err != nil {
 		log.Errorf("chain manager startup failed, %s", err.Error())
-		errorChan <- err
+		_ = chainManager.Stop()
+		return 1
 	}
 	// -------------------------------------------------------------------
 
-	// 5. 启动 rpcServer 并监听指定的端口
+	// 5. 启动 rpcServer 并监听指定的端口, Serve 会一直阻塞, 所以放在单独的协程之中
 	// -------------------------------------------------------------------
-	err = rpcServer.Start()
-	if err != nil {
-		log.Errorf("rpc server startup failed, %s", err.Error())
-		errorChan <- err
-	}
+	go func() {
+		if err := rpcServer.Start(); err != nil {
+			log.Errorf("rpc server startup failed, %s", err.Error())
+			errorChan <- err
+		}
+	}()
 	// -------------------------------------------------------------------
 
-	// 当没有错误的时候阻塞在这里, 如果出现错误执行清理程序
+	// 6. 收到 SIGHUP 的时候重新加载配置文件
 	// -------------------------------------------------------------------
-	err = <-errorChan
-	if err != nil {
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	defer signal.Stop(reloadChan)
+	go ReloadOnSignal(chainManager, reloadChan)
+	// -------------------------------------------------------------------
+
+	// 当没有错误并且没有收到关闭请求或者信号的时候阻塞在这里, 否则执行清理程序
+	// -------------------------------------------------------------------
+	exitCode := 0
+	select {
+	case err = <-errorChan:
 		log.Errorf("chainmaker server startup failed, %s", err.Error())
+		exitCode = 1
+	case reason := <-rpcServer.ShutdownC():
+		log.Infof("shutdown requested by admin service, reason: %s", reason)
+	case sig := <-signalChan:
+		log.Infof("received signal %s, shutting down", sig)
+	}
+
+	// 关闭的过程之中再次收到信号的时候立即退出
+	go func() {
+		sig := <-signalChan
+		log.Errorf("received signal %s again, exit immediately", sig)
+		os.Exit(1)
+	}()
+
+	if err = Shutdown(rpcServer, chainManager, ShutdownGracePeriod()); err != nil {
+		log.Errorf("chainmaker server shutdown failed, %s", err.Error())
+		exitCode = 1
 	}
-	log.Infof("stop the rpc server")
-	rpcServer.Stop()
-	log.Infof("stop the chain manager")
-	chainManager.Stop()
 	// -------------------------------------------------------------------
+	return exitCode
 }
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+	"zhanghefan123/security/localconf"
+	"zhanghefan123/security/modules/config_checker"
+	"zhanghefan123/security/starter/register"
+)
+
+// CreateValidateCmd 创建配置检查命令, 在启动节点之前发现配置之中的问题
+func CreateValidateCmd() *cobra.Command {
+	var validateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Validate Chainmaker Node Config",
+		Long:  "Validate chainmaker.yml and the genesis files it references without starting the node",
+		Run: func(cmd *cobra.Command, args []string) {
+			// 需要注册共识组件才能检查 consensus_type 是否可用
+			register.RegisterAllComponents()
+			InitLocalConfig(cmd)
+			problems := config_checker.Check(localconf.ChainMakerConfig)
+			errorCount := 0
+			for _, problem := range problems {
+				if problem.Level == config_checker.LevelError {
+					errorCount++
+				}
+				fmt.Println(problem)
+			}
+			fmt.Printf("%d error(s), %d warning(s)\n", errorCount, len(problems)-errorCount)
+			if errorCount > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	AttachFlags(validateCmd, []string{flagNameOfConfigFilePath})
+	return validateCmd
+}
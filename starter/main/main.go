@@ -12,8 +12,22 @@ func main() {
 	rootCmd := cmd.CreateRootCmd()
 	startCmd := cmd.CreateStartCmd()
 	versionCmd := cmd.CreateVersionCmd()
+	validateCmd := cmd.CreateValidateCmd()
+	clientCmd := cmd.CreateClientCmd()
+	benchCmd := cmd.CreateBenchCmd()
+	scenarioCmd := cmd.CreateScenarioCmd()
+	decisionsCmd := cmd.CreateDecisionsCmd()
+	clusterCmd := cmd.CreateClusterCmd()
+	orbitCmd := cmd.CreateOrbitCmd()
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(clientCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(scenarioCmd)
+	rootCmd.AddCommand(decisionsCmd)
+	rootCmd.AddCommand(clusterCmd)
+	rootCmd.AddCommand(orbitCmd)
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)